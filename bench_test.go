@@ -0,0 +1,84 @@
+//go:build !race
+
+package volmetd_test
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd"
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+)
+
+// benchVolumes is the number of volumes of the synthetic node benchmarked
+const benchVolumes = 1000
+
+// collectAllocBudget is the allocations per volume a whole scrape through
+// the registry may take: discovery, every collector and the metric
+// families, for about 27 series per volume. Label pairs are cached across
+// scrapes, so most of it is discovery and the registry.
+const collectAllocBudget = 350
+
+// newBenchRegistry returns a registry of an exporter of a synthetic node
+// with benchVolumes volumes, scraped once. The API discoverer is left out:
+// against a fixture it reads a fake clientset, whose deep copies would
+// dominate.
+func newBenchRegistry(tb testing.TB) *prometheus.Registry {
+	tb.Helper()
+	// Discovery logs every run
+	out, logger := log.Writer(), slog.Default()
+	log.SetOutput(io.Discard)
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	tb.Cleanup(func() {
+		log.SetOutput(out)
+		slog.SetDefault(logger)
+	})
+
+	dir := tb.TempDir()
+	if err := fixture.Synthesize(dir, benchVolumes); err != nil {
+		tb.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.FixtureDir = dir
+	cfg.DiscoveryMethods = []string{config.DiscoveryCSI}
+	reg := prometheus.NewRegistry()
+	if _, err := volmetd.New(volmetd.WithConfig(cfg), volmetd.WithRegisterer(reg), volmetd.WithGatherer(reg)); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := reg.Gather(); err != nil {
+		tb.Fatal(err)
+	}
+	return reg
+}
+
+func BenchmarkCollect(b *testing.B) {
+	reg := newBenchRegistry(b)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := reg.Gather(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCollectAllocBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scrapes a 1000-volume node")
+	}
+	reg := newBenchRegistry(t)
+
+	allocs := testing.AllocsPerRun(3, func() {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if perVolume := allocs / benchVolumes; perVolume > collectAllocBudget {
+		t.Errorf("scrape: %g allocations per volume, budget %d", perVolume, collectAllocBudget)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/gfx-labs/volmetd/pkg/aggregate"
+	"github.com/gfx-labs/volmetd/pkg/config"
+)
+
+// runAggregate runs the cluster aggregator: replicas elect a leader, which
+// scrapes every node volmetd and serves cluster rollups
+func runAggregate() int {
+	cfg := config.FromEnv()
+	slog.Info("volmetd aggregator starting", "listen", cfg.ListenAddr, "selector", cfg.AggregateSelector, "interval", cfg.AggregateInterval)
+
+	agg, err := aggregate.New(cfg)
+	if err != nil {
+		slog.Error("failed to create aggregator", "error", err)
+		return 1
+	}
+	reg := prometheus.NewRegistry()
+	if err := agg.Register(reg, cfg.MetricPrefix); err != nil {
+		slog.Error("failed to register aggregator metrics", "error", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Run only fails before campaigning; stop serving and exit non-zero
+	runErr := make(chan error, 1)
+	go func() {
+		err := agg.Run(ctx)
+		if err != nil {
+			stop()
+		}
+		runErr <- err
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.MetricsPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !agg.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	server := &http.Server{
+		Addr:         cfg.ListenAddr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		slog.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown error", "error", err)
+		}
+	}()
+
+	slog.Info("listening", "addr", cfg.ListenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		slog.Error("listen error", "error", err)
+		stop()
+		<-runErr
+		return 1
+	}
+	// Release the lease so a standby takes over without waiting for it to expire
+	if err := <-runErr; err != nil {
+		slog.Error("leader election failed", "error", err)
+		return 1
+	}
+	slog.Info("goodbye")
+	return 0
+}
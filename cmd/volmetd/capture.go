@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gfx-labs/volmetd"
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// capture writes a sanitized support bundle of the node, which simulate can
+// replay once extracted
+func capture(args []string) int {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	output := fs.String("output", "", "`file` to write the gzipped tar to, - for stdout (default volmetd-capture-<node>-<time>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	name := *output
+	if name == "" {
+		name = fmt.Sprintf("volmetd-capture-%s-%s.tar.gz", discovery.DetectNodeName(), time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	var w io.WriteCloser = os.Stdout
+	if name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			slog.Error("failed to create capture", "error", err)
+			return 1
+		}
+		w = f
+	}
+
+	err := volmetd.Capture(w, config.FromEnv())
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.Error("capture failed", "error", err)
+		return 1
+	}
+	if name != "-" {
+		slog.Info("wrote capture; add API objects (kubectl get pods,pv,pvc,nodes -o yaml) to its api directory to replay k8sapi discovery", "file", name)
+	}
+	return 0
+}
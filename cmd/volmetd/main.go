@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,11 +12,13 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
-	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd"
 	"github.com/gfx-labs/volmetd/pkg/config"
-	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/inventory"
+	pb "github.com/gfx-labs/volmetd/pkg/inventory/inventorypb"
+	"github.com/gfx-labs/volmetd/pkg/notify"
 )
 
 func main() {
@@ -26,11 +29,22 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			os.Exit(simulate(os.Args[2:]))
+		case "capture":
+			os.Exit(capture(os.Args[2:]))
+		case "aggregate":
+			os.Exit(runAggregate())
+		}
+	}
+
 	slog.Info("volmetd starting")
 
 	cfg := config.FromEnv()
-	slog.Info("config", "listen", cfg.ListenAddr, "metrics", cfg.MetricsPath)
-	slog.Info("config", "hostProc", cfg.HostProcPath, "kubelet", cfg.KubeletPath)
+	slog.Info("config", "listen", cfg.ListenAddr, "metrics", cfg.MetricsPath, "prefix", cfg.MetricPrefix)
+	slog.Info("config", "hostProc", cfg.HostProcPath, "kubelet", cfg.KubeletPath, "kubeletLayout", cfg.KubeletLayout)
 	slog.Info("config", "discovery", cfg.DiscoveryMethods)
 	if len(cfg.Namespaces) > 0 {
 		slog.Info("config", "namespaces", cfg.Namespaces)
@@ -38,56 +52,63 @@ func main() {
 		slog.Info("config", "namespaces", "all")
 	}
 
-	// Build discoverers in configured order
-	var discoverers []discovery.Discoverer
-
-	for _, method := range cfg.DiscoveryMethods {
-		switch method {
-		case config.DiscoveryCSI:
-			csi := discovery.NewCSIDiscoverer(cfg.KubeletPath, cfg.MountsPath())
-			discoverers = append(discoverers, csi)
-			slog.Info("enabled discoverer", "method", method)
-
-		case config.DiscoveryK8sAPI:
-			k8s, err := discovery.NewK8sAPIDiscoverer(cfg.KubeletPath, cfg.MountsPath(), cfg.Namespaces)
-			if err != nil {
-				slog.Warn("discoverer disabled", "method", method, "error", err)
-			} else {
-				discoverers = append(discoverers, k8s)
-				slog.Info("enabled discoverer", "method", method)
-			}
-
-		default:
-			slog.Warn("unknown discovery method", "method", method)
-		}
-	}
-
-	if len(discoverers) == 0 {
-		slog.Error("no discoverers available")
+	exporter, err := volmetd.New(
+		volmetd.WithConfig(cfg),
+		volmetd.WithRegisterer(prometheus.DefaultRegisterer),
+		volmetd.WithGatherer(prometheus.DefaultGatherer),
+	)
+	if err != nil {
+		slog.Error("failed to create exporter", "error", err)
 		os.Exit(1)
 	}
 
-	multi := discovery.NewMultiDiscoverer(discoverers...)
+	// Background tasks are stopped on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-	// Create collectors
-	diskstats := collector.NewDiskstatsCollector(cfg.HostProcPath)
-	capacity := collector.NewCapacityCollector()
+	go exporter.Run(bgCtx)
 
-	// Create and register volume collector
-	vc := collector.NewVolumeCollector(multi, cfg.HostProcPath, diskstats, capacity)
-	prometheus.MustRegister(vc)
+	// Webhook notifications
+	if cfg.WebhookURL != "" {
+		watcher := notify.NewWatcher(exporter, notify.NewWebhookNotifier(cfg.WebhookURL), cfg.HostProcPath, cfg.WebhookInterval, cfg.WebhookFillThreshold)
+		watcher.SetPolicy(exporter.Policy())
+		go watcher.Run(bgCtx)
+		slog.Info("enabled webhook notifications", "interval", cfg.WebhookInterval, "fillThreshold", cfg.WebhookFillThreshold)
+	}
+
+	// gRPC volume inventory
+	var grpcServer *grpc.Server
+	if cfg.GRPCListenAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListenAddr)
+		if err != nil {
+			slog.Error("grpc listen error", "error", err)
+			os.Exit(1)
+		}
+		inv := inventory.NewServer(exporter.Discoverer(), cfg.GRPCInterval)
+		go inv.Run(bgCtx)
+
+		grpcServer = grpc.NewServer()
+		pb.RegisterVolumeInventoryServer(grpcServer, inv)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				slog.Error("grpc serve error", "error", err)
+			}
+		}()
+		slog.Info("enabled grpc inventory", "addr", cfg.GRPCListenAddr, "interval", cfg.GRPCInterval)
+	}
 
 	// HTTP server
 	mux := http.NewServeMux()
-	mux.Handle(cfg.MetricsPath, promhttp.Handler())
+	mux.Handle(cfg.MetricsPath, exporter)
+	namespacePath := strings.TrimSuffix(cfg.MetricsPath, "/") + "/namespace/"
+	mux.Handle(namespacePath, exporter.NamespaceHandler(namespacePath))
+	mux.Handle(strings.TrimSuffix(cfg.MetricsPath, "/")+"/summary", exporter.SummaryHandler())
+	mux.Handle("/debug/volumes", exporter.DebugHandler())
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
-	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
-	})
+	mux.Handle("/readyz", exporter.ReadyHandler())
 
 	server := &http.Server{
 		Addr:         cfg.ListenAddr,
@@ -95,6 +116,10 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	// Scrapers with HTTP/2 enabled speak it in cleartext with prior knowledge
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
 
 	// Graceful shutdown
 	done := make(chan struct{})
@@ -103,6 +128,10 @@ func main() {
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 		slog.Info("shutting down")
+		stopBackground()
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
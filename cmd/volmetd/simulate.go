@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gfx-labs/volmetd"
+	"github.com/gfx-labs/volmetd/pkg/config"
+)
+
+// simulate runs discovery and collection once against a fixture directory
+// and prints the metrics that would be served
+func simulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	dir := fs.String("fixtures", os.Getenv("VOLMETD_FIXTURE_DIR"), "fixture `dir`ectory captured from a node")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "usage: volmetd simulate --fixtures <dir>")
+		return 2
+	}
+
+	cfg := config.FromEnv()
+	cfg.FixtureDir = *dir
+	exporter, err := volmetd.New(volmetd.WithConfig(cfg))
+	if err != nil {
+		slog.Error("failed to create exporter", "error", err)
+		return 1
+	}
+	if err := exporter.WriteMetrics(os.Stdout); err != nil {
+		slog.Error("failed to write metrics", "error", err)
+		return 1
+	}
+	return 0
+}
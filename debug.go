@@ -0,0 +1,182 @@
+package volmetd
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+type debugVolume struct {
+	*discovery.VolumeInfo
+	Stats    *diskstats.Stats
+	StatsBy  string // how the diskstats row was matched: "device id", "device name" or ""
+	Capacity *mounts.Capacity
+	Errors   []string
+}
+
+type debugPage struct {
+	Time        time.Time     // of the scrape shown
+	Stale       time.Duration // age of its volumes, when discovery failed
+	ProcPath    string
+	KubeletPath string
+	Discoverers []discovery.DiscovererStatus
+	Collectors  []collector.CollectorStatus
+	Volumes     []*debugVolume
+	Error       string
+}
+
+var debugTemplate = template.Must(template.New("volumes").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"pct": func(c *mounts.Capacity) float64 {
+		if c.TotalBytes == 0 {
+			return 0
+		}
+		return float64(c.UsedBytes) / float64(c.TotalBytes) * 100
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>volmetd volumes</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+code { font-size: 12px; }
+.err { color: #b00; }
+</style>
+</head>
+<body>
+<h1>volmetd volumes</h1>
+<p>{{if not .Time.IsZero}}Last scrape {{.Time.Format "2006-01-02 15:04:05 MST"}} &middot; {{end}}proc <code>{{.ProcPath}}</code> &middot; kubelet <code>{{.KubeletPath}}</code></p>
+{{if .Error}}<p class="err">{{.Error}}</p>{{end}}
+{{if .Stale}}<p class="err">serving volumes discovered {{.Stale}} ago</p>{{end}}
+
+<h2>Discoverers</h2>
+<table>
+<tr><th>Name</th><th>Available</th><th>Volumes</th><th>Breaker</th><th>Last run</th><th>Last error</th></tr>
+{{range .Discoverers}}<tr><td>{{.Name}}</td><td>{{.Available}}</td><td>{{.Volumes}}</td><td>{{.Breaker}}{{if .Failures}} ({{.Failures}} failures){{end}}</td><td>{{if not .Time.IsZero}}{{.Time.Format "15:04:05"}}{{end}}</td><td class="err">{{.Error}}</td></tr>
+{{end}}</table>
+
+<h2>Collectors</h2>
+<table>
+<tr><th>Name</th><th>Duration</th><th>Last run</th><th>Last error</th></tr>
+{{range .Collectors}}<tr><td>{{.Name}}</td><td>{{.Duration}}</td><td>{{if not .Time.IsZero}}{{.Time.Format "15:04:05"}}{{end}}</td><td class="err">{{.Error}}</td></tr>
+{{end}}</table>
+
+<h2>Volumes ({{len .Volumes}})</h2>
+<table>
+<tr><th>PVC</th><th>Pod</th><th>Storage</th><th>Resolution chain</th><th>Diskstats</th><th>Capacity</th><th>Errors</th></tr>
+{{range .Volumes}}<tr>
+<td>{{.PVCNamespace}}/{{.PVCName}}<br><small>pv {{.PVName}}</small></td>
+<td>{{range .Pods}}{{.Namespace}}/{{.Name}}<br><small>{{.UID}}</small><br>{{else}}{{.PodNamespace}}/{{.PodName}}<br><small>{{.PodUID}}</small>{{end}}{{if .Workload}}<small>{{.WorkloadKind}} {{.Workload}}</small>{{end}}</td>
+<td>{{.StorageClass}}<br><small>{{.CSIDriver}}</small><br><small>{{.VolumeHandle}}</small></td>
+<td>
+<code>{{.MountPath}}</code><br>
+&rarr; <code>{{.CSIDevicePath}}</code><br>
+&rarr; <code>{{.DevicePath}}</code><br>
+&rarr; <code>{{.DeviceID}}</code><br>
+&rarr; <code>{{.DeviceName}}</code>
+</td>
+<td>{{if .Stats}}matched by {{.StatsBy}}<br>{{end}}{{with .Stats}}<code>{{.Major}}:{{.Minor}} {{.DeviceName}}</code><br>
+reads {{.ReadsCompleted}} ({{bytes .ReadBytesTotal}})<br>
+writes {{.WritesCompleted}} ({{bytes .WriteBytesTotal}})<br>
+in progress {{.IOInProgress}}{{end}}</td>
+<td>{{with .Capacity}}{{bytes .UsedBytes}} / {{bytes .TotalBytes}} ({{printf "%.1f" (pct .)}}%)<br>
+inodes {{.UsedInodes}} / {{.TotalInodes}}{{end}}</td>
+<td class="err">{{range .Errors}}{{.}}<br>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// DebugHandler returns an http.Handler rendering a human-readable page of the
+// volumes the last scrape discovered, their device resolution chain,
+// capacity, and the errors collectors recorded. It reads only diskstats
+// itself, so viewing it neither runs discovery nor touches mounts.
+func (e *Exporter) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := &debugPage{
+			ProcPath:    e.cfg.HostProcPath,
+			KubeletPath: e.cfg.KubeletPath,
+			Discoverers: e.discoverer.Status(),
+			Collectors:  e.collector.Status(),
+		}
+		for _, s := range page.Collectors {
+			if s.Name == "discovery" && s.Error != "" {
+				page.Error = "discovery failed: " + s.Error
+			}
+		}
+
+		last := e.collector.LastScrape()
+		if last == nil {
+			if page.Error == "" {
+				page.Error = "no scrape yet"
+			}
+			last = &collector.LastScrape{}
+		}
+		page.Time, page.Stale = last.Time, last.Stale.Round(time.Second)
+
+		stats, statsErr := diskstats.Parse(e.cfg.DiskstatsPath())
+
+		for _, vol := range last.Volumes {
+			dv := &debugVolume{VolumeInfo: vol}
+
+			switch {
+			case statsErr != nil:
+				dv.Errors = append(dv.Errors, statsErr.Error())
+			case vol.DeviceID != "" && stats.ByDeviceID[vol.DeviceID] != nil:
+				dv.Stats = stats.ByDeviceID[vol.DeviceID]
+				dv.StatsBy = "device id"
+			case vol.DeviceName != "" && stats.ByName[vol.DeviceName] != nil:
+				dv.Stats = stats.ByName[vol.DeviceName]
+				dv.StatsBy = "device name"
+			default:
+				dv.Errors = append(dv.Errors, "no diskstats row for device")
+			}
+
+			if e.capacity != nil && vol.MountPath != "" {
+				dv.Capacity = e.capacity.LastCapacity(vol.MountPath)
+			}
+			dv.Errors = append(dv.Errors, last.Errors.Reasons(vol)...)
+
+			page.Volumes = append(page.Volumes, dv)
+		}
+
+		sort.Slice(page.Volumes, func(i, j int) bool {
+			a, b := page.Volumes[i], page.Volumes[j]
+			if a.PVCNamespace != b.PVCNamespace {
+				return a.PVCNamespace < b.PVCNamespace
+			}
+			return a.PVCName < b.PVCName
+		})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := debugTemplate.Execute(w, page); err != nil {
+			slog.Error("debug page render error", "error", err)
+		}
+	})
+}
+
+// formatBytes formats a byte count using binary units
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
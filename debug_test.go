@@ -0,0 +1,51 @@
+package volmetd_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd"
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+)
+
+// The debug page renders the last scrape rather than discovering again
+func TestDebugHandlerLastScrape(t *testing.T) {
+	dir := t.TempDir()
+	if err := fixture.Synthesize(dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.FixtureDir = dir
+	d := discoverytest.New("fake", discoverytest.Volume("data-db-0", "db"))
+	reg := prometheus.NewRegistry()
+	e, err := volmetd.New(volmetd.WithConfig(cfg), volmetd.WithDiscoverers(d), volmetd.WithRegisterer(reg), volmetd.WithGatherer(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := func() string {
+		rec := httptest.NewRecorder()
+		e.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/volumes", nil))
+		return rec.Body.String()
+	}
+	if body := page(); !strings.Contains(body, "no scrape yet") {
+		t.Errorf("debug page before the first scrape lacks \"no scrape yet\":\n%s", body)
+	}
+
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if body := page(); !strings.Contains(body, "db/data-db-0") {
+			t.Errorf("debug page lacks the scraped volume db/data-db-0:\n%s", body)
+		}
+	}
+	if calls := d.Calls(); calls != 1 {
+		t.Errorf("discovery ran %d times, want once, for the scrape", calls)
+	}
+}
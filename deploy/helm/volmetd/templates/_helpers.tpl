@@ -58,3 +58,32 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Policy ConfigMap reference, empty when there is none
+*/}}
+{{- define "volmetd.policyConfigMap" -}}
+{{- if .Values.config.policy.create }}
+{{- printf "%s-policy" (include "volmetd.fullname" .) }}
+{{- else }}
+{{- .Values.config.policy.configMap }}
+{{- end }}
+{{- end }}
+
+{{/*
+Aggregator selector labels, distinct from the node DaemonSet's so the
+aggregator doesn't scrape itself
+*/}}
+{{- define "volmetd.aggregatorSelectorLabels" -}}
+app.kubernetes.io/name: {{ include "volmetd.name" . }}-aggregator
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+
+{{/*
+Host kubelet root directory of the configured kubelet layout, mounted at
+/host<root> (see kubelet.Layout)
+*/}}
+{{- define "volmetd.kubeletRoot" -}}
+{{- $roots := dict "k3s" "/var/lib/rancher/k3s/agent/kubelet" "microk8s" "/var/snap/microk8s/common/var/lib/kubelet" }}
+{{- get $roots (.Values.config.kubeletLayout | default "") | default "/var/lib/kubelet" }}
+{{- end }}
@@ -0,0 +1,83 @@
+package volmetd
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// compressions are the response encodings built in, by name. zstd needs the
+// zstd build tag (see zstd.go), which links github.com/klauspost/compress.
+var compressions = map[string]promhttp.Compression{
+	"gzip": promhttp.Gzip,
+}
+
+// scrapeSizeBuckets span a handful of volumes to several MB on big nodes
+var scrapeSizeBuckets = prometheus.ExponentialBuckets(1024, 4, 9)
+
+// handlerOpts returns the promhttp options for the configured response
+// formats and compressions. Protobuf is always negotiable, since scrapers
+// only ask for it when they want native histograms.
+func handlerOpts(openMetrics bool, names []string) promhttp.HandlerOpts {
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: openMetrics}
+	for _, name := range names {
+		switch c, ok := compressions[name]; {
+		case ok:
+			opts.OfferedCompressions = append(opts.OfferedCompressions, c)
+		case name == "zstd":
+			slog.Debug("zstd compression not built in, skipping")
+		case name != "none":
+			slog.Warn("unsupported metrics compression, ignoring", "compression", name)
+		}
+	}
+	if len(opts.OfferedCompressions) == 0 {
+		opts.DisableCompression = true
+	} else {
+		// Clients that accept none of them get the payload uncompressed
+		opts.OfferedCompressions = append(opts.OfferedCompressions, promhttp.Identity)
+	}
+	return opts
+}
+
+// sizeWriter counts the bytes written to the client, after compression
+type sizeWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += n
+	return n, err
+}
+
+// instrumentSize observes the size of each response h serves in hist, by
+// format and content encoding
+func instrumentSize(hist *prometheus.HistogramVec, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &sizeWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+
+		encoding := w.Header().Get("Content-Encoding")
+		if encoding == "" {
+			encoding = string(promhttp.Identity)
+		}
+		hist.WithLabelValues(responseFormat(w.Header().Get("Content-Type")), encoding).Observe(float64(sw.n))
+	})
+}
+
+// responseFormat names the exposition format of a Content-Type
+func responseFormat(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/openmetrics-text"):
+		return "openmetrics"
+	case strings.HasPrefix(contentType, "application/vnd.google.protobuf"):
+		return "protobuf"
+	case strings.HasPrefix(contentType, "text/plain"):
+		return "text"
+	}
+	return "other"
+}
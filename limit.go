@@ -0,0 +1,46 @@
+package volmetd
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeLimiter bounds concurrent scrapes. Each scrape runs discovery and
+// every collector, so a scraper misconfigured with a 1s interval, or several
+// at once, would otherwise pile them up on the node.
+type scrapeLimiter struct {
+	slots    chan struct{} // nil = unlimited
+	rejected prometheus.Counter
+}
+
+func newScrapeLimiter(max int) *scrapeLimiter {
+	l := &scrapeLimiter{
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scrapes_rejected_total",
+			Help: "Scrapes rejected with 503 because the maximum number of concurrent scrapes were already running",
+		}),
+	}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// wrap serves h when a scrape slot is free, and 503 with Retry-After otherwise
+func (l *scrapeLimiter) wrap(h http.Handler) http.Handler {
+	if l.slots == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+			h.ServeHTTP(w, r)
+		default:
+			l.rejected.Inc()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many concurrent scrapes", http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package volmetd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// namespaceLabel is the PVC namespace label of per-volume series
+const namespaceLabel = "namespace"
+
+// NamespaceHandler returns an http.Handler serving only the series of PVCs
+// in one namespace, named by the path after prefix, e.g. prefix
+// "/metrics/namespace/" serves namespace ns at /metrics/namespace/ns. It lets
+// tenant-scoped Prometheus instances scrape their own volumes from a shared
+// node exporter. Node-level series, which carry no namespace, are left out.
+// Scrapes count towards the same concurrency limit as ServeHTTP's.
+func (e *Exporter) NamespaceHandler(prefix string) http.Handler {
+	return e.limiter.wrap(instrumentSize(e.sizes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if ns == "" || strings.Contains(ns, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		e.serveNamespaces(w, r, []string{ns})
+	})))
+}
+
+// serveNamespaces serves the series of PVCs in namespaces
+func (e *Exporter) serveNamespaces(w http.ResponseWriter, r *http.Request, namespaces []string) {
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := e.gatherer.Gather()
+		return filterNamespaces(families, namespaces), err
+	})
+	promhttp.HandlerFor(g, e.opts).ServeHTTP(w, r)
+}
+
+// filterNamespaces keeps the metrics labelled with one of namespaces,
+// dropping families left empty
+func filterNamespaces(families []*dto.MetricFamily, namespaces []string) []*dto.MetricFamily {
+	allowed := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		allowed[ns] = true
+	}
+
+	result := families[:0]
+	for _, mf := range families {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			if allowed[labelValue(m, namespaceLabel)] {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			result = append(result, mf)
+		}
+	}
+	return result
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package volmetd_test
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd"
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+)
+
+// kubeletPID is the kubelet's PID in the OpenShift fixture
+const kubeletPID = "2417"
+
+// writeOpenShiftFixture writes a synthetic fixture of an OpenShift node to
+// dir: CRI-O and the kubelet run in a mount namespace of their own (kubens),
+// so pod volumes are mounted in the kubelet's, proc/<pid>/mounts, and not
+// in PID 1's, proc/mounts.
+func writeOpenShiftFixture(t *testing.T, dir string, volumes int) {
+	t.Helper()
+	if err := fixture.Synthesize(dir, volumes); err != nil {
+		t.Fatal(err)
+	}
+	proc := filepath.Join(dir, "proc")
+	kubens := filepath.Join(proc, kubeletPID)
+	if err := os.MkdirAll(kubens, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for src, dst := range map[string]string{"mounts": "mounts", "self/mountinfo": "mountinfo"} {
+		if err := os.Rename(filepath.Join(proc, src), filepath.Join(kubens, dst)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The host namespace holds the kubelet directory, but no pod volumes
+	files := map[string]string{
+		"proc/mounts": "/dev/sda1 / ext4 rw,relatime 0 0\n" +
+			"/dev/sda1 /var/lib/kubelet ext4 rw,relatime 0 0\n",
+		"proc/self/mountinfo": "21 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n" +
+			"24 21 8:1 /var/lib/kubelet /var/lib/kubelet rw,relatime - ext4 /dev/sda1 rw\n",
+		"proc/1/comm":                  "systemd\n",
+		"proc/2310/comm":               "crio\n",
+		"proc/" + kubeletPID + "/comm": "kubelet\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// scrapeFixture runs discovery and collection of an exporter with cfg
+// against the fixture in dir, returning the device of every volume's
+// reads_completed_total series by PV
+func scrapeFixture(t *testing.T, cfg *config.Config, dir string) map[string]string {
+	t.Helper()
+	out, logger := log.Writer(), slog.Default()
+	log.SetOutput(io.Discard)
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		slog.SetDefault(logger)
+	})
+
+	cfg.FixtureDir = dir
+	cfg.DiscoveryMethods = []string{config.DiscoveryCSI}
+	reg := prometheus.NewRegistry()
+	if _, err := volmetd.New(volmetd.WithConfig(cfg), volmetd.WithRegisterer(reg), volmetd.WithGatherer(reg)); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := make(map[string]string)
+	for _, mf := range families {
+		if !strings.HasSuffix(mf.GetName(), "reads_completed_total") {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			devices[label(m, "pv")] = label(m, "device")
+		}
+	}
+	return devices
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestOpenShiftFixture(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenShiftFixture(t, dir, 2)
+
+	want := map[string]string{
+		"pvc-10000000-0000-4000-8000-000000000000": "nvme1n1",
+		"pvc-10000000-0000-4000-8000-000000000001": "nvme2n1",
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.ApplyOpenShift()
+	if got := scrapeFixture(t, cfg, dir); !maps.Equal(got, want) {
+		t.Errorf("OpenShift volume devices = %v, want %v", got, want)
+	}
+
+	// Reading PID 1's namespace, every volume looks like the kubelet
+	// directory's filesystem
+	host := scrapeFixture(t, config.DefaultConfig(), dir)
+	if len(host) != len(want) {
+		t.Errorf("host namespace volumes = %v, want %d", host, len(want))
+	}
+	for pv, device := range host {
+		if device != "sda1" {
+			t.Errorf("host namespace device of %s = %s, want sda1", pv, device)
+		}
+	}
+}
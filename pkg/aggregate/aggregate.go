@@ -0,0 +1,444 @@
+// Package aggregate implements volmetd's cluster aggregator mode. One
+// leader-elected replica scrapes every node's volmetd, and lists
+// PersistentVolumes and claims from the API, and exports cluster-level
+// rollups: capacity per storage class, the fullest volumes, the PVs no node
+// has mounted, and released volumes and stuck claims the node-scoped
+// exporter can't see.
+// Small clusters get cluster summaries from a single scrape target.
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Leader election timings, the client-go defaults used by most controllers
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// scrapeTimeout bounds each node scrape
+const scrapeTimeout = 10 * time.Second
+
+// scrapeConcurrency bounds how many nodes are scraped at once
+const scrapeConcurrency = 16
+
+var (
+	leaderDesc = prometheus.NewDesc(
+		"aggregate_leader",
+		"Whether this replica is the elected aggregator; only the leader exports cluster rollups",
+		nil, nil,
+	)
+	refreshTimestampDesc = prometheus.NewDesc(
+		"aggregate_refresh_timestamp_seconds",
+		"When the cluster rollups were last refreshed, as a Unix timestamp",
+		nil, nil,
+	)
+	targetUpDesc = prometheus.NewDesc(
+		"aggregate_target_up",
+		"Whether the node volmetd was scraped successfully in the last refresh",
+		[]string{"pod", "node"}, nil,
+	)
+	classVolumesDesc = prometheus.NewDesc(
+		"cluster_storage_class_volumes",
+		"Volumes of the storage class mounted on any node",
+		[]string{"storage_class"}, nil,
+	)
+	classCapacityDesc = prometheus.NewDesc(
+		"cluster_storage_class_capacity_bytes_total",
+		"Total filesystem capacity of the storage class's mounted volumes",
+		[]string{"storage_class"}, nil,
+	)
+	classUsedDesc = prometheus.NewDesc(
+		"cluster_storage_class_capacity_bytes_used",
+		"Used filesystem capacity of the storage class's mounted volumes",
+		[]string{"storage_class"}, nil,
+	)
+	classProvisionedDesc = prometheus.NewDesc(
+		"cluster_storage_class_provisioned_bytes",
+		"Capacity of every PersistentVolume of the storage class, from the API, mounted or not",
+		[]string{"storage_class"}, nil,
+	)
+	fullestDesc = prometheus.NewDesc(
+		"cluster_fullest_volume_used_ratio",
+		"Used fraction of the cluster's fullest volumes, rank 1 being the fullest",
+		[]string{"rank", "namespace", "pvc", "pv", "storage_class", "node"}, nil,
+	)
+	unattachedDesc = prometheus.NewDesc(
+		"cluster_unattached_persistent_volumes",
+		"PersistentVolumes no node has mounted; only exported when every node was scraped",
+		[]string{"storage_class"}, nil,
+	)
+)
+
+// Aggregator elects a leader among its replicas and, while leading,
+// periodically scrapes every node volmetd for cluster rollups
+type Aggregator struct {
+	client    kubernetes.Interface
+	http      *http.Client
+	namespace string
+	identity  string // this replica's pod name, its leader election identity
+
+	selector   string // node volmetd pods
+	port       int    // node metrics port when pods don't name one
+	path       string // node metrics path
+	prefix     string // node metric name prefix
+	interval   time.Duration
+	topN       int
+	objectInfo bool // export info series for each unbound PV and stuck PVC
+	leaseName  string
+
+	mu        sync.Mutex
+	leader    bool
+	following string // identity of another replica's lease, if any
+	snapshot  *snapshot
+}
+
+// snapshot holds the rollups from one refresh
+type snapshot struct {
+	time       time.Time
+	up         map[target]bool
+	classes    map[string]*classTotals
+	fullest    []*volume
+	unattached map[string]int // by storage class; nil when unknown
+
+	pvPhases  map[phaseKey]int
+	pvcPhases map[phaseKey]int // nil when claims couldn't be listed
+	unusedPVs []unusedPV
+	stuckPVCs []stuckPVC
+}
+
+type classTotals struct {
+	volumes     int
+	total       float64
+	used        float64
+	provisioned float64
+}
+
+// New creates an aggregator using the in-cluster config.
+// discovery.ErrNotInCluster is returned outside a cluster.
+func New(cfg *config.Config) (*Aggregator, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return NewForClient(client, discovery.DetectNamespace(), cfg)
+}
+
+// NewForClient creates an aggregator in namespace using client
+func NewForClient(client kubernetes.Interface, namespace string, cfg *config.Config) (*Aggregator, error) {
+	if namespace == "" {
+		return nil, errors.New("aggregator namespace unknown, set POD_NAMESPACE")
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("leader election identity: %w", err)
+	}
+
+	port := 6060
+	if _, p, err := net.SplitHostPort(cfg.ListenAddr); err == nil {
+		if n, err := strconv.Atoi(p); err == nil {
+			port = n
+		}
+	}
+	return &Aggregator{
+		client:     client,
+		http:       &http.Client{Timeout: scrapeTimeout},
+		namespace:  namespace,
+		identity:   identity,
+		selector:   cfg.AggregateSelector,
+		port:       port,
+		path:       cfg.MetricsPath,
+		prefix:     cfg.MetricPrefix,
+		interval:   cfg.AggregateInterval,
+		topN:       cfg.AggregateTopN,
+		objectInfo: cfg.AggregateObjectInfo,
+		leaseName:  cfg.AggregateLeaseName,
+	}, nil
+}
+
+// Run campaigns for leadership until ctx is cancelled, refreshing the
+// rollups every interval while leading
+func (a *Aggregator) Run(ctx context.Context) error {
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: a.leaseName, Namespace: a.namespace},
+			Client:     a.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: a.identity},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            a.leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: a.lead,
+			OnStoppedLeading: func() {
+				a.mu.Lock()
+				defer a.mu.Unlock()
+				if a.leader {
+					slog.Info("aggregate: lost leadership")
+				}
+				a.leader = false
+				a.snapshot = nil
+			},
+			OnNewLeader: func(identity string) {
+				a.mu.Lock()
+				defer a.mu.Unlock()
+				a.following = ""
+				if identity != a.identity {
+					slog.Info("aggregate: following leader", "leader", identity)
+					a.following = identity
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Run returns when leadership is lost; campaign again
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
+
+// lead refreshes the rollups every interval until leadership is lost
+func (a *Aggregator) lead(ctx context.Context) {
+	slog.Info("aggregate: elected leader", "identity", a.identity)
+	a.mu.Lock()
+	a.leader = true
+	a.mu.Unlock()
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Aggregator) refresh(ctx context.Context) {
+	targets, err := a.targets(ctx)
+	if err != nil {
+		slog.Warn("aggregate: refresh failed", "error", err)
+		return
+	}
+
+	s := &snapshot{
+		time:    time.Now(),
+		up:      make(map[target]bool, len(targets)),
+		classes: make(map[string]*classTotals),
+	}
+	class := func(name string) *classTotals {
+		c := s.classes[name]
+		if c == nil {
+			c = &classTotals{}
+			s.classes[name] = c
+		}
+		return c
+	}
+
+	mounted := make(map[string]*volume)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, scrapeConcurrency)
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			scrapeCtx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+			defer cancel()
+			volumes, err := a.scrape(scrapeCtx, t)
+			if err != nil {
+				slog.Warn("aggregate: scrape failed", "pod", t.pod, "node", t.node, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			s.up[t] = err == nil
+			for _, v := range volumes {
+				// A volume mounted on several nodes reports the same
+				// filesystem from each; keep the first with capacity
+				if prev, ok := mounted[v.key()]; ok && prev.total > 0 {
+					continue
+				}
+				mounted[v.key()] = v
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	for _, v := range mounted {
+		c := class(v.storageClass)
+		c.volumes++
+		c.total += v.total
+		c.used += v.used
+		if v.total > 0 {
+			s.fullest = append(s.fullest, v)
+		}
+	}
+	sort.Slice(s.fullest, func(i, j int) bool {
+		return s.fullest[i].used/s.fullest[i].total > s.fullest[j].used/s.fullest[j].total
+	})
+	if len(s.fullest) > a.topN {
+		s.fullest = s.fullest[:a.topN]
+	}
+
+	allUp := true
+	for _, up := range s.up {
+		allUp = allUp && up
+	}
+	pvs, err := a.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		slog.Warn("aggregate: list persistentvolumes failed", "error", err)
+	} else {
+		if allUp {
+			s.unattached = make(map[string]int)
+		}
+		s.pvPhases = make(map[phaseKey]int)
+		for i := range pvs.Items {
+			pv := &pvs.Items[i]
+			a.countPV(s, pv)
+			if q, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+				class(pv.Spec.StorageClassName).provisioned += q.AsApproximateFloat64()
+			}
+			if s.unattached != nil {
+				n := s.unattached[pv.Spec.StorageClassName]
+				if mounted[pv.Name] == nil {
+					n++
+				}
+				s.unattached[pv.Spec.StorageClassName] = n
+			}
+		}
+	}
+
+	a.claims(ctx, s)
+
+	slog.Debug("aggregate: refreshed", "targets", len(targets), "volumes", len(mounted))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.leader {
+		a.snapshot = s
+	}
+}
+
+// Describe implements prometheus.Collector
+func (a *Aggregator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- leaderDesc
+	ch <- refreshTimestampDesc
+	ch <- targetUpDesc
+	ch <- classVolumesDesc
+	ch <- classCapacityDesc
+	ch <- classUsedDesc
+	ch <- classProvisionedDesc
+	ch <- fullestDesc
+	ch <- unattachedDesc
+	ch <- pvPhaseDesc
+	ch <- pvcPhaseDesc
+	ch <- pvInfoDesc
+	ch <- pvPhaseTransitionDesc
+	ch <- pvcInfoDesc
+}
+
+// Collect implements prometheus.Collector
+func (a *Aggregator) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	leader, s := a.leader, a.snapshot
+	a.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(leaderDesc, prometheus.GaugeValue, boolToFloat(leader))
+	if s == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(refreshTimestampDesc, prometheus.GaugeValue, float64(s.time.Unix()))
+	for t, up := range s.up {
+		ch <- prometheus.MustNewConstMetric(targetUpDesc, prometheus.GaugeValue, boolToFloat(up), t.pod, t.node)
+	}
+	for name, c := range s.classes {
+		if c.volumes > 0 {
+			ch <- prometheus.MustNewConstMetric(classVolumesDesc, prometheus.GaugeValue, float64(c.volumes), name)
+			ch <- prometheus.MustNewConstMetric(classCapacityDesc, prometheus.GaugeValue, c.total, name)
+			ch <- prometheus.MustNewConstMetric(classUsedDesc, prometheus.GaugeValue, c.used, name)
+		}
+		ch <- prometheus.MustNewConstMetric(classProvisionedDesc, prometheus.GaugeValue, c.provisioned, name)
+	}
+	for i, v := range s.fullest {
+		ch <- prometheus.MustNewConstMetric(fullestDesc, prometheus.GaugeValue, v.used/v.total,
+			strconv.Itoa(i+1), v.namespace, v.pvc, v.pv, v.storageClass, v.node)
+	}
+	for name, n := range s.unattached {
+		ch <- prometheus.MustNewConstMetric(unattachedDesc, prometheus.GaugeValue, float64(n), name)
+	}
+	collectClaims(s, ch)
+}
+
+// Register registers the aggregator's metrics with reg, prefixing their names
+func (a *Aggregator) Register(reg prometheus.Registerer, prefix string) error {
+	return prometheus.WrapRegistererWithPrefix(prefix, reg).Register(a)
+}
+
+// Leader reports whether this replica is the elected aggregator
+func (a *Aggregator) Leader() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.leader
+}
+
+// Ready reports whether the replica is serving: the leader once a refresh
+// has completed, a standby once it has seen another replica take the lease.
+// A standby is ready so that rolling updates aren't held up waiting on
+// replicas that will never lead
+func (a *Aggregator) Ready() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.leader {
+		return a.snapshot != nil
+	}
+	return a.following != ""
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
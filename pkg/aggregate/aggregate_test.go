@@ -0,0 +1,84 @@
+package aggregate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/gfx-labs/volmetd/pkg/config"
+)
+
+func TestReady(t *testing.T) {
+	held := func() *coordinationv1.Lease {
+		identity, duration := "volmetd-aggregator-other", int32(3600)
+		now := metav1.NewMicroTime(time.Now())
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "volmetd-aggregator", Namespace: "monitoring"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		lease  *coordinationv1.Lease
+		leader bool
+	}{
+		{name: "leader after refresh", leader: true},
+		{name: "standby", lease: held()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tt.lease != nil {
+				client = fake.NewSimpleClientset(tt.lease)
+			}
+			cfg := config.DefaultConfig()
+			a, err := NewForClient(client, "monitoring", cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Ready() {
+				t.Fatal("ready before campaigning")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- a.Run(ctx) }()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			deadline := time.Now().Add(10 * time.Second)
+			for !a.Ready() {
+				if time.Now().After(deadline) {
+					t.Fatal("never ready")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if a.Leader() != tt.leader {
+				t.Errorf("Leader() = %v, want %v", a.Leader(), tt.leader)
+			}
+		})
+	}
+}
+
+func TestReadyLeaderWithoutRefresh(t *testing.T) {
+	a := &Aggregator{leader: true}
+	if a.Ready() {
+		t.Error("leader ready before its first refresh")
+	}
+	a.snapshot = &snapshot{}
+	if !a.Ready() {
+		t.Error("leader not ready after a refresh")
+	}
+}
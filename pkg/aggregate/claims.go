@@ -0,0 +1,146 @@
+package aggregate
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	pvPhaseDesc = prometheus.NewDesc(
+		"cluster_persistent_volumes",
+		"PersistentVolumes by phase; Released volumes are orphaned by a deleted claim but still hold their storage",
+		[]string{"phase", "storage_class"}, nil,
+	)
+	pvcPhaseDesc = prometheus.NewDesc(
+		"cluster_persistent_volume_claims",
+		"PersistentVolumeClaims by phase; Pending claims are stuck waiting for a volume and Lost ones have lost theirs",
+		[]string{"phase", "storage_class"}, nil,
+	)
+	pvInfoDesc = prometheus.NewDesc(
+		"cluster_persistent_volume_info",
+		"PersistentVolumes in the Available, Released or Failed phase (always 1)",
+		[]string{"pv", "phase", "storage_class", "reclaim_policy", "claim_namespace", "claim"}, nil,
+	)
+	pvPhaseTransitionDesc = prometheus.NewDesc(
+		"cluster_persistent_volume_phase_transition_timestamp_seconds",
+		"When a PersistentVolume in the Available, Released or Failed phase entered it, as a Unix timestamp",
+		[]string{"pv", "phase", "storage_class"}, nil,
+	)
+	pvcInfoDesc = prometheus.NewDesc(
+		"cluster_persistent_volume_claim_info",
+		"PersistentVolumeClaims in the Pending or Lost phase (always 1)",
+		[]string{"namespace", "pvc", "phase", "storage_class", "volume"}, nil,
+	)
+)
+
+// phaseKey counts objects by phase and storage class
+type phaseKey struct {
+	phase        string
+	storageClass string
+}
+
+// unusedPV is a PersistentVolume not bound to a claim
+type unusedPV struct {
+	name           string
+	phase          string
+	storageClass   string
+	reclaimPolicy  string
+	claimNamespace string
+	claim          string
+	transition     int64 // Unix seconds, 0 when the API server doesn't report it
+}
+
+// stuckPVC is a PersistentVolumeClaim without a usable volume
+type stuckPVC struct {
+	namespace    string
+	name         string
+	phase        string
+	storageClass string
+	volume       string
+}
+
+// countPV adds a PersistentVolume to the phase counts, keeping those not
+// bound to a claim when per-object info is enabled
+func (a *Aggregator) countPV(s *snapshot, pv *corev1.PersistentVolume) {
+	phase := pv.Status.Phase
+	if phase == "" {
+		phase = corev1.VolumePending
+	}
+	s.pvPhases[phaseKey{string(phase), pv.Spec.StorageClassName}]++
+
+	if !a.objectInfo || phase == corev1.VolumeBound || phase == corev1.VolumePending {
+		return
+	}
+	u := unusedPV{
+		name:          pv.Name,
+		phase:         string(phase),
+		storageClass:  pv.Spec.StorageClassName,
+		reclaimPolicy: string(pv.Spec.PersistentVolumeReclaimPolicy),
+	}
+	if ref := pv.Spec.ClaimRef; ref != nil {
+		u.claimNamespace, u.claim = ref.Namespace, ref.Name
+	}
+	if t := pv.Status.LastPhaseTransitionTime; t != nil {
+		u.transition = t.Unix()
+	}
+	s.unusedPVs = append(s.unusedPVs, u)
+}
+
+// claims counts PersistentVolumeClaims by phase, keeping Pending and Lost
+// ones when per-object info is enabled
+func (a *Aggregator) claims(ctx context.Context, s *snapshot) {
+	pvcs, err := a.client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		slog.Warn("aggregate: list persistentvolumeclaims failed", "error", err)
+		return
+	}
+
+	s.pvcPhases = make(map[phaseKey]int)
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		var class string
+		if pvc.Spec.StorageClassName != nil {
+			class = *pvc.Spec.StorageClassName
+		}
+		phase := pvc.Status.Phase
+		if phase == "" {
+			phase = corev1.ClaimPending
+		}
+		s.pvcPhases[phaseKey{string(phase), class}]++
+
+		if a.objectInfo && phase != corev1.ClaimBound {
+			s.stuckPVCs = append(s.stuckPVCs, stuckPVC{
+				namespace:    pvc.Namespace,
+				name:         pvc.Name,
+				phase:        string(phase),
+				storageClass: class,
+				volume:       pvc.Spec.VolumeName,
+			})
+		}
+	}
+}
+
+func collectClaims(s *snapshot, ch chan<- prometheus.Metric) {
+	for k, n := range s.pvPhases {
+		ch <- prometheus.MustNewConstMetric(pvPhaseDesc, prometheus.GaugeValue, float64(n), k.phase, k.storageClass)
+	}
+	for k, n := range s.pvcPhases {
+		ch <- prometheus.MustNewConstMetric(pvcPhaseDesc, prometheus.GaugeValue, float64(n), k.phase, k.storageClass)
+	}
+	for _, pv := range s.unusedPVs {
+		ch <- prometheus.MustNewConstMetric(pvInfoDesc, prometheus.GaugeValue, 1,
+			pv.name, pv.phase, pv.storageClass, pv.reclaimPolicy, pv.claimNamespace, pv.claim)
+		if pv.transition > 0 {
+			ch <- prometheus.MustNewConstMetric(pvPhaseTransitionDesc, prometheus.GaugeValue, float64(pv.transition),
+				pv.name, pv.phase, pv.storageClass)
+		}
+	}
+	for _, pvc := range s.stuckPVCs {
+		ch <- prometheus.MustNewConstMetric(pvcInfoDesc, prometheus.GaugeValue, 1,
+			pvc.namespace, pvc.name, pvc.phase, pvc.storageClass, pvc.volume)
+	}
+}
@@ -0,0 +1,146 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// target is one node's volmetd
+type target struct {
+	pod  string
+	node string
+	url  string
+}
+
+// volume is one volume reported by a node
+type volume struct {
+	namespace    string
+	pvc          string
+	pv           string
+	storageClass string
+	node         string
+	total        float64
+	used         float64
+}
+
+// key identifies a volume across nodes; ReadWriteMany volumes are reported
+// by every node mounting them
+func (v *volume) key() string {
+	if v.pv != "" {
+		return v.pv
+	}
+	return v.node + "/" + v.namespace + "/" + v.pvc
+}
+
+// targets lists the running node volmetd pods
+func (a *Aggregator) targets(ctx context.Context) ([]target, error) {
+	pods, err := a.client.CoreV1().Pods(a.namespace).List(ctx, metav1.ListOptions{LabelSelector: a.selector})
+	if err != nil {
+		return nil, fmt.Errorf("list volmetd pods: %w", err)
+	}
+	var targets []target
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.Name == a.identity {
+			continue
+		}
+		targets = append(targets, target{
+			pod:  pod.Name,
+			node: pod.Spec.NodeName,
+			url:  "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(metricsPort(pod, a.port))) + a.path,
+		})
+	}
+	return targets, nil
+}
+
+// metricsPort returns the pod's container port named "metrics", or def
+func metricsPort(pod *corev1.Pod, def int) int {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == "metrics" {
+				return int(p.ContainerPort)
+			}
+		}
+	}
+	return def
+}
+
+// scrape fetches a node's metrics and returns the volumes it reports, with
+// capacity where the node has it
+func (a *Aggregator) scrape(ctx context.Context, t target) ([]*volume, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", t.url, resp.Status)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.url, err)
+	}
+
+	volumes := make(map[string]*volume)
+	get := func(m *dto.Metric) *volume {
+		v := &volume{
+			namespace:    labelValue(m, "namespace"),
+			pvc:          labelValue(m, "pvc"),
+			pv:           labelValue(m, "pv"),
+			storageClass: labelValue(m, "storage_class"),
+			node:         t.node,
+		}
+		if existing, ok := volumes[v.key()]; ok {
+			return existing
+		}
+		volumes[v.key()] = v
+		return v
+	}
+
+	if f := families[a.prefix+"volume_info"]; f != nil {
+		for _, m := range f.GetMetric() {
+			get(m)
+		}
+	}
+	if f := families[a.prefix+"capacity_bytes_total"]; f != nil {
+		for _, m := range f.GetMetric() {
+			get(m).total = m.GetGauge().GetValue()
+		}
+	}
+	if f := families[a.prefix+"capacity_bytes_used"]; f != nil {
+		for _, m := range f.GetMetric() {
+			get(m).used = m.GetGauge().GetValue()
+		}
+	}
+
+	result := make([]*volume, 0, len(volumes))
+	for _, v := range volumes {
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+// Package blkzone reports the zone conditions of zoned block devices (ZNS
+// SSDs, host-managed/host-aware SMR disks) via the BLKREPORTZONE ioctl
+package blkzone
+
+// Zone conditions from linux/blkzoned.h
+const (
+	CondNotWP    = "not_wp"
+	CondEmpty    = "empty"
+	CondImpOpen  = "implicit_open"
+	CondExpOpen  = "explicit_open"
+	CondClosed   = "closed"
+	CondReadOnly = "read_only"
+	CondFull     = "full"
+	CondOffline  = "offline"
+	CondUnknown  = "unknown"
+)
+
+// Report counts a device's zones by condition
+type Report struct {
+	Zones      uint64
+	Conditions map[string]uint64
+}
+
+// Open returns the number of implicitly or explicitly open zones, which
+// count against the device's max_open_zones
+func (r *Report) Open() uint64 {
+	return r.Conditions[CondImpOpen] + r.Conditions[CondExpOpen]
+}
+
+// Active returns the number of open or closed zones, which count against
+// the device's max_active_zones
+func (r *Report) Active() uint64 {
+	return r.Open() + r.Conditions[CondClosed]
+}
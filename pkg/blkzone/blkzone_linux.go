@@ -0,0 +1,91 @@
+package blkzone
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// _IOWR(0x12, 130, struct blk_zone_report); the same value with the
+	// generic, powerpc and mips ioctl encodings
+	blkReportZone = 0xc0101282
+
+	// zones fetched per ioctl
+	reportBatch = 4096
+)
+
+// blkZoneReport mirrors the header of struct blk_zone_report
+type blkZoneReport struct {
+	sector  uint64
+	nrZones uint32
+	flags   uint32
+}
+
+// blkZone mirrors struct blk_zone
+type blkZone struct {
+	start    uint64
+	len      uint64
+	wp       uint64
+	typ      uint8
+	cond     uint8
+	nonSeq   uint8
+	reset    uint8
+	resv     [4]uint8
+	capacity uint64
+	reserved [24]uint8
+}
+
+var conditions = map[uint8]string{
+	0x0: CondNotWP,
+	0x1: CondEmpty,
+	0x2: CondImpOpen,
+	0x3: CondExpOpen,
+	0x4: CondClosed,
+	0xd: CondReadOnly,
+	0xe: CondFull,
+	0xf: CondOffline,
+}
+
+// ReportZones reads every zone of the zoned block device at devPath (e.g.
+// /dev/sdb) and counts them by condition
+func ReportZones(devPath string) (*Report, error) {
+	f, err := os.Open(devPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hdrSize := unsafe.Sizeof(blkZoneReport{})
+	zoneSize := unsafe.Sizeof(blkZone{})
+	buf := make([]byte, hdrSize+reportBatch*zoneSize)
+	hdr := (*blkZoneReport)(unsafe.Pointer(&buf[0]))
+
+	report := &Report{Conditions: make(map[string]uint64)}
+	var sector uint64
+	for {
+		*hdr = blkZoneReport{sector: sector, nrZones: reportBatch}
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), blkReportZone, uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+			return nil, fmt.Errorf("report zones %s: %w", devPath, errno)
+		}
+		if hdr.nrZones == 0 {
+			break
+		}
+
+		var last *blkZone
+		for i := uintptr(0); i < uintptr(hdr.nrZones); i++ {
+			z := (*blkZone)(unsafe.Pointer(&buf[hdrSize+i*zoneSize]))
+			cond, ok := conditions[z.cond]
+			if !ok {
+				cond = CondUnknown
+			}
+			report.Conditions[cond]++
+			report.Zones++
+			last = z
+		}
+		sector = last.start + last.len
+	}
+	return report, nil
+}
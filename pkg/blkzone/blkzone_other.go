@@ -0,0 +1,10 @@
+//go:build !linux
+
+package blkzone
+
+import "errors"
+
+// ReportZones is only supported on Linux
+func ReportZones(devPath string) (*Report, error) {
+	return nil, errors.New("zone reports are only supported on linux")
+}
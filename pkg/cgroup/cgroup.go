@@ -0,0 +1,308 @@
+// Package cgroup reads block I/O limits and usage from pod cgroups, for both
+// the unified (v2) and blkio (v1) hierarchies
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrPodNotFound is returned when no cgroup exists for a pod
+var ErrPodNotFound = errors.New("pod cgroup not found")
+
+// IOLimit holds the throttling limits configured for one device. Zero means
+// no limit.
+type IOLimit struct {
+	ReadBps   uint64
+	WriteBps  uint64
+	ReadIOPS  uint64
+	WriteIOPS uint64
+}
+
+// Limited reports whether any limit is set
+func (l *IOLimit) Limited() bool {
+	return l.ReadBps != 0 || l.WriteBps != 0 || l.ReadIOPS != 0 || l.WriteIOPS != 0
+}
+
+// IOStat holds the cumulative I/O a cgroup issued to one device
+type IOStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadIOs    uint64
+	WriteIOs   uint64
+}
+
+// podUIDRe matches the pod UID in a kubepods cgroup path, with dashes
+// (cgroupfs driver) or underscores (systemd driver)
+var podUIDRe = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// qosClasses are the kubelet's QoS cgroups; guaranteed pods sit directly
+// beneath kubepods
+var qosClasses = []string{"", "burstable", "besteffort"}
+
+// PodDir returns the cgroup directory of a pod beneath root (e.g.
+// /sys/fs/cgroup), trying the systemd and cgroupfs drivers' layouts in both
+// the unified and blkio hierarchies
+func PodDir(root, podUID string) (string, error) {
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	if podUID == "" {
+		return "", ErrPodNotFound
+	}
+	escaped := strings.ReplaceAll(podUID, "-", "_")
+
+	for _, hierarchy := range []string{root, filepath.Join(root, "blkio")} {
+		for _, qos := range qosClasses {
+			var candidates []string
+			if qos == "" {
+				candidates = []string{
+					filepath.Join(hierarchy, "kubepods.slice", "kubepods-pod"+escaped+".slice"),
+					filepath.Join(hierarchy, "kubepods", "pod"+podUID),
+				}
+			} else {
+				candidates = []string{
+					filepath.Join(hierarchy, "kubepods.slice", "kubepods-"+qos+".slice", "kubepods-"+qos+"-pod"+escaped+".slice"),
+					filepath.Join(hierarchy, "kubepods", qos, "pod"+podUID),
+				}
+			}
+			for _, dir := range candidates {
+				if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+					return dir, nil
+				}
+			}
+		}
+	}
+	return "", ErrPodNotFound
+}
+
+// ProcessPodUID returns the UID of the pod a process runs in, read from
+// <procPath>/<pid>/cgroup. ok is false for processes outside kubepods.
+func ProcessPodUID(procPath string, pid int) (uid string, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", false, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, "kubepods") {
+			continue
+		}
+		if m := podUIDRe.FindStringSubmatch(line); m != nil {
+			return strings.ReplaceAll(m[1], "_", "-"), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Dirs returns a pod cgroup directory and its direct children (the pod's
+// containers), since limits may be set at either level
+func Dirs(podDir string) []string {
+	dirs := []string{podDir}
+	entries, err := os.ReadDir(podDir)
+	if err != nil {
+		return dirs
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(podDir, e.Name()))
+		}
+	}
+	return dirs
+}
+
+// ReadIOLimits returns the I/O limits of a cgroup keyed by major:minor, from
+// io.max (v2) or blkio.throttle.*_device (v1). Devices without limits are
+// omitted.
+func ReadIOLimits(dir string) (map[string]*IOLimit, error) {
+	limits := make(map[string]*IOLimit)
+	get := func(dev string) *IOLimit {
+		if limits[dev] == nil {
+			limits[dev] = &IOLimit{}
+		}
+		return limits[dev]
+	}
+
+	// v2: 8:16 rbps=2097152 wbps=max riops=max wiops=120
+	err := eachLine(filepath.Join(dir, "io.max"), func(fields []string) {
+		if len(fields) < 2 {
+			return
+		}
+		l := get(fields[0])
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || v == "max" {
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "rbps":
+				l.ReadBps = n
+			case "wbps":
+				l.WriteBps = n
+			case "riops":
+				l.ReadIOPS = n
+			case "wiops":
+				l.WriteIOPS = n
+			}
+		}
+	})
+	if err == nil {
+		return pruneUnlimited(limits), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// v1: one file per limit, lines of "8:16 2097152"
+	v1 := map[string]func(*IOLimit, uint64){
+		"blkio.throttle.read_bps_device":   func(l *IOLimit, n uint64) { l.ReadBps = n },
+		"blkio.throttle.write_bps_device":  func(l *IOLimit, n uint64) { l.WriteBps = n },
+		"blkio.throttle.read_iops_device":  func(l *IOLimit, n uint64) { l.ReadIOPS = n },
+		"blkio.throttle.write_iops_device": func(l *IOLimit, n uint64) { l.WriteIOPS = n },
+	}
+	found := false
+	for file, set := range v1 {
+		err := eachLine(filepath.Join(dir, file), func(fields []string) {
+			if len(fields) != 2 {
+				return
+			}
+			if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				set(get(fields[0]), n)
+			}
+		})
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return pruneUnlimited(limits), nil
+}
+
+// ReadIOStats returns the cumulative I/O of a cgroup keyed by major:minor,
+// from io.stat (v2) or blkio.throttle.io_service_bytes/io_serviced (v1)
+func ReadIOStats(dir string) (map[string]*IOStat, error) {
+	stats := make(map[string]*IOStat)
+	get := func(dev string) *IOStat {
+		if stats[dev] == nil {
+			stats[dev] = &IOStat{}
+		}
+		return stats[dev]
+	}
+
+	// v2: 8:16 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	err := eachLine(filepath.Join(dir, "io.stat"), func(fields []string) {
+		if len(fields) < 2 {
+			return
+		}
+		s := get(fields[0])
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "rbytes":
+				s.ReadBytes = n
+			case "wbytes":
+				s.WriteBytes = n
+			case "rios":
+				s.ReadIOs = n
+			case "wios":
+				s.WriteIOs = n
+			}
+		}
+	})
+	if err == nil {
+		return stats, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// v1: lines of "8:16 Read 1459200"; the _recursive variants include
+	// child cgroups
+	v1 := []struct {
+		file        string
+		read, write func(*IOStat, uint64)
+	}{
+		{"blkio.throttle.io_service_bytes",
+			func(s *IOStat, n uint64) { s.ReadBytes = n },
+			func(s *IOStat, n uint64) { s.WriteBytes = n }},
+		{"blkio.throttle.io_serviced",
+			func(s *IOStat, n uint64) { s.ReadIOs = n },
+			func(s *IOStat, n uint64) { s.WriteIOs = n }},
+	}
+	found := false
+	for _, f := range v1 {
+		path := filepath.Join(dir, f.file+"_recursive")
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(dir, f.file)
+		}
+		err := eachLine(path, func(fields []string) {
+			if len(fields) != 3 {
+				return
+			}
+			n, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				return
+			}
+			switch fields[1] {
+			case "Read":
+				f.read(get(fields[0]), n)
+			case "Write":
+				f.write(get(fields[0]), n)
+			}
+		})
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return stats, nil
+}
+
+func pruneUnlimited(limits map[string]*IOLimit) map[string]*IOLimit {
+	for dev, l := range limits {
+		if !l.Limited() {
+			delete(limits, dev)
+		}
+	}
+	return limits
+}
+
+// eachLine calls fn with the whitespace-separated fields of each line in path
+func eachLine(path string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			fn(fields)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,98 @@
+// Package cloud looks up cloud provider disk properties (SKU, performance
+// limits) for CSI volumes, authenticating through the instance metadata service
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DiskInfo holds the provider's view of a disk
+type DiskInfo struct {
+	Provider        string
+	Name            string
+	SKU             string  // disk type, e.g. pd-ssd, Premium_LRS
+	Tier            string  // performance tier, e.g. P30; empty if not applicable
+	SizeBytes       float64 // provisioned size
+	IOPS            float64 // provisioned IOPS, 0 if unknown
+	ThroughputBytes float64 // provisioned throughput in bytes/s, 0 if unknown
+}
+
+// DiskProvider looks up disks for a single CSI driver
+type DiskProvider interface {
+	// Name returns the provider name, e.g. gce
+	Name() string
+	// Driver returns the CSI driver whose volume handles this provider understands
+	Driver() string
+	// Disk looks up a disk by CSI volume handle
+	Disk(ctx context.Context, handle string) (*DiskInfo, error)
+}
+
+// httpTimeout bounds each metadata and API request
+const httpTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// getJSON performs a GET with the given headers and decodes a JSON response into v
+func getJSON(ctx context.Context, url string, headers map[string]string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// token caches an OAuth access token fetched from an instance metadata service
+type token struct {
+	fetch func(ctx context.Context) (value string, expiresIn time.Duration, err error)
+
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// get returns the cached token, refreshing it a minute before it expires
+func (t *token) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.value != "" && time.Until(t.expires) > time.Minute {
+		return t.value, nil
+	}
+	value, expiresIn, err := t.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("metadata token: %w", err)
+	}
+	t.value, t.expires = value, time.Now().Add(expiresIn)
+	return t.value, nil
+}
+
+// NewProvider returns the provider for a configured enricher name
+func NewProvider(name string) (DiskProvider, error) {
+	switch name {
+	case ProviderGCE:
+		return NewGCEProvider(), nil
+	case ProviderAzure:
+		return NewAzureProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown cloud enricher %q", name)
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/cgroup"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+)
+
+var (
+	podIOBytesDesc = prometheus.NewDesc(
+		"pod_io_bytes_total",
+		"Bytes the cgroups of the pods mounting the volume issued to its device (cgroup io.stat); compare with read_bytes_total and write_bytes_total for amplification over any window",
+		append(append([]string{}, volumeLabels_...), "direction"), nil,
+	)
+	ioAmplificationDesc = prometheus.NewDesc(
+		"io_amplification_ratio",
+		"Bytes the device transferred per byte the volume's pods issued since the previous scrape. Above 1 is filesystem overhead (journaling, copy-on-write, metadata) or I/O from outside the pods.",
+		append(append([]string{}, volumeLabels_...), "direction"), nil,
+	)
+)
+
+// AmplificationCollector compares the I/O the pods mounting a volume issued,
+// from their cgroups' io.stat, with what its device actually transferred,
+// from diskstats. Journal and metadata writeback runs outside the pods'
+// cgroups, so the ratio shows filesystem overhead for tuning databases on
+// copy-on-write or journaling filesystems.
+type AmplificationCollector struct {
+	procPath   string
+	cgroupRoot string
+	sysPath    string
+
+	mu   sync.Mutex
+	prev map[string]ampSample // keyed by PV name, or mount path without one
+}
+
+// ampSample is the cumulative I/O of a volume's pods and its device
+type ampSample struct {
+	device                  string
+	pods                    string // UIDs summed, since a pod joining or leaving jumps the sum
+	podRead, podWrite       uint64
+	deviceRead, deviceWrite uint64
+}
+
+// NewAmplificationCollector creates a collector reading pod cgroups beneath
+// cgroupRoot, e.g. /host/sys/fs/cgroup
+func NewAmplificationCollector(procPath, cgroupRoot, sysPath string) *AmplificationCollector {
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &AmplificationCollector{
+		procPath:   procPath,
+		cgroupRoot: cgroupRoot,
+		sysPath:    sysPath,
+		prev:       make(map[string]ampSample),
+	}
+}
+
+func (c *AmplificationCollector) Name() string {
+	return "amplification"
+}
+
+func (c *AmplificationCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	return c.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape compares each volume's pod and device I/O using the scrape's
+// parsed diskstats, reading them itself if there are none
+func (c *AmplificationCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	stats := scrape.Diskstats
+	if stats == nil {
+		var err error
+		if stats, err = diskstats.Parse(c.procPath + "/diskstats"); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]ampSample, len(scrape.Volumes))
+	for _, vol := range scrape.Volumes {
+		cur, ok := c.sample(vol, stats)
+		if !ok {
+			continue
+		}
+		key := vol.PVName
+		if key == "" {
+			key = vol.MountPath
+		}
+		current[key] = cur
+
+		labels := volumeLabels(vol)
+		labels = labels[:len(labels):len(labels)] // read and write labels mustn't share an array
+		readLabels, writeLabels := append(labels, "read"), append(labels, "write")
+		ch <- prometheus.MustNewConstMetric(podIOBytesDesc, prometheus.CounterValue, float64(cur.podRead), readLabels...)
+		ch <- prometheus.MustNewConstMetric(podIOBytesDesc, prometheus.CounterValue, float64(cur.podWrite), writeLabels...)
+
+		prev, ok := c.prev[key]
+		if !ok || prev.device != cur.device || prev.pods != cur.pods {
+			continue
+		}
+		if r, ok := amplification(cur.deviceRead, prev.deviceRead, cur.podRead, prev.podRead); ok {
+			ch <- prometheus.MustNewConstMetric(ioAmplificationDesc, prometheus.GaugeValue, r, readLabels...)
+		}
+		if r, ok := amplification(cur.deviceWrite, prev.deviceWrite, cur.podWrite, prev.podWrite); ok {
+			ch <- prometheus.MustNewConstMetric(ioAmplificationDesc, prometheus.GaugeValue, r, writeLabels...)
+		}
+	}
+	c.prev = current
+
+	return nil
+}
+
+// sample sums the I/O the volume's pods issued to its device, and reads the
+// device's own. Partitions are compared on the whole disk when that's how
+// the cgroups account them.
+func (c *AmplificationCollector) sample(vol *discovery.VolumeInfo, stats *diskstats.StatsMap) (ampSample, bool) {
+	devs := deviceNumbers(c.sysPath, vol)
+	pods := volumePods(vol)
+	if len(devs) == 0 || len(pods) == 0 {
+		return ampSample{}, false
+	}
+
+	var podStats []map[string]*cgroup.IOStat
+	var uids []string
+	for _, pod := range pods {
+		dir, err := cgroup.PodDir(c.cgroupRoot, pod.UID)
+		if err != nil {
+			continue
+		}
+		// The pod cgroup's io.stat includes its containers
+		if s, err := cgroup.ReadIOStats(dir); err == nil {
+			podStats = append(podStats, s)
+			uids = append(uids, pod.UID)
+		}
+	}
+	sort.Strings(uids)
+
+	for _, dev := range devs {
+		d, ok := stats.ByDeviceID[dev]
+		if !ok {
+			continue
+		}
+		s := ampSample{
+			device:      dev,
+			pods:        strings.Join(uids, ","),
+			deviceRead:  d.ReadBytesTotal(),
+			deviceWrite: d.WriteBytesTotal(),
+		}
+		found := false
+		for _, ps := range podStats {
+			if io := ps[dev]; io != nil {
+				s.podRead += io.ReadBytes
+				s.podWrite += io.WriteBytes
+				found = true
+			}
+		}
+		if found {
+			return s, true
+		}
+	}
+	return ampSample{}, false
+}
+
+// amplification returns the device bytes per pod byte between two samples. Intervals
+// without pod I/O, and counters that went backwards because a pod or the
+// device was replaced, have none.
+func amplification(device, prevDevice, pod, prevPod uint64) (float64, bool) {
+	if device < prevDevice || pod <= prevPod {
+		return 0, false
+	}
+	return float64(device-prevDevice) / float64(pod-prevPod), true
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var (
+	deviceReattachDesc = prometheus.NewDesc(
+		"device_reattach_total",
+		"Times the device (major:minor) backing the volume changed between scrapes, e.g. a CSI detach/reattach; its diskstats counters restart from zero each time",
+		volumeLabels_, nil,
+	)
+	deviceAttachTimestampDesc = prometheus.NewDesc(
+		"device_attach_timestamp_seconds",
+		"When the volume's current device was first seen, as a Unix timestamp; volmetd's start time for devices attached before it started",
+		volumeLabels_, nil,
+	)
+)
+
+// attachForget is how long a volume that left the node is remembered, so a
+// pod moving away and back still counts as a reattach
+const attachForget = time.Hour
+
+// AttachCollector tracks each PV's backing device across scrapes and
+// counts device changes, to explain diskstats counter resets and correlate
+// them with CSI attach/detach problems
+type AttachCollector struct {
+	mu      sync.Mutex
+	volumes map[string]*attachState // keyed by PV name, or mount path without one
+}
+
+type attachState struct {
+	deviceID   string
+	since      time.Time
+	seen       time.Time
+	reattaches uint64
+}
+
+// NewAttachCollector creates a new attach collector
+func NewAttachCollector() *AttachCollector {
+	return &AttachCollector{volumes: make(map[string]*attachState)}
+}
+
+func (c *AttachCollector) Name() string {
+	return "attach"
+}
+
+func (c *AttachCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, vol := range volumes {
+		if vol.DeviceID == "" {
+			continue
+		}
+		key := vol.PVName
+		if key == "" {
+			key = vol.MountPath
+		}
+
+		s := c.volumes[key]
+		switch {
+		case s == nil:
+			s = &attachState{deviceID: vol.DeviceID, since: now}
+			c.volumes[key] = s
+		case s.deviceID != vol.DeviceID:
+			slog.Info("volume device changed", "pv", vol.PVName, "pvc", vol.PVCNamespace+"/"+vol.PVCName, "old", s.deviceID, "new", vol.DeviceID)
+			s.deviceID = vol.DeviceID
+			s.since = now
+			s.reattaches++
+		}
+		s.seen = now
+
+		ch <- volumeMetric(deviceReattachDesc, prometheus.CounterValue, float64(s.reattaches), vol)
+		ch <- volumeMetric(deviceAttachTimestampDesc, prometheus.GaugeValue, float64(s.since.UnixNano())/1e9, vol)
+	}
+
+	for key, s := range c.volumes {
+		if now.Sub(s.seen) > attachForget {
+			delete(c.volumes, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var collectorCacheAgeDesc = prometheus.NewDesc(
+	"collector_cache_age_seconds",
+	"Age of the metrics a collector refreshed in the background served this scrape",
+	[]string{"collector"}, nil,
+)
+
+// IntervalCollector is implemented by collectors too expensive to run on
+// every scrape, e.g. those calling remote APIs. Interval is how often they
+// refresh by default; WithIntervals runs them in the background.
+type IntervalCollector interface {
+	Interval() time.Duration
+}
+
+// CachedCollector runs a collector at most every interval, in the background
+// of the scrapes asking for it, and serves the metrics of its latest run in
+// between. The first scrape waits for a run, so metrics appear immediately.
+// Runs see copies of the volumes of the scrape that started them, and the
+// per-volume errors of the latest run are reported with every scrape served.
+type CachedCollector struct {
+	c        Collector
+	interval time.Duration
+
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+	err     error
+	errs    *VolumeErrors // of the latest run
+	time    time.Time     // of the latest run
+	running bool
+}
+
+// NewCachedCollector wraps c to run at most every interval
+func NewCachedCollector(c Collector, interval time.Duration) *CachedCollector {
+	return &CachedCollector{c: c, interval: interval}
+}
+
+// WithIntervals wraps the collectors that declare an Interval, or are named
+// in intervals, in CachedCollectors. An interval of 0 in intervals keeps a
+// collector on every scrape.
+func WithIntervals(collectors []Collector, intervals map[string]time.Duration) []Collector {
+	result := make([]Collector, len(collectors))
+	for i, c := range collectors {
+		result[i] = c
+		interval, ok := intervals[c.Name()]
+		if ic, declared := c.(IntervalCollector); !ok && declared {
+			interval = ic.Interval()
+		}
+		if interval > 0 {
+			slog.Debug("collector refreshed in the background", "collector", c.Name(), "interval", interval)
+			result[i] = NewCachedCollector(c, interval)
+		}
+	}
+	return result
+}
+
+func (c *CachedCollector) Name() string {
+	return c.c.Name()
+}
+
+func (c *CachedCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	return c.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape serves the latest run's metrics, volume errors and error,
+// starting a run when the interval has passed. Only the first run, which
+// the scrape waits for, is given the scrape's parsed diskstats; background
+// runs outlive the scrape, so collectors read their own.
+func (c *CachedCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	first := c.time.IsZero() && !c.running
+	if !c.running && time.Since(c.time) >= c.interval {
+		c.running = true
+		run := &Scrape{Volumes: scrape.Volumes, Errors: NewVolumeErrors()}
+		if first {
+			run.Diskstats = scrape.Diskstats
+			c.mu.Unlock()
+			c.refresh(run)
+			c.mu.Lock()
+		} else {
+			go c.refresh(run)
+		}
+	}
+	metrics, err, errs, ran, age := c.metrics, c.err, c.errs, !c.time.IsZero(), time.Since(c.time)
+	c.mu.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	scrape.Errors.merge(errs)
+	if ran {
+		ch <- prometheus.MustNewConstMetric(collectorCacheAgeDesc, prometheus.GaugeValue, age.Seconds(), c.Name())
+	}
+	return err
+}
+
+// refresh runs the collector on run and keeps its metrics
+func (c *CachedCollector) refresh(run *Scrape) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
+	}()
+	var err error
+	if sc, ok := c.c.(ScrapeCollector); ok {
+		err = sc.UpdateScrape(run, ch)
+	} else {
+		err = c.c.Update(run.Volumes, ch)
+	}
+	close(ch)
+	metrics := <-done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics, c.err, c.errs, c.time, c.running = metrics, err, run.Errors, time.Now(), false
+}
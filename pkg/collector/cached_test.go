@@ -0,0 +1,76 @@
+package collector_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/collector/collectortest"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+)
+
+// csistats is refreshed in the background by default, and must still
+// report the volumes its driver calls fail on
+func TestCachedCollectorVolumeErrors(t *testing.T) {
+	proc := t.TempDir()
+	if err := os.WriteFile(filepath.Join(proc, "diskstats"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	kubeletPath := t.TempDir()
+	serveCSIDriver(t, filepath.Join(kubeletPath, "plugins", "csi.example.com", "csi.sock"))
+	csistats := collector.NewCSIStatsCollector(kubeletPath, "")
+	collectors := collector.WithIntervals([]collector.Collector{csistats}, nil)
+	if _, ok := collectors[0].(*collector.CachedCollector); !ok {
+		t.Fatalf("csistats wrapped as %T, want *collector.CachedCollector", collectors[0])
+	}
+
+	d := discoverytest.New("fake", discoverytest.Volume("data-db-0", "db"))
+	v := collector.NewVolumeCollector(discovery.NewMultiDiscoverer(d), proc, collectors...)
+	for i := 0; i < 2; i++ {
+		ms, err := collectortest.GatherFrom(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collectortest.Expect(t, ms, "volume_scrape_error", 1, "pvc", "data-db-0", "namespace", "db", "collector", "csistats")
+	}
+}
+
+// csiDriver advertises volume stats but fails every NodeGetVolumeStats call
+type csiDriver struct {
+	csipb.UnimplementedIdentityServer
+	csipb.UnimplementedNodeServer
+}
+
+func (csiDriver) GetPluginInfo(context.Context, *csipb.GetPluginInfoRequest) (*csipb.GetPluginInfoResponse, error) {
+	return &csipb.GetPluginInfoResponse{Name: "csi.example.com"}, nil
+}
+
+func (csiDriver) NodeGetCapabilities(context.Context, *csipb.NodeGetCapabilitiesRequest) (*csipb.NodeGetCapabilitiesResponse, error) {
+	return &csipb.NodeGetCapabilitiesResponse{Capabilities: []*csipb.NodeServiceCapability{{
+		Type: &csipb.NodeServiceCapability_Rpc{Rpc: &csipb.NodeServiceCapability_RPC{Type: csipb.NodeServiceCapability_RPC_GET_VOLUME_STATS}},
+	}}}, nil
+}
+
+// serveCSIDriver serves csiDriver on a unix socket at path until the test ends
+func serveCSIDriver(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	csipb.RegisterIdentityServer(s, csiDriver{})
+	csipb.RegisterNodeServer(s, csiDriver{})
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+}
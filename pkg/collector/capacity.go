@@ -1,11 +1,18 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
 	"github.com/gfx-labs/volmetd/pkg/mounts"
 )
 
@@ -18,12 +25,98 @@ var capacityMetrics = MetricSet[*mounts.Capacity]{
 	Gauge("capacity_inodes_free", "Free number of inodes", volumeLabels_, func(c *mounts.Capacity) float64 { return float64(c.FreeInodes) }),
 }
 
+var subPathLabels = append(append([]string(nil), volumeLabels_...), "subpath")
+
+var (
+	subPathBytesUsedDesc = prometheus.NewDesc(
+		"subpath_bytes_used",
+		"Bytes used beneath a subPath containers mount of the volume, from its project quota or du",
+		subPathLabels, nil,
+	)
+	subPathInodesUsedDesc = prometheus.NewDesc(
+		"subpath_inodes_used",
+		"Inodes used beneath a subPath containers mount of the volume, from its project quota or du",
+		subPathLabels, nil,
+	)
+	subPathBytesLimitDesc = prometheus.NewDesc(
+		"subpath_bytes_limit",
+		"Project quota limit in bytes of a subPath containers mount of the volume",
+		subPathLabels, nil,
+	)
+)
+
+// duInterval is how often a directory's usage is re-walked for subpath volumes
+// without a project quota
+const duInterval = 5 * time.Minute
+
 // CapacityCollector collects filesystem capacity metrics via statfs
-type CapacityCollector struct{}
+type CapacityCollector struct {
+	hostRoot        string // resolve mounts inside this root, e.g. /host/proc/1/root
+	kubeletPath     string // kubelet path as seen by volmetd
+	hostKubeletPath string // kubelet path as seen by the host
+	subpath         bool   // report project quota / du usage for subpath volumes
+	subPathMounts   bool   // report usage beneath each container subPath of volumes
+
+	du *duCache
+
+	forecast *fillForecaster // nil when disabled
+
+	highUsage *highUsageSampler // nil when disabled
+
+	kubelet *kubelet.Client // capacity of volumes statfs is denied for, nil = disabled
+
+	mu   sync.Mutex
+	last map[string]*mounts.Capacity // by mount path, from the latest run, see LastCapacity
+}
+
+// NewCapacityCollector creates a new capacity collector. If hostRoot is set,
+// statfs runs against the host mount namespace with mount paths rewritten
+// from kubeletPath to hostKubeletPath. If subpath is set, volumes carved as
+// directories from a shared filesystem report their project quota, or a du
+// walk of the directory, instead of the whole filesystem.
+func NewCapacityCollector(hostRoot, kubeletPath, hostKubeletPath string, subpath bool) *CapacityCollector {
+	return &CapacityCollector{
+		hostRoot:        hostRoot,
+		kubeletPath:     kubeletPath,
+		hostKubeletPath: hostKubeletPath,
+		subpath:         subpath,
+		du:              newDUCache("capacity", duInterval),
+		forecast:        newFillForecaster(DefaultForecastWindow),
+	}
+}
+
+// SetForecastWindow sets the sliding window fill rates and time until full
+// are computed over. 0 disables forecasting.
+func (c *CapacityCollector) SetForecastWindow(window time.Duration) {
+	if window <= 0 {
+		c.forecast = nil
+		return
+	}
+	c.forecast = newFillForecaster(window)
+}
+
+// SetHighUsageSampling samples volumes at least thresholdPercent full every
+// interval between scrapes and reports the highest usage seen since the
+// previous scrape as capacity_bytes_used_max. 0 disables it.
+func (c *CapacityCollector) SetHighUsageSampling(thresholdPercent float64, interval time.Duration) {
+	if thresholdPercent <= 0 {
+		c.highUsage = nil
+		return
+	}
+	c.highUsage = newHighUsageSampler(thresholdPercent, interval)
+}
 
-// NewCapacityCollector creates a new capacity collector
-func NewCapacityCollector() *CapacityCollector {
-	return &CapacityCollector{}
+// SetKubeletStats falls back to the capacity the kubelet reports for a PVC
+// when statfs of its mount path fails, as it does without root
+func (c *CapacityCollector) SetKubeletStats(k *kubelet.Client) {
+	c.kubelet = k
+}
+
+// SetSubPathMounts also reports the usage beneath each subPath containers
+// mount of a volume, from the directory's project quota or otherwise du,
+// for PVCs split between tenants by subPath
+func (c *CapacityCollector) SetSubPathMounts(enabled bool) {
+	c.subPathMounts = enabled
 }
 
 func (c *CapacityCollector) Name() string {
@@ -31,6 +124,21 @@ func (c *CapacityCollector) Name() string {
 }
 
 func (c *CapacityCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	return c.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape collects capacity, reporting volumes that fail statfs to the
+// scrape's errors
+func (c *CapacityCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	volumes, errs := scrape.Volumes, scrape.Errors
+	var shared map[string]bool
+	if c.subpath {
+		shared = sharedDevices(volumes)
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	last := make(map[string]*mounts.Capacity, len(volumes))
 	wg := sync.WaitGroup{}
 	for _, vol := range volumes {
 		if vol.MountPath == "" {
@@ -39,12 +147,169 @@ func (c *CapacityCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- pr
 		wg.Add(1)
 		go func(vol *discovery.VolumeInfo) {
 			defer wg.Done()
-			if cap, err := mounts.GetCapacity(vol.MountPath); err == nil {
-				capacityMetrics.Collect(cap, volumeLabels(vol), ch)
+			cap, err := c.getVolumeCapacity(vol, shared[vol.DeviceID])
+			if err != nil && c.kubelet != nil && vol.PVCName != "" {
+				if kcap, kerr := c.kubelet.Capacity(context.Background(), vol.PVCNamespace, vol.PVCName); kerr == nil {
+					cap, err = kcap, nil
+				} else {
+					slog.Debug("capacity: kubelet stats", "pvc", vol.PVCNamespace+"/"+vol.PVCName, "error", kerr)
+				}
+			}
+			if err != nil {
+				errs.Add(c.Name(), vol, err)
+				return
+			}
+			mu.Lock()
+			last[vol.MountPath] = cap
+			mu.Unlock()
+			labels := volumeLabels(vol)
+			capacityMetrics.Collect(cap, labels, ch)
+			if c.forecast != nil {
+				c.collectForecast(vol, cap, labels, now, ch)
+			}
+			if c.highUsage != nil {
+				maxUsed := c.highUsage.observe(vol.MountPath, cap, now, func() (*mounts.Capacity, error) {
+					return c.getVolumeCapacity(vol, shared[vol.DeviceID])
+				})
+				ch <- prometheus.MustNewConstMetric(capacityBytesUsedMaxDesc, prometheus.GaugeValue, float64(maxUsed), labels...)
+			}
+			if c.subPathMounts {
+				c.collectSubPaths(vol, labels, ch)
 			}
 		}(vol)
 	}
 	wg.Wait()
 
+	c.mu.Lock()
+	c.last = last
+	c.mu.Unlock()
+	if c.forecast != nil {
+		c.forecast.prune(now)
+	}
+	if c.highUsage != nil {
+		c.highUsage.prune(now)
+	}
+
 	return nil
 }
+
+// LastCapacity returns the capacity the latest run found for the volume
+// mounted at mountPath, or nil if it had none
+func (c *CapacityCollector) LastCapacity(mountPath string) *mounts.Capacity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last[mountPath]
+}
+
+// collectForecast records the volume's used bytes and emits its fill rate
+// and time until full once the window has enough samples
+func (c *CapacityCollector) collectForecast(vol *discovery.VolumeInfo, cap *mounts.Capacity, labels []string, now time.Time, ch chan<- prometheus.Metric) {
+	key := vol.PVName
+	if key == "" {
+		key = vol.MountPath
+	}
+	rate, ok := c.forecast.observe(key, cap.UsedBytes, now)
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(capacityFillRateDesc, prometheus.GaugeValue, rate, labels...)
+	ch <- prometheus.MustNewConstMetric(capacitySecondsUntilFullDesc, prometheus.GaugeValue, secondsUntilFull(cap.FreeBytes, rate), labels...)
+}
+
+// collectSubPaths emits the usage beneath each of the volume's container
+// subPaths once known; du results arrive from a background walk
+func (c *CapacityCollector) collectSubPaths(vol *discovery.VolumeInfo, labels []string, ch chan<- prometheus.Metric) {
+	for _, sub := range vol.ContainerSubPaths {
+		local := filepath.Join(vol.MountPath, sub)
+		if !strings.HasPrefix(local, vol.MountPath+"/") {
+			continue
+		}
+		base := vol.MountPath
+		if c.hostRoot != "" {
+			base = filepath.Join(c.hostRoot, c.hostPath(vol.MountPath))
+		}
+		// A tenant could replace its directory, or any above it, with a
+		// symlink out of the volume
+		rel := strings.TrimPrefix(local, vol.MountPath+"/")
+		if err := mounts.CheckDirBeneath(base, rel); err != nil {
+			slog.Debug("capacity: subPath", "volume", vol.MountPath, "subPath", sub, "error", err)
+			continue
+		}
+		path := filepath.Join(base, rel)
+
+		subLabels := append(labels[:len(labels):len(labels)], sub)
+		if q, err := mounts.GetProjectQuota(path); err == nil {
+			ch <- prometheus.MustNewConstMetric(subPathBytesUsedDesc, prometheus.GaugeValue, float64(q.UsedBytes), subLabels...)
+			ch <- prometheus.MustNewConstMetric(subPathInodesUsedDesc, prometheus.GaugeValue, float64(q.UsedInodes), subLabels...)
+			if q.TotalBytes > 0 {
+				ch <- prometheus.MustNewConstMetric(subPathBytesLimitDesc, prometheus.GaugeValue, float64(q.TotalBytes), subLabels...)
+			}
+			continue
+		}
+		if bytes, inodes, ok := c.du.usage(path); ok {
+			ch <- prometheus.MustNewConstMetric(subPathBytesUsedDesc, prometheus.GaugeValue, float64(bytes), subLabels...)
+			ch <- prometheus.MustNewConstMetric(subPathInodesUsedDesc, prometheus.GaugeValue, float64(inodes), subLabels...)
+		}
+	}
+}
+
+func (c *CapacityCollector) getCapacity(mountPath string) (*mounts.Capacity, error) {
+	if c.hostRoot == "" {
+		return mounts.GetCapacity(mountPath)
+	}
+	return mounts.GetCapacityInRoot(c.hostRoot, c.hostPath(mountPath))
+}
+
+// getVolumeCapacity returns the capacity of a volume, preferring its project
+// quota in subpath mode. Volumes sharing a filesystem with other PVs and no
+// quota fall back to du for usage, with size and free space from statfs.
+func (c *CapacityCollector) getVolumeCapacity(vol *discovery.VolumeInfo, shared bool) (*mounts.Capacity, error) {
+	if !c.subpath {
+		return c.getCapacity(vol.MountPath)
+	}
+
+	path := vol.MountPath
+	if c.hostRoot != "" {
+		path = filepath.Join(c.hostRoot, c.hostPath(vol.MountPath))
+	}
+
+	q, err := mounts.GetProjectQuota(path)
+	if err == nil {
+		return q, nil
+	}
+	if !errors.Is(err, mounts.ErrNoProjectQuota) {
+		slog.Debug("capacity: project quota", "path", path, "error", err)
+	}
+
+	cap, err := c.getCapacity(vol.MountPath)
+	if err != nil || !shared {
+		return cap, err
+	}
+
+	if bytes, inodes, ok := c.du.usage(path); ok {
+		cap.UsedBytes = bytes
+		cap.UsedInodes = inodes
+	}
+	return cap, nil
+}
+
+// hostPath rewrites a kubelet path as seen by volmetd to the host's
+func (c *CapacityCollector) hostPath(mountPath string) string {
+	return mounts.RebasePath(mountPath, c.kubeletPath, c.hostKubeletPath)
+}
+
+// sharedDevices returns the device IDs backing more than one PV
+func sharedDevices(volumes []*discovery.VolumeInfo) map[string]bool {
+	pvs := make(map[string]string)
+	shared := make(map[string]bool)
+	for _, vol := range volumes {
+		if vol.DeviceID == "" {
+			continue
+		}
+		if pv, ok := pvs[vol.DeviceID]; ok && pv != vol.PVName {
+			shared[vol.DeviceID] = true
+		}
+		pvs[vol.DeviceID] = vol.PVName
+	}
+	return shared
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/cloud"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var cloudDiskLabels_ = []string{"pvc", "namespace", "pv", "provider"}
+
+var cloudDiskInfoDesc = prometheus.NewDesc(
+	"cloud_disk_info",
+	"Cloud provider disk backing the volume (always 1)",
+	append(append([]string{}, cloudDiskLabels_...), "disk", "sku", "tier"), nil,
+)
+
+var cloudDiskMetrics = MetricSet[*cloud.DiskInfo]{
+	Gauge("cloud_disk_size_bytes", "Provisioned disk size reported by the cloud provider", cloudDiskLabels_, func(d *cloud.DiskInfo) float64 { return d.SizeBytes }),
+	Gauge("cloud_disk_provisioned_iops", "Provisioned IOPS limit reported by the cloud provider", cloudDiskLabels_, func(d *cloud.DiskInfo) float64 { return d.IOPS }),
+	Gauge("cloud_disk_provisioned_throughput_bytes", "Provisioned throughput limit in bytes per second reported by the cloud provider", cloudDiskLabels_, func(d *cloud.DiskInfo) float64 { return d.ThroughputBytes }),
+}
+
+const (
+	// cloudDiskTTL is how long disk lookups are cached; SKUs and limits rarely change
+	cloudDiskTTL = 10 * time.Minute
+	// cloudDiskErrorTTL is how long failed lookups are cached to avoid hammering the API
+	cloudDiskErrorTTL = time.Minute
+)
+
+type cloudDiskEntry struct {
+	disk    *cloud.DiskInfo
+	err     error
+	expires time.Time
+}
+
+// CloudDiskCollector exports cloud provider disk SKU and provisioned
+// performance limits for volumes, keyed by PVC. It needs cloud credentials
+// from the instance metadata service so it is only enabled explicitly.
+type CloudDiskCollector struct {
+	providers map[string]cloud.DiskProvider // keyed by CSI driver
+
+	mu    sync.Mutex
+	cache map[string]*cloudDiskEntry // keyed by volume handle
+}
+
+// NewCloudDiskCollector creates a new cloud disk collector
+func NewCloudDiskCollector(providers ...cloud.DiskProvider) *CloudDiskCollector {
+	c := &CloudDiskCollector{
+		providers: make(map[string]cloud.DiskProvider),
+		cache:     make(map[string]*cloudDiskEntry),
+	}
+	for _, p := range providers {
+		c.providers[p.Driver()] = p
+	}
+	return c
+}
+
+func (c *CloudDiskCollector) Name() string {
+	return "cloud"
+}
+
+func (c *CloudDiskCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []string
+	)
+	for _, vol := range volumes {
+		p, ok := c.providers[vol.CSIDriver]
+		if !ok || vol.VolumeHandle == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(vol *discovery.VolumeInfo, p cloud.DiskProvider) {
+			defer wg.Done()
+			disk, err := c.disk(p, vol.VolumeHandle)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
+				return
+			}
+
+			labels := []string{vol.PVCName, vol.PVCNamespace, vol.PVName, p.Name()}
+			ch <- prometheus.MustNewConstMetric(cloudDiskInfoDesc, prometheus.GaugeValue, 1,
+				append(labels, disk.Name, disk.SKU, disk.Tier)...)
+			cloudDiskMetrics.Collect(disk, labels, ch)
+		}(vol, p)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d disk lookups failed: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// disk returns the cached disk for a handle, looking it up when expired
+func (c *CloudDiskCollector) disk(p cloud.DiskProvider, handle string) (*cloud.DiskInfo, error) {
+	c.mu.Lock()
+	e, ok := c.cache[handle]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.disk, e.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	disk, err := p.Disk(ctx, handle)
+
+	e = &cloudDiskEntry{disk: disk, err: err, expires: time.Now().Add(cloudDiskTTL)}
+	if err != nil {
+		e.expires = time.Now().Add(cloudDiskErrorTTL)
+	}
+	c.mu.Lock()
+	c.cache[handle] = e
+	c.mu.Unlock()
+	return disk, err
+}
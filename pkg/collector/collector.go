@@ -2,7 +2,11 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,8 +14,14 @@ import (
 
 	"github.com/gfx-labs/volmetd/pkg/discovery"
 	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+	"github.com/gfx-labs/volmetd/pkg/policy"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
 )
 
+// DefaultPrefix is prepended to all metric names unless overridden
+const DefaultPrefix = "volmetd_"
+
 // Collector collects metrics for discovered volumes
 type Collector interface {
 	// Name returns the collector name
@@ -22,27 +32,265 @@ type Collector interface {
 
 var (
 	scrapeDurationDesc = prometheus.NewDesc(
-		"volmetd_scrape_duration_seconds",
+		"scrape_duration_seconds",
 		"Time spent collecting metrics by collector",
 		[]string{"collector"}, nil,
 	)
 	scrapeSuccessDesc = prometheus.NewDesc(
-		"volmetd_scrape_success",
+		"scrape_success",
 		"Whether the collector succeeded",
 		[]string{"collector"}, nil,
 	)
 	volumesDiscoveredDesc = prometheus.NewDesc(
-		"volmetd_volumes_discovered",
+		"volumes_discovered",
 		"Number of PVC volumes discovered",
 		nil, nil,
 	)
+	discovererBreakerDesc = prometheus.NewDesc(
+		"discoverer_breaker_state",
+		"Circuit breaker state of each discoverer (1 for the current state)",
+		[]string{"discoverer", "state"}, nil,
+	)
+	discoveryStaleDesc = prometheus.NewDesc(
+		"discovery_stale_seconds",
+		"Age of the volume snapshot metrics are served from, 0 when discovery succeeded this scrape",
+		nil, nil,
+	)
+	discovererFailuresDesc = prometheus.NewDesc(
+		"discoverer_consecutive_failures",
+		"Consecutive failed runs of each discoverer",
+		[]string{"discoverer"}, nil,
+	)
+	discoveryNamespaceSuccessDesc = prometheus.NewDesc(
+		"discovery_namespace_success",
+		"Whether the most recent pod listing of each configured namespace succeeded",
+		[]string{"namespace"}, nil,
+	)
+	discoveryNamespaceDurationDesc = prometheus.NewDesc(
+		"discovery_namespace_duration_seconds",
+		"Time spent on the most recent pod listing of each configured namespace",
+		[]string{"namespace"}, nil,
+	)
+	discoveryNamespacePodsDesc = prometheus.NewDesc(
+		"discovery_namespace_pods",
+		"Pods on this node found by the most recent listing of each configured namespace",
+		[]string{"namespace"}, nil,
+	)
+	discovererPVListDesc = prometheus.NewDesc(
+		"discoverer_pv_list_success",
+		"Whether the discoverer's most recent PersistentVolume list succeeded. When it fails, e.g. forbidden by RBAC, storage class and CSI driver come from PVCs and vol_data.json. Absent in minimal RBAC mode.",
+		[]string{"discoverer"}, nil,
+	)
+	discovererAccessDeniedDesc = prometheus.NewDesc(
+		"discoverer_access_denied_paths",
+		"Kubelet paths the discoverer's most recent run was denied access to (EACCES/EPERM), typically by SELinux or AppArmor confinement; the logs name one",
+		[]string{"discoverer"}, nil,
+	)
+	discoveryVolumeChangesDesc = prometheus.NewDesc(
+		"discovery_volume_changes_total",
+		"Volumes discovery passes found added, removed or changed since the previous successful pass",
+		[]string{"change"}, nil,
+	)
+	discoveryFieldChangesDesc = prometheus.NewDesc(
+		"discovery_volume_field_changes_total",
+		"Changes of each volume field (device, mount_path, pods, ...) between successful discovery passes",
+		[]string{"field"}, nil,
+	)
+	volumeScrapeErrorDesc = prometheus.NewDesc(
+		"volume_scrape_error",
+		"Set to 1 when a collector failed to collect a volume's stats this scrape, absent otherwise. reason is mount_not_found, device_unresolvable, no_diskstats, permission_denied or error. The collector's scrape_success is unaffected.",
+		[]string{"pvc", "namespace", "collector", "reason"}, nil,
+	)
+	volumeCollectorSkippedDesc = prometheus.NewDesc(
+		"volume_collector_skipped",
+		"Set to 1 when a collector doesn't apply to a volume's backend_type (tmpfs, overlay, network), e.g. diskstats to tmpfs, absent otherwise",
+		[]string{"pvc", "namespace", "collector", "backend_type"}, nil,
+	)
+	cephOSDVolumeDesc = prometheus.NewDesc(
+		"ceph_osd_volume",
+		"Volumes excluded from per-volume metrics as backing a Ceph OSD (a Rook OSD pod's PVC, or a device beneath a ceph-volume logical volume), whose I/O the clients' RBD and CephFS volumes already count (always 1)",
+		[]string{"pvc", "namespace", "pv", "device"}, nil,
+	)
+)
+
+// ScrapeCollector is implemented by collectors that use state shared across
+// a scrape. VolumeCollector calls UpdateScrape instead of Update.
+type ScrapeCollector interface {
+	UpdateScrape(s *Scrape, ch chan<- prometheus.Metric) error
+}
+
+// Scrape is the state of one VolumeCollector scrape shared by its collectors
+type Scrape struct {
+	Volumes []*discovery.VolumeInfo
+
+	// Diskstats is /proc/diskstats parsed once for the scrape, or nil if it
+	// couldn't be parsed. Its Stats are reused by later scrapes, so must not
+	// be kept past UpdateScrape.
+	Diskstats *diskstats.StatsMap
+
+	// Errors collects the volumes collectors failed on, exported as
+	// volume_scrape_error rather than failing the collector
+	Errors *VolumeErrors
+}
+
+// VolumeErrors records the volumes collectors failed on during a scrape, and
+// those they skipped as not applying to the volume's backend. Methods on a
+// nil *VolumeErrors only log.
+type VolumeErrors struct {
+	mu    sync.Mutex
+	errs  map[volumeErrorKey]bool
+	skips map[volumeErrorKey]bool // reason is the backend
+}
+
+type volumeErrorKey struct {
+	pvc, namespace, collector, reason string
+}
+
+// NewVolumeErrors creates an empty set of volume errors
+func NewVolumeErrors() *VolumeErrors {
+	return &VolumeErrors{errs: make(map[volumeErrorKey]bool), skips: make(map[volumeErrorKey]bool)}
+}
+
+// Add records that collector failed on vol
+func (e *VolumeErrors) Add(collector string, vol *discovery.VolumeInfo, err error) {
+	reason := errorReason(err)
+	slog.Debug("volume scrape error", "collector", collector, "pvc", vol.PVCNamespace+"/"+vol.PVCName, "pv", vol.PVName, "reason", reason, "error", err)
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.errs[volumeErrorKey{vol.PVCName, vol.PVCNamespace, collector, reason}] = true
+	e.mu.Unlock()
+}
+
+// Skip records that collector doesn't apply to vol's backend
+func (e *VolumeErrors) Skip(collector string, vol *discovery.VolumeInfo) {
+	backend := vol.Backend()
+	slog.Debug("volume skipped", "collector", collector, "pvc", vol.PVCNamespace+"/"+vol.PVCName, "pv", vol.PVName, "backend", backend)
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.skips[volumeErrorKey{vol.PVCName, vol.PVCNamespace, collector, backend}] = true
+	e.mu.Unlock()
+}
+
+// Reasons returns the collectors that failed on vol and why, as
+// "collector: reason", sorted
+func (e *VolumeErrors) Reasons(vol *discovery.VolumeInfo) []string {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var reasons []string
+	for k := range e.errs {
+		if k.pvc == vol.PVCName && k.namespace == vol.PVCNamespace {
+			reasons = append(reasons, k.collector+": "+k.reason)
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// merge records from's failures and skips too. Either may be nil.
+func (e *VolumeErrors) merge(from *VolumeErrors) {
+	if e == nil || from == nil {
+		return
+	}
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for k := range from.errs {
+		e.errs[k] = true
+	}
+	for k := range from.skips {
+		e.skips[k] = true
+	}
+}
+
+// collect emits volume_scrape_error for each recorded failure and
+// volume_collector_skipped for each skip. Volumes mounted by several pods are
+// reported once.
+func (e *VolumeErrors) collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for k := range e.errs {
+		ch <- prometheus.MustNewConstMetric(volumeScrapeErrorDesc, prometheus.GaugeValue, 1, k.pvc, k.namespace, k.collector, k.reason)
+	}
+	for k := range e.skips {
+		ch <- prometheus.MustNewConstMetric(volumeCollectorSkippedDesc, prometheus.GaugeValue, 1, k.pvc, k.namespace, k.collector, k.reason)
+	}
+}
+
+// Reasons a collector failed on a volume, the reason label of
+// volume_scrape_error
+const (
+	ReasonMountNotFound      = "mount_not_found"
+	ReasonDeviceUnresolvable = "device_unresolvable"
+	ReasonNoDiskstats        = "no_diskstats"
+	ReasonPermissionDenied   = "permission_denied"
+	ReasonError              = "error"
 )
 
+// errorReason classifies a volume error by the typed errors it wraps
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, mounts.ErrMountNotFound), errors.Is(err, mounts.ErrNotMountPoint):
+		return ReasonMountNotFound
+	case errors.Is(err, mounts.ErrDeviceUnresolvable):
+		return ReasonDeviceUnresolvable
+	case errors.Is(err, diskstats.ErrNoDiskstats):
+		return ReasonNoDiskstats
+	case errors.Is(err, fs.ErrPermission):
+		return ReasonPermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		// The mount path is gone, e.g. the pod was deleted since discovery
+		return ReasonMountNotFound
+	}
+	return ReasonError
+}
+
+var breakerStates = []string{discovery.BreakerClosed, discovery.BreakerOpen, discovery.BreakerHalfOpen}
+
+// CollectorStatus records the outcome of a collector's most recent run
+type CollectorStatus struct {
+	Name     string
+	Duration time.Duration
+	Error    string
+	Time     time.Time
+}
+
+// DefaultStaleTTL is how long the last discovered volumes are served when discovery fails
+const DefaultStaleTTL = 5 * time.Minute
+
 // VolumeCollector orchestrates all sub-collectors
 type VolumeCollector struct {
 	discoverer *discovery.MultiDiscoverer
 	collectors []Collector
 	procPath   string
+	staleTTL   time.Duration
+	omitPods   bool
+	cephOSDs   bool      // exclude Ceph OSD volumes
+	sysPath    string    // for finding Ceph OSD devices
+	stats      sync.Pool // *diskstats.StatsMap reused across scrapes
+	policy     policy.Source
+
+	mu     sync.Mutex
+	status map[string]*CollectorStatus
+
+	// Last successful discovery, served while discovery fails
+	lastVolumes []*discovery.VolumeInfo
+	lastSuccess time.Time
+
+	lastScrape *LastScrape
+
+	// File the last successful discovery is persisted to, see SetStateFile
+	stateFile  string
+	stateMu    sync.Mutex
+	stateSaved []byte // volumes last written
+	stateTime  time.Time
 }
 
 // NewVolumeCollector creates a new volume collector
@@ -54,14 +302,131 @@ func NewVolumeCollector(discoverer *discovery.MultiDiscoverer, procPath string,
 		discoverer: discoverer,
 		collectors: collectors,
 		procPath:   procPath,
+		staleTTL:   DefaultStaleTTL,
+		stats:      sync.Pool{New: func() any { return diskstats.NewStatsMap() }},
+		status:     make(map[string]*CollectorStatus),
 	}
 }
 
+// SetStaleTTL sets how long the last discovered volumes are served when
+// discovery fails. 0 disables serving stale volumes.
+func (v *VolumeCollector) SetStaleTTL(ttl time.Duration) {
+	v.staleTTL = ttl
+}
+
+// SetOmitPodLabels leaves pod and pod_namespace empty on per-volume metrics,
+// so their series are keyed by PVC and don't change identity when a pod is
+// rescheduled. volume_info and per-pod metrics keep the pods.
+func (v *VolumeCollector) SetOmitPodLabels(omit bool) {
+	v.omitPods = omit
+}
+
+// SetExcludeCephOSDs drops the volumes backing Ceph OSDs from every
+// collector, exporting ceph_osd_volume for them instead. On nodes running
+// Rook OSDs the OSD pods' PVCs and OSD devices are otherwise discovered like
+// any volume, and their I/O counted on top of the clients' RBD volumes.
+func (v *VolumeCollector) SetExcludeCephOSDs(enabled bool, sysPath string) {
+	v.cephOSDs = enabled
+	v.sysPath = sysPath
+}
+
+// SetPolicy skips collectors the policy from src disables, checked on every
+// scrape so policy changes apply without a restart
+func (v *VolumeCollector) SetPolicy(src policy.Source) {
+	v.policy = src
+}
+
+// volumesOrStale records a successful discovery, or on failure returns the
+// last successful volumes if they're within the stale TTL. Every scrape
+// resolves device names in its volumes in place, so the volumes kept and
+// each scrape served them get copies of their own.
+func (v *VolumeCollector) volumesOrStale(volumes []*discovery.VolumeInfo, err error) ([]*discovery.VolumeInfo, time.Duration, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err == nil {
+		v.lastVolumes = copyVolumes(volumes)
+		v.lastSuccess = time.Now()
+		return volumes, 0, true
+	}
+
+	age := time.Since(v.lastSuccess)
+	if v.lastSuccess.IsZero() || v.staleTTL <= 0 || age > v.staleTTL {
+		return nil, 0, false
+	}
+	return copyVolumes(v.lastVolumes), age, true
+}
+
+// copyVolumes returns shallow copies of volumes
+func copyVolumes(volumes []*discovery.VolumeInfo) []*discovery.VolumeInfo {
+	result := make([]*discovery.VolumeInfo, len(volumes))
+	for i, vol := range volumes {
+		c := *vol
+		result[i] = &c
+	}
+	return result
+}
+
+// Status returns the most recent run status of discovery and each collector
+func (v *VolumeCollector) Status() []CollectorStatus {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	names := make([]string, 0, len(v.collectors)+1)
+	names = append(names, "discovery")
+	for _, c := range v.collectors {
+		names = append(names, c.Name())
+	}
+
+	result := make([]CollectorStatus, 0, len(names))
+	for _, name := range names {
+		if s, ok := v.status[name]; ok {
+			result = append(result, *s)
+		} else {
+			result = append(result, CollectorStatus{Name: name})
+		}
+	}
+	return result
+}
+
+func (v *VolumeCollector) setStatus(name string, duration time.Duration, err error) {
+	s := &CollectorStatus{Name: name, Duration: duration, Time: time.Now()}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	v.mu.Lock()
+	v.status[name] = s
+	v.mu.Unlock()
+}
+
+// Register registers the collector into reg with every metric name prefixed
+// by prefix and the constant labels added, if any. A nil reg registers into
+// the default registry.
+func (v *VolumeCollector) Register(reg prometheus.Registerer, prefix string, labels prometheus.Labels) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(newWrappedCollector(v, prefix, labels))
+}
+
 // Describe implements prometheus.Collector
 func (v *VolumeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
 	ch <- volumesDiscoveredDesc
+	ch <- discoveryStaleDesc
+	ch <- discovererBreakerDesc
+	ch <- discovererFailuresDesc
+	ch <- discovererPVListDesc
+	ch <- discovererAccessDeniedDesc
+	ch <- discoveryNamespaceSuccessDesc
+	ch <- discoveryNamespaceDurationDesc
+	ch <- discoveryNamespacePodsDesc
+	ch <- discoveryVolumeChangesDesc
+	ch <- discoveryFieldChangesDesc
+	ch <- volumeScrapeErrorDesc
+	ch <- volumeCollectorSkippedDesc
+	ch <- cephOSDVolumeDesc
 }
 
 // Collect implements prometheus.Collector
@@ -71,37 +436,126 @@ func (v *VolumeCollector) Collect(ch chan<- prometheus.Metric) {
 	// Discover volumes
 	start := time.Now()
 	volumes, err := v.discoverer.Discover(ctx)
+	v.setStatus("discovery", time.Since(start), err)
 	duration := time.Since(start).Seconds()
 
+	for _, s := range v.discoverer.Status() {
+		for _, state := range breakerStates {
+			ch <- prometheus.MustNewConstMetric(discovererBreakerDesc, prometheus.GaugeValue, boolToFloat(s.Breaker == state), s.Name, state)
+		}
+		ch <- prometheus.MustNewConstMetric(discovererFailuresDesc, prometheus.GaugeValue, float64(s.Failures), s.Name)
+	}
+	for name, err := range v.discoverer.PVListStatus() {
+		ch <- prometheus.MustNewConstMetric(discovererPVListDesc, prometheus.GaugeValue, boolToFloat(err == nil), name)
+	}
+	for name, n := range v.discoverer.AccessDenied() {
+		ch <- prometheus.MustNewConstMetric(discovererAccessDeniedDesc, prometheus.GaugeValue, float64(n), name)
+	}
+	for _, s := range v.discoverer.NamespaceStatus() {
+		ch <- prometheus.MustNewConstMetric(discoveryNamespaceSuccessDesc, prometheus.GaugeValue, boolToFloat(s.Error == ""), s.Namespace)
+		ch <- prometheus.MustNewConstMetric(discoveryNamespaceDurationDesc, prometheus.GaugeValue, s.Duration.Seconds(), s.Namespace)
+		ch <- prometheus.MustNewConstMetric(discoveryNamespacePodsDesc, prometheus.GaugeValue, float64(s.Pods), s.Namespace)
+	}
+	changes, fields := v.discoverer.ChangeCounts()
+	for _, change := range []string{discovery.ChangeAdded, discovery.ChangeRemoved, discovery.ChangeChanged} {
+		ch <- prometheus.MustNewConstMetric(discoveryVolumeChangesDesc, prometheus.CounterValue, float64(changes[change]), change)
+	}
+	for field, n := range fields {
+		ch <- prometheus.MustNewConstMetric(discoveryFieldChangesDesc, prometheus.CounterValue, float64(n), field)
+	}
+
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration, "discovery")
 	if err != nil {
 		slog.Error("discovery error", "error", err)
 		ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 0, "discovery")
+	} else {
+		ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 1, "discovery")
+	}
+
+	// Serve the last good volumes through brief discovery outages rather
+	// than dropping every series
+	volumes, age, ok := v.volumesOrStale(volumes, err)
+	if !ok {
 		return
 	}
-	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 1, "discovery")
+	if err != nil {
+		slog.Warn("serving stale volumes", "age", age.Round(time.Second), "volumes", len(volumes))
+	} else {
+		v.saveState(volumes)
+	}
+	ch <- prometheus.MustNewConstMetric(discoveryStaleDesc, prometheus.GaugeValue, age.Seconds())
 	ch <- prometheus.MustNewConstMetric(volumesDiscoveredDesc, prometheus.GaugeValue, float64(len(volumes)))
 
+	// Parse diskstats once for device name resolution and every collector.
+	// Concurrent scrapes each take their own StatsMap from the pool.
+	stats := v.stats.Get().(*diskstats.StatsMap)
+	defer v.stats.Put(stats)
+	scrape := &Scrape{Errors: NewVolumeErrors()}
+	if err := diskstats.ParseIntoContext(ctx, v.procPath+"/diskstats", stats, diskstats.DefaultLimits); err != nil {
+		slog.Error("failed to parse diskstats", "error", err)
+	} else {
+		scrape.Diskstats = stats
+	}
+
 	// Resolve device names from diskstats before running collectors
-	v.resolveDeviceNames(volumes)
+	v.resolveDeviceNames(volumes, scrape.Diskstats)
+	if v.cephOSDs {
+		volumes = v.excludeCephOSDs(volumes, ch)
+	}
+	if v.omitPods {
+		volumes = withoutPodLabels(volumes)
+	}
+	scrape.Volumes = volumes
 
-	// Run collectors in parallel
+	// Run collectors in parallel, less any the policy disables
+	p := policy.Get(v.policy)
 	wg := sync.WaitGroup{}
-	wg.Add(len(v.collectors))
 
 	for _, c := range v.collectors {
+		if !p.CollectorEnabled(c.Name()) {
+			continue
+		}
+		wg.Add(1)
 		go func(c Collector) {
 			defer wg.Done()
-			v.execute(c, volumes, ch)
+			v.execute(c, scrape, ch)
 		}(c)
 	}
 
 	wg.Wait()
+	scrape.Errors.collect(ch)
+	sweepLabelPairs()
+
+	v.mu.Lock()
+	v.lastScrape = &LastScrape{Time: start, Volumes: volumes, Errors: scrape.Errors, Stale: age}
+	v.mu.Unlock()
 }
 
-func (v *VolumeCollector) execute(c Collector, volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) {
+// LastScrape is what the most recent scrape collected volumes for saw
+type LastScrape struct {
+	Time    time.Time
+	Volumes []*discovery.VolumeInfo // with device names resolved
+	Errors  *VolumeErrors           // volumes collectors failed on
+	Stale   time.Duration           // age of the volumes when discovery failed, else 0
+}
+
+// LastScrape returns the most recent scrape that collected volumes, or nil
+// before the first. Its volumes and errors must not be modified.
+func (v *VolumeCollector) LastScrape() *LastScrape {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.lastScrape
+}
+
+func (v *VolumeCollector) execute(c Collector, scrape *Scrape, ch chan<- prometheus.Metric) {
 	start := time.Now()
-	err := c.Update(volumes, ch)
+	var err error
+	if sc, ok := c.(ScrapeCollector); ok {
+		err = sc.UpdateScrape(scrape, ch)
+	} else {
+		err = c.Update(scrape.Volumes, ch)
+	}
+	v.setStatus(c.Name(), time.Since(start), err)
 	duration := time.Since(start).Seconds()
 
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration, c.Name())
@@ -114,11 +568,47 @@ func (v *VolumeCollector) execute(c Collector, volumes []*discovery.VolumeInfo,
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 1, c.Name())
 }
 
+// withoutPodLabels returns copies of PVC volumes with the primary pod's name
+// and namespace cleared. Pods is filled in, so collectors attributing to
+// individual pods still see them. Volumes without a PVC, such as hostPath and
+// CSI inline volumes, keep them: the pod is all that tells two of them on one
+// device apart.
+func withoutPodLabels(volumes []*discovery.VolumeInfo) []*discovery.VolumeInfo {
+	result := make([]*discovery.VolumeInfo, len(volumes))
+	for i, vol := range volumes {
+		if vol.PVCName == "" {
+			result[i] = vol
+			continue
+		}
+		c := *vol
+		c.Pods = volumePods(vol)
+		c.PodName, c.PodNamespace = "", ""
+		result[i] = &c
+	}
+	return result
+}
+
+// excludeCephOSDs returns volumes less those backing Ceph OSDs, emitting
+// ceph_osd_volume for each of them
+func (v *VolumeCollector) excludeCephOSDs(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) []*discovery.VolumeInfo {
+	result := make([]*discovery.VolumeInfo, 0, len(volumes))
+	for _, vol := range volumes {
+		// Rook names OSD deployments and their prepare jobs rook-ceph-osd-*
+		osd := strings.HasPrefix(vol.Workload, "rook-ceph-osd-") ||
+			(vol.DeviceName != "" && sysfs.CephOSDDevice(v.sysPath, vol.DeviceName))
+		if !osd {
+			result = append(result, vol)
+			continue
+		}
+		slog.Debug("excluding ceph osd volume", "pvc", vol.PVCNamespace+"/"+vol.PVCName, "device", vol.DeviceName, "workload", vol.Workload)
+		ch <- prometheus.MustNewConstMetric(cephOSDVolumeDesc, prometheus.GaugeValue, 1, vol.PVCName, vol.PVCNamespace, vol.PVName, vol.DeviceName)
+	}
+	return result
+}
+
 // resolveDeviceNames resolves device names from diskstats using device IDs
-func (v *VolumeCollector) resolveDeviceNames(volumes []*discovery.VolumeInfo) {
-	stats, err := diskstats.Parse(v.procPath + "/diskstats")
-	if err != nil {
-		slog.Error("failed to parse diskstats", "error", err)
+func (v *VolumeCollector) resolveDeviceNames(volumes []*discovery.VolumeInfo, stats *diskstats.StatsMap) {
+	if stats == nil {
 		return
 	}
 
@@ -0,0 +1,85 @@
+package collector_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/collector/collectortest"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+)
+
+// Concurrent scrapes through a discovery outage each resolve device names
+// in the stale volumes they serve, which must not be shared. Run with -race.
+func TestConcurrentStaleScrapes(t *testing.T) {
+	proc := t.TempDir()
+	stats := "   8      16 sdb 1 0 8 1 2 0 16 2 0 3 3 0 0 0 0 0 0\n" +
+		"   8      32 sdc 1 0 8 1 2 0 16 2 0 3 3 0 0 0 0 0 0\n"
+	if err := os.WriteFile(filepath.Join(proc, "diskstats"), []byte(stats), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := discoverytest.New("fake",
+		discoverytest.Volume("data-db-0", "db"),
+		discoverytest.Volume("logs", "web", discoverytest.WithDevice("sdc", "8:32")),
+	)
+	v := collector.NewVolumeCollector(discovery.NewMultiDiscoverer(d), proc, collector.NewDiskstatsCollector(proc, "", false))
+	v.SetStaleTTL(time.Hour)
+	if _, err := collectortest.GatherFrom(v); err != nil {
+		t.Fatal(err)
+	}
+	d.SetError(errors.New("api down"))
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				ms, err := collectortest.GatherFrom(v)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				collectortest.Expect(t, ms, "reads_completed_total", 1, "pvc", "logs", "device", "sdc")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Omitting pod labels must not merge the series of volumes without a PVC,
+// which only their pod tells apart: the registry would reject the duplicate
+// and fail the whole scrape
+func TestOmitPodLabelsWithoutPVC(t *testing.T) {
+	proc := t.TempDir()
+	stats := "   8      16 sdb 1 0 8 1 2 0 16 2 0 3 3 0 0 0 0 0 0\n"
+	if err := os.WriteFile(filepath.Join(proc, "diskstats"), []byte(stats), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Host directories of one disk, mounted at the same path by two pods
+	hostPath := func(pod, path string) *discovery.VolumeInfo {
+		vol := discoverytest.Volume("", "web", discoverytest.WithPod(pod, pod+"-uid"))
+		vol.PVName, vol.StorageClass = "", ""
+		vol.CSIDriver, vol.VolumeHandle = discovery.HostPathDriver, path
+		return vol
+	}
+	d := discoverytest.New("fake", hostPath("web-0", "/srv/a"), hostPath("web-1", "/srv/b"), discoverytest.Volume("data-db-0", "db"))
+	v := collector.NewVolumeCollector(discovery.NewMultiDiscoverer(d), proc, collector.NewDiskstatsCollector(proc, "", false))
+	v.SetOmitPodLabels(true)
+
+	ms, err := collectortest.GatherFrom(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range []string{"web-0", "web-1"} {
+		collectortest.Expect(t, ms, "reads_completed_total", 1, "namespace", "web", "pod", pod)
+	}
+	collectortest.Expect(t, ms, "reads_completed_total", 1, "pvc", "data-db-0", "pod", "")
+}
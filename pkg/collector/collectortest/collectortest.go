@@ -0,0 +1,185 @@
+// Package collectortest runs collectors against canned volumes and checks
+// the metrics they export, for testing collectors and embedders of volmetd
+// without a node.
+//
+//	ms, err := collectortest.Gather(collector.NewInfoCollector("/sys"), volumes)
+//	collectortest.Expect(t, ms, "volume_info", 1, "pvc", "data-db-0")
+//
+// Metric names are as the collectors define them, without the prefix the
+// exporter adds when registering.
+package collectortest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Sample is one exported series
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	// Value is the counter, gauge or untyped value, or the sample count of
+	// a histogram or summary
+	Value float64
+	Type  dto.MetricType
+
+	Metric *dto.Metric
+}
+
+func (s Sample) String() string {
+	pairs := make([]string, 0, len(s.Labels))
+	for k, v := range s.Labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("%s{%s} %g", s.Name, strings.Join(pairs, ","), s.Value)
+}
+
+// Metrics are the samples of one collection, sorted by name
+type Metrics []Sample
+
+// Gather runs c's Update once on volumes. Metrics sent before an error are
+// returned with it.
+func Gather(c collector.Collector, volumes []*discovery.VolumeInfo) (Metrics, error) {
+	var updateErr error
+	ms, err := GatherFrom(collectorFunc(func(ch chan<- prometheus.Metric) {
+		updateErr = c.Update(volumes, ch)
+	}))
+	if err != nil {
+		return ms, err
+	}
+	return ms, updateErr
+}
+
+// GatherFrom collects any Prometheus collector once, e.g. a whole
+// collector.VolumeCollector over a discoverytest.Discoverer
+func GatherFrom(c prometheus.Collector) (Metrics, error) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		return nil, err
+	}
+	families, err := reg.Gather()
+
+	var ms Metrics
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			s := Sample{
+				Name:   mf.GetName(),
+				Labels: make(map[string]string, len(m.GetLabel())),
+				Type:   mf.GetType(),
+				Metric: m,
+			}
+			for _, l := range m.GetLabel() {
+				s.Labels[l.GetName()] = l.GetValue()
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				s.Value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				s.Value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				s.Value = m.GetUntyped().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				s.Value = float64(m.GetHistogram().GetSampleCount())
+			case dto.MetricType_SUMMARY:
+				s.Value = float64(m.GetSummary().GetSampleCount())
+			}
+			ms = append(ms, s)
+		}
+	}
+	return ms, err
+}
+
+// Find returns the samples named name whose labels include labels, given
+// as name/value pairs
+func (ms Metrics) Find(name string, labels ...string) Metrics {
+	if len(labels)%2 != 0 {
+		panic("collectortest: odd number of label arguments")
+	}
+	var result Metrics
+	for _, s := range ms {
+		if s.Name == name && s.matches(labels) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (s Sample) matches(labels []string) bool {
+	for i := 0; i < len(labels); i += 2 {
+		if s.Labels[labels[i]] != labels[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+// Value returns the value of the single sample Find returns, and false if
+// there is none or more than one
+func (ms Metrics) Value(name string, labels ...string) (float64, bool) {
+	found := ms.Find(name, labels...)
+	if len(found) != 1 {
+		return 0, false
+	}
+	return found[0].Value, true
+}
+
+// Expect fails t unless exactly one sample named name with labels exists
+// and has value want
+func Expect(t testing.TB, ms Metrics, name string, want float64, labels ...string) {
+	t.Helper()
+	found := ms.Find(name, labels...)
+	switch {
+	case len(found) == 0:
+		t.Errorf("no %s%v sample, have:\n%s", name, labels, ms.names())
+	case len(found) > 1:
+		t.Errorf("%d %s%v samples, want 1:\n%s", len(found), name, labels, found)
+	case found[0].Value != want:
+		t.Errorf("%s = %g, want %g", found[0], found[0].Value, want)
+	}
+}
+
+// ExpectAbsent fails t if any sample named name with labels exists
+func ExpectAbsent(t testing.TB, ms Metrics, name string, labels ...string) {
+	t.Helper()
+	if found := ms.Find(name, labels...); len(found) > 0 {
+		t.Errorf("unexpected samples:\n%s", found)
+	}
+}
+
+func (ms Metrics) String() string {
+	lines := make([]string, len(ms))
+	for i, s := range ms {
+		lines[i] = s.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// names lists the distinct sample names, for failure messages
+func (ms Metrics) names() string {
+	var names []string
+	for _, s := range ms {
+		if len(names) == 0 || names[len(names)-1] != s.Name {
+			names = append(names, s.Name)
+		}
+	}
+	return strings.Join(names, "\n")
+}
+
+// collectorFunc is an unchecked prometheus.Collector, since Collectors
+// don't describe their metrics up front
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) {
+	f(ch)
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/cri"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// criTimeout bounds each call to the container runtime
+const criTimeout = 10 * time.Second
+
+var containerLayerLabels = []string{"namespace", "pod", "container"}
+
+var (
+	containerLayerBytesDesc = prometheus.NewDesc(
+		"container_writable_layer_bytes_used",
+		"Bytes used by a container's writable layer, as reported by the container runtime",
+		containerLayerLabels, nil,
+	)
+	containerLayerInodesDesc = prometheus.NewDesc(
+		"container_writable_layer_inodes_used",
+		"Inodes used by a container's writable layer, as reported by the container runtime",
+		containerLayerLabels, nil,
+	)
+	runtimeFSBytesDesc = prometheus.NewDesc(
+		"container_runtime_fs_bytes_used",
+		"Bytes used on the container runtime's filesystems: fs=image for images (and writable layers, unless split) and fs=container for writable layers on a separate filesystem",
+		[]string{"fs", "mountpoint"}, nil,
+	)
+	runtimeFSInodesDesc = prometheus.NewDesc(
+		"container_runtime_fs_inodes_used",
+		"Inodes used on the container runtime's filesystems",
+		[]string{"fs", "mountpoint"}, nil,
+	)
+)
+
+// ContainerLayerCollector attributes the container runtime's disk usage to
+// pods: the writable layer of each container, and the image filesystem as a
+// whole. Alongside the volume metrics this splits a node's disk pressure
+// into PVCs and container layers. Usage is read over the CRI socket, so any
+// CRI runtime (containerd, CRI-O) works.
+type ContainerLayerCollector struct {
+	client     *cri.Client
+	namespaces []string // empty = all namespaces
+}
+
+// NewContainerLayerCollector creates a collector reading the container
+// runtime through client, only reporting containers of pods in namespaces
+// if any are given
+func NewContainerLayerCollector(client *cri.Client, namespaces []string) *ContainerLayerCollector {
+	return &ContainerLayerCollector{client: client, namespaces: namespaces}
+}
+
+func (c *ContainerLayerCollector) Name() string {
+	return "containerlayer"
+}
+
+func (c *ContainerLayerCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), criTimeout)
+	defer cancel()
+
+	imageFS, containerFS, err := c.client.ImageFsInfo(ctx)
+	if err != nil {
+		return err
+	}
+	emitRuntimeFS(ch, "image", imageFS)
+	emitRuntimeFS(ch, "container", containerFS)
+
+	stats, err := c.client.ListContainerStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Runtimes keep exited containers until the kubelet removes them, so a
+	// restarted container can appear more than once; report the latest
+	type containerKey struct{ namespace, pod, container string }
+	latest := make(map[containerKey]*cri.ContainerStats)
+	for i := range stats {
+		s := &stats[i]
+		if s.Pod == "" || s.WritableLayer == nil {
+			// Not created by the kubelet, or no layer stats
+			continue
+		}
+		if len(c.namespaces) > 0 && !slices.Contains(c.namespaces, s.PodNamespace) {
+			continue
+		}
+		key := containerKey{s.PodNamespace, s.Pod, s.Name}
+		if prev, ok := latest[key]; !ok || s.Attempt > prev.Attempt {
+			latest[key] = s
+		}
+	}
+
+	for key, s := range latest {
+		layer := s.WritableLayer
+		if layer.HasBytes {
+			ch <- prometheus.MustNewConstMetric(containerLayerBytesDesc, prometheus.GaugeValue, float64(layer.UsedBytes), key.namespace, key.pod, key.container)
+		}
+		if layer.HasInodes {
+			ch <- prometheus.MustNewConstMetric(containerLayerInodesDesc, prometheus.GaugeValue, float64(layer.UsedInodes), key.namespace, key.pod, key.container)
+		}
+	}
+	return nil
+}
+
+func emitRuntimeFS(ch chan<- prometheus.Metric, fs string, usage []cri.FilesystemUsage) {
+	seen := make(map[string]bool)
+	for _, u := range usage {
+		if seen[u.Mountpoint] {
+			continue
+		}
+		seen[u.Mountpoint] = true
+		if u.HasBytes {
+			ch <- prometheus.MustNewConstMetric(runtimeFSBytesDesc, prometheus.GaugeValue, float64(u.UsedBytes), fs, u.Mountpoint)
+		}
+		if u.HasInodes {
+			ch <- prometheus.MustNewConstMetric(runtimeFSInodesDesc, prometheus.GaugeValue, float64(u.UsedInodes), fs, u.Mountpoint)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/csi"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+var csiStatsMetrics = MetricSet[*csi.VolumeStats]{
+	Gauge("csi_capacity_bytes_total", "Total capacity in bytes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.TotalBytes) }),
+	Gauge("csi_capacity_bytes_used", "Used capacity in bytes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.UsedBytes) }),
+	Gauge("csi_capacity_bytes_free", "Available capacity in bytes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.AvailableBytes) }),
+}
+
+var csiInodeMetrics = MetricSet[*csi.VolumeStats]{
+	Gauge("csi_capacity_inodes_total", "Total number of inodes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.TotalInodes) }),
+	Gauge("csi_capacity_inodes_used", "Used number of inodes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.UsedInodes) }),
+	Gauge("csi_capacity_inodes_free", "Available number of inodes reported by the CSI driver", volumeLabels_, func(s *csi.VolumeStats) float64 { return float64(s.AvailableInodes) }),
+}
+
+var csiVolumeAbnormalDesc = prometheus.NewDesc(
+	"csi_volume_condition_abnormal",
+	"Whether the CSI driver reports the volume condition as abnormal",
+	volumeLabels_, nil,
+)
+
+// csiStatsTimeout bounds each NodeGetVolumeStats call
+const csiStatsTimeout = 5 * time.Second
+
+// csiStatsInterval is how often drivers are asked by default; network
+// storage drivers may query their backend for each call
+const csiStatsInterval = time.Minute
+
+// CSIStatsCollector collects driver-reported usage and volume condition by
+// calling NodeGetVolumeStats on each CSI driver's node plugin socket. Some
+// drivers (e.g. SMB, object-backed) only report usage this way.
+type CSIStatsCollector struct {
+	registry        *csi.Registry
+	kubeletPath     string // kubelet path as seen by volmetd
+	hostKubeletPath string // kubelet path as seen by the host and the driver
+}
+
+// NewCSIStatsCollector creates a new CSI stats collector for plugin sockets
+// beneath <kubeletPath>/plugins. Volume paths are rewritten from kubeletPath
+// to hostKubeletPath before being passed to the driver.
+func NewCSIStatsCollector(kubeletPath, hostKubeletPath string) *CSIStatsCollector {
+	if kubeletPath == "" {
+		kubeletPath = "/var/lib/kubelet"
+	}
+	if hostKubeletPath == "" {
+		hostKubeletPath = "/var/lib/kubelet"
+	}
+	return &CSIStatsCollector{
+		registry:        csi.NewRegistry(kubelet.Standard.PluginsDir(kubeletPath)),
+		kubeletPath:     kubeletPath,
+		hostKubeletPath: hostKubeletPath,
+	}
+}
+
+// SetLayout finds plugin sockets in l's plugins directory beneath the kubelet
+// path, by default kubelet.Standard's. It must be called before Update.
+func (c *CSIStatsCollector) SetLayout(l kubelet.Layout) {
+	c.registry = csi.NewRegistry(l.PluginsDir(c.kubeletPath))
+}
+
+func (c *CSIStatsCollector) Name() string {
+	return "csistats"
+}
+
+func (c *CSIStatsCollector) Interval() time.Duration {
+	return csiStatsInterval
+}
+
+func (c *CSIStatsCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	return c.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape collects CSI volume stats, reporting volumes whose
+// NodeGetVolumeStats call fails to the scrape's errors
+func (c *CSIStatsCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	volumes, errs := scrape.Volumes, scrape.Errors
+	var wg sync.WaitGroup
+	for _, vol := range volumes {
+		if vol.CSIDriver == "" || vol.VolumeHandle == "" || vol.MountPath == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(vol *discovery.VolumeInfo) {
+			defer wg.Done()
+			if err := c.collectVolume(vol, ch); err != nil {
+				errs.Add(c.Name(), vol, err)
+			}
+		}(vol)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (c *CSIStatsCollector) collectVolume(vol *discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), csiStatsTimeout)
+	defer cancel()
+
+	plugin, err := c.registry.Plugin(ctx, vol.CSIDriver)
+	if err != nil {
+		// Drivers without a node socket on this node aren't an error
+		return nil
+	}
+
+	ok, err := plugin.HasCapability(ctx, csipb.NodeServiceCapability_RPC_GET_VOLUME_STATS)
+	if err != nil || !ok {
+		return err
+	}
+
+	stats, err := plugin.NodeGetVolumeStats(ctx, vol.VolumeHandle, c.hostPath(vol.MountPath))
+	if err != nil {
+		return err
+	}
+
+	labels := volumeLabels(vol)
+	if stats.HasBytes {
+		csiStatsMetrics.Collect(stats, labels, ch)
+	}
+	if stats.HasInodes {
+		csiInodeMetrics.Collect(stats, labels, ch)
+	}
+	if stats.Condition != nil {
+		ch <- prometheus.MustNewConstMetric(csiVolumeAbnormalDesc, prometheus.GaugeValue, boolToFloat(stats.Condition.GetAbnormal()), labels...)
+		ch <- volumeAbnormalMetric(vol, HealthSourceCSI, stats.Condition.GetAbnormal(), stats.Condition.GetMessage())
+	}
+	return nil
+}
+
+// hostPath rewrites a kubelet path as seen by volmetd to the host's
+func (c *CSIStatsCollector) hostPath(mountPath string) string {
+	return mounts.RebasePath(mountPath, c.kubeletPath, c.hostKubeletPath)
+}
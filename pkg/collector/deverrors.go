@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/nvme"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+// Kinds of device error counted by DeviceErrorsCollector
+const (
+	DeviceErrorSCSIIO      = "scsi_io"        // SCSI commands completed with an error (ioerr_cnt)
+	DeviceErrorSCSITimeout = "scsi_timeout"   // SCSI commands that timed out (iotmo_cnt)
+	DeviceErrorNVMeMedia   = "nvme_media"     // unrecovered NVMe data integrity errors
+	DeviceErrorNVMeLog     = "nvme_error_log" // NVMe error information log entries
+)
+
+var deviceErrorsDesc = prometheus.NewDesc(
+	"device_errors_total",
+	"Errors the kernel or the drive counted on the volume's device: SCSI I/O errors and timeouts, NVMe media errors and error log entries",
+	append(append([]string{}, volumeLabels_...), "kind"), nil,
+)
+
+var nvmeCriticalWarningDesc = prometheus.NewDesc(
+	"device_nvme_critical_warning",
+	"Critical warning bits of the NVMe SMART log of the volume's device (spare, temperature, reliability, read-only, backup), 0 when healthy",
+	volumeLabels_, nil,
+)
+
+// smartLogInterval is how often an NVMe device's SMART log is re-read
+const smartLogInterval = time.Minute
+
+// DeviceErrorsCollector exports the error counters of each volume's device,
+// from the SCSI mid-layer in sysfs and from the SMART log of NVMe drives, so
+// media and transport errors show up next to the PVC instead of only in dmesg
+type DeviceErrorsCollector struct {
+	sysPath string
+	devPath string
+
+	mu    sync.Mutex
+	smart map[string]*smartResult // keyed by device name
+}
+
+type smartResult struct {
+	log  *nvme.SMARTLog
+	time time.Time
+}
+
+// NewDeviceErrorsCollector creates a new device errors collector. NVMe SMART
+// logs are read from the device nodes beneath devPath, which needs
+// CAP_SYS_ADMIN; without it only SCSI counters are exported.
+func NewDeviceErrorsCollector(sysPath, devPath string) *DeviceErrorsCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if devPath == "" {
+		devPath = "/dev"
+	}
+	return &DeviceErrorsCollector{
+		sysPath: sysPath,
+		devPath: devPath,
+		smart:   make(map[string]*smartResult),
+	}
+}
+
+func (c *DeviceErrorsCollector) Name() string {
+	return "device_errors"
+}
+
+func (c *DeviceErrorsCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	smart := make(map[string]*smartResult)
+	for _, vol := range volumes {
+		if vol.DeviceName == "" {
+			continue
+		}
+		labels := volumeLabels(vol)
+
+		dev := vol.DeviceName
+		if parent, ok := sysfs.ParentDevice(c.sysPath, dev); ok {
+			dev = parent
+		}
+		if strings.HasPrefix(dev, "nvme") {
+			r := c.smartLog(dev)
+			if r == nil {
+				continue
+			}
+			smart[dev] = r
+			ch <- prometheus.MustNewConstMetric(deviceErrorsDesc, prometheus.CounterValue, float64(r.log.MediaErrors), append(labels, DeviceErrorNVMeMedia)...)
+			ch <- prometheus.MustNewConstMetric(deviceErrorsDesc, prometheus.CounterValue, float64(r.log.ErrorLogEntries), append(labels, DeviceErrorNVMeLog)...)
+			ch <- prometheus.MustNewConstMetric(nvmeCriticalWarningDesc, prometheus.GaugeValue, float64(r.log.CriticalWarning), labels...)
+			continue
+		}
+
+		counters, err := sysfs.ReadSCSICounters(c.sysPath, dev)
+		if err != nil {
+			// Not a SCSI disk, e.g. virtio-blk or device-mapper
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(deviceErrorsDesc, prometheus.CounterValue, float64(counters.IOErrors), append(labels, DeviceErrorSCSIIO)...)
+		ch <- prometheus.MustNewConstMetric(deviceErrorsDesc, prometheus.CounterValue, float64(counters.IOTimeouts), append(labels, DeviceErrorSCSITimeout)...)
+	}
+	c.smart = smart
+
+	return nil
+}
+
+// smartLog returns the device's cached SMART log, re-reading it once
+// smartLogInterval has passed. Must be called with c.mu held.
+func (c *DeviceErrorsCollector) smartLog(dev string) *smartResult {
+	if r := c.smart[dev]; r != nil && time.Since(r.time) < smartLogInterval {
+		return r
+	}
+	log, err := nvme.ReadSMARTLog(filepath.Join(c.devPath, dev))
+	if err != nil {
+		slog.Debug("device errors: nvme smart log failed", "device", dev, "error", err)
+		return nil
+	}
+	return &smartResult{log: log, time: time.Now()}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var (
+	discoveryCompareVolumesDesc = prometheus.NewDesc(
+		"discovery_compare_volumes",
+		"Volumes found by a discoverer run on its own for comparison",
+		[]string{"discoverer"}, nil,
+	)
+	discoveryCompareMissingDesc = prometheus.NewDesc(
+		"discovery_compare_missing_volumes",
+		"Volumes the other compared discoverer found but this one did not",
+		[]string{"discoverer"}, nil,
+	)
+	discoveryCompareDeviceConflictsDesc = prometheus.NewDesc(
+		"discovery_compare_device_conflicts",
+		"Volumes both compared discoverers found but mapped to different devices",
+		nil, nil,
+	)
+)
+
+// Comparing runs both discoverers in full, the API one listing pods and PVs
+const (
+	discoveryCompareInterval = 5 * time.Minute
+	discoveryCompareTimeout  = time.Minute
+)
+
+// DiscoveryCompareCollector is a sanity check of discovery: it runs two
+// discoverers, normally csi and k8sapi, independently of the merged
+// discovery and reports how their volumes disagree. Both should find the
+// same PVCs on the same devices; a discoverer falling behind hints at a
+// kubelet layout change (csi) or RBAC drift (k8sapi) before volumes go
+// missing from the merged results.
+type DiscoveryCompareCollector struct {
+	first, second discovery.Discoverer
+}
+
+// NewDiscoveryCompareCollector creates a collector comparing the volumes of
+// two discoverers. They should be their own instances, not those of the
+// merged discovery, whose state they would share.
+func NewDiscoveryCompareCollector(first, second discovery.Discoverer) *DiscoveryCompareCollector {
+	return &DiscoveryCompareCollector{first: first, second: second}
+}
+
+func (c *DiscoveryCompareCollector) Name() string {
+	return "discoverycompare"
+}
+
+func (c *DiscoveryCompareCollector) Interval() time.Duration {
+	return discoveryCompareInterval
+}
+
+func (c *DiscoveryCompareCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryCompareTimeout)
+	defer cancel()
+
+	first, err := c.first.Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.first.Name(), err)
+	}
+	second, err := c.second.Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.second.Name(), err)
+	}
+
+	d := discovery.CompareVolumes(first, second)
+	ch <- prometheus.MustNewConstMetric(discoveryCompareVolumesDesc, prometheus.GaugeValue, float64(d.Both+d.OnlyFirst), c.first.Name())
+	ch <- prometheus.MustNewConstMetric(discoveryCompareVolumesDesc, prometheus.GaugeValue, float64(d.Both+d.OnlySecond), c.second.Name())
+	ch <- prometheus.MustNewConstMetric(discoveryCompareMissingDesc, prometheus.GaugeValue, float64(d.OnlySecond), c.first.Name())
+	ch <- prometheus.MustNewConstMetric(discoveryCompareMissingDesc, prometheus.GaugeValue, float64(d.OnlyFirst), c.second.Name())
+	ch <- prometheus.MustNewConstMetric(discoveryCompareDeviceConflictsDesc, prometheus.GaugeValue, float64(d.DeviceConflicts))
+	return nil
+}
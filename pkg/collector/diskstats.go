@@ -1,12 +1,19 @@
 package collector
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/gfx-labs/volmetd/pkg/discovery"
 	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
 )
 
 var volumeLabels_ = []string{
@@ -22,46 +29,205 @@ var volumeLabels_ = []string{
 	"mount_path",
 }
 
-var diskstatsMetrics = MetricSet[*diskstats.Stats]{
-	// Reads
-	Counter("reads_completed_total", "Total number of reads completed successfully", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.ReadsCompleted) }),
-	Counter("reads_merged_total", "Total number of reads merged", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.ReadsMerged) }),
-	Counter("read_bytes_total", "Total number of bytes read", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.ReadBytesTotal()) }),
-	Counter("read_time_seconds_total", "Total time spent reading in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.ReadTimeMs) / 1000 }),
+// Device roles for the device_role label. Series of the volume's own device
+// carry no device_role, so they keep their identity whether or not rollups
+// are enabled; the others are labeled with their role.
+const (
+	DeviceRoleVolume  = "volume"  // the device backing the volume's mount
+	DeviceRoleParent  = "parent"  // the whole disk containing a partition-backed volume
+	DeviceRoleBacking = "backing" // a device beneath a dm-crypt volume, holding the ciphertext
+	DeviceRoleNode    = "node"    // a device of the node backing no volume, see SetAllDevices
+)
+
+// deviceLabels_ label the series of devices other than the volume's own
+var deviceLabels_ = []string{
+	"device",
+	"csi_device",
+	"pvc",
+	"namespace",
+	"pv",
+	"pod",
+	"pod_namespace",
+	"storage_class",
+	"csi_driver",
+	"mount_path",
+	"device_role",
+}
+
+var (
+	diskstatsMetrics     = diskstatsMetricSet("", volumeLabels_)
+	roleDiskstatsMetrics = diskstatsMetricSet("", deviceLabels_)
+)
+
+// deviceMetricLabels label the device_* family, which carries no pod labels
+// so its series survive pod rescheduling like the device counters do
+var deviceMetricLabels = []string{"device", "pv"}
+
+var deviceDiskstatsMetrics = diskstatsMetricSet("device_", deviceMetricLabels)
+
+// diskstatsMetricSet returns the diskstats metrics named with prefix and
+// labelled with labels
+func diskstatsMetricSet(prefix string, labels []string) MetricSet[*diskstats.Stats] {
+	return MetricSet[*diskstats.Stats]{
+		// Reads
+		Counter(prefix+"reads_completed_total", "Total number of reads completed successfully", labels, func(s *diskstats.Stats) float64 { return float64(s.ReadsCompleted) }),
+		Counter(prefix+"reads_merged_total", "Total number of reads merged", labels, func(s *diskstats.Stats) float64 { return float64(s.ReadsMerged) }),
+		Counter(prefix+"read_bytes_total", "Total number of bytes read", labels, func(s *diskstats.Stats) float64 { return float64(s.ReadBytesTotal()) }),
+		Counter(prefix+"read_time_seconds_total", "Total time spent reading in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.ReadTimeMs) / 1000 }),
+
+		// Writes
+		Counter(prefix+"writes_completed_total", "Total number of writes completed successfully", labels, func(s *diskstats.Stats) float64 { return float64(s.WritesCompleted) }),
+		Counter(prefix+"writes_merged_total", "Total number of writes merged", labels, func(s *diskstats.Stats) float64 { return float64(s.WritesMerged) }),
+		Counter(prefix+"write_bytes_total", "Total number of bytes written", labels, func(s *diskstats.Stats) float64 { return float64(s.WriteBytesTotal()) }),
+		Counter(prefix+"write_time_seconds_total", "Total time spent writing in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.WriteTimeMs) / 1000 }),
+
+		// I/O
+		Gauge(prefix+"io_in_progress", "Number of I/O operations currently in progress", labels, func(s *diskstats.Stats) float64 { return float64(s.IOInProgress) }),
+		Counter(prefix+"io_time_seconds_total", "Total time spent doing I/O in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.IOTimeMs) / 1000 }),
+		Counter(prefix+"weighted_io_time_seconds_total", "Weighted time spent doing I/O in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.WeightedIOTimeMs) / 1000 }),
+
+		// Discards
+		Counter(prefix+"discards_completed_total", "Total number of discards completed successfully", labels, func(s *diskstats.Stats) float64 { return float64(s.DiscardsCompleted) }),
+		Counter(prefix+"discards_merged_total", "Total number of discards merged", labels, func(s *diskstats.Stats) float64 { return float64(s.DiscardsMerged) }),
+		Counter(prefix+"discard_bytes_total", "Total number of bytes discarded", labels, func(s *diskstats.Stats) float64 { return float64(s.SectorsDiscarded * 512) }),
+		Counter(prefix+"discard_time_seconds_total", "Total time spent discarding in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.DiscardTimeMs) / 1000 }),
+
+		// Flushes
+		Counter(prefix+"flushes_completed_total", "Total number of flushes completed successfully", labels, func(s *diskstats.Stats) float64 { return float64(s.FlushCompleted) }),
+		Counter(prefix+"flush_time_seconds_total", "Total time spent flushing in seconds", labels, func(s *diskstats.Stats) float64 { return float64(s.FlushTimeMs) / 1000 }),
+	}
+}
+
+// deviceRates holds iostat-style values derived from two consecutive samples
+// taken by Run
+type deviceRates struct {
+	Utilization float64 // percent of wall time the device was busy
+	QueueDepth  float64 // average number of requests in flight
+	IOPS        float64 // reads and writes completed per second
+	Throughput  float64 // bytes read and written per second
+}
 
-	// Writes
-	Counter("writes_completed_total", "Total number of writes completed successfully", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.WritesCompleted) }),
-	Counter("writes_merged_total", "Total number of writes merged", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.WritesMerged) }),
-	Counter("write_bytes_total", "Total number of bytes written", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.WriteBytesTotal()) }),
-	Counter("write_time_seconds_total", "Total time spent writing in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.WriteTimeMs) / 1000 }),
+var (
+	deviceRateMetrics     = deviceRateMetricSet(volumeLabels_)
+	roleDeviceRateMetrics = deviceRateMetricSet(deviceLabels_)
+)
 
-	// I/O
-	Gauge("io_in_progress", "Number of I/O operations currently in progress", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.IOInProgress) }),
-	Counter("io_time_seconds_total", "Total time spent doing I/O in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.IOTimeMs) / 1000 }),
-	Counter("weighted_io_time_seconds_total", "Weighted time spent doing I/O in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.WeightedIOTimeMs) / 1000 }),
+func deviceRateMetricSet(labels []string) MetricSet[*deviceRates] {
+	return MetricSet[*deviceRates]{
+		Gauge("device_utilization", "Percentage of time the device was busy with I/O over the latest rate interval", labels, func(r *deviceRates) float64 { return r.Utilization }),
+		Gauge("avg_queue_depth", "Average I/O queue depth over the latest rate interval", labels, func(r *deviceRates) float64 { return r.QueueDepth }),
+	}
+}
 
-	// Discards
-	Counter("discards_completed_total", "Total number of discards completed successfully", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.DiscardsCompleted) }),
-	Counter("discards_merged_total", "Total number of discards merged", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.DiscardsMerged) }),
-	Counter("discard_bytes_total", "Total number of bytes discarded", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.SectorsDiscarded * 512) }),
-	Counter("discard_time_seconds_total", "Total time spent discarding in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.DiscardTimeMs) / 1000 }),
+var (
+	nodeVolumeIOPSDesc = prometheus.NewDesc(
+		"node_volume_iops",
+		"Distribution of per-volume IOPS over each rate interval across the node's volume devices",
+		nil, nil,
+	)
+	nodeVolumeThroughputDesc = prometheus.NewDesc(
+		"node_volume_throughput_bytes_per_second",
+		"Distribution of per-volume throughput over each rate interval across the node's volume devices",
+		nil, nil,
+	)
 
-	// Flushes
-	Counter("flushes_completed_total", "Total number of flushes completed successfully", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.FlushCompleted) }),
-	Counter("flush_time_seconds_total", "Total time spent flushing in seconds", volumeLabels_, func(s *diskstats.Stats) float64 { return float64(s.FlushTimeMs) / 1000 }),
+	nodeVolumeIOPSBuckets       = []float64{1, 10, 50, 100, 500, 1000, 5000, 10000, 50000, 100000}
+	nodeVolumeThroughputBuckets = prometheus.ExponentialBuckets(1024, 4, 12) // 1KiB to 4GiB
+)
+
+// DefaultRateInterval is how often Run samples diskstats for
+// device_utilization and avg_queue_depth by default
+const DefaultRateInterval = 15 * time.Second
+
+// deviceSample is Run's previous sample of a device's counters
+type deviceSample struct {
+	ioTimeMs         uint64
+	weightedIOTimeMs uint64
+	ios              uint64
+	bytes            uint64
+	time             time.Time
 }
 
 // DiskstatsCollector collects disk I/O metrics from /proc/diskstats
 type DiskstatsCollector struct {
-	procPath string
+	procPath     string
+	sysPath      string
+	parentRollup bool // also emit stats for the parent disk of partitions
+	deviceFamily bool // also emit device_* without pod labels
+	allDevices   bool // also emit stats for devices backing no volume
+	devices      *diskstats.DeviceFilter
+
+	resets *counterResets
+
+	rateInterval time.Duration
+	mu           sync.Mutex
+	prev         map[string]deviceSample // keyed by device name
+	rates        map[string]*deviceRates // of Run's latest interval, replaced whole
+
+	// Run observes the rates of the latest scrape's volume devices
+	volumeDevices map[string]bool
+	iops          *constHistogram
+	throughput    *constHistogram
 }
 
-// NewDiskstatsCollector creates a new diskstats collector
-func NewDiskstatsCollector(procPath string) *DiskstatsCollector {
+// NewDiskstatsCollector creates a new diskstats collector. If parentRollup is
+// set, partition-backed volumes also get stats for their whole disk.
+func NewDiskstatsCollector(procPath, sysPath string, parentRollup bool) *DiskstatsCollector {
 	if procPath == "" {
 		procPath = "/proc"
 	}
-	return &DiskstatsCollector{procPath: procPath}
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &DiskstatsCollector{
+		procPath:     procPath,
+		sysPath:      sysPath,
+		parentRollup: parentRollup,
+		resets:       newCounterResets(),
+		rateInterval: DefaultRateInterval,
+		prev:         make(map[string]deviceSample),
+		iops:         newConstHistogram(nodeVolumeIOPSBuckets),
+		throughput:   newConstHistogram(nodeVolumeThroughputBuckets),
+	}
+}
+
+// SetRateInterval sets how often Run samples diskstats for the rates, by
+// default DefaultRateInterval. It must be called before Run.
+func (d *DiskstatsCollector) SetRateInterval(interval time.Duration) {
+	if interval > 0 {
+		d.rateInterval = interval
+	}
+}
+
+// SetDeviceMetrics enables the device_* family: each volume device's
+// diskstats labelled by device and pv only, so rate() over them isn't broken
+// up when the consuming pod is rescheduled
+func (d *DiskstatsCollector) SetDeviceMetrics(enabled bool) {
+	d.deviceFamily = enabled
+}
+
+// SetClampCounterResets keeps the exported diskstats counters from going
+// backwards when a device's counters reset: they hold their previous value
+// and carry on from there, so rate() doesn't read a reset as a burst of I/O.
+// Resets are counted in counter_resets_total either way.
+func (d *DiskstatsCollector) SetClampCounterResets(enabled bool) {
+	d.resets.clamp = enabled
+}
+
+// SetAllDevices also emits diskstats for every block device of the node
+// backing no volume, with empty volume labels and device_role="node", so
+// volmetd can replace node_exporter's diskstats collector
+func (d *DiskstatsCollector) SetAllDevices(enabled bool) {
+	d.allDevices = enabled
+}
+
+// SetDeviceFilter only emits diskstats for devices the filter allows, be
+// they volume devices, their parents and backing devices, or node devices.
+// A volume on an excluded device, e.g. a loop device, gets no diskstats and
+// isn't reported as an error.
+func (d *DiskstatsCollector) SetDeviceFilter(f *diskstats.DeviceFilter) {
+	d.devices = f
+	d.resets.filter = f
 }
 
 func (d *DiskstatsCollector) Name() string {
@@ -69,45 +235,251 @@ func (d *DiskstatsCollector) Name() string {
 }
 
 func (d *DiskstatsCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
-	stats, err := diskstats.Parse(d.procPath + "/diskstats")
-	if err != nil {
-		return err
+	return d.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape collects diskstats from the scrape's parsed diskstats, reading
+// them itself if there are none, and reports block-backed volumes without a
+// diskstats row to the scrape's errors
+func (d *DiskstatsCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	volumes, errs := scrape.Volumes, scrape.Errors
+	stats := scrape.Diskstats
+	if stats == nil {
+		var err error
+		if stats, err = diskstats.Parse(d.procPath + "/diskstats"); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	devices := make(map[string]bool, len(volumes))
+	for _, vol := range volumes {
+		if vol.DeviceName != "" {
+			devices[vol.DeviceName] = true
+		}
 	}
+	d.mu.Lock()
+	rates := d.rates
+	d.volumeDevices = devices
+	d.mu.Unlock()
+	d.resets.update(stats, now)
 
+	seen := make(map[[2]string]bool) // device_* series emitted, by device and pv
+	used := make(map[string]bool)    // devices emitted for a volume, by name
 	wg := sync.WaitGroup{}
 	for _, vol := range volumes {
+		// Only block volumes have diskstats; unknown backends are tried
+		if b := vol.Backend(); b != discovery.BackendBlock && b != "" {
+			errs.Skip(d.Name(), vol)
+			continue
+		}
+
 		// Device name should already be resolved by VolumeCollector
 		if vol.DeviceName == "" {
+			// Network and virtual filesystems have anonymous (major 0)
+			// devices and no diskstats row; a block device should have one
+			switch {
+			case vol.DeviceErr != nil:
+				errs.Add(d.Name(), vol, vol.DeviceErr)
+			case vol.DeviceID != "" && !strings.HasPrefix(vol.DeviceID, "0:"):
+				errs.Add(d.Name(), vol, fmt.Errorf("device %s: %w", vol.DeviceID, diskstats.ErrNoDiskstats))
+			}
+			continue
+		}
+		if !d.devices.Allows(vol.DeviceName) {
 			continue
 		}
 
 		s, ok := stats.ByName[vol.DeviceName]
-		if !ok {
+		if ok && d.deviceFamily {
+			key := [2]string{s.DeviceName, vol.PVName}
+			if !seen[key] {
+				seen[key] = true
+				deviceDiskstatsMetrics.Collect(d.resets.adjust(s), key[:], ch)
+			}
+		}
+
+		// Partitions roll up to their parent disk when enabled, or when
+		// diskstats only has the whole disk
+		var parent *diskstats.Stats
+		if name, isPart := sysfs.ParentDevice(d.sysPath, vol.DeviceName); isPart && (d.parentRollup || !ok) && d.devices.Allows(name) {
+			parent = stats.ByName[name]
+		}
+
+		// dm-crypt volumes also get stats for the encrypted devices beneath
+		var backing []*diskstats.Stats
+		if encrypted, names := sysfs.DMCrypt(d.sysPath, vol.DeviceName); encrypted {
+			for _, name := range names {
+				if bs, ok := stats.ByName[name]; ok && d.devices.Allows(name) {
+					backing = append(backing, bs)
+				}
+			}
+		}
+
+		if !ok && parent == nil && len(backing) == 0 {
+			// A device name guessed from the mount's device path when the
+			// device ID couldn't be resolved is the likelier culprit
+			if vol.DeviceErr != nil {
+				errs.Add(d.Name(), vol, vol.DeviceErr)
+			} else {
+				errs.Add(d.Name(), vol, fmt.Errorf("device %s: %w", vol.DeviceName, diskstats.ErrNoDiskstats))
+			}
 			continue
 		}
 
+		if d.allDevices {
+			used[vol.DeviceName] = true
+			if parent != nil {
+				used[parent.DeviceName] = true
+			}
+			for _, bs := range backing {
+				used[bs.DeviceName] = true
+			}
+		}
+
 		wg.Add(1)
-		go func(vol *discovery.VolumeInfo, s *diskstats.Stats) {
+		go func(vol *discovery.VolumeInfo, s, parent *diskstats.Stats, backing []*diskstats.Stats) {
 			defer wg.Done()
-			diskstatsMetrics.Collect(s, volumeLabels(vol), ch)
-		}(vol, s)
+			if s != nil {
+				d.collectDevice(vol, s, DeviceRoleVolume, rates, ch)
+			}
+			if parent != nil {
+				d.collectDevice(vol, parent, DeviceRoleParent, rates, ch)
+			}
+			for _, bs := range backing {
+				d.collectDevice(vol, bs, DeviceRoleBacking, rates, ch)
+			}
+		}(vol, s, parent, backing)
 	}
 	wg.Wait()
 
+	if d.allDevices {
+		node := &discovery.VolumeInfo{}
+		for name, s := range stats.ByName {
+			if !used[name] && d.devices.Allows(name) {
+				d.collectDevice(node, s, DeviceRoleNode, rates, ch)
+			}
+		}
+	}
+
+	d.collectNodeDistribution(ch)
+	d.resets.collect(volumes, ch)
+
 	return nil
 }
 
-func volumeLabels(vol *discovery.VolumeInfo) []string {
-	return []string{
-		vol.DeviceName,
-		vol.CSIDevicePath,
-		vol.PVCName,
-		vol.PVCNamespace,
-		vol.PVName,
-		vol.PodName,
-		vol.PodNamespace,
-		vol.StorageClass,
-		vol.CSIDriver,
-		vol.ContainerMountPath,
+// collectNodeDistribution emits histograms of IOPS and throughput across the
+// node's volume devices, so skew (one PVC doing most of a node's I/O) is
+// visible without aggregating every per-volume series. Each rate interval
+// Run observes every volume device once, so the counts grow with time, not
+// with the number of scrapes.
+func (d *DiskstatsCollector) collectNodeDistribution(ch chan<- prometheus.Metric) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch <- prometheus.MustNewConstHistogram(nodeVolumeIOPSDesc, d.iops.count, d.iops.sum, d.iops.snapshot())
+	ch <- prometheus.MustNewConstHistogram(nodeVolumeThroughputDesc, d.throughput.count, d.throughput.sum, d.throughput.snapshot())
+}
+
+func (d *DiskstatsCollector) collectDevice(vol *discovery.VolumeInfo, s *diskstats.Stats, role string, rates map[string]*deviceRates, ch chan<- prometheus.Metric) {
+	stats, rateMetrics := diskstatsMetrics, deviceRateMetrics
+	if role != DeviceRoleVolume {
+		stats, rateMetrics = roleDiskstatsMetrics, roleDeviceRateMetrics
+	}
+	labels := deviceLabels(vol, s.DeviceName, role)
+	stats.Collect(d.resets.adjust(s), labels, ch)
+	if r, ok := rates[s.DeviceName]; ok {
+		rateMetrics.Collect(r, labels, ch)
+	}
+}
+
+// Run samples diskstats every rate interval until ctx is cancelled, deriving
+// the rates scrapes export. Rates over a fixed interval rather than since
+// the previous scrape mean the same whatever scrapes the exporter, be it one
+// Prometheus, an HA pair or several endpoints. There are none until the
+// second sample.
+func (d *DiskstatsCollector) Run(ctx context.Context) {
+	stats := diskstats.NewStatsMap()
+	defer stats.Close()
+
+	ticker := time.NewTicker(d.rateInterval)
+	defer ticker.Stop()
+	for {
+		if err := diskstats.ParseIntoContext(ctx, filepath.Join(d.procPath, "diskstats"), stats, diskstats.DefaultLimits); err != nil {
+			slog.Debug("diskstats: rate sample", "error", err)
+		} else {
+			d.updateRates(stats, time.Now())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateRates derives utilization and queue depth for every device from the
+// delta against the previous sample, then stores the current sample, and
+// observes the IOPS and throughput of the volume devices. Devices without a
+// previous sample or whose counters went backwards are skipped.
+func (d *DiskstatsCollector) updateRates(stats *diskstats.StatsMap, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rates := make(map[string]*deviceRates, len(stats.ByName))
+	next := make(map[string]deviceSample, len(stats.ByName))
+
+	for name, s := range stats.ByName {
+		cur := deviceSample{
+			ioTimeMs:         s.IOTimeMs,
+			weightedIOTimeMs: s.WeightedIOTimeMs,
+			ios:              s.ReadsCompleted + s.WritesCompleted,
+			bytes:            s.ReadBytesTotal() + s.WriteBytesTotal(),
+			time:             now,
+		}
+		next[name] = cur
+
+		prev, ok := d.prev[name]
+		if !ok || cur.ioTimeMs < prev.ioTimeMs || cur.weightedIOTimeMs < prev.weightedIOTimeMs ||
+			cur.ios < prev.ios || cur.bytes < prev.bytes {
+			continue
+		}
+		elapsedMs := float64(now.Sub(prev.time).Milliseconds())
+		if elapsedMs <= 0 {
+			continue
+		}
+
+		util := float64(cur.ioTimeMs-prev.ioTimeMs) / elapsedMs * 100
+		if util > 100 {
+			util = 100
+		}
+		rates[name] = &deviceRates{
+			Utilization: util,
+			QueueDepth:  float64(cur.weightedIOTimeMs-prev.weightedIOTimeMs) / elapsedMs,
+			IOPS:        float64(cur.ios-prev.ios) / elapsedMs * 1000,
+			Throughput:  float64(cur.bytes-prev.bytes) / elapsedMs * 1000,
+		}
+	}
+
+	d.prev, d.rates = next, rates
+	for name := range d.volumeDevices {
+		if r, ok := rates[name]; ok {
+			d.iops.observe(r.IOPS)
+			d.throughput.observe(r.Throughput)
+		}
 	}
 }
+
+// deviceLabels returns volume labels for the given device, and device_role
+// for roles other than DeviceRoleVolume
+func deviceLabels(vol *discovery.VolumeInfo, device, role string) []string {
+	labels := volumeLabels(vol)
+	labels[0] = device
+	if role == DeviceRoleVolume {
+		return labels
+	}
+	return append(labels, role)
+}
+
+func volumeLabels(vol *discovery.VolumeInfo) []string {
+	values := volumeLabelKey(vol)
+	return values[:]
+}
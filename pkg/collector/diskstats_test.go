@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+)
+
+// parseDiskstats parses a /proc/diskstats holding a line for sdb with the
+// given I/O time, weighted I/O time and reads completed
+func parseDiskstats(t *testing.T, ioTimeMs, weightedMs, reads int) *diskstats.StatsMap {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "diskstats")
+	line := fmt.Sprintf("   8      16 sdb %d 0 %d 10 0 0 0 0 0 %d %d 0 0 0 0 0 0\n", reads, reads*8, ioTimeMs, weightedMs)
+	if err := os.WriteFile(path, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := diskstats.Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+// scrapeCollector collects a ScrapeCollector on a canned scrape, unchecked
+type scrapeCollector struct {
+	c      ScrapeCollector
+	scrape *Scrape
+}
+
+func (c scrapeCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	if err := c.c.UpdateScrape(c.scrape, ch); err != nil {
+		panic(err)
+	}
+}
+
+// gatherScrape returns the metric families c exports for scrape, by name
+func gatherScrape(t *testing.T, c ScrapeCollector, scrape *Scrape) map[string]*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(scrapeCollector{c, scrape})
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+	return byName
+}
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// A volume's own device keeps the series it had before rollups and device
+// roles, so upgrading doesn't break recording rules or rate() continuity
+func TestDiskstatsDefaultGolden(t *testing.T) {
+	d := NewDiskstatsCollector(t.TempDir(), t.TempDir(), false)
+	volumes := []*discovery.VolumeInfo{{
+		PVCName:            "data-db-0",
+		PVCNamespace:       "db",
+		PVName:             "pvc-1234",
+		PodName:            "db-0",
+		PodNamespace:       "db",
+		StorageClass:       "standard",
+		CSIDriver:          "csi.example.com",
+		DeviceName:         "sdb",
+		DeviceID:           "8:16",
+		ContainerMountPath: "/data",
+	}}
+	start := time.Now()
+	d.updateRates(parseDiskstats(t, 1000, 2000, 100), start)
+	d.updateRates(parseDiskstats(t, 6000, 22000, 1100), start.Add(10*time.Second))
+	families := gatherScrape(t, d, &Scrape{Volumes: volumes, Diskstats: parseDiskstats(t, 6000, 22000, 1100)})
+
+	var buf bytes.Buffer
+	for _, name := range sortedKeys(families) {
+		if _, err := expfmt.MetricFamilyToText(&buf, families[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden := filepath.Join("testdata", "diskstats_default.prom")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("diskstats output differs from %s:\n%s", golden, buf.String())
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Rates are over Run's sampling interval, so however often and by however
+// many scrapers the collector is scraped, they don't change in between
+func TestDiskstatsRatesIndependentOfScrapes(t *testing.T) {
+	d := NewDiskstatsCollector(t.TempDir(), t.TempDir(), false)
+	start := time.Now()
+	d.updateRates(parseDiskstats(t, 1000, 2000, 100), start)
+	d.updateRates(parseDiskstats(t, 6000, 22000, 1100), start.Add(10*time.Second))
+
+	volumes := []*discovery.VolumeInfo{{PVCName: "data", PVCNamespace: "db", DeviceName: "sdb", DeviceID: "8:16"}}
+	for i, ioTimeMs := range []int{7000, 7000, 9000} {
+		scrape := &Scrape{Volumes: volumes, Diskstats: parseDiskstats(t, ioTimeMs, 30000, 2000)}
+		families := gatherScrape(t, d, scrape)
+		for name, want := range map[string]float64{"device_utilization": 50, "avg_queue_depth": 2} {
+			mf := families[name]
+			if mf == nil || len(mf.GetMetric()) != 1 {
+				t.Fatalf("scrape %d: want one %s series, have %v", i, name, mf)
+			}
+			if got := mf.GetMetric()[0].GetGauge().GetValue(); got != want {
+				t.Errorf("scrape %d: %s = %g, want %g", i, name, got, want)
+			}
+		}
+	}
+}
+
+// The node distribution is observed once per rate interval, so extra scrapes
+// don't add observations
+func TestDiskstatsNodeDistributionPerInterval(t *testing.T) {
+	d := NewDiskstatsCollector(t.TempDir(), t.TempDir(), false)
+	volumes := []*discovery.VolumeInfo{{PVCName: "data", PVCNamespace: "db", DeviceName: "sdb", DeviceID: "8:16"}}
+	stats := parseDiskstats(t, 1000, 2000, 100)
+	count := func() uint64 {
+		t.Helper()
+		mf := gatherScrape(t, d, &Scrape{Volumes: volumes, Diskstats: stats})["node_volume_iops"]
+		if mf == nil {
+			t.Fatal("no node_volume_iops")
+		}
+		return mf.GetMetric()[0].GetHistogram().GetSampleCount()
+	}
+
+	// The first scrape tells Run the volume devices
+	count()
+	start := time.Now()
+	d.updateRates(parseDiskstats(t, 1000, 2000, 100), start)
+	d.updateRates(parseDiskstats(t, 2000, 4000, 1100), start.Add(10*time.Second))
+	d.updateRates(parseDiskstats(t, 3000, 6000, 2100), start.Add(20*time.Second))
+	for i := 0; i < 3; i++ {
+		if got := count(); got != 2 {
+			t.Fatalf("scrape %d: node_volume_iops count %d, want 2", i, got)
+		}
+	}
+	mf := gatherScrape(t, d, &Scrape{Volumes: volumes, Diskstats: stats})["node_volume_iops"]
+	if got := mf.GetMetric()[0].GetHistogram().GetSampleSum(); got != 200 {
+		t.Errorf("node_volume_iops sum %g, want 200", got)
+	}
+}
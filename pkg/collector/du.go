@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// duCache serves the last du walk of directories, walking each again in the
+// background once its result is older than interval, so scrapes never block
+// on a walk
+type duCache struct {
+	name     string // collector, for logs
+	interval time.Duration
+
+	mu      sync.Mutex
+	results map[string]*duResult // keyed by local path
+}
+
+type duResult struct {
+	bytes   uint64
+	inodes  uint64
+	time    time.Time
+	running bool
+}
+
+func newDUCache(name string, interval time.Duration) *duCache {
+	return &duCache{name: name, interval: interval, results: make(map[string]*duResult)}
+}
+
+// usage returns the last walk of path, and false until the first finishes
+func (c *duCache) usage(path string) (bytes, inodes uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.results[path]
+	if r == nil {
+		r = &duResult{}
+		c.results[path] = r
+	}
+	if !r.running && time.Since(r.time) > c.interval {
+		r.running = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+			defer cancel()
+			bytes, inodes, err := mounts.DiskUsage(ctx, path)
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			r.running = false
+			if err != nil {
+				slog.Warn(c.name+": du walk failed", "path", path, "error", err)
+				delete(c.results, path)
+				return
+			}
+			r.bytes, r.inodes, r.time = bytes, inodes, time.Now()
+		}()
+	}
+	return r.bytes, r.inodes, !r.time.IsZero()
+}
+
+// retain forgets the paths not in keep, e.g. of deleted pods
+func (c *duCache) retain(keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, r := range c.results {
+		if !keep[path] && !r.running {
+			delete(c.results, path)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// emptyDir media, as the medium label
+const (
+	EmptyDirMediumDisk      = "disk"
+	EmptyDirMediumMemory    = "memory"
+	EmptyDirMediumHugePages = "hugepages"
+)
+
+var emptyDirLabels = []string{"namespace", "pod", "volume", "medium"}
+
+var (
+	emptyDirUsedDesc = prometheus.NewDesc(
+		"emptydir_bytes_used",
+		"Bytes used by a pod's emptyDir volume, in memory for memory-backed (tmpfs) volumes",
+		emptyDirLabels, nil,
+	)
+	emptyDirLimitDesc = prometheus.NewDesc(
+		"emptydir_bytes_limit",
+		"Size limit of a pod's emptyDir volume: its sizeLimit, or the size of its tmpfs. Absent for disk-backed volumes without a sizeLimit, which share the node's filesystem.",
+		emptyDirLabels, nil,
+	)
+	emptyDirInodesUsedDesc = prometheus.NewDesc(
+		"emptydir_inodes_used",
+		"Inodes used by a pod's emptyDir volume",
+		emptyDirLabels, nil,
+	)
+)
+
+// EmptyDirCollector reports how full the emptyDir volumes of pods on this
+// node are. Disk-backed emptyDirs fill the node's root or kubelet
+// filesystem, which the kubelet's own metrics make hard to attribute to a
+// pod. Memory-backed ones are read with statfs of their tmpfs; disk-backed
+// ones from the project quota the kubelet may assign, otherwise a du walk
+// of the directory in the background.
+type EmptyDirCollector struct {
+	client     kubernetes.Interface
+	nodeName   string
+	namespaces []string // empty = all namespaces
+
+	hostRoot        string // statfs inside this root, e.g. /host/proc/1/root
+	kubeletPath     string // kubelet path as seen by volmetd
+	hostKubeletPath string // kubelet path as seen by the host
+	layout          kubelet.Layout
+
+	du *duCache
+}
+
+// NewEmptyDirCollector creates an emptyDir collector using the in-cluster
+// config. discovery.ErrNotInCluster is returned outside a cluster. If
+// hostRoot is set, volumes are read in the host mount namespace with paths
+// rewritten from kubeletPath to hostKubeletPath, as for capacity.
+func NewEmptyDirCollector(kubeletPath, hostRoot, hostKubeletPath string, namespaces []string) (*EmptyDirCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEmptyDirCollectorForClient(client, discovery.DetectNodeName(), kubeletPath, hostRoot, hostKubeletPath, namespaces), nil
+}
+
+// NewEmptyDirCollectorForClient creates an emptyDir collector for the given
+// node using an existing client
+func NewEmptyDirCollectorForClient(client kubernetes.Interface, nodeName, kubeletPath, hostRoot, hostKubeletPath string, namespaces []string) *EmptyDirCollector {
+	if kubeletPath == "" {
+		kubeletPath = "/var/lib/kubelet"
+	}
+	return &EmptyDirCollector{
+		client:          client,
+		nodeName:        nodeName,
+		namespaces:      namespaces,
+		hostRoot:        hostRoot,
+		kubeletPath:     kubeletPath,
+		hostKubeletPath: hostKubeletPath,
+		layout:          kubelet.Standard,
+		du:              newDUCache("emptydir", duInterval),
+	}
+}
+
+// SetLayout locates pods' volumes beneath the kubelet path with l, by
+// default kubelet.Standard. It must be called before Update.
+func (c *EmptyDirCollector) SetLayout(l kubelet.Layout) {
+	c.layout = l
+}
+
+func (c *EmptyDirCollector) Name() string {
+	return "emptydir"
+}
+
+func (c *EmptyDirCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	pods, err := c.listPods(ctx)
+	if err != nil {
+		return fmt.Errorf("list pods: %w", err)
+	}
+
+	walked := make(map[string]bool)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			// Volumes torn down; a path left behind would statfs the parent
+			continue
+		}
+		for _, vol := range pod.Spec.Volumes {
+			if vol.EmptyDir == nil {
+				continue
+			}
+			path := filepath.Join(c.layout.PodVolumesDir(c.kubeletPath, discovery.KubeletPodUID(pod), kubelet.PluginEmptyDir), vol.Name)
+			medium := emptyDirMedium(vol.EmptyDir.Medium)
+			labels := []string{pod.Namespace, pod.Name, vol.Name, medium}
+
+			usage, err := c.usage(path, medium, walked)
+			if err != nil {
+				slog.Debug("emptydir: usage", "pod", pod.Namespace+"/"+pod.Name, "volume", vol.Name, "error", err)
+				continue
+			}
+			if usage == nil {
+				// First du walk still running
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(emptyDirUsedDesc, prometheus.GaugeValue, float64(usage.UsedBytes), labels...)
+			ch <- prometheus.MustNewConstMetric(emptyDirInodesUsedDesc, prometheus.GaugeValue, float64(usage.UsedInodes), labels...)
+
+			var limit uint64
+			if l := vol.EmptyDir.SizeLimit; l != nil && !l.IsZero() {
+				limit = uint64(l.Value())
+			} else if medium != EmptyDirMediumDisk {
+				limit = usage.TotalBytes
+			}
+			if limit > 0 {
+				ch <- prometheus.MustNewConstMetric(emptyDirLimitDesc, prometheus.GaugeValue, float64(limit), labels...)
+			}
+		}
+	}
+	c.du.retain(walked)
+	return nil
+}
+
+// usage returns the usage of the emptyDir at path, or nil before its first
+// du walk finishes. Paths walked are added to walked.
+func (c *EmptyDirCollector) usage(path, medium string, walked map[string]bool) (*mounts.Capacity, error) {
+	if medium != EmptyDirMediumDisk {
+		// Its own tmpfs or hugetlbfs mount
+		if c.hostRoot == "" {
+			return mounts.GetCapacity(path)
+		}
+		return mounts.GetCapacityInRoot(c.hostRoot, c.hostPath(path))
+	}
+
+	local := path
+	if c.hostRoot != "" {
+		local = filepath.Join(c.hostRoot, c.hostPath(path))
+	}
+	q, err := mounts.GetProjectQuota(local)
+	if err == nil {
+		return q, nil
+	}
+	if !errors.Is(err, mounts.ErrNoProjectQuota) {
+		slog.Debug("emptydir: project quota", "path", local, "error", err)
+	}
+
+	walked[local] = true
+	bytes, inodes, ok := c.du.usage(local)
+	if !ok {
+		return nil, nil
+	}
+	return &mounts.Capacity{UsedBytes: bytes, UsedInodes: inodes}, nil
+}
+
+// hostPath rewrites a kubelet path as seen by volmetd to the host's
+func (c *EmptyDirCollector) hostPath(path string) string {
+	return mounts.RebasePath(path, c.kubeletPath, c.hostKubeletPath)
+}
+
+// listPods lists the pods on this node, in the configured namespaces if any
+func (c *EmptyDirCollector) listPods(ctx context.Context) ([]corev1.Pod, error) {
+	opts := metav1.ListOptions{FieldSelector: "spec.nodeName=" + c.nodeName}
+
+	namespaces := c.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		list, err := c.client.CoreV1().Pods(ns).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+	return pods, nil
+}
+
+func emptyDirMedium(m corev1.StorageMedium) string {
+	switch {
+	case m == corev1.StorageMediumMemory:
+		return EmptyDirMediumMemory
+	case m == corev1.StorageMediumHugePages || strings.HasPrefix(string(m), string(corev1.StorageMediumHugePagesPrefix)):
+		return EmptyDirMediumHugePages
+	}
+	return EmptyDirMediumDisk
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/plugin"
+)
+
+// DefaultPluginTimeout bounds each plugin run by default
+const DefaultPluginTimeout = 10 * time.Second
+
+// ExecCollector runs an external plugin on every scrape, passing it the
+// node's volumes as JSON and re-exporting the metrics it prints; see package
+// plugin for the protocol
+type ExecCollector struct {
+	name    string
+	path    string
+	node    string
+	timeout time.Duration
+}
+
+// NewExecCollector creates a collector running the plugin executable at path
+func NewExecCollector(name, path, node string, timeout time.Duration) *ExecCollector {
+	if timeout <= 0 {
+		timeout = DefaultPluginTimeout
+	}
+	return &ExecCollector{name: name, path: path, node: node, timeout: timeout}
+}
+
+func (c *ExecCollector) Name() string {
+	return "plugin:" + c.name
+}
+
+func (c *ExecCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	req, err := plugin.MarshalRequest(c.node, volumes)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	families, err := plugin.Run(ctx, c.path, nil, nil, req)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, mf := range families {
+		if err := emitFamily(mf, nil, nil, ch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// emitFamily re-exports a parsed metric family, adding labelNames with
+// labelValues to each metric. Labels the metric already has win.
+func emitFamily(mf *dto.MetricFamily, labelNames, labelValues []string, ch chan<- prometheus.Metric) error {
+	for _, m := range mf.GetMetric() {
+		names := make([]string, 0, len(m.GetLabel())+len(labelNames))
+		values := make([]string, 0, cap(names))
+		own := make(map[string]bool, len(m.GetLabel()))
+		for _, l := range m.GetLabel() {
+			names = append(names, l.GetName())
+			values = append(values, l.GetValue())
+			own[l.GetName()] = true
+		}
+		for i, name := range labelNames {
+			if !own[name] {
+				names = append(names, name)
+				values = append(values, labelValues[i])
+			}
+		}
+		desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), names, nil)
+
+		var metric prometheus.Metric
+		var err error
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+		case dto.MetricType_GAUGE:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+		case dto.MetricType_UNTYPED:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			buckets := make(map[float64]uint64, len(h.GetBucket()))
+			for _, b := range h.GetBucket() {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			metric, err = prometheus.NewConstHistogram(desc, h.GetSampleCount(), h.GetSampleSum(), buckets, values...)
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			quantiles := make(map[float64]float64, len(s.GetQuantile()))
+			for _, q := range s.GetQuantile() {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			metric, err = prometheus.NewConstSummary(desc, s.GetSampleCount(), s.GetSampleSum(), quantiles, values...)
+		default:
+			err = fmt.Errorf("unsupported type %s", mf.GetType())
+		}
+		if err != nil {
+			return fmt.Errorf("metric %s: %w", mf.GetName(), err)
+		}
+		ch <- metric
+	}
+	return nil
+}
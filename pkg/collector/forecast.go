@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	capacityFillRateDesc = prometheus.NewDesc(
+		"capacity_fill_rate_bytes_per_second",
+		"Rate the volume's used bytes grew over the forecast window (least-squares slope, negative when shrinking)",
+		volumeLabels_, nil,
+	)
+	capacitySecondsUntilFullDesc = prometheus.NewDesc(
+		"capacity_seconds_until_full",
+		"Seconds until the volume is full at its current fill rate, +Inf when it isn't growing",
+		volumeLabels_, nil,
+	)
+)
+
+// DefaultForecastWindow is the sliding window fill rates are computed over
+const DefaultForecastWindow = 6 * time.Hour
+
+// forecastSamples caps the samples kept per volume; samples closer together
+// than window/forecastSamples are dropped
+const forecastSamples = 128
+
+// fillForecaster tracks used bytes per volume over a sliding window, so fill
+// rate alerts don't need predict_linear over thousands of series
+type fillForecaster struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	volumes map[string]*fillHistory
+}
+
+type fillSample struct {
+	time time.Time
+	used float64
+}
+
+type fillHistory struct {
+	samples []fillSample
+}
+
+func newFillForecaster(window time.Duration) *fillForecaster {
+	return &fillForecaster{
+		window:  window,
+		volumes: make(map[string]*fillHistory),
+	}
+}
+
+// observe records a sample and returns the fill rate over the window. ok is
+// false until the window holds at least two samples.
+func (f *fillForecaster) observe(key string, used uint64, now time.Time) (rate float64, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h := f.volumes[key]
+	if h == nil {
+		h = &fillHistory{}
+		f.volumes[key] = h
+	}
+
+	// Drop samples that fell out of the window
+	cutoff := now.Add(-f.window)
+	i := 0
+	for i < len(h.samples) && h.samples[i].time.Before(cutoff) {
+		i++
+	}
+	h.samples = h.samples[i:]
+
+	if n := len(h.samples); n == 0 || now.Sub(h.samples[n-1].time) >= f.window/forecastSamples {
+		h.samples = append(h.samples, fillSample{time: now, used: float64(used)})
+	}
+
+	return slope(h.samples)
+}
+
+// prune forgets volumes not observed within the window
+func (f *fillForecaster) prune(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, h := range f.volumes {
+		if n := len(h.samples); n == 0 || now.Sub(h.samples[n-1].time) > f.window {
+			delete(f.volumes, key)
+		}
+	}
+}
+
+// slope returns the least-squares slope of used bytes over time in bytes per second
+func slope(samples []fillSample) (float64, bool) {
+	n := float64(len(samples))
+	if n < 2 {
+		return 0, false
+	}
+
+	// Offset times from the first sample to keep the sums well conditioned
+	t0 := samples[0].time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.time.Sub(t0).Seconds()
+		sumX += x
+		sumY += s.used
+		sumXY += x * s.used
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denom, true
+}
+
+// secondsUntilFull returns how long free bytes last at rate, +Inf if the
+// volume isn't growing
+func secondsUntilFull(free uint64, rate float64) float64 {
+	if rate <= 0 {
+		return math.Inf(1)
+	}
+	return float64(free) / rate
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var hbaPortInfoDesc = prometheus.NewDesc(
+	"hba_port_info",
+	"FC host port or SAS phy the volume's device is reached through (always 1)",
+	append(append([]string{}, volumeLabels_...), "hba_type", "host", "port", "state"), nil,
+)
+
+var hbaPortErrorsDesc = prometheus.NewDesc(
+	"hba_port_errors_total",
+	"Error counters of the FC host port or SAS phy the volume's device is reached through",
+	append(append([]string{}, volumeLabels_...), "hba_type", "host", "port", "counter"), nil,
+)
+
+// HBACollector ties FC and SAS HBA port error counters (loss of signal,
+// invalid CRC, ...) to the volumes whose devices hang off each port, so SAN
+// issues can be traced to workloads
+type HBACollector struct {
+	sysPath string
+}
+
+// NewHBACollector creates a new HBA collector
+func NewHBACollector(sysPath string) *HBACollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &HBACollector{sysPath: sysPath}
+}
+
+func (c *HBACollector) Name() string {
+	return "hba"
+}
+
+func (c *HBACollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	for _, vol := range volumes {
+		if vol.DeviceName == "" {
+			continue
+		}
+		ports, err := sysfs.HBAPortsForDevice(c.sysPath, vol.DeviceName)
+		if err != nil {
+			continue
+		}
+
+		labels := volumeLabels(vol)
+		for _, p := range ports {
+			ch <- prometheus.MustNewConstMetric(hbaPortInfoDesc, prometheus.GaugeValue, 1,
+				append(labels, p.Type, p.Host, p.Port, p.State)...)
+			for name, n := range p.Errors {
+				ch <- prometheus.MustNewConstMetric(hbaPortErrorsDesc, prometheus.CounterValue, float64(n),
+					append(labels, p.Type, p.Host, p.Port, name)...)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Volume health sources for volume_abnormal
+const (
+	HealthSourceCSI   = "csi"   // VolumeCondition from NodeGetVolumeStats
+	HealthSourceEvent = "event" // events from the external health monitor
+)
+
+// Event reasons emitted by the CSI external-health-monitor controller on PVCs
+const (
+	eventVolumeConditionAbnormal = "VolumeConditionAbnormal"
+	eventVolumeConditionNormal   = "VolumeConditionNormal"
+)
+
+// maxReasonLen bounds the reason label, which comes from free-form driver messages
+const maxReasonLen = 128
+
+var volumeAbnormalDesc = prometheus.NewDesc(
+	"volume_abnormal",
+	"Whether the volume's health is reported abnormal, with the reported reason",
+	append(append([]string{}, volumeLabels_...), "source", "reason"), nil,
+)
+
+// volumeAbnormalMetric emits volume_abnormal for a volume. reason is only
+// kept for abnormal volumes so healthy volumes have a single stable series.
+func volumeAbnormalMetric(vol *discovery.VolumeInfo, source string, abnormal bool, reason string) prometheus.Metric {
+	if !abnormal {
+		reason = ""
+	}
+	labels := append(volumeLabels(vol), source, truncateReason(reason))
+	return prometheus.MustNewConstMetric(volumeAbnormalDesc, prometheus.GaugeValue, boolToFloat(abnormal), labels...)
+}
+
+func truncateReason(reason string) string {
+	reason = strings.Join(strings.Fields(reason), " ")
+	if r := []rune(reason); len(r) > maxReasonLen {
+		return string(r[:maxReasonLen])
+	}
+	return reason
+}
+
+// VolumeHealthCollector reports volume health from the events the CSI
+// external-health-monitor controller records on PVCs
+type VolumeHealthCollector struct {
+	client     kubernetes.Interface
+	namespaces []string // empty = all namespaces
+}
+
+// NewVolumeHealthCollector creates a new volume health collector using the
+// in-cluster config. discovery.ErrNotInCluster is returned outside a cluster.
+func NewVolumeHealthCollector(namespaces []string) (*VolumeHealthCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeHealthCollector{client: client, namespaces: namespaces}, nil
+}
+
+func (c *VolumeHealthCollector) Name() string {
+	return "volumehealth"
+}
+
+func (c *VolumeHealthCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	events, err := c.listEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+
+	// Latest health event per PVC
+	latest := make(map[string]*corev1.Event)
+	for i := range events {
+		e := &events[i]
+		if e.Reason != eventVolumeConditionAbnormal && e.Reason != eventVolumeConditionNormal {
+			continue
+		}
+		key := e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name
+		if prev, ok := latest[key]; !ok || eventTime(e).After(eventTime(prev)) {
+			latest[key] = e
+		}
+	}
+
+	for _, vol := range volumes {
+		e, ok := latest[vol.PVCNamespace+"/"+vol.PVCName]
+		if !ok {
+			continue
+		}
+		ch <- volumeAbnormalMetric(vol, HealthSourceEvent, e.Reason == eventVolumeConditionAbnormal, e.Message)
+	}
+	return nil
+}
+
+func (c *VolumeHealthCollector) listEvents(ctx context.Context) ([]corev1.Event, error) {
+	opts := metav1.ListOptions{FieldSelector: "involvedObject.kind=PersistentVolumeClaim"}
+
+	if len(c.namespaces) == 0 {
+		list, err := c.client.CoreV1().Events("").List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+
+	var items []corev1.Event
+	for _, ns := range c.namespaces {
+		list, err := c.client.CoreV1().Events(ns).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+	}
+	return items, nil
+}
+
+// eventTime returns when an event was last observed
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+var capacityBytesUsedMaxDesc = prometheus.NewDesc(
+	"capacity_bytes_used_max",
+	"Highest used capacity in bytes seen since the previous scrape, sampled every few seconds while the volume is above the high usage threshold",
+	volumeLabels_, nil,
+)
+
+// DefaultHighUsageInterval is how often volumes above the high usage
+// threshold are sampled by default
+const DefaultHighUsageInterval = time.Second
+
+// highUsageIdle stops sampling a volume no scrape has asked about for this
+// long, e.g. when scraping stops
+const highUsageIdle = 10 * time.Minute
+
+// highUsageSampler samples the capacity of near-full volumes between scrapes,
+// so a volume that fills to 100% and is freed again (log rotation, a
+// compaction's temporary files) before the next scrape still shows the
+// spike. statfs is cheap enough to poll; inotify can't watch a whole tree
+// and says nothing about blocks used.
+type highUsageSampler struct {
+	threshold float64 // used/total ratio
+	interval  time.Duration
+
+	mu      sync.Mutex
+	volumes map[string]*highUsage // by mount path
+}
+
+type highUsage struct {
+	maxUsed  uint64 // since the previous scrape
+	seen     time.Time
+	sampling bool
+	stop     chan struct{}
+}
+
+func newHighUsageSampler(thresholdPercent float64, interval time.Duration) *highUsageSampler {
+	if interval <= 0 {
+		interval = DefaultHighUsageInterval
+	}
+	return &highUsageSampler{
+		threshold: thresholdPercent / 100,
+		interval:  interval,
+		volumes:   make(map[string]*highUsage),
+	}
+}
+
+// observe records the volume's capacity at a scrape and returns the highest
+// used bytes since the previous one. Sampling starts once a scrape sees the
+// volume above the threshold and stops once a whole scrape interval stayed
+// below it. sample reads the volume's capacity.
+func (s *highUsageSampler) observe(key string, cap *mounts.Capacity, now time.Time, sample func() (*mounts.Capacity, error)) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.volumes[key]
+	if h == nil {
+		h = &highUsage{}
+		s.volumes[key] = h
+	}
+	h.seen = now
+
+	maxUsed := max(h.maxUsed, cap.UsedBytes)
+	h.maxUsed = cap.UsedBytes
+
+	high := s.above(maxUsed, cap.TotalBytes)
+	switch {
+	case high && !h.sampling:
+		h.sampling = true
+		h.stop = make(chan struct{})
+		go s.sample(key, h, h.stop, sample)
+	case !high && h.sampling:
+		h.stopSampling()
+	}
+	return maxUsed
+}
+
+func (s *highUsageSampler) above(used, total uint64) bool {
+	return total > 0 && float64(used) >= s.threshold*float64(total)
+}
+
+// sample polls a volume's capacity until stopped, or until no scrape has
+// asked about it for highUsageIdle
+func (s *highUsageSampler) sample(key string, h *highUsage, stop chan struct{}, sample func() (*mounts.Capacity, error)) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		cap, err := sample()
+
+		s.mu.Lock()
+		if h.stop != stop {
+			// Stopped while sampling
+			s.mu.Unlock()
+			return
+		}
+		if err != nil || time.Since(h.seen) > highUsageIdle {
+			if err != nil {
+				slog.Debug("capacity: high usage sampling stopped", "path", key, "error", err)
+			}
+			h.stopSampling()
+			s.mu.Unlock()
+			return
+		}
+		h.maxUsed = max(h.maxUsed, cap.UsedBytes)
+		s.mu.Unlock()
+	}
+}
+
+func (h *highUsage) stopSampling() {
+	close(h.stop)
+	h.stop = nil
+	h.sampling = false
+}
+
+// prune stops sampling and forgets the volumes the scrape at now didn't see
+func (s *highUsageSampler) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, h := range s.volumes {
+		if h.seen.Before(now) {
+			if h.sampling {
+				h.stopSampling()
+			}
+			delete(s.volumes, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var volumeInfoLabels = []string{"pvc", "namespace", "pv", "pod", "pod_namespace", "storage_class", "csi_driver", "volume_handle", "cloud_volume_id", "pool", "workload", "workload_kind", "encrypted", "subpath", "backend_type"}
+
+var volumeInfoDesc = prometheus.NewDesc(
+	"volume_info",
+	"Metadata about each volume (always 1), for joining onto per-volume metrics by pvc and namespace",
+	volumeInfoLabels, nil,
+)
+
+var volumeInfoPairs = newLabelPairCache[[15]string](volumeInfoLabels)
+
+var (
+	volumeAttachedTimestampDesc = prometheus.NewDesc(
+		"volume_attached_timestamp_seconds",
+		"When the kubelet set the volume up for the pod on this node, from the mtime of a CSI volume's vol_data.json",
+		volumeLabels_, nil,
+	)
+	volumePodStartTimestampDesc = prometheus.NewDesc(
+		"volume_pod_start_timestamp_seconds",
+		"When the pod mounting the volume started, from the API",
+		volumeLabels_, nil,
+	)
+	pvcCreatedTimestampDesc = prometheus.NewDesc(
+		"pvc_created_timestamp_seconds",
+		"When the volume's PVC was created, from the API",
+		volumeLabels_, nil,
+	)
+	volumePodTerminatingDesc = prometheus.NewDesc(
+		"volume_pod_terminating",
+		"Whether every pod on this node mounting the volume is being deleted; probes of the volume are skipped meanwhile",
+		volumeLabels_, nil,
+	)
+)
+
+// InfoCollector exports volume_info with metadata that would add too much
+// churn or cardinality as labels on every metric, such as the owning
+// workload, whether the device is dm-crypt encrypted, the subpath of its
+// filesystem a bind-mounted volume is, or the kind of storage backing it
+type InfoCollector struct {
+	sysPath string
+}
+
+// NewInfoCollector creates a new info collector
+func NewInfoCollector(sysPath string) *InfoCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &InfoCollector{sysPath: sysPath}
+}
+
+func (c *InfoCollector) Name() string {
+	return "info"
+}
+
+func (c *InfoCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	for _, vol := range volumes {
+		// Unknown without a block device, e.g. NFS
+		var encrypted string
+		if vol.DeviceName != "" {
+			ok, _ := sysfs.DMCrypt(c.sysPath, vol.DeviceName)
+			encrypted = strconv.FormatBool(ok)
+		}
+		// The primary pod, kept here when pod labels are omitted elsewhere
+		var pod discovery.PodRef
+		if pods := volumePods(vol); len(pods) > 0 {
+			pod = pods[0]
+		}
+		values := [15]string{vol.PVCName, vol.PVCNamespace, vol.PVName, pod.Name, pod.Namespace, vol.StorageClass, vol.CSIDriver, vol.VolumeHandle,
+			vol.CloudVolumeID, vol.Pool, vol.Workload, vol.WorkloadKind, encrypted, vol.SubPath, vol.Backend()}
+		ch <- volumeInfoPairs.metric(volumeInfoDesc, prometheus.GaugeValue, 1, values, values[:])
+
+		// Volume age, and warm-up windows for alerts to skip
+		if !vol.Mounted.IsZero() {
+			ch <- volumeMetric(volumeAttachedTimestampDesc, prometheus.GaugeValue, float64(vol.Mounted.Unix()), vol)
+		}
+		if !vol.PodStarted.IsZero() {
+			ch <- volumeMetric(volumePodStartTimestampDesc, prometheus.GaugeValue, float64(vol.PodStarted.Unix()), vol)
+		}
+		if !vol.PVCCreated.IsZero() {
+			ch <- volumeMetric(pvcCreatedTimestampDesc, prometheus.GaugeValue, float64(vol.PVCCreated.Unix()), vol)
+		}
+		ch <- volumeMetric(volumePodTerminatingDesc, prometheus.GaugeValue, boolToFloat(vol.Terminating()), vol)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var iscsiSessionInfoDesc = prometheus.NewDesc(
+	"iscsi_session_info",
+	"iSCSI session backing the volume (always 1)",
+	append(append([]string{}, volumeLabels_...), "session", "target", "portal"), nil,
+)
+
+var iscsiSessionUpDesc = prometheus.NewDesc(
+	"iscsi_session_up",
+	"Whether the iSCSI session backing the volume is logged in",
+	volumeLabels_, nil,
+)
+
+var scsiMetrics = MetricSet[*sysfs.SCSICounters]{
+	Counter("scsi_io_requests_total", "SCSI commands issued to the volume's device", volumeLabels_, func(c *sysfs.SCSICounters) float64 { return float64(c.IORequests) }),
+	Counter("scsi_io_done_total", "SCSI commands completed by the volume's device", volumeLabels_, func(c *sysfs.SCSICounters) float64 { return float64(c.IODone) }),
+	Counter("scsi_io_errors_total", "SCSI commands completed with an error", volumeLabels_, func(c *sysfs.SCSICounters) float64 { return float64(c.IOErrors) }),
+	Counter("scsi_io_timeouts_total", "SCSI commands that timed out", volumeLabels_, func(c *sysfs.SCSICounters) float64 { return float64(c.IOTimeouts) }),
+}
+
+// ISCSICollector exports session state, target/portal info and SCSI error
+// counters for iSCSI-backed volumes from /sys/class/iscsi_session
+type ISCSICollector struct {
+	sysPath string
+}
+
+// NewISCSICollector creates a new iSCSI collector
+func NewISCSICollector(sysPath string) *ISCSICollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &ISCSICollector{sysPath: sysPath}
+}
+
+func (c *ISCSICollector) Name() string {
+	return "iscsi"
+}
+
+func (c *ISCSICollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	for _, vol := range volumes {
+		if vol.DeviceName == "" {
+			continue
+		}
+		session, ok, err := sysfs.ISCSISessionForDevice(c.sysPath, vol.DeviceName)
+		if err != nil || !ok {
+			continue
+		}
+
+		labels := volumeLabels(vol)
+		ch <- prometheus.MustNewConstMetric(iscsiSessionInfoDesc, prometheus.GaugeValue, 1,
+			append(labels, session.Session, session.Target, session.Portal)...)
+		ch <- prometheus.MustNewConstMetric(iscsiSessionUpDesc, prometheus.GaugeValue, boolToFloat(session.LoggedIn()), labels...)
+
+		if counters, err := sysfs.ReadSCSICounters(c.sysPath, vol.DeviceName); err == nil {
+			scsiMetrics.Collect(counters, labels, ch)
+		}
+	}
+	return nil
+}
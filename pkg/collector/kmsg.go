@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/kmsg"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var (
+	kernelErrorsDesc = prometheus.NewDesc(
+		"kernel_errors_total",
+		"Kernel log errors on the volume's device since boot: ext4_error, xfs_corruption, io_error, nvme_reset",
+		append(append([]string{}, volumeLabels_...), "kind"), nil,
+	)
+	kernelErrorLastDesc = prometheus.NewDesc(
+		"kernel_error_last_timestamp_seconds",
+		"Time of the most recent kernel log error of each kind on the volume's device",
+		append(append([]string{}, volumeLabels_...), "kind"), nil,
+	)
+	kernelErrorRecentDesc = prometheus.NewDesc(
+		"kernel_error_recent_info",
+		"Most recent kernel log error of each kind on the volume's device within the last hour (always 1)",
+		append(append([]string{}, volumeLabels_...), "kind", "message"), nil,
+	)
+	kernelLogErrorsDesc = prometheus.NewDesc(
+		"kernel_log_errors_total",
+		"Kernel log errors since boot by device, including devices backing no volume",
+		[]string{"device", "kind"}, nil,
+	)
+)
+
+// kernelErrorRecent is how long an error is exported as kernel_error_recent_info
+const kernelErrorRecent = time.Hour
+
+// KernelLogCollector exports the filesystem and device errors a kmsg.Watcher
+// counted, mapped to the volumes on each device
+type KernelLogCollector struct {
+	watcher *kmsg.Watcher
+	sysPath string
+}
+
+// NewKernelLogCollector creates a collector of the errors w counts. w must
+// be run separately.
+func NewKernelLogCollector(w *kmsg.Watcher, sysPath string) *KernelLogCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &KernelLogCollector{watcher: w, sysPath: sysPath}
+}
+
+func (c *KernelLogCollector) Name() string {
+	return "kernel_log"
+}
+
+func (c *KernelLogCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	counts := c.watcher.Counts()
+	last := c.watcher.Last()
+	for key, n := range counts {
+		ch <- prometheus.MustNewConstMetric(kernelLogErrorsDesc, prometheus.CounterValue, float64(n), key.Device, key.Kind)
+	}
+
+	now := time.Now()
+	for _, vol := range volumes {
+		if vol.DeviceName == "" {
+			continue
+		}
+		total := make(map[string]uint64)
+		latest := make(map[string]kmsg.Event)
+		for key, n := range counts {
+			if !c.affects(key.Device, vol.DeviceName) {
+				continue
+			}
+			total[key.Kind] += n
+			if e := last[key]; e.Time.After(latest[key.Kind].Time) {
+				latest[key.Kind] = e
+			}
+		}
+
+		labels := volumeLabels(vol)
+		for kind, n := range total {
+			ch <- prometheus.MustNewConstMetric(kernelErrorsDesc, prometheus.CounterValue, float64(n), append(labels, kind)...)
+			e := latest[kind]
+			ch <- prometheus.MustNewConstMetric(kernelErrorLastDesc, prometheus.GaugeValue, float64(e.Time.UnixNano())/1e9, append(labels, kind)...)
+			if now.Sub(e.Time) < kernelErrorRecent {
+				ch <- prometheus.MustNewConstMetric(kernelErrorRecentDesc, prometheus.GaugeValue, 1, append(labels, kind, truncateReason(e.Message))...)
+			}
+		}
+	}
+	return nil
+}
+
+// affects reports whether errors logged for device concern a volume on dev:
+// the same device, the disk of a partition volume, or the NVMe controller of
+// a namespace
+func (c *KernelLogCollector) affects(device, dev string) bool {
+	if device == dev {
+		return true
+	}
+	if parent, ok := sysfs.ParentDevice(c.sysPath, dev); ok && parent == device {
+		return true
+	}
+	// nvme0 controls namespaces nvme0n1, nvme0n2, ...
+	return strings.HasPrefix(device, "nvme") && strings.HasPrefix(dev, device+"n")
+}
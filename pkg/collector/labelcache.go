@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// volumeLabelValues are a volume's values for volumeLabels_, in order. An
+// array rather than a slice so it can key a map without allocating.
+type volumeLabelValues [10]string
+
+func volumeLabelKey(vol *discovery.VolumeInfo) volumeLabelValues {
+	return volumeLabelValues{
+		vol.DeviceName,
+		vol.CSIDevicePath,
+		vol.PVCName,
+		vol.PVCNamespace,
+		vol.PVName,
+		vol.PodName,
+		vol.PodNamespace,
+		vol.StorageClass,
+		vol.CSIDriver,
+		vol.ContainerMountPath,
+	}
+}
+
+// volumePairs caches the label pairs of every volume series
+var volumePairs = newLabelPairCache[volumeLabelValues](volumeLabels_)
+
+// labelPairCaches are swept by sweepLabelPairs
+var (
+	labelPairCachesMu sync.Mutex
+	labelPairCaches   []interface{ sweep() }
+)
+
+// labelPairCache holds the label pairs of a label set across scrapes, keyed
+// by the label values as an array. Volume labels and metadata rarely
+// change, so most scrapes build none. Entries are kept while any scrape
+// uses them, and dropped by the sweep after one that doesn't.
+type labelPairCache[K comparable] struct {
+	names []string
+	order []int
+
+	mu      sync.RWMutex
+	gen     uint64
+	entries map[K]*labelPairEntry
+}
+
+type labelPairEntry struct {
+	pairs []*dto.LabelPair
+	used  uint64 // generation last used in
+}
+
+func newLabelPairCache[K comparable](names []string) *labelPairCache[K] {
+	c := &labelPairCache[K]{names: names, order: labelOrder(names), entries: make(map[K]*labelPairEntry)}
+	labelPairCachesMu.Lock()
+	labelPairCaches = append(labelPairCaches, c)
+	labelPairCachesMu.Unlock()
+	return c
+}
+
+// get returns the label pairs of values, keyed by key holding the same
+// values, building them on first use. The pairs are shared, so must not be
+// modified.
+func (c *labelPairCache[K]) get(key K, values []string) []*dto.LabelPair {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	stale := ok && e.used != c.gen
+	c.mu.RUnlock()
+	if ok {
+		if stale {
+			c.mu.Lock()
+			e.used = c.gen
+			c.mu.Unlock()
+		}
+		return e.pairs
+	}
+
+	pairs := labelPairs(c.names, c.order, values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.used = c.gen
+		return e.pairs
+	}
+	c.entries[key] = &labelPairEntry{pairs: pairs, used: c.gen}
+	return pairs
+}
+
+// metric returns a metric of desc, whose variable labels must be the
+// cache's, with the label pairs of values
+func (c *labelPairCache[K]) metric(desc *prometheus.Desc, typ prometheus.ValueType, value float64, key K, values []string) prometheus.Metric {
+	m := &constMetric{}
+	m.init(desc, typ, value, c.get(key, values))
+	return m
+}
+
+// sweep drops the entries no scrape used since the previous sweep
+func (c *labelPairCache[K]) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if e.used != c.gen {
+			delete(c.entries, key)
+		}
+	}
+	c.gen++
+}
+
+// sweepLabelPairs sweeps every label pair cache, after a scrape
+func sweepLabelPairs() {
+	labelPairCachesMu.Lock()
+	defer labelPairCachesMu.Unlock()
+	for _, c := range labelPairCaches {
+		c.sweep()
+	}
+}
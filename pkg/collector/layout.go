@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var kubeletLayoutInfoDesc = prometheus.NewDesc(
+	"kubelet_layout_info",
+	"Kubelet directory layout volmetd detected or was configured with at startup, the kubelet root on the host and the kubelet and proc paths it reads them through (always 1)",
+	[]string{"layout", "host_kubelet_path", "kubelet_path", "proc_path"}, nil,
+)
+
+// LayoutCollector exports kubelet_layout_info, so nodes where volmetd
+// guessed the distro's layout wrong, or found no kubelet directory at all,
+// stand out without reading its logs
+type LayoutCollector struct {
+	labels []string
+}
+
+// NewLayoutCollector creates a collector of the named kubelet layout, with
+// its root on the host and the paths volmetd reads the kubelet directory and
+// /proc through
+func NewLayoutCollector(layout, hostKubeletPath, kubeletPath, procPath string) *LayoutCollector {
+	return &LayoutCollector{labels: []string{layout, hostKubeletPath, kubeletPath, procPath}}
+}
+
+func (c *LayoutCollector) Name() string {
+	return "layout"
+}
+
+func (c *LayoutCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(kubeletLayoutInfoDesc, prometheus.GaugeValue, 1, c.labels...)
+	return nil
+}
@@ -1,38 +1,226 @@
 package collector
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
 
 // Metric defines a single metric to collect from a data source
 type Metric[T any] struct {
 	Desc  *prometheus.Desc
 	Type  prometheus.ValueType
 	Value func(T) float64
+
+	labels []string // variable label names, in Desc order
+	order  []int    // indexes of labels sorted by name
 }
 
 // Counter creates a counter metric
 func Counter[T any](name, help string, labels []string, value func(T) float64) Metric[T] {
-	return Metric[T]{
-		Desc:  prometheus.NewDesc("volmetd_"+name, help, labels, nil),
-		Type:  prometheus.CounterValue,
-		Value: value,
-	}
+	return newMetric(name, help, prometheus.CounterValue, labels, value)
 }
 
 // Gauge creates a gauge metric
 func Gauge[T any](name, help string, labels []string, value func(T) float64) Metric[T] {
+	return newMetric(name, help, prometheus.GaugeValue, labels, value)
+}
+
+func newMetric[T any](name, help string, typ prometheus.ValueType, labels []string, value func(T) float64) Metric[T] {
 	return Metric[T]{
-		Desc:  prometheus.NewDesc("volmetd_"+name, help, labels, nil),
-		Type:  prometheus.GaugeValue,
-		Value: value,
+		Desc:   prometheus.NewDesc(name, help, labels, nil),
+		Type:   typ,
+		Value:  value,
+		labels: labels,
+		order:  labelOrder(labels),
 	}
 }
 
+// labelOrder returns the indexes of names sorted by name, the order label
+// pairs are written in
+func labelOrder(names []string) []int {
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(names[a], names[b]) })
+	return order
+}
+
 // MetricSet is a collection of metrics for a data source
 type MetricSet[T any] []Metric[T]
 
-// Collect emits all metrics for the given data and labels
+// Collect emits all metrics for the given data and labels. The label pairs
+// are shared by every metric with the same label names, rather than built
+// per metric as prometheus.NewConstMetric does, and volume labels come from
+// volumePairs so are usually not built at all. The metrics are allocated
+// together; they can't be pooled, as the registry holds them until written.
 func (ms MetricSet[T]) Collect(data T, labels []string, ch chan<- prometheus.Metric) {
-	for _, m := range ms {
-		ch <- prometheus.MustNewConstMetric(m.Desc, m.Type, m.Value(data), labels...)
+	metrics := make([]constMetric, len(ms))
+	var pairs []*dto.LabelPair
+	var names []string
+	for i, m := range ms {
+		if pairs == nil || !slices.Equal(names, m.labels) {
+			names = m.labels
+			if slices.Equal(names, volumeLabels_) && len(labels) == len(volumeLabels_) {
+				pairs = volumePairs.get(volumeLabelValues(labels), labels)
+			} else {
+				pairs = labelPairs(m.labels, m.order, labels)
+			}
+		}
+		metrics[i].init(m.Desc, m.Type, m.Value(data), pairs)
+		ch <- &metrics[i]
+	}
+}
+
+// volumeMetric returns a metric with vol's labels, for descs whose variable
+// labels are volumeLabels_, using volumePairs rather than building them
+func volumeMetric(desc *prometheus.Desc, typ prometheus.ValueType, value float64, vol *discovery.VolumeInfo) prometheus.Metric {
+	values := volumeLabelKey(vol)
+	return volumePairs.metric(desc, typ, value, values, values[:])
+}
+
+// labelPairs pairs names with values, sorted by name. It panics like
+// prometheus.MustNewConstMetric on a value count mismatch or invalid value.
+func labelPairs(names []string, order []int, values []string) []*dto.LabelPair {
+	if len(values) != len(names) {
+		panic(fmt.Errorf("%d label values for %d variable labels %q", len(values), len(names), names))
+	}
+	// Values are copied, as callers reuse their label slices
+	backing := make([]struct {
+		pair  dto.LabelPair
+		value string
+	}, len(names))
+	pairs := make([]*dto.LabelPair, len(names))
+	for i, j := range order {
+		if !utf8.ValidString(values[j]) {
+			panic(fmt.Errorf("label %s: value %q is not valid UTF-8", names[j], values[j]))
+		}
+		b := &backing[i]
+		b.value = values[j]
+		b.pair.Name, b.pair.Value = &names[j], &b.value
+		pairs[i] = &b.pair
+	}
+	return pairs
+}
+
+// constMetric is a metric with a fixed value and label pairs shared with
+// other metrics. It holds its dto value, so writing it doesn't allocate;
+// writers must not modify what it writes.
+type constMetric struct {
+	desc   *prometheus.Desc
+	labels []*dto.LabelPair
+	value  float64
+
+	counter dto.Counter
+	gauge   dto.Gauge
+	untyped dto.Untyped
+	typ     prometheus.ValueType
+}
+
+func (m *constMetric) init(desc *prometheus.Desc, typ prometheus.ValueType, value float64, labels []*dto.LabelPair) {
+	m.desc, m.typ, m.value, m.labels = desc, typ, value, labels
+	m.counter.Value, m.gauge.Value, m.untyped.Value = &m.value, &m.value, &m.value
+}
+
+func (m *constMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *constMetric) Write(out *dto.Metric) error {
+	out.Label = m.labels
+	switch m.typ {
+	case prometheus.CounterValue:
+		out.Counter = &m.counter
+	case prometheus.GaugeValue:
+		out.Gauge = &m.gauge
+	default:
+		out.Untyped = &m.untyped
+	}
+	return nil
+}
+
+// constHistogram accumulates observations for prometheus.MustNewConstHistogram
+type constHistogram struct {
+	bounds  []float64
+	buckets map[float64]uint64 // cumulative counts by upper bound
+	count   uint64
+	sum     float64
+}
+
+func newConstHistogram(bounds []float64) *constHistogram {
+	return &constHistogram{bounds: bounds, buckets: make(map[float64]uint64, len(bounds))}
+}
+
+func (h *constHistogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for _, b := range h.bounds {
+		if v <= b {
+			h.buckets[b]++
+		}
+	}
+}
+
+// snapshot returns a copy of the bucket counts; const histograms keep the
+// map they are given, so it must not change after they're created
+func (h *constHistogram) snapshot() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(h.buckets))
+	for b, n := range h.buckets {
+		buckets[b] = n
+	}
+	return buckets
+}
+
+// exemplarHistogram is a constHistogram that keeps the latest observation
+// falling in each bucket as an exemplar, exposed over OpenMetrics
+type exemplarHistogram struct {
+	*constHistogram
+	exemplars map[float64]prometheus.Exemplar // by the smallest bound >= the value
+}
+
+func newExemplarHistogram(bounds []float64) *exemplarHistogram {
+	return &exemplarHistogram{
+		constHistogram: newConstHistogram(bounds),
+		exemplars:      make(map[float64]prometheus.Exemplar),
+	}
+}
+
+// observeExemplar observes v and records it with labels as its bucket's exemplar
+func (h *exemplarHistogram) observeExemplar(v float64, labels prometheus.Labels) {
+	h.observe(v)
+	bound := math.Inf(1)
+	for _, b := range h.bounds {
+		if v <= b {
+			bound = b
+			break
+		}
+	}
+	h.exemplars[bound] = prometheus.Exemplar{Value: v, Labels: labels, Timestamp: time.Now()}
+}
+
+// metric returns the histogram with its exemplars
+func (h *exemplarHistogram) metric(desc *prometheus.Desc, labels ...string) prometheus.Metric {
+	m := prometheus.MustNewConstHistogram(desc, h.count, h.sum, h.snapshot(), labels...)
+	if len(h.exemplars) == 0 {
+		return m
+	}
+	exemplars := make([]prometheus.Exemplar, 0, len(h.exemplars))
+	for _, e := range h.exemplars {
+		exemplars = append(exemplars, e)
+	}
+	withExemplars, err := prometheus.NewMetricWithExemplars(m, exemplars...)
+	if err != nil {
+		// e.g. exemplar labels over the OpenMetrics length limit
+		return m
 	}
+	return withExemplars
 }
@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var volumeMountInfoLabels = append(append([]string{}, volumeLabels_...), "fstype", "options")
+
+var volumeMountInfoDesc = prometheus.NewDesc(
+	"volume_mount_info",
+	"Filesystem type and current mount options of the volume (always 1)",
+	volumeMountInfoLabels, nil,
+)
+
+var volumeMountInfoPairs = newLabelPairCache[[12]string](volumeMountInfoLabels)
+
+var volumeMountReadOnlyDesc = prometheus.NewDesc(
+	"volume_mount_read_only",
+	"Whether the volume is mounted read-only",
+	volumeLabels_, nil,
+)
+
+var volumeMountDiscardDesc = prometheus.NewDesc(
+	"volume_mount_discard",
+	"Whether the volume is mounted with online discard (the discard option); without it, space is only reclaimed by a periodic fstrim",
+	volumeLabels_, nil,
+)
+
+var volumeMountDAXDesc = prometheus.NewDesc(
+	"volume_mount_dax",
+	"Whether the volume is mounted with DAX (dax or dax=always), mapping files straight to persistent memory; only exported for pmem devices and mounts naming a dax option",
+	volumeLabels_, nil,
+)
+
+var volumeMountOptionChangesDesc = prometheus.NewDesc(
+	"volume_mount_option_changes_total",
+	"Times the volume's mount options changed between scrapes, e.g. a remount from rw to ro",
+	volumeLabels_, nil,
+)
+
+var mountResolverCacheDesc = prometheus.NewDesc(
+	"mount_resolver_cache_lookups_total",
+	"Device path and device ID lookups by whether they were served from the resolver's cache (hit) or resolved (miss); the cache is dropped when the mount table changes",
+	[]string{"result"}, nil,
+)
+
+// MountOptionsCollector tracks each volume's mount options across scrapes and
+// counts changes, so remounts (rw to ro after an I/O error, options dropped by
+// a CSI driver or automounter upgrade) don't go unnoticed
+type MountOptionsCollector struct {
+	resolver *mounts.Resolver
+
+	mu      sync.Mutex
+	options map[string]*mountOptions // keyed by mount path
+}
+
+type mountOptions struct {
+	options string
+	changes uint64
+}
+
+// NewMountOptionsCollector creates a collector reading the resolver's mount table
+func NewMountOptionsCollector(resolver *mounts.Resolver) *MountOptionsCollector {
+	return &MountOptionsCollector{
+		resolver: resolver,
+		options:  make(map[string]*mountOptions),
+	}
+}
+
+func (c *MountOptionsCollector) Name() string {
+	return "mountoptions"
+}
+
+func (c *MountOptionsCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	all, err := c.resolver.Mounts()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]*mountOptions, len(volumes))
+	for _, vol := range volumes {
+		if vol.MountPath == "" {
+			continue
+		}
+		m := c.findMount(all, vol.MountPath)
+		if m == nil {
+			continue
+		}
+
+		opts := normalizeOptions(m.Options)
+		state := c.options[vol.MountPath]
+		switch {
+		case state == nil:
+			state = &mountOptions{options: opts}
+		case state.options != opts:
+			slog.Info("mount options changed", "pvc", vol.PVCNamespace+"/"+vol.PVCName, "path", vol.MountPath, "old", state.options, "new", opts)
+			state.options = opts
+			state.changes++
+		}
+		current[vol.MountPath] = state
+
+		var values [12]string
+		key := volumeLabelKey(vol)
+		copy(values[:], key[:])
+		values[10], values[11] = m.FSType, opts
+		ch <- volumeMountInfoPairs.metric(volumeMountInfoDesc, prometheus.GaugeValue, 1, values, values[:])
+		ch <- volumeMetric(volumeMountReadOnlyDesc, prometheus.GaugeValue, boolToFloat(m.ReadOnly()), vol)
+		ch <- volumeMetric(volumeMountDiscardDesc, prometheus.GaugeValue, boolToFloat(m.HasOption("discard")), vol)
+		ch <- volumeMetric(volumeMountOptionChangesDesc, prometheus.CounterValue, float64(state.changes), vol)
+		// A PMEM volume mounted without DAX goes through the page cache
+		if sysfs.IsPMEM(vol.DeviceName) || strings.Contains(opts, "dax") {
+			ch <- volumeMetric(volumeMountDAXDesc, prometheus.GaugeValue, boolToFloat(m.HasOption("dax") || m.HasOption("dax=always")), vol)
+		}
+	}
+	c.options = current
+
+	hits, misses := c.resolver.CacheStats()
+	ch <- prometheus.MustNewConstMetric(mountResolverCacheDesc, prometheus.CounterValue, float64(hits), "hit")
+	ch <- prometheus.MustNewConstMetric(mountResolverCacheDesc, prometheus.CounterValue, float64(misses), "miss")
+
+	return nil
+}
+
+// findMount returns the mount at exactly the volume's mount path; when a path
+// is mounted over, the last (visible) entry wins
+func (c *MountOptionsCollector) findMount(all []*mounts.Mount, mountPath string) *mounts.Mount {
+	path := c.resolver.HostPath(mountPath)
+	var found *mounts.Mount
+	for _, m := range all {
+		if m.MountPoint == path {
+			found = m
+		}
+	}
+	return found
+}
+
+// normalizeOptions sorts mount options so reordering isn't reported as a change
+func normalizeOptions(options string) string {
+	opts := strings.Split(options, ",")
+	sort.Strings(opts)
+	return strings.Join(opts, ",")
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Access modes that allow a single node, or a single pod, to use a volume
+const (
+	accessModeRWO  = "ReadWriteOnce"
+	accessModeRWOP = "ReadWriteOncePod"
+)
+
+var multiAttachLabels = append(append([]string{}, volumeLabels_...), "access_mode")
+
+var (
+	volumeMultiAttachDesc = prometheus.NewDesc(
+		"volume_multi_attach",
+		"Whether a single-writer (ReadWriteOnce/ReadWriteOncePod) volume mounted on this node is also attached to another node by its VolumeAttachments, or a ReadWriteOncePod volume is mounted by more than one pod here",
+		multiAttachLabels, nil,
+	)
+	volumeAttachedNodesDesc = prometheus.NewDesc(
+		"volume_attached_nodes",
+		"Nodes a single-writer volume mounted on this node is attached to: those its VolumeAttachments report attached, and this one",
+		multiAttachLabels, nil,
+	)
+)
+
+// MultiAttachCollector flags single-writer volumes mounted on this node that
+// appear in use elsewhere too. After a node failure some CSI drivers attach
+// an RWO volume to a new node before the old attachment is gone, and both
+// nodes writing to it corrupts the filesystem; the VolumeAttachments of
+// every node show it before the data does.
+type MultiAttachCollector struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewMultiAttachCollector creates a multi-attach collector using the
+// in-cluster config. discovery.ErrNotInCluster is returned outside a cluster.
+func NewMultiAttachCollector() (*MultiAttachCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMultiAttachCollectorForClient(client, discovery.DetectNodeName()), nil
+}
+
+// NewMultiAttachCollectorForClient creates a multi-attach collector for the
+// given node using an existing client
+func NewMultiAttachCollectorForClient(client kubernetes.Interface, nodeName string) *MultiAttachCollector {
+	return &MultiAttachCollector{client: client, nodeName: nodeName}
+}
+
+func (c *MultiAttachCollector) Name() string {
+	return "multiattach"
+}
+
+func (c *MultiAttachCollector) Interval() time.Duration {
+	return volumeAttachmentInterval
+}
+
+func (c *MultiAttachCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	// Local pods mounting each single-writer PV; volumes shared by pods on
+	// this node are already merged into one with several Pods
+	type localPV struct {
+		vol  *discovery.VolumeInfo
+		mode string
+		pods int
+	}
+	local := make(map[string]*localPV)
+	for _, vol := range volumes {
+		mode := singleWriterMode(vol.AccessModes)
+		if vol.PVName == "" || mode == "" {
+			continue
+		}
+		if l := local[vol.PVName]; l != nil {
+			l.pods += max(len(vol.Pods), 1)
+			continue
+		}
+		local[vol.PVName] = &localPV{vol: vol, mode: mode, pods: max(len(vol.Pods), 1)}
+	}
+	if len(local) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	attachments, err := listVolumeAttachments(ctx, c.client)
+	if err != nil {
+		return fmt.Errorf("list volumeattachments: %w", err)
+	}
+
+	// Nodes each local PV is attached to besides this one
+	elsewhere := make(map[string]map[string]bool)
+	for i := range attachments {
+		va := &attachments[i]
+		pv := va.Spec.Source.PersistentVolumeName
+		if pv == nil || local[*pv] == nil || !va.Status.Attached || va.Spec.NodeName == c.nodeName {
+			continue
+		}
+		if elsewhere[*pv] == nil {
+			elsewhere[*pv] = make(map[string]bool)
+		}
+		elsewhere[*pv][va.Spec.NodeName] = true
+	}
+
+	for pv, l := range local {
+		multi := len(elsewhere[pv]) > 0 || (l.mode == accessModeRWOP && l.pods > 1)
+		labels := append(volumeLabels(l.vol), l.mode)
+		ch <- prometheus.MustNewConstMetric(volumeMultiAttachDesc, prometheus.GaugeValue, boolToFloat(multi), labels...)
+		ch <- prometheus.MustNewConstMetric(volumeAttachedNodesDesc, prometheus.GaugeValue, float64(1+len(elsewhere[pv])), labels...)
+	}
+	return nil
+}
+
+// singleWriterMode returns the most restrictive access mode of a volume
+// only one node may write, empty for volumes shared between nodes
+func singleWriterMode(modes []string) string {
+	switch {
+	case slices.Contains(modes, accessModeRWOP):
+		return accessModeRWOP
+	case len(modes) == 1 && modes[0] == accessModeRWO:
+		return accessModeRWO
+	}
+	return ""
+}
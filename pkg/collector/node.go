@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var nodeInfoDesc = prometheus.NewDesc(
+	"node_info",
+	"Node volmetd runs on and its topology (always 1)",
+	[]string{"node", "zone", "region"}, nil,
+)
+
+// NodeCollector exports node_info so aggregated metrics can be joined to
+// their node and zone without relying on external labels
+type NodeCollector struct {
+	node discovery.NodeInfo
+}
+
+// NewNodeCollector creates a new node collector
+func NewNodeCollector(node discovery.NodeInfo) *NodeCollector {
+	return &NodeCollector{node: node}
+}
+
+func (c *NodeCollector) Name() string {
+	return "node"
+}
+
+func (c *NodeCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(nodeInfoDesc, prometheus.GaugeValue, 1, c.node.Name, c.node.Zone, c.node.Region)
+	return nil
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/cri"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// Node filesystems, as the volume_type label
+const (
+	NodeFSRoot        = "root"        // /
+	NodeFSKubelet     = "kubelet"     // the kubelet directory, the kubelet's nodefs
+	NodeFSImage       = "imagefs"     // the container runtime's images
+	NodeFSContainerFS = "containerfs" // writable layers, when the runtime splits them from images
+)
+
+var nodeFSLabels = []string{"volume_type", "mountpoint", "device"}
+
+var nodeFSMetrics = MetricSet[*mounts.Capacity]{
+	Gauge("node_fs_capacity_bytes_total", "Total capacity in bytes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.TotalBytes) }),
+	Gauge("node_fs_capacity_bytes_used", "Used capacity in bytes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.UsedBytes) }),
+	Gauge("node_fs_capacity_bytes_free", "Free capacity in bytes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.FreeBytes) }),
+	Gauge("node_fs_capacity_inodes_total", "Total number of inodes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.TotalInodes) }),
+	Gauge("node_fs_capacity_inodes_used", "Used number of inodes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.UsedInodes) }),
+	Gauge("node_fs_capacity_inodes_free", "Free number of inodes of a node filesystem", nodeFSLabels, func(c *mounts.Capacity) float64 { return float64(c.FreeInodes) }),
+}
+
+// imageFSPaths are where container runtimes keep images by default, used
+// when the runtime can't be asked
+var imageFSPaths = []string{
+	"/var/lib/containerd",
+	"/var/lib/containers/storage", // CRI-O, podman
+	"/var/lib/docker",
+}
+
+// NodeFSCollector reports the capacity of the node's own filesystems that
+// pods fill besides their volumes: the root filesystem, the kubelet
+// directory and the container runtime's image filesystem. They are named
+// like the volume capacity metrics, with volume_type in place of the volume
+// labels, so one dashboard covers PVC and node disk exhaustion without
+// node_exporter's filesystem collector. Each filesystem is reported once
+// per volume_type, so the same mount can appear as root and kubelet.
+type NodeFSCollector struct {
+	hostRoot    string // e.g. /host/proc/1/root
+	table       *mounts.Table
+	resolver    *mounts.Resolver
+	kubeletPath string // kubelet directory on the host
+
+	cri *cri.Client // asked for the image filesystems, nil = imageFSPaths
+}
+
+// NewNodeFSCollector creates a node filesystem collector reading the host
+// through PID 1 in hostProcPath. kubeletPath is the kubelet directory on the
+// host, and resolver names the devices of the host's mounts.
+func NewNodeFSCollector(hostProcPath, kubeletPath string, resolver *mounts.Resolver) *NodeFSCollector {
+	if kubeletPath == "" {
+		kubeletPath = "/var/lib/kubelet"
+	}
+	return &NodeFSCollector{
+		hostRoot:    filepath.Join(hostProcPath, "1", "root"),
+		table:       mounts.NewTable(filepath.Join(hostProcPath, "1", "mounts"), mounts.DefaultLimits),
+		resolver:    resolver,
+		kubeletPath: kubeletPath,
+	}
+}
+
+// SetCRI finds the image filesystems by asking the container runtime rather
+// than looking for its default directories
+func (c *NodeFSCollector) SetCRI(client *cri.Client) {
+	c.cri = client
+}
+
+func (c *NodeFSCollector) Name() string {
+	return "nodefs"
+}
+
+func (c *NodeFSCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), criTimeout)
+	defer cancel()
+
+	hostMounts, err := c.table.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	collect := func(volumeType, path string) {
+		cap, err := mounts.GetPathCapacityInRoot(c.hostRoot, path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		mountPoint, device := path, ""
+		if m := mounts.FindMountByPath(hostMounts, path); m != nil {
+			mountPoint = m.MountPoint
+			_, device = c.resolver.ResolveDevice(m.Device)
+		}
+		nodeFSMetrics.Collect(cap, []string{volumeType, mountPoint, device}, ch)
+	}
+
+	collect(NodeFSRoot, "/")
+	collect(NodeFSKubelet, c.kubeletPath)
+
+	imageFS, containerFS := c.runtimeFilesystems(ctx)
+	for _, path := range imageFS {
+		collect(NodeFSImage, path)
+	}
+	for _, path := range containerFS {
+		collect(NodeFSContainerFS, path)
+	}
+	return errors.Join(errs...)
+}
+
+// runtimeFilesystems returns the host paths of the container runtime's image
+// and split writable layer filesystems, one per filesystem
+func (c *NodeFSCollector) runtimeFilesystems(ctx context.Context) (imageFS, containerFS []string) {
+	if c.cri != nil {
+		images, containers, err := c.cri.ImageFsInfo(ctx)
+		if err == nil {
+			return mountpoints(images), mountpoints(containers)
+		}
+		slog.Debug("nodefs: image filesystems from the runtime, falling back to defaults", "error", err)
+	}
+	for _, path := range imageFSPaths {
+		if _, err := os.Stat(filepath.Join(c.hostRoot, path)); err == nil {
+			return []string{path}, nil
+		}
+	}
+	return nil, nil
+}
+
+// mountpoints returns the distinct mountpoints of usage
+func mountpoints(usage []cri.FilesystemUsage) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, u := range usage {
+		if u.Mountpoint != "" && !seen[u.Mountpoint] {
+			seen[u.Mountpoint] = true
+			paths = append(paths, u.Mountpoint)
+		}
+	}
+	return paths
+}
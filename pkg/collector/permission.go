@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/selfcheck"
+)
+
+var permissionOKDesc = prometheus.NewDesc(
+	"permission_ok",
+	"Whether the startup self-check could access what volmetd needs: host paths and Kubernetes RBAC verbs",
+	[]string{"check"}, nil,
+)
+
+var capabilityDegradedDesc = prometheus.NewDesc(
+	"capability_degraded",
+	"Whether a privileged way of gathering data is unavailable and fallback is used instead; fallback \"none\" means the data is missing",
+	[]string{"capability", "fallback"}, nil,
+)
+
+// PermissionCollector exports the startup self-check results, so a DaemonSet
+// missing a host mount or RBAC rule can be alerted on rather than showing up
+// as missing volume metrics
+type PermissionCollector struct {
+	results      []selfcheck.Result
+	capabilities []selfcheck.Capability
+}
+
+// NewPermissionCollector creates a collector exporting the given results
+func NewPermissionCollector(results []selfcheck.Result) *PermissionCollector {
+	return &PermissionCollector{results: results}
+}
+
+// SetCapabilities also exports which capabilities are degraded to a fallback,
+// see selfcheck.Capabilities
+func (c *PermissionCollector) SetCapabilities(capabilities []selfcheck.Capability) {
+	c.capabilities = capabilities
+}
+
+func (c *PermissionCollector) Name() string {
+	return "permission"
+}
+
+func (c *PermissionCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	for _, r := range c.results {
+		ch <- prometheus.MustNewConstMetric(permissionOKDesc, prometheus.GaugeValue, boolToFloat(r.OK), r.Check)
+	}
+	for _, cap := range c.capabilities {
+		ch <- prometheus.MustNewConstMetric(capabilityDegradedDesc, prometheus.GaugeValue, boolToFloat(!cap.Available), cap.Name, cap.Fallback)
+	}
+	return nil
+}
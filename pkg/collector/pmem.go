@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var (
+	pmemRegionInfoDesc = prometheus.NewDesc(
+		"pmem_region_info",
+		"Persistent memory region of the node (always 1)",
+		[]string{"region", "persistence_domain", "numa_node"}, nil,
+	)
+	pmemRegionSizeDesc = prometheus.NewDesc(
+		"pmem_region_size_bytes",
+		"Size of the persistent memory region",
+		[]string{"region"}, nil,
+	)
+	pmemRegionAvailableDesc = prometheus.NewDesc(
+		"pmem_region_available_bytes",
+		"Space of the persistent memory region not allocated to a namespace",
+		[]string{"region"}, nil,
+	)
+	pmemRegionBadSectorsDesc = prometheus.NewDesc(
+		"pmem_region_bad_sectors",
+		"512-byte sectors of the persistent memory region with known media errors",
+		[]string{"region"}, nil,
+	)
+	pmemNamespaceInfoDesc = prometheus.NewDesc(
+		"pmem_namespace_info",
+		"Persistent memory namespace and region backing the volume, and its mode (fsdax, devdax, sector, raw) (always 1)",
+		append(append([]string{}, volumeLabels_...), "region", "pmem_namespace", "mode"), nil,
+	)
+	pmemNamespaceSizeDesc = prometheus.NewDesc(
+		"pmem_namespace_size_bytes",
+		"Size of the persistent memory namespace backing the volume",
+		volumeLabels_, nil,
+	)
+	pmemBadSectorsDesc = prometheus.NewDesc(
+		"pmem_bad_sectors",
+		"512-byte sectors of the volume's pmem device with known media errors; reads of them fail with EIO, and DAX mappings of them raise SIGBUS",
+		volumeLabels_, nil,
+	)
+)
+
+// PMEMCollector exports persistent memory regions from /sys/bus/nd, and the
+// namespace backing each PMEM volume (pmemN devices, typically mounted with
+// the dax option by PMEM-CSI). The page cache is bypassed on DAX mounts, so
+// PMEM volumes' diskstats are empty unless the device's iostats are on; the
+// regions' capacity and media errors are what's left to watch.
+type PMEMCollector struct {
+	sysPath string
+}
+
+// NewPMEMCollector creates a new PMEM collector
+func NewPMEMCollector(sysPath string) *PMEMCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &PMEMCollector{sysPath: sysPath}
+}
+
+func (c *PMEMCollector) Name() string {
+	return "pmem"
+}
+
+func (c *PMEMCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	regions, err := sysfs.PMEMRegions(c.sysPath)
+	if err != nil {
+		return err
+	}
+	for _, r := range regions {
+		ch <- prometheus.MustNewConstMetric(pmemRegionInfoDesc, prometheus.GaugeValue, 1, r.Region, r.PersistenceDomain, r.NUMANode)
+		ch <- prometheus.MustNewConstMetric(pmemRegionSizeDesc, prometheus.GaugeValue, float64(r.Size), r.Region)
+		ch <- prometheus.MustNewConstMetric(pmemRegionAvailableDesc, prometheus.GaugeValue, float64(r.Available), r.Region)
+		ch <- prometheus.MustNewConstMetric(pmemRegionBadSectorsDesc, prometheus.GaugeValue, float64(r.BadSectors), r.Region)
+	}
+	if len(regions) == 0 {
+		return nil
+	}
+
+	for _, vol := range volumes {
+		if !sysfs.IsPMEM(vol.DeviceName) {
+			continue
+		}
+		ns, ok, err := sysfs.PMEMNamespaceForDevice(c.sysPath, vol.DeviceName)
+		if err != nil || !ok {
+			continue
+		}
+
+		labels := volumeLabels(vol)
+		ch <- prometheus.MustNewConstMetric(pmemNamespaceInfoDesc, prometheus.GaugeValue, 1,
+			append(labels, ns.Region, ns.Namespace, ns.Mode)...)
+		ch <- prometheus.MustNewConstMetric(pmemNamespaceSizeDesc, prometheus.GaugeValue, float64(ns.Size), labels...)
+		ch <- prometheus.MustNewConstMetric(pmemBadSectorsDesc, prometheus.GaugeValue, float64(ns.BadSectors), labels...)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var volumeMountedByPodLabels = []string{"pvc", "namespace", "pv", "pod", "pod_namespace", "pod_uid", "workload", "workload_kind"}
+
+var volumeMountedByPodDesc = prometheus.NewDesc(
+	"volume_mounted_by_pod",
+	"Pods on this node mounting the volume, one series per consumer (always 1)",
+	volumeMountedByPodLabels, nil,
+)
+
+var volumeMountedByPodPairs = newLabelPairCache[[8]string](volumeMountedByPodLabels)
+
+// PodsCollector enumerates every pod consuming each volume, so shared (RWX)
+// PVCs mounted by several pods on a node are attributed to all of them
+type PodsCollector struct{}
+
+// NewPodsCollector creates a new pods collector
+func NewPodsCollector() *PodsCollector {
+	return &PodsCollector{}
+}
+
+func (c *PodsCollector) Name() string {
+	return "pods"
+}
+
+func (c *PodsCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	for _, vol := range volumes {
+		for _, p := range vol.Pods {
+			values := [8]string{vol.PVCName, vol.PVCNamespace, vol.PVName, p.Name, p.Namespace, p.UID, p.Workload, p.WorkloadKind}
+			ch <- volumeMountedByPodPairs.metric(volumeMountedByPodDesc, prometheus.GaugeValue, 1, values, values[:])
+		}
+	}
+	return nil
+}
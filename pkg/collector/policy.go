@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/policy"
+)
+
+var (
+	policyLoadSuccessDesc = prometheus.NewDesc(
+		"policy_load_success",
+		"Whether the most recent load of the policy ConfigMap succeeded; on failure the previous policy stays in effect",
+		nil, nil,
+	)
+	policyLoadTimestampDesc = prometheus.NewDesc(
+		"policy_load_timestamp_seconds",
+		"When the policy ConfigMap was last loaded, as a Unix timestamp",
+		nil, nil,
+	)
+	volumeFillThresholdDesc = prometheus.NewDesc(
+		"volume_fill_threshold_percent",
+		"Percent used at which the volume's storage class policy considers it full",
+		volumeLabels_, nil,
+	)
+	storageClassLabelDesc = prometheus.NewDesc(
+		"storage_class_label",
+		"Labels the policy maps onto a storage class, one series per label, for joining onto volume metrics by storage_class",
+		[]string{"storage_class", "label", "value"}, nil,
+	)
+)
+
+// policyStatus is implemented by policy sources reporting how loading went
+type policyStatus interface {
+	Status() policy.Status
+}
+
+// PolicyCollector exports the policy in effect: per-volume fill thresholds,
+// storage class label mappings and the state of the policy ConfigMap
+type PolicyCollector struct {
+	src policy.Source
+}
+
+// NewPolicyCollector creates a collector exporting the policy from src
+func NewPolicyCollector(src policy.Source) *PolicyCollector {
+	return &PolicyCollector{src: src}
+}
+
+func (c *PolicyCollector) Name() string {
+	return "policy"
+}
+
+func (c *PolicyCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	if s, ok := c.src.(policyStatus); ok {
+		if status := s.Status(); !status.Time.IsZero() {
+			ch <- prometheus.MustNewConstMetric(policyLoadSuccessDesc, prometheus.GaugeValue, boolToFloat(status.Error == ""))
+			ch <- prometheus.MustNewConstMetric(policyLoadTimestampDesc, prometheus.GaugeValue, float64(status.Time.Unix()))
+		}
+	}
+
+	p := policy.Get(c.src)
+	if p == nil {
+		return nil
+	}
+
+	for _, vol := range volumes {
+		if class, ok := p.Class(vol.StorageClass); ok && class.FillThreshold > 0 {
+			ch <- prometheus.MustNewConstMetric(volumeFillThresholdDesc, prometheus.GaugeValue, class.FillThreshold, volumeLabels(vol)...)
+		}
+	}
+
+	names := make([]string, 0, len(p.StorageClasses))
+	for name := range p.StorageClasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for label, value := range p.StorageClasses[name].Labels {
+			ch <- prometheus.MustNewConstMetric(storageClassLabelDesc, prometheus.GaugeValue, 1, name, label, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+	"github.com/gfx-labs/volmetd/pkg/plugin"
+)
+
+var probeFsyncDesc = prometheus.NewDesc(
+	"probe_fsync_seconds",
+	"Latency of writing and fsyncing a small probe file in the volume",
+	volumeLabels_, nil,
+)
+
+var probeFsyncErrorsDesc = prometheus.NewDesc(
+	"probe_fsync_errors_total",
+	"Write/fsync probes of the volume that failed",
+	volumeLabels_, nil,
+)
+
+// probeBuckets span healthy local SSDs to badly degraded network storage
+var probeBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// DefaultProbeInterval is how often each volume is probed by default
+const DefaultProbeInterval = time.Minute
+
+// FsyncProbeCollector actively probes each volume by writing and fsyncing a
+// tiny file in its mount every interval, so a device that still accepts I/O
+// but has become far slower is caught even on idle volumes. It writes to
+// user volumes and must be enabled explicitly.
+type FsyncProbeCollector struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	probes map[string]*probeHistogram // keyed by mount path
+}
+
+// probeHistogram accumulates probe results for one volume. Each bucket's
+// latest probe is kept as an exemplar labelled with the volume's device and
+// pod UID, so a latency spike links straight to the volume.
+type probeHistogram struct {
+	*exemplarHistogram
+	errors uint64
+
+	time     time.Time
+	running  bool
+	disabled bool // not a writable directory, e.g. block or read-only volumes
+}
+
+// NewFsyncProbeCollector creates a collector probing each volume every interval
+func NewFsyncProbeCollector(interval time.Duration) *FsyncProbeCollector {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	return &FsyncProbeCollector{
+		interval: interval,
+		probes:   make(map[string]*probeHistogram),
+	}
+}
+
+func (c *FsyncProbeCollector) Name() string {
+	return "fsyncprobe"
+}
+
+func (c *FsyncProbeCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]*probeHistogram, len(volumes))
+	for _, vol := range volumes {
+		if vol.MountPath == "" {
+			continue
+		}
+		h := c.probes[vol.MountPath]
+		if h == nil {
+			h = &probeHistogram{exemplarHistogram: newExemplarHistogram(probeBuckets)}
+		}
+		current[vol.MountPath] = h
+
+		// Mounts of deleted pods may be half torn down, and hang a probe
+		if !h.running && !h.disabled && !vol.Terminating() && time.Since(h.time) >= c.interval {
+			h.running = true
+			go c.probe(vol.MountPath, probeExemplarLabels(vol), h)
+		}
+
+		if h.count == 0 && h.errors == 0 {
+			continue
+		}
+		labels := volumeLabels(vol)
+		ch <- h.metric(probeFsyncDesc, labels...)
+		ch <- prometheus.MustNewConstMetric(probeFsyncErrorsDesc, prometheus.CounterValue, float64(h.errors), labels...)
+	}
+	c.probes = current
+
+	return nil
+}
+
+// probe runs one write+fsync probe in the background; a hung fsync only
+// holds up this volume's next probe, never a scrape
+func (c *FsyncProbeCollector) probe(path string, exemplar prometheus.Labels, h *probeHistogram) {
+	var elapsed time.Duration
+	fi, err := os.Stat(path)
+	if err == nil && !fi.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err == nil {
+		elapsed, err = mounts.ProbeFsync(path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h.running = false
+	h.time = time.Now()
+
+	switch {
+	case errors.Is(err, syscall.ENOTDIR), errors.Is(err, syscall.EROFS), errors.Is(err, syscall.EACCES):
+		slog.Debug("fsync probe: volume not probeable", "path", path, "error", err)
+		h.disabled = true
+	case err != nil:
+		slog.Warn("fsync probe failed", "path", path, "error", err)
+		h.errors++
+	default:
+		h.observeExemplar(elapsed.Seconds(), exemplar)
+	}
+}
+
+// probeExemplarLabels identifies the volume's device and pod in exemplars
+func probeExemplarLabels(vol *discovery.VolumeInfo) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if vol.DeviceName != "" {
+		labels["device"] = vol.DeviceName
+	}
+	if vol.PodUID != "" {
+		labels["pod_uid"] = vol.PodUID
+	}
+	return labels
+}
+
+var volumeReachableDesc = prometheus.NewDesc(
+	"volume_reachable",
+	"Whether a read-only probe (statfs, open and read of the mount root) of the volume completed within the timeout",
+	volumeLabels_, nil,
+)
+
+// DefaultProbeTimeout bounds each read probe by default
+const DefaultProbeTimeout = 5 * time.Second
+
+// ReadProbeCollector checks on every scrape that each volume's mount still
+// answers read-only requests, catching wedged mounts (stale NFS handles,
+// dead iSCSI sessions) that still appear in /proc/mounts
+type ReadProbeCollector struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[string]bool // mount paths with a probe still blocked
+}
+
+// NewReadProbeCollector creates a read probe collector with the given per-volume timeout
+func NewReadProbeCollector(timeout time.Duration) *ReadProbeCollector {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	return &ReadProbeCollector{
+		timeout: timeout,
+		pending: make(map[string]bool),
+	}
+}
+
+func (c *ReadProbeCollector) Name() string {
+	return "readprobe"
+}
+
+func (c *ReadProbeCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	wg := sync.WaitGroup{}
+	for _, vol := range volumes {
+		// A deleted pod's mount being torn down isn't a failure
+		if vol.MountPath == "" || vol.Terminating() {
+			continue
+		}
+		wg.Add(1)
+		go func(vol *discovery.VolumeInfo) {
+			defer wg.Done()
+			reachable := c.probe(vol.MountPath)
+			ch <- prometheus.MustNewConstMetric(volumeReachableDesc, prometheus.GaugeValue, boolToFloat(reachable), volumeLabels(vol)...)
+		}(vol)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// probe runs a read probe bounded by the timeout. A probe blocked in the
+// kernel can't be cancelled, so while one is outstanding the volume is
+// reported unreachable without starting another.
+func (c *ReadProbeCollector) probe(path string) bool {
+	c.mu.Lock()
+	if c.pending[path] {
+		c.mu.Unlock()
+		return false
+	}
+	c.pending[path] = true
+	c.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		err := mounts.ProbeRead(path)
+		c.mu.Lock()
+		delete(c.pending, path)
+		c.mu.Unlock()
+		done <- err
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			slog.Debug("read probe failed", "path", path, "error", err)
+		}
+		return err == nil
+	case <-timer.C:
+		slog.Warn("read probe timed out", "path", path, "timeout", c.timeout)
+		return false
+	}
+}
+
+var (
+	probeExecSuccessDesc = prometheus.NewDesc(
+		"probe_exec_success",
+		"Whether the most recent run of the exec probe for the volume exited zero and printed valid metrics",
+		volumeLabels_, nil,
+	)
+	probeExecDurationDesc = prometheus.NewDesc(
+		"probe_exec_duration_seconds",
+		"How long the most recent run of the exec probe for the volume took",
+		volumeLabels_, nil,
+	)
+)
+
+// DefaultExecProbeTimeout bounds each exec probe run by default
+const DefaultExecProbeTimeout = 30 * time.Second
+
+// ExecProbeCollector runs an operator-supplied command for each volume every
+// interval, in the background, and exports the Prometheus text format metrics
+// it prints with the volume's labels added: an escape hatch for site-specific
+// checks such as quota tools. The command gets the volume as plugin.Volume
+// JSON on stdin and in VOLMETD_* environment variables.
+type ExecProbeCollector struct {
+	command  []string
+	interval time.Duration
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	probes map[string]*execProbe // keyed by mount path
+}
+
+// execProbe holds the latest result of one volume's probe
+type execProbe struct {
+	families []*dto.MetricFamily
+	err      error
+	duration time.Duration
+
+	time    time.Time
+	running bool
+}
+
+// NewExecProbeCollector creates a collector running command, split on
+// whitespace, for each volume every interval
+func NewExecProbeCollector(command string, interval, timeout time.Duration) *ExecProbeCollector {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultExecProbeTimeout
+	}
+	return &ExecProbeCollector{
+		command:  strings.Fields(command),
+		interval: interval,
+		timeout:  timeout,
+		probes:   make(map[string]*execProbe),
+	}
+}
+
+func (c *ExecProbeCollector) Name() string {
+	return "execprobe"
+}
+
+func (c *ExecProbeCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	if len(c.command) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	current := make(map[string]*execProbe, len(volumes))
+	for _, vol := range volumes {
+		if vol.MountPath == "" {
+			continue
+		}
+		p := c.probes[vol.MountPath]
+		if p == nil {
+			p = &execProbe{}
+		}
+		current[vol.MountPath] = p
+
+		if !p.running && !vol.Terminating() && time.Since(p.time) >= c.interval {
+			p.running = true
+			go c.probe(vol, p)
+		}
+
+		if p.time.IsZero() {
+			continue
+		}
+		labels := volumeLabels(vol)
+		ch <- prometheus.MustNewConstMetric(probeExecSuccessDesc, prometheus.GaugeValue, boolToFloat(p.err == nil), labels...)
+		ch <- prometheus.MustNewConstMetric(probeExecDurationDesc, prometheus.GaugeValue, p.duration.Seconds(), labels...)
+		for _, mf := range p.families {
+			if err := emitFamily(mf, volumeLabels_, labels, ch); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", vol.MountPath, err))
+			}
+		}
+	}
+	c.probes = current
+
+	return errors.Join(errs...)
+}
+
+// probe runs the command for one volume in the background; a hung command
+// is killed at the timeout and only holds up this volume's next run
+func (c *ExecProbeCollector) probe(vol *discovery.VolumeInfo, p *execProbe) {
+	var families []*dto.MetricFamily
+	start := time.Now()
+	stdin, err := json.Marshal(plugin.NewVolume(vol))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		families, err = plugin.Run(ctx, c.command[0], c.command[1:], execProbeEnv(vol), stdin)
+		cancel()
+	}
+	duration := time.Since(start)
+	if err != nil {
+		slog.Warn("exec probe failed", "path", vol.MountPath, "error", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.running = false
+	p.time = time.Now()
+	p.families, p.err, p.duration = families, err, duration
+}
+
+// execProbeEnv describes the volume to the probe command
+func execProbeEnv(vol *discovery.VolumeInfo) []string {
+	return []string{
+		"VOLMETD_MOUNT_PATH=" + vol.MountPath,
+		"VOLMETD_CONTAINER_MOUNT_PATH=" + vol.ContainerMountPath,
+		"VOLMETD_DEVICE=" + vol.DeviceName,
+		"VOLMETD_DEVICE_PATH=" + vol.DevicePath,
+		"VOLMETD_PVC=" + vol.PVCName,
+		"VOLMETD_NAMESPACE=" + vol.PVCNamespace,
+		"VOLMETD_PV=" + vol.PVName,
+		"VOLMETD_STORAGE_CLASS=" + vol.StorageClass,
+		"VOLMETD_CSI_DRIVER=" + vol.CSIDriver,
+		"VOLMETD_POD=" + vol.PodName,
+		"VOLMETD_POD_NAMESPACE=" + vol.PodNamespace,
+	}
+}
@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/blkzone"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var deviceDiscardSupportedDesc = prometheus.NewDesc(
+	"device_discard_supported",
+	"Whether the volume's device accepts discard (TRIM/UNMAP) requests",
+	volumeLabels_, nil,
+)
+
+var (
+	deviceZonedInfoDesc = prometheus.NewDesc(
+		"device_zoned_info",
+		"Zone model of a zoned (ZNS/SMR) volume device (always 1)",
+		append(append([]string{}, volumeLabels_...), "model"), nil,
+	)
+	deviceZonesDesc = prometheus.NewDesc(
+		"device_zones",
+		"Number of zones on the volume's zoned device",
+		volumeLabels_, nil,
+	)
+	deviceZoneSizeDesc = prometheus.NewDesc(
+		"device_zone_size_bytes",
+		"Zone size of the volume's zoned device",
+		volumeLabels_, nil,
+	)
+	deviceZonesMaxOpenDesc = prometheus.NewDesc(
+		"device_zones_max_open",
+		"Maximum open zones the volume's zoned device allows, 0 for no limit",
+		volumeLabels_, nil,
+	)
+	deviceZonesMaxActiveDesc = prometheus.NewDesc(
+		"device_zones_max_active",
+		"Maximum active (open or closed) zones the volume's zoned device allows, 0 for no limit",
+		volumeLabels_, nil,
+	)
+	deviceZonesOpenDesc = prometheus.NewDesc(
+		"device_zones_open",
+		"Implicitly or explicitly open zones on the volume's zoned device",
+		volumeLabels_, nil,
+	)
+	deviceZonesActiveDesc = prometheus.NewDesc(
+		"device_zones_active",
+		"Open or closed zones on the volume's zoned device",
+		volumeLabels_, nil,
+	)
+	deviceZonesByConditionDesc = prometheus.NewDesc(
+		"device_zones_by_condition",
+		"Zones on the volume's zoned device by condition",
+		append(append([]string{}, volumeLabels_...), "condition"), nil,
+	)
+)
+
+var (
+	deviceQueueInfoDesc = prometheus.NewDesc(
+		"device_queue_info",
+		"I/O scheduler, read_ahead_kb and nr_requests of the volume device's request queue (always 1)",
+		append(append([]string{}, volumeLabels_...), "scheduler", "read_ahead_kb", "nr_requests"), nil,
+	)
+	deviceQueueChangesDesc = prometheus.NewDesc(
+		"device_queue_setting_changes_total",
+		"Times a request queue setting (scheduler, read_ahead_kb, nr_requests) of the volume's device differed from the previous scrape",
+		append(append([]string{}, volumeLabels_...), "setting"), nil,
+	)
+)
+
+// queueSettings are the request queue settings whose changes are counted
+var queueSettings = []struct {
+	name string
+	get  func(*sysfs.QueueTuning) string
+}{
+	{"scheduler", func(t *sysfs.QueueTuning) string { return t.Scheduler }},
+	{"read_ahead_kb", func(t *sysfs.QueueTuning) string { return strconv.FormatUint(t.ReadAheadKB, 10) }},
+	{"nr_requests", func(t *sysfs.QueueTuning) string { return strconv.FormatUint(t.NrRequests, 10) }},
+}
+
+// zoneReportInterval is how often a zoned device's zones are re-reported;
+// on large SMR disks a report covers tens of thousands of zones
+const zoneReportInterval = time.Minute
+
+// QueueCollector exports capabilities of each volume device's request queue
+// from /sys/block/<dev>/queue, and zone usage of zoned devices
+type QueueCollector struct {
+	sysPath string
+	devPath string
+
+	mu     sync.Mutex
+	zones  map[string]*zoneResult // keyed by device name
+	tuning map[string]*tuningState
+}
+
+// tuningState is a device's queue settings at the previous scrape and how
+// often each changed
+type tuningState struct {
+	tuning  *sysfs.QueueTuning
+	changes map[string]uint64
+}
+
+type zoneResult struct {
+	report *blkzone.Report
+	time   time.Time
+}
+
+// NewQueueCollector creates a new queue collector. Zone reports open the
+// device nodes beneath devPath.
+func NewQueueCollector(sysPath, devPath string) *QueueCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if devPath == "" {
+		devPath = "/dev"
+	}
+	return &QueueCollector{
+		sysPath: sysPath,
+		devPath: devPath,
+		zones:   make(map[string]*zoneResult),
+		tuning:  make(map[string]*tuningState),
+	}
+}
+
+func (c *QueueCollector) Name() string {
+	return "queue"
+}
+
+func (c *QueueCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zones := make(map[string]*zoneResult)
+	tuning := make(map[string]*tuningState)
+	for _, vol := range volumes {
+		if vol.DeviceName == "" {
+			continue
+		}
+		labels := volumeLabels(vol)
+		if ok, err := sysfs.DiscardSupported(c.sysPath, vol.DeviceName); err == nil {
+			ch <- prometheus.MustNewConstMetric(deviceDiscardSupportedDesc, prometheus.GaugeValue, boolToFloat(ok), labels...)
+		}
+		if st := c.queueTuning(vol.DeviceName, tuning); st != nil {
+			t := st.tuning
+			ch <- prometheus.MustNewConstMetric(deviceQueueInfoDesc, prometheus.GaugeValue, 1,
+				append(labels, t.Scheduler, strconv.FormatUint(t.ReadAheadKB, 10), strconv.FormatUint(t.NrRequests, 10))...)
+			for _, setting := range queueSettings {
+				ch <- prometheus.MustNewConstMetric(deviceQueueChangesDesc, prometheus.CounterValue, float64(st.changes[setting.name]), append(labels, setting.name)...)
+			}
+		}
+
+		z, ok, err := sysfs.ZonedInfo(c.sysPath, vol.DeviceName)
+		if err != nil || !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(deviceZonedInfoDesc, prometheus.GaugeValue, 1, append(labels, z.Model)...)
+		ch <- prometheus.MustNewConstMetric(deviceZonesDesc, prometheus.GaugeValue, float64(z.Zones), labels...)
+		ch <- prometheus.MustNewConstMetric(deviceZoneSizeDesc, prometheus.GaugeValue, float64(z.ZoneSizeBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(deviceZonesMaxOpenDesc, prometheus.GaugeValue, float64(z.MaxOpenZones), labels...)
+		ch <- prometheus.MustNewConstMetric(deviceZonesMaxActiveDesc, prometheus.GaugeValue, float64(z.MaxActiveZones), labels...)
+
+		r := c.zoneReport(vol.DeviceName)
+		if r == nil {
+			continue
+		}
+		zones[vol.DeviceName] = r
+		ch <- prometheus.MustNewConstMetric(deviceZonesOpenDesc, prometheus.GaugeValue, float64(r.report.Open()), labels...)
+		ch <- prometheus.MustNewConstMetric(deviceZonesActiveDesc, prometheus.GaugeValue, float64(r.report.Active()), labels...)
+		for cond, n := range r.report.Conditions {
+			ch <- prometheus.MustNewConstMetric(deviceZonesByConditionDesc, prometheus.GaugeValue, float64(n), append(labels, cond)...)
+		}
+	}
+	c.zones = zones
+	c.tuning = tuning
+
+	return nil
+}
+
+// queueTuning reads a device's queue settings, counting and logging those
+// that differ from the previous scrape, and records the state in next. Each
+// device is read once per scrape. Must be called with c.mu held.
+func (c *QueueCollector) queueTuning(dev string, next map[string]*tuningState) *tuningState {
+	if st, ok := next[dev]; ok {
+		return st
+	}
+	t, err := sysfs.ReadQueueTuning(c.sysPath, dev)
+	if err != nil {
+		slog.Debug("queue: reading tuning failed", "device", dev, "error", err)
+		return nil
+	}
+
+	st := &tuningState{tuning: t, changes: make(map[string]uint64)}
+	if prev := c.tuning[dev]; prev != nil {
+		st.changes = prev.changes
+		for _, setting := range queueSettings {
+			if before, after := setting.get(prev.tuning), setting.get(t); before != after {
+				st.changes[setting.name]++
+				slog.Info("device queue setting changed", "device", dev, "setting", setting.name, "old", before, "new", after)
+			}
+		}
+	}
+	next[dev] = st
+	return st
+}
+
+// zoneReport returns the device's cached zone report, refreshing it once
+// zoneReportInterval has passed. Must be called with c.mu held.
+func (c *QueueCollector) zoneReport(dev string) *zoneResult {
+	if r := c.zones[dev]; r != nil && time.Since(r.time) < zoneReportInterval {
+		return r
+	}
+	report, err := blkzone.ReportZones(filepath.Join(c.devPath, dev))
+	if err != nil {
+		slog.Debug("queue: zone report failed", "device", dev, "error", err)
+		return nil
+	}
+	return &zoneResult{report: report, time: time.Now()}
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+)
+
+// Reasons for the reason label of counter_resets_total
+const (
+	ResetRegression     = "regression"      // a counter decreased, e.g. the driver reset its stats
+	ResetDeviceReplaced = "device_replaced" // the device name came back with another major:minor
+)
+
+var (
+	counterResetsDesc = prometheus.NewDesc(
+		"counter_resets_total",
+		"Times a device's diskstats counters went backwards between scrapes, by reason (regression, device_replaced)",
+		[]string{"device", "reason"}, nil,
+	)
+	counterLastResetDesc = prometheus.NewDesc(
+		"counter_last_reset_timestamp_seconds",
+		"When a device's diskstats counters last went backwards, to ignore rate() windows spanning it",
+		[]string{"device"}, nil,
+	)
+)
+
+// diskstatsCounters are the cumulative fields of a diskstats row
+var diskstatsCounters = [...]func(*diskstats.Stats) *uint64{
+	func(s *diskstats.Stats) *uint64 { return &s.ReadsCompleted },
+	func(s *diskstats.Stats) *uint64 { return &s.ReadsMerged },
+	func(s *diskstats.Stats) *uint64 { return &s.SectorsRead },
+	func(s *diskstats.Stats) *uint64 { return &s.ReadTimeMs },
+	func(s *diskstats.Stats) *uint64 { return &s.WritesCompleted },
+	func(s *diskstats.Stats) *uint64 { return &s.WritesMerged },
+	func(s *diskstats.Stats) *uint64 { return &s.SectorsWritten },
+	func(s *diskstats.Stats) *uint64 { return &s.WriteTimeMs },
+	func(s *diskstats.Stats) *uint64 { return &s.IOTimeMs },
+	func(s *diskstats.Stats) *uint64 { return &s.WeightedIOTimeMs },
+	func(s *diskstats.Stats) *uint64 { return &s.DiscardsCompleted },
+	func(s *diskstats.Stats) *uint64 { return &s.DiscardsMerged },
+	func(s *diskstats.Stats) *uint64 { return &s.SectorsDiscarded },
+	func(s *diskstats.Stats) *uint64 { return &s.DiscardTimeMs },
+	func(s *diskstats.Stats) *uint64 { return &s.FlushCompleted },
+	func(s *diskstats.Stats) *uint64 { return &s.FlushTimeMs },
+}
+
+// counterStateTTL forgets devices missing from diskstats this long
+const counterStateTTL = time.Hour
+
+// counterState is a device's counters as of the previous scrape
+type counterState struct {
+	major, minor int
+	raw          [len(diskstatsCounters)]uint64
+	offset       [len(diskstatsCounters)]int64 // added to raw when clamping
+	clamped      bool                          // any offset is non-zero
+
+	regressions  uint64
+	replacements uint64
+	lastReset    time.Time
+	seen         time.Time
+}
+
+// counterResets detects diskstats counters going backwards between scrapes.
+// rate() takes any decrease for a reset to zero, so a device replaced under
+// the same name or a driver resetting its stats shows as a burst of I/O;
+// counter_resets_total and its timestamp explain the burst and let alert
+// rules skip the window. With clamping the exported counters instead hold
+// their previous value across the reset and carry on from there.
+type counterResets struct {
+	clamp  bool
+	filter *diskstats.DeviceFilter // devices whose resets are emitted
+
+	mu      sync.Mutex
+	devices map[string]*counterState // by device name
+}
+
+func newCounterResets() *counterResets {
+	return &counterResets{devices: make(map[string]*counterState)}
+}
+
+// update compares every device's counters with the previous scrape's
+func (r *counterResets) update(stats *diskstats.StatsMap, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, s := range stats.ByName {
+		st := r.devices[name]
+		if st == nil {
+			st = &counterState{major: s.Major, minor: s.Minor}
+			for i, counter := range diskstatsCounters {
+				st.raw[i] = *counter(s)
+			}
+			st.seen = now
+			r.devices[name] = st
+			continue
+		}
+		st.seen = now
+
+		replaced := s.Major != st.major || s.Minor != st.minor
+		regressed := false
+		for i, counter := range diskstatsCounters {
+			cur := *counter(s)
+			if cur < st.raw[i] {
+				regressed = true
+			}
+			if r.clamp && (replaced || cur < st.raw[i]) {
+				// Carry on from the value last exported
+				st.offset[i] += int64(st.raw[i]) - int64(cur)
+				st.clamped = true
+			}
+			st.raw[i] = cur
+		}
+		switch {
+		case replaced:
+			st.major, st.minor = s.Major, s.Minor
+			st.replacements++
+			st.lastReset = now
+		case regressed:
+			st.regressions++
+			st.lastReset = now
+		}
+	}
+
+	for name, st := range r.devices {
+		if now.Sub(st.seen) > counterStateTTL {
+			delete(r.devices, name)
+		}
+	}
+}
+
+// adjust returns s with its counters clamped across resets, s itself unless
+// a reset was clamped
+func (r *counterResets) adjust(s *diskstats.Stats) *diskstats.Stats {
+	if !r.clamp {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.devices[s.DeviceName]
+	if st == nil || !st.clamped {
+		return s
+	}
+	adjusted := *s
+	for i, counter := range diskstatsCounters {
+		p := counter(&adjusted)
+		*p = uint64(int64(*p) + st.offset[i])
+	}
+	return &adjusted
+}
+
+// collect emits the resets of the volumes' devices, and of any other device
+// that has reset
+func (r *counterResets) collect(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	emit := func(name string, st *counterState) {
+		ch <- prometheus.MustNewConstMetric(counterResetsDesc, prometheus.CounterValue, float64(st.regressions), name, ResetRegression)
+		ch <- prometheus.MustNewConstMetric(counterResetsDesc, prometheus.CounterValue, float64(st.replacements), name, ResetDeviceReplaced)
+		if !st.lastReset.IsZero() {
+			ch <- prometheus.MustNewConstMetric(counterLastResetDesc, prometheus.GaugeValue, float64(st.lastReset.Unix()), name)
+		}
+	}
+
+	seen := make(map[string]bool, len(volumes))
+	for _, vol := range volumes {
+		if st := r.devices[vol.DeviceName]; st != nil && !seen[vol.DeviceName] && r.filter.Allows(vol.DeviceName) {
+			seen[vol.DeviceName] = true
+			emit(vol.DeviceName, st)
+		}
+	}
+	for name, st := range r.devices {
+		if !seen[name] && !st.lastReset.IsZero() && r.filter.Allows(name) {
+			emit(name, st)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+)
+
+// sampleWindow summarizes the samples of a value taken since the previous
+// scrape
+type sampleWindow struct {
+	min, max, sum float64
+	n             int
+}
+
+func (w *sampleWindow) add(v float64) {
+	if w.n == 0 {
+		w.min, w.max = v, v
+	} else {
+		w.min, w.max = math.Min(w.min, v), math.Max(w.max, v)
+	}
+	w.sum += v
+	w.n++
+}
+
+func (w *sampleWindow) avg() float64 {
+	return w.sum / float64(w.n)
+}
+
+// sampleWindowMetrics returns min, max and avg gauges of a sampled value,
+// named <name>_sampled_{min,max,avg}
+func sampleWindowMetrics(name, help string) MetricSet[*sampleWindow] {
+	return MetricSet[*sampleWindow]{
+		Gauge(name+"_sampled_min", "Lowest "+help+" sampled since the previous scrape", volumeLabels_, func(w *sampleWindow) float64 { return w.min }),
+		Gauge(name+"_sampled_max", "Highest "+help+" sampled since the previous scrape", volumeLabels_, func(w *sampleWindow) float64 { return w.max }),
+		Gauge(name+"_sampled_avg", "Average "+help+" sampled since the previous scrape", volumeLabels_, func(w *sampleWindow) float64 { return w.avg() }),
+	}
+}
+
+var (
+	sampledCapacityMetrics     = sampleWindowMetrics("capacity_bytes_used", "used capacity in bytes")
+	sampledIOInProgressMetrics = sampleWindowMetrics("io_in_progress", "number of I/O operations in progress on the volume's device")
+)
+
+// DefaultSampleInterval is how often volumes are sampled between scrapes by
+// default
+const DefaultSampleInterval = 5 * time.Second
+
+// SamplingCollector samples each volume's used capacity and I/O in progress
+// every interval in the background and reports their min, max and average
+// since the previous scrape. With 30-60s scrape intervals a burst that fills
+// a volume or its device queue for a few seconds, enough to fail requests,
+// is rarely caught by the instantaneous gauges. The volumes sampled are
+// those of the latest scrape, so nothing is sampled until the first one.
+type SamplingCollector struct {
+	capacity *CapacityCollector // reads capacity as the capacity collector does
+	procPath string
+	interval time.Duration
+
+	mu      sync.Mutex
+	volumes []*discovery.VolumeInfo  // of the latest scrape
+	used    map[string]*sampleWindow // by mount path
+	io      map[string]*sampleWindow // by device name
+	pending map[string]bool          // mount paths with a statfs still running
+}
+
+// NewSamplingCollector creates a collector sampling volumes every interval,
+// reading capacity through capacity and I/O in progress from diskstats in
+// procPath
+func NewSamplingCollector(capacity *CapacityCollector, procPath string, interval time.Duration) *SamplingCollector {
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	return &SamplingCollector{
+		capacity: capacity,
+		procPath: procPath,
+		interval: interval,
+		used:     make(map[string]*sampleWindow),
+		io:       make(map[string]*sampleWindow),
+		pending:  make(map[string]bool),
+	}
+}
+
+func (c *SamplingCollector) Name() string {
+	return "sampling"
+}
+
+// Update reports the samples taken since the previous scrape and starts new
+// windows for volumes
+func (c *SamplingCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	used, io := c.used, c.io
+	c.volumes = volumes
+	c.used = make(map[string]*sampleWindow, len(volumes))
+	c.io = make(map[string]*sampleWindow, len(io))
+	c.mu.Unlock()
+
+	for _, vol := range volumes {
+		labels := volumeLabels(vol)
+		if w := used[vol.MountPath]; w != nil && vol.MountPath != "" {
+			sampledCapacityMetrics.Collect(w, labels, ch)
+		}
+		if w := io[vol.DeviceName]; w != nil && vol.DeviceName != "" {
+			sampledIOInProgressMetrics.Collect(w, labels, ch)
+		}
+	}
+	return nil
+}
+
+// Run samples the latest scrape's volumes every interval until ctx is
+// cancelled
+func (c *SamplingCollector) Run(ctx context.Context) {
+	stats := diskstats.NewStatsMap()
+	defer stats.Close()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.sample(ctx, stats)
+	}
+}
+
+// sample takes one sample of every volume. statfs runs in the background,
+// so a wedged mount only goes unsampled rather than stalling the others.
+func (c *SamplingCollector) sample(ctx context.Context, stats *diskstats.StatsMap) {
+	c.mu.Lock()
+	volumes := c.volumes
+	c.mu.Unlock()
+	if len(volumes) == 0 {
+		return
+	}
+
+	err := diskstats.ParseIntoContext(ctx, filepath.Join(c.procPath, "diskstats"), stats, diskstats.DefaultLimits)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := make(map[string]bool, len(volumes))
+	for _, vol := range volumes {
+		if err == nil && vol.DeviceName != "" && !seen[vol.DeviceName] {
+			seen[vol.DeviceName] = true
+			if s, ok := stats.ByName[vol.DeviceName]; ok {
+				window(c.io, vol.DeviceName).add(float64(s.IOInProgress))
+			}
+		}
+		if vol.MountPath != "" && !c.pending[vol.MountPath] {
+			c.pending[vol.MountPath] = true
+			go c.sampleCapacity(vol)
+		}
+	}
+}
+
+func (c *SamplingCollector) sampleCapacity(vol *discovery.VolumeInfo) {
+	cap, err := c.capacity.getVolumeCapacity(vol, false)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, vol.MountPath)
+	if err == nil {
+		window(c.used, vol.MountPath).add(float64(cap.UsedBytes))
+	}
+}
+
+// window returns the window of key in windows, adding it if missing
+func window(windows map[string]*sampleWindow, key string) *sampleWindow {
+	w := windows[key]
+	if w == nil {
+		w = &sampleWindow{}
+		windows[key] = w
+	}
+	return w
+}
@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/policy"
+)
+
+var sloLabels = append(append([]string{}, volumeLabels_...), "op")
+
+var (
+	latencySLOViolationDesc = prometheus.NewDesc(
+		"latency_slo_violation_seconds_total",
+		"Time the volume's average read or write latency between scrapes exceeded its storage class's target, from diskstats deltas. rate() of it is the fraction of time in violation.",
+		sloLabels, nil,
+	)
+	latencySLOTargetDesc = prometheus.NewDesc(
+		"latency_slo_target_seconds",
+		"Target average read or write latency for the volume's storage class",
+		sloLabels, nil,
+	)
+)
+
+// LatencySLO is a target average latency per I/O, 0 = no target
+type LatencySLO struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// DefaultSLOClass names the SLO for storage classes without their own
+const DefaultSLOClass = "*"
+
+// ParseLatencySLOs parses comma-separated <storage class>=<read>/<write>
+// targets, e.g. "gp3=10ms/20ms,io2=2ms,*=50ms". A single duration applies to
+// both reads and writes, and DefaultSLOClass covers every other class.
+func ParseLatencySLOs(s string) (map[string]LatencySLO, error) {
+	slos := make(map[string]LatencySLO)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		class, targets, ok := strings.Cut(entry, "=")
+		if !ok || class == "" {
+			return nil, fmt.Errorf("latency SLO %q: want <storage class>=<read>/<write>", entry)
+		}
+		readStr, writeStr, ok := strings.Cut(targets, "/")
+		if !ok {
+			writeStr = readStr
+		}
+		read, err := time.ParseDuration(readStr)
+		if err != nil {
+			return nil, fmt.Errorf("latency SLO %q: %w", entry, err)
+		}
+		write, err := time.ParseDuration(writeStr)
+		if err != nil {
+			return nil, fmt.Errorf("latency SLO %q: %w", entry, err)
+		}
+		slos[strings.TrimSpace(class)] = LatencySLO{Read: read, Write: write}
+	}
+	return slos, nil
+}
+
+// LatencySLOCollector tracks, per volume, how long its average I/O latency
+// has been over its storage class's target, so storage tiers can be burn-rate
+// alerted on without per-PVC recording rules
+type LatencySLOCollector struct {
+	procPath string
+	slos     map[string]LatencySLO
+	policy   policy.Source
+
+	mu      sync.Mutex
+	volumes map[string]*sloState // keyed by PV name, or mount path without one
+}
+
+type sloState struct {
+	device string
+	sample latencySample
+	read   float64 // seconds in violation
+	write  float64
+}
+
+type latencySample struct {
+	reads, readTimeMs   uint64
+	writes, writeTimeMs uint64
+	time                time.Time
+}
+
+// NewLatencySLOCollector creates a collector checking volumes against slos,
+// keyed by storage class
+func NewLatencySLOCollector(procPath string, slos map[string]LatencySLO) *LatencySLOCollector {
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	return &LatencySLOCollector{
+		procPath: procPath,
+		slos:     slos,
+		volumes:  make(map[string]*sloState),
+	}
+}
+
+// SetPolicy takes targets from the storage class policy in src where it sets
+// them, ahead of the collector's own
+func (c *LatencySLOCollector) SetPolicy(src policy.Source) {
+	c.policy = src
+}
+
+func (c *LatencySLOCollector) Name() string {
+	return "latencyslo"
+}
+
+func (c *LatencySLOCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	return c.UpdateScrape(&Scrape{Volumes: volumes}, ch)
+}
+
+// UpdateScrape checks latency since the previous scrape using the scrape's
+// parsed diskstats, reading them itself if there are none
+func (c *LatencySLOCollector) UpdateScrape(scrape *Scrape, ch chan<- prometheus.Metric) error {
+	stats := scrape.Diskstats
+	if stats == nil {
+		var err error
+		if stats, err = diskstats.Parse(c.procPath + "/diskstats"); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := policy.Get(c.policy)
+	now := time.Now()
+	current := make(map[string]*sloState, len(scrape.Volumes))
+	for _, vol := range scrape.Volumes {
+		slo, ok := c.target(p, vol.StorageClass)
+		if !ok || vol.DeviceName == "" {
+			continue
+		}
+		s, ok := stats.ByName[vol.DeviceName]
+		if !ok {
+			continue
+		}
+		key := vol.PVName
+		if key == "" {
+			key = vol.MountPath
+		}
+
+		cur := latencySample{
+			reads:       s.ReadsCompleted,
+			readTimeMs:  s.ReadTimeMs,
+			writes:      s.WritesCompleted,
+			writeTimeMs: s.WriteTimeMs,
+			time:        now,
+		}
+		st := c.volumes[key]
+		if st == nil {
+			st = &sloState{}
+		} else if st.device == s.DeviceName {
+			st.observe(cur, slo)
+		}
+		st.device, st.sample = s.DeviceName, cur
+		current[key] = st
+
+		labels := volumeLabels(vol)
+		labels = labels[:len(labels):len(labels)] // read and write labels mustn't share an array
+		if slo.Read > 0 {
+			readLabels := append(labels, "read")
+			ch <- prometheus.MustNewConstMetric(latencySLOViolationDesc, prometheus.CounterValue, st.read, readLabels...)
+			ch <- prometheus.MustNewConstMetric(latencySLOTargetDesc, prometheus.GaugeValue, slo.Read.Seconds(), readLabels...)
+		}
+		if slo.Write > 0 {
+			writeLabels := append(labels, "write")
+			ch <- prometheus.MustNewConstMetric(latencySLOViolationDesc, prometheus.CounterValue, st.write, writeLabels...)
+			ch <- prometheus.MustNewConstMetric(latencySLOTargetDesc, prometheus.GaugeValue, slo.Write.Seconds(), writeLabels...)
+		}
+	}
+	c.volumes = current
+
+	return nil
+}
+
+// target returns the SLO for a storage class
+func (c *LatencySLOCollector) target(p *policy.Policy, storageClass string) (LatencySLO, bool) {
+	if class, ok := p.Class(storageClass); ok && (class.ReadLatency > 0 || class.WriteLatency > 0) {
+		return LatencySLO{Read: time.Duration(class.ReadLatency), Write: time.Duration(class.WriteLatency)}, true
+	}
+	if slo, ok := c.slos[storageClass]; ok {
+		return slo, true
+	}
+	slo, ok := c.slos[DefaultSLOClass]
+	return slo, ok
+}
+
+// observe adds the time since the previous sample to each op whose average
+// latency over it exceeded the target. Intervals without I/O never violate,
+// and counters that went backwards (device reset) are skipped.
+func (st *sloState) observe(cur latencySample, slo LatencySLO) {
+	prev := st.sample
+	elapsed := cur.time.Sub(prev.time).Seconds()
+	if elapsed <= 0 || cur.reads < prev.reads || cur.writes < prev.writes ||
+		cur.readTimeMs < prev.readTimeMs || cur.writeTimeMs < prev.writeTimeMs {
+		return
+	}
+	if over(cur.reads-prev.reads, cur.readTimeMs-prev.readTimeMs, slo.Read) {
+		st.read += elapsed
+	}
+	if over(cur.writes-prev.writes, cur.writeTimeMs-prev.writeTimeMs, slo.Write) {
+		st.write += elapsed
+	}
+}
+
+// over reports whether ios that took timeMs in total averaged above target
+func over(ios, timeMs uint64, target time.Duration) bool {
+	if ios == 0 || target <= 0 {
+		return false
+	}
+	return float64(timeMs)/float64(ios) > float64(target)/float64(time.Millisecond)
+}
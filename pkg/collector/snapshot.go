@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var (
+	volumeSnapshotGVR        = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+	volumeSnapshotContentGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotcontents"}
+
+	snapshotLabels_        = []string{"pvc", "namespace", "snapshot", "snapshot_class", "snapshot_content"}
+	snapshotContentLabels_ = []string{"pvc", "namespace", "snapshot", "snapshot_content", "csi_driver", "deletion_policy"}
+
+	snapshotReadyDesc = prometheus.NewDesc(
+		"volume_snapshot_ready_to_use",
+		"Whether the VolumeSnapshot is ready to use",
+		snapshotLabels_, nil,
+	)
+	snapshotErrorDesc = prometheus.NewDesc(
+		"volume_snapshot_error",
+		"Whether the VolumeSnapshot reports an error",
+		snapshotLabels_, nil,
+	)
+	snapshotCreationDesc = prometheus.NewDesc(
+		"volume_snapshot_creation_timestamp_seconds",
+		"Time the snapshot was taken by the storage system",
+		snapshotLabels_, nil,
+	)
+	snapshotRestoreSizeDesc = prometheus.NewDesc(
+		"volume_snapshot_restore_size_bytes",
+		"Minimum size of a volume restored from the snapshot",
+		snapshotLabels_, nil,
+	)
+	snapshotContentReadyDesc = prometheus.NewDesc(
+		"volume_snapshot_content_ready_to_use",
+		"Whether the VolumeSnapshotContent is ready to use",
+		snapshotContentLabels_, nil,
+	)
+)
+
+// apiTimeout bounds the K8s API calls made during a scrape
+const apiTimeout = 10 * time.Second
+
+// snapshotInterval is how often snapshots are listed by default, rather
+// than listing them cluster-wide from every node on every scrape
+const snapshotInterval = time.Minute
+
+// SnapshotCollector exports VolumeSnapshot and VolumeSnapshotContent state
+// for snapshots of PVCs discovered on this node
+type SnapshotCollector struct {
+	client     dynamic.Interface
+	namespaces []string // empty = all namespaces
+}
+
+// NewSnapshotCollector creates a new snapshot collector using the in-cluster
+// config. discovery.ErrNotInCluster is returned outside a cluster.
+func NewSnapshotCollector(namespaces []string) (*SnapshotCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotCollector{client: client, namespaces: namespaces}, nil
+}
+
+func (c *SnapshotCollector) Name() string {
+	return "snapshot"
+}
+
+func (c *SnapshotCollector) Interval() time.Duration {
+	return snapshotInterval
+}
+
+func (c *SnapshotCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	// Only snapshots of PVCs on this node are reported
+	pvcs := make(map[string]bool, len(volumes))
+	for _, vol := range volumes {
+		pvcs[vol.PVCNamespace+"/"+vol.PVCName] = true
+	}
+	if len(pvcs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	snapshots, err := c.listSnapshots(ctx)
+	if err != nil {
+		return fmt.Errorf("list volumesnapshots: %w", err)
+	}
+
+	// snapshot content name -> the snapshot bound to it
+	bound := make(map[string]*unstructured.Unstructured)
+
+	for i := range snapshots {
+		s := &snapshots[i]
+		pvc, _, _ := unstructured.NestedString(s.Object, "spec", "source", "persistentVolumeClaimName")
+		if pvc == "" || !pvcs[s.GetNamespace()+"/"+pvc] {
+			continue
+		}
+
+		class, _, _ := unstructured.NestedString(s.Object, "spec", "volumeSnapshotClassName")
+		content, _, _ := unstructured.NestedString(s.Object, "status", "boundVolumeSnapshotContentName")
+		labels := []string{pvc, s.GetNamespace(), s.GetName(), class, content}
+
+		ready, _, _ := unstructured.NestedBool(s.Object, "status", "readyToUse")
+		ch <- prometheus.MustNewConstMetric(snapshotReadyDesc, prometheus.GaugeValue, boolToFloat(ready), labels...)
+
+		_, hasError, _ := unstructured.NestedMap(s.Object, "status", "error")
+		ch <- prometheus.MustNewConstMetric(snapshotErrorDesc, prometheus.GaugeValue, boolToFloat(hasError), labels...)
+
+		if v, ok, _ := unstructured.NestedString(s.Object, "status", "creationTime"); ok {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				ch <- prometheus.MustNewConstMetric(snapshotCreationDesc, prometheus.GaugeValue, float64(t.Unix()), labels...)
+			}
+		}
+
+		if v, ok, _ := unstructured.NestedString(s.Object, "status", "restoreSize"); ok {
+			if q, err := resource.ParseQuantity(v); err == nil {
+				ch <- prometheus.MustNewConstMetric(snapshotRestoreSizeDesc, prometheus.GaugeValue, q.AsApproximateFloat64(), labels...)
+			}
+		}
+
+		if content != "" {
+			bound[content] = s
+		}
+	}
+
+	if len(bound) == 0 {
+		return nil
+	}
+
+	contents, err := c.client.Resource(volumeSnapshotContentGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list volumesnapshotcontents: %w", err)
+	}
+
+	for i := range contents.Items {
+		vsc := &contents.Items[i]
+		s, ok := bound[vsc.GetName()]
+		if !ok {
+			continue
+		}
+
+		pvc, _, _ := unstructured.NestedString(s.Object, "spec", "source", "persistentVolumeClaimName")
+		driver, _, _ := unstructured.NestedString(vsc.Object, "spec", "driver")
+		policy, _, _ := unstructured.NestedString(vsc.Object, "spec", "deletionPolicy")
+		ready, _, _ := unstructured.NestedBool(vsc.Object, "status", "readyToUse")
+
+		ch <- prometheus.MustNewConstMetric(snapshotContentReadyDesc, prometheus.GaugeValue, boolToFloat(ready),
+			pvc, s.GetNamespace(), s.GetName(), vsc.GetName(), driver, policy)
+	}
+
+	return nil
+}
+
+func (c *SnapshotCollector) listSnapshots(ctx context.Context) ([]unstructured.Unstructured, error) {
+	if len(c.namespaces) == 0 {
+		list, err := c.client.Resource(volumeSnapshotGVR).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+
+	var items []unstructured.Unstructured
+	for _, ns := range c.namespaces {
+		list, err := c.client.Resource(volumeSnapshotGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+	}
+	return items, nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// stateVersion is bumped when the state file format changes incompatibly;
+// files of other versions are ignored
+const stateVersion = 1
+
+// stateSaveInterval is how often an unchanged inventory is rewritten, so the
+// file's time stays close enough to serve it within the stale TTL
+const stateSaveInterval = time.Minute
+
+// state is the last discovered volume inventory persisted across restarts
+type state struct {
+	Version int                     `json:"version"`
+	Time    time.Time               `json:"time"`
+	Volumes []*discovery.VolumeInfo `json:"volumes"`
+}
+
+// SetStateFile persists each successful discovery to path and loads the
+// inventory saved there by a previous run, so after a restart it's served
+// as stale volumes until discovery first succeeds. Only inventories within
+// the stale TTL are served.
+func (v *VolumeCollector) SetStateFile(path string) {
+	v.stateFile = path
+
+	s, err := loadState(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return
+	case err != nil:
+		slog.Warn("ignoring volume state file", "path", path, "error", err)
+		return
+	}
+	slog.Info("loaded volume state", "path", path, "volumes", len(s.Volumes), "age", time.Since(s.Time).Round(time.Second))
+
+	v.mu.Lock()
+	v.lastVolumes, v.lastSuccess = s.Volumes, s.Time
+	v.mu.Unlock()
+}
+
+func loadState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version != stateVersion {
+		return nil, fmt.Errorf("version %d, want %d", s.Version, stateVersion)
+	}
+	return &s, nil
+}
+
+// saveState writes the inventory to the state file when it changed or
+// stateSaveInterval has passed since the last write. The file is replaced
+// atomically so a crash mid-write never leaves a truncated one.
+func (v *VolumeCollector) saveState(volumes []*discovery.VolumeInfo) {
+	if v.stateFile == "" {
+		return
+	}
+
+	// Discovery order is random; sort a copy so unchanged inventories compare equal
+	sorted := append([]*discovery.VolumeInfo(nil), volumes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].PVName != sorted[j].PVName {
+			return sorted[i].PVName < sorted[j].PVName
+		}
+		return sorted[i].MountPath < sorted[j].MountPath
+	})
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		slog.Warn("failed to encode volume state", "error", err)
+		return
+	}
+
+	v.stateMu.Lock()
+	defer v.stateMu.Unlock()
+	if bytes.Equal(data, v.stateSaved) && time.Since(v.stateTime) < stateSaveInterval {
+		return
+	}
+
+	now := time.Now()
+	out, err := json.Marshal(state{Version: stateVersion, Time: now, Volumes: sorted})
+	if err == nil {
+		err = writeFileAtomic(v.stateFile, out)
+	}
+	if err != nil {
+		slog.Warn("failed to save volume state", "path", v.stateFile, "error", err)
+		return
+	}
+	v.stateSaved, v.stateTime = data, now
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var storageClassInfoDesc = prometheus.NewDesc(
+	"storageclass_info",
+	"StorageClass parameters (always 1), for joining onto volume metrics by storage_class",
+	[]string{"storage_class", "provisioner", "reclaim_policy", "volume_binding_mode", "allow_volume_expansion"}, nil,
+)
+
+// storageClassTTL is how long a fetched StorageClass is reused. They're
+// rarely changed, and most fields are immutable.
+const storageClassTTL = 10 * time.Minute
+
+// StorageClassCollector exports storageclass_info for the StorageClasses of
+// discovered volumes
+type StorageClassCollector struct {
+	client kubernetes.Interface
+
+	mu    sync.Mutex
+	cache map[string]*storageClassEntry
+}
+
+type storageClassEntry struct {
+	class *storagev1.StorageClass // nil if it doesn't exist
+	time  time.Time
+}
+
+// NewStorageClassCollector creates a new StorageClass collector using the
+// in-cluster config. discovery.ErrNotInCluster is returned outside a cluster.
+func NewStorageClassCollector() (*StorageClassCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorageClassCollectorForClient(client), nil
+}
+
+// NewStorageClassCollectorForClient creates a new StorageClass collector using client
+func NewStorageClassCollectorForClient(client kubernetes.Interface) *StorageClassCollector {
+	return &StorageClassCollector{
+		client: client,
+		cache:  make(map[string]*storageClassEntry),
+	}
+}
+
+func (c *StorageClassCollector) Name() string {
+	return "storageclass"
+}
+
+func (c *StorageClassCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	names := make(map[string]bool)
+	for _, vol := range volumes {
+		if vol.StorageClass != "" {
+			names[vol.StorageClass] = true
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for name := range names {
+		sc, err := c.get(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get storageclass %s: %w", name, err))
+		}
+		if sc == nil {
+			continue
+		}
+
+		reclaim := "Delete"
+		if sc.ReclaimPolicy != nil {
+			reclaim = string(*sc.ReclaimPolicy)
+		}
+		binding := string(storagev1.VolumeBindingImmediate)
+		if sc.VolumeBindingMode != nil {
+			binding = string(*sc.VolumeBindingMode)
+		}
+		expansion := sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+		ch <- prometheus.MustNewConstMetric(storageClassInfoDesc, prometheus.GaugeValue, 1,
+			sc.Name, sc.Provisioner, reclaim, binding, strconv.FormatBool(expansion))
+	}
+
+	// Forget classes no volume uses any more
+	for name := range c.cache {
+		if !names[name] {
+			delete(c.cache, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// get returns a StorageClass, fetching it when not cached or stale. A class
+// that doesn't exist is cached as nil. On errors a stale copy is kept.
+func (c *StorageClassCollector) get(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	entry := c.cache[name]
+	if entry != nil && time.Since(entry.time) < storageClassTTL {
+		return entry.class, nil
+	}
+
+	sc, err := c.client.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		sc = nil
+	case err != nil && entry != nil:
+		return entry.class, err
+	case err != nil:
+		return nil, err
+	}
+	c.cache[name] = &storageClassEntry{class: sc, time: time.Now()}
+	return sc, nil
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/cgroup"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/sysfs"
+)
+
+var ioLimitBytesDesc = prometheus.NewDesc(
+	"io_limit_bytes_per_second",
+	"Bandwidth limit the pod's cgroup places on the volume's device",
+	append(append([]string{}, volumeLabels_...), "direction"), nil,
+)
+
+var ioLimitIOPSDesc = prometheus.NewDesc(
+	"io_limit_iops",
+	"IOPS limit the pod's cgroup places on the volume's device",
+	append(append([]string{}, volumeLabels_...), "direction"), nil,
+)
+
+var ioThrottleRatioDesc = prometheus.NewDesc(
+	"io_throttle_ratio",
+	"I/O issued by the pod's cgroup to the volume's device since the previous scrape as a fraction of its limit; values near 1 mean the pod is being throttled",
+	append(append([]string{}, volumeLabels_...), "direction", "kind"), nil,
+)
+
+// ioDim is one throttleable dimension of cgroup I/O
+type ioDim struct {
+	direction string
+	kind      string // bytes or iops
+	limit     func(*cgroup.IOLimit) uint64
+	usage     func(*cgroup.IOStat) uint64
+}
+
+var ioDims = []ioDim{
+	{"read", "bytes", func(l *cgroup.IOLimit) uint64 { return l.ReadBps }, func(s *cgroup.IOStat) uint64 { return s.ReadBytes }},
+	{"write", "bytes", func(l *cgroup.IOLimit) uint64 { return l.WriteBps }, func(s *cgroup.IOStat) uint64 { return s.WriteBytes }},
+	{"read", "iops", func(l *cgroup.IOLimit) uint64 { return l.ReadIOPS }, func(s *cgroup.IOStat) uint64 { return s.ReadIOs }},
+	{"write", "iops", func(l *cgroup.IOLimit) uint64 { return l.WriteIOPS }, func(s *cgroup.IOStat) uint64 { return s.WriteIOs }},
+}
+
+// ThrottleCollector exports the io.max (cgroup v2) or blkio.throttle (v1)
+// limits set on the volume's device by each consuming pod's cgroup, and how
+// close the pod ran to them, so throttling can be told apart from slow storage
+type ThrottleCollector struct {
+	cgroupRoot string
+	sysPath    string
+
+	mu   sync.Mutex
+	prev map[string]ioSample // keyed by cgroup dir and device
+}
+
+type ioSample struct {
+	stat cgroup.IOStat
+	time time.Time
+}
+
+// NewThrottleCollector creates a new throttle collector reading cgroups
+// beneath cgroupRoot, e.g. /host/sys/fs/cgroup
+func NewThrottleCollector(cgroupRoot, sysPath string) *ThrottleCollector {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &ThrottleCollector{
+		cgroupRoot: cgroupRoot,
+		sysPath:    sysPath,
+		prev:       make(map[string]ioSample),
+	}
+}
+
+func (c *ThrottleCollector) Name() string {
+	return "throttle"
+}
+
+func (c *ThrottleCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]ioSample)
+
+	for _, vol := range volumes {
+		devs := deviceNumbers(c.sysPath, vol)
+		if len(devs) == 0 {
+			continue
+		}
+
+		for _, pod := range volumePods(vol) {
+			podDir, err := cgroup.PodDir(c.cgroupRoot, pod.UID)
+			if err != nil {
+				continue
+			}
+
+			// Attribute to this pod rather than the volume's primary pod
+			labels := volumeLabels(vol)
+			labels[5], labels[6] = pod.Name, pod.Namespace
+			c.collectPod(podDir, devs, labels, now, seen, ch)
+		}
+	}
+
+	c.prev = seen
+	return nil
+}
+
+// collectPod emits the effective limits of a pod on the first of devs with
+// any, taking the strictest limit across the pod and container cgroups and
+// the highest utilization of any of them
+func (c *ThrottleCollector) collectPod(podDir string, devs, labels []string, now time.Time, seen map[string]ioSample, ch chan<- prometheus.Metric) {
+	limit := make([]uint64, len(ioDims))
+	ratio := make([]float64, len(ioDims))
+	hasRatio := make([]bool, len(ioDims))
+	found := false
+
+	for _, dir := range cgroup.Dirs(podDir) {
+		limits, err := cgroup.ReadIOLimits(dir)
+		if err != nil || len(limits) == 0 {
+			continue
+		}
+		var dev string
+		var l *cgroup.IOLimit
+		for _, d := range devs {
+			if l = limits[d]; l != nil {
+				dev = d
+				break
+			}
+		}
+		if l == nil {
+			continue
+		}
+		found = true
+
+		var stat *cgroup.IOStat
+		if stats, err := cgroup.ReadIOStats(dir); err == nil {
+			stat = stats[dev]
+		}
+		key := dir + "|" + dev
+		prev, hasPrev := c.prev[key]
+		if stat != nil {
+			seen[key] = ioSample{stat: *stat, time: now}
+		}
+
+		for i, d := range ioDims {
+			lim := d.limit(l)
+			if lim == 0 {
+				continue
+			}
+			if limit[i] == 0 || lim < limit[i] {
+				limit[i] = lim
+			}
+			if stat == nil || !hasPrev {
+				continue
+			}
+			elapsed := now.Sub(prev.time).Seconds()
+			cur, last := d.usage(stat), d.usage(&prev.stat)
+			if elapsed <= 0 || cur < last {
+				continue
+			}
+			r := float64(cur-last) / elapsed / float64(lim)
+			if !hasRatio[i] || r > ratio[i] {
+				ratio[i], hasRatio[i] = r, true
+			}
+		}
+	}
+	if !found {
+		return
+	}
+
+	for i, d := range ioDims {
+		if limit[i] == 0 {
+			continue
+		}
+		desc := ioLimitBytesDesc
+		if d.kind == "iops" {
+			desc = ioLimitIOPSDesc
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(limit[i]), append(labels, d.direction)...)
+		if hasRatio[i] {
+			ch <- prometheus.MustNewConstMetric(ioThrottleRatioDesc, prometheus.GaugeValue, ratio[i], append(labels, d.direction, d.kind)...)
+		}
+	}
+}
+
+// deviceNumbers returns the major:minor numbers cgroup limits and stats for
+// a volume may be keyed by: its own device and, for partitions, the whole disk
+func deviceNumbers(sysPath string, vol *discovery.VolumeInfo) []string {
+	var devs []string
+	if vol.DeviceID != "" {
+		devs = append(devs, vol.DeviceID)
+	}
+	if vol.DeviceName != "" {
+		if parent, ok := sysfs.ParentDevice(sysPath, vol.DeviceName); ok {
+			if id, err := sysfs.DeviceNumber(sysPath, parent); err == nil {
+				devs = append(devs, id)
+			}
+		}
+	}
+	return devs
+}
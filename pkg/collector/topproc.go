@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/procio"
+)
+
+var topProcessIODesc = prometheus.NewDesc(
+	"volume_top_process_io_bytes_per_second",
+	"Storage I/O rate since the previous scrape of the busiest processes in pods mounting the volume, top N per direction. A process's I/O is not split by device, so it counts toward every volume its pod mounts.",
+	append(append([]string{}, volumeLabels_...), "direction", "rank", "pid", "comm"), nil,
+)
+
+// TopProcessesCollector samples /proc/<pid>/io for processes in the pods
+// mounting each volume and exports the top N readers and writers, to tell
+// which container process is hammering a volume
+type TopProcessesCollector struct {
+	procPath string
+	n        int
+
+	mu   sync.Mutex
+	prev map[int]procSample // keyed by pid
+}
+
+type procSample struct {
+	comm  string
+	read  uint64
+	write uint64
+	time  time.Time
+}
+
+type procRate struct {
+	*procio.Process
+	read, write float64
+}
+
+// NewTopProcessesCollector creates a collector exporting the top n
+// processes per volume and direction
+func NewTopProcessesCollector(procPath string, n int) *TopProcessesCollector {
+	return &TopProcessesCollector{
+		procPath: procPath,
+		n:        n,
+		prev:     make(map[int]procSample),
+	}
+}
+
+func (c *TopProcessesCollector) Name() string {
+	return "topprocesses"
+}
+
+func (c *TopProcessesCollector) Update(volumes []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	uids := make(map[string]bool)
+	for _, vol := range volumes {
+		for _, p := range volumePods(vol) {
+			uids[p.UID] = true
+		}
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	procs, err := procio.ScanPods(c.procPath, uids)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	byPod := make(map[string][]procRate)
+	seen := make(map[int]procSample, len(procs))
+	for _, p := range procs {
+		seen[p.PID] = procSample{comm: p.Comm, read: p.ReadBytes, write: p.WriteBytes, time: now}
+
+		// Skip the first sample of a pid, and pids reused by another command
+		prev, ok := c.prev[p.PID]
+		if !ok || prev.comm != p.Comm || p.ReadBytes < prev.read || p.WriteBytes < prev.write {
+			continue
+		}
+		elapsed := now.Sub(prev.time).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		byPod[p.PodUID] = append(byPod[p.PodUID], procRate{
+			Process: p,
+			read:    float64(p.ReadBytes-prev.read) / elapsed,
+			write:   float64(p.WriteBytes-prev.write) / elapsed,
+		})
+	}
+	c.prev = seen
+	c.mu.Unlock()
+
+	for _, vol := range volumes {
+		var rates []procRate
+		pods := make(map[string]discovery.PodRef)
+		for _, p := range volumePods(vol) {
+			rates = append(rates, byPod[p.UID]...)
+			pods[p.UID] = p
+		}
+		c.emitTop(vol, pods, rates, "read", func(r procRate) float64 { return r.read }, ch)
+		c.emitTop(vol, pods, rates, "write", func(r procRate) float64 { return r.write }, ch)
+	}
+	return nil
+}
+
+// emitTop emits the n processes with the highest non-zero rate
+func (c *TopProcessesCollector) emitTop(vol *discovery.VolumeInfo, pods map[string]discovery.PodRef, rates []procRate, direction string, rate func(procRate) float64, ch chan<- prometheus.Metric) {
+	sort.Slice(rates, func(i, j int) bool { return rate(rates[i]) > rate(rates[j]) })
+	for i, r := range rates {
+		if i >= c.n || rate(r) == 0 {
+			break
+		}
+		pod := pods[r.PodUID]
+		labels := volumeLabels(vol)
+		labels[5], labels[6] = pod.Name, pod.Namespace
+		ch <- prometheus.MustNewConstMetric(topProcessIODesc, prometheus.GaugeValue, rate(r),
+			append(labels, direction, strconv.Itoa(i+1), strconv.Itoa(r.PID), r.Comm)...)
+	}
+}
+
+// volumePods returns the pods consuming a volume, falling back to its
+// primary pod for discoverers that don't fill in Pods
+func volumePods(vol *discovery.VolumeInfo) []discovery.PodRef {
+	if len(vol.Pods) == 0 && vol.PodUID != "" {
+		return []discovery.PodRef{{Name: vol.PodName, Namespace: vol.PodNamespace, UID: vol.PodUID}}
+	}
+	return vol.Pods
+}
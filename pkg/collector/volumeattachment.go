@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+var volumeAttachmentLabels = []string{"pv", "attacher", "volume_attachment"}
+
+var volumeAttachmentErrorLabels = append(append([]string{}, volumeAttachmentLabels...), "message")
+
+var (
+	volumeAttachmentAttachedDesc = prometheus.NewDesc(
+		"volume_attachment_attached",
+		"Whether the attacher reports the VolumeAttachment of a PV to this node attached",
+		volumeAttachmentLabels, nil,
+	)
+	volumeAttachmentDetachingDesc = prometheus.NewDesc(
+		"volume_attachment_detaching",
+		"Whether the VolumeAttachment of a PV to this node is being deleted, i.e. the volume is being detached",
+		volumeAttachmentLabels, nil,
+	)
+	volumeAttachmentAttachErrorDesc = prometheus.NewDesc(
+		"volume_attachment_attach_error",
+		"Whether the last attach of a PV to this node failed, with the attacher's message",
+		volumeAttachmentErrorLabels, nil,
+	)
+	volumeAttachmentDetachErrorDesc = prometheus.NewDesc(
+		"volume_attachment_detach_error",
+		"Whether the last detach of a PV from this node failed, with the attacher's message",
+		volumeAttachmentErrorLabels, nil,
+	)
+)
+
+// volumeAttachmentInterval is how often VolumeAttachments are listed by
+// default; they're cluster-wide, and listed from every node
+const volumeAttachmentInterval = 30 * time.Second
+
+// VolumeAttachmentCollector reports the VolumeAttachments of this node: whether
+// each PV is attached, being detached, and the attacher's last attach or
+// detach error. A CSI driver stuck retrying an attach or detach otherwise
+// only shows in the controller's logs, far from the node it blocks. Series
+// are labelled by PV, as a volume still attaching has no pod mounting it.
+type VolumeAttachmentCollector struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewVolumeAttachmentCollector creates a VolumeAttachment collector using
+// the in-cluster config. discovery.ErrNotInCluster is returned outside a
+// cluster.
+func NewVolumeAttachmentCollector() (*VolumeAttachmentCollector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if rest.ErrNotInCluster == err {
+			return nil, discovery.ErrNotInCluster
+		}
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVolumeAttachmentCollectorForClient(client, discovery.DetectNodeName()), nil
+}
+
+// NewVolumeAttachmentCollectorForClient creates a VolumeAttachment collector
+// for the given node using an existing client
+func NewVolumeAttachmentCollectorForClient(client kubernetes.Interface, nodeName string) *VolumeAttachmentCollector {
+	return &VolumeAttachmentCollector{client: client, nodeName: nodeName}
+}
+
+func (c *VolumeAttachmentCollector) Name() string {
+	return "volumeattachment"
+}
+
+func (c *VolumeAttachmentCollector) Interval() time.Duration {
+	return volumeAttachmentInterval
+}
+
+func (c *VolumeAttachmentCollector) Update(_ []*discovery.VolumeInfo, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	attachments, err := listVolumeAttachments(ctx, c.client)
+	if err != nil {
+		return fmt.Errorf("list volumeattachments: %w", err)
+	}
+
+	for i := range attachments {
+		va := &attachments[i]
+		if va.Spec.NodeName != c.nodeName {
+			continue
+		}
+		// Inline volumes (migrated in-tree specs) have no PV
+		var pv string
+		if va.Spec.Source.PersistentVolumeName != nil {
+			pv = *va.Spec.Source.PersistentVolumeName
+		}
+		labels := []string{pv, va.Spec.Attacher, va.Name}
+		ch <- prometheus.MustNewConstMetric(volumeAttachmentAttachedDesc, prometheus.GaugeValue, boolToFloat(va.Status.Attached), labels...)
+		ch <- prometheus.MustNewConstMetric(volumeAttachmentDetachingDesc, prometheus.GaugeValue, boolToFloat(va.DeletionTimestamp != nil), labels...)
+		ch <- volumeAttachmentErrorMetric(volumeAttachmentAttachErrorDesc, va.Status.AttachError, labels)
+		ch <- volumeAttachmentErrorMetric(volumeAttachmentDetachErrorDesc, va.Status.DetachError, labels)
+	}
+	return nil
+}
+
+// volumeAttachmentErrorMetric emits 1 with the error's message when err is
+// set, otherwise 0 with an empty message so healthy attachments have a
+// single stable series
+func volumeAttachmentErrorMetric(desc *prometheus.Desc, err *storagev1.VolumeError, labels []string) prometheus.Metric {
+	var message string
+	if err != nil {
+		message = truncateReason(err.Message)
+	}
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(err != nil), append(labels, message)...)
+}
+
+// listVolumeAttachments lists every VolumeAttachment; they can't be
+// selected by node
+func listVolumeAttachments(ctx context.Context, client kubernetes.Interface) ([]storagev1.VolumeAttachment, error) {
+	list, err := client.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
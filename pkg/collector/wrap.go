@@ -0,0 +1,177 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// wrappedCollector prefixes the metric names of a collector and adds
+// constant labels, like registering it through prometheus.WrapRegistererWith
+// and WrapRegistererWithPrefix. Those build a new Desc for every metric of
+// every scrape, which with hundreds of volumes was most of a scrape's
+// allocations; here each Desc is wrapped once and reused. Descs built
+// during a scrape, as plugins' are, are never seen again, so like
+// labelPairCache entries they are dropped after a Collect not using them.
+type wrappedCollector struct {
+	c      prometheus.Collector
+	prefix string
+	labels []*dto.LabelPair // sorted by name
+
+	mu    sync.RWMutex
+	gen   uint64
+	descs map[*prometheus.Desc]*wrappedDesc
+}
+
+type wrappedDesc struct {
+	desc *prometheus.Desc
+	used uint64 // generation last used in
+}
+
+func newWrappedCollector(c prometheus.Collector, prefix string, labels prometheus.Labels) *wrappedCollector {
+	w := &wrappedCollector{c: c, prefix: prefix, descs: make(map[*prometheus.Desc]*wrappedDesc)}
+	for name, value := range labels {
+		w.labels = append(w.labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	slices.SortFunc(w.labels, compareLabelPairs)
+	return w
+}
+
+func (w *wrappedCollector) Describe(ch chan<- *prometheus.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		w.c.Describe(descs)
+		close(descs)
+	}()
+	for d := range descs {
+		ch <- w.desc(d)
+	}
+}
+
+func (w *wrappedCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		w.c.Collect(metrics)
+		close(metrics)
+	}()
+	// Allocated in blocks rather than one by one, as nearly every metric of
+	// a scrape passes through here
+	var block []wrappedMetric
+	for m := range metrics {
+		if len(block) == 0 {
+			block = make([]wrappedMetric, 256)
+		}
+		wm := &block[0]
+		block = block[1:]
+		*wm = wrappedMetric{Metric: m, desc: w.desc(m.Desc()), labels: w.labels}
+		ch <- wm
+	}
+	w.sweep()
+}
+
+// desc returns d wrapped, wrapping it on first use
+func (w *wrappedCollector) desc(d *prometheus.Desc) *prometheus.Desc {
+	w.mu.RLock()
+	e, ok := w.descs[d]
+	stale := ok && e.used != w.gen
+	w.mu.RUnlock()
+	if ok {
+		if stale {
+			w.mu.Lock()
+			e.used = w.gen
+			w.mu.Unlock()
+		}
+		return e.desc
+	}
+
+	// Have the library wrap it, so names and label validation match
+	// registering through its wrapping registerer
+	labels := make(prometheus.Labels, len(w.labels))
+	for _, lp := range w.labels {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	capture := &captureRegisterer{}
+	prometheus.WrapRegistererWithPrefix(w.prefix, prometheus.WrapRegistererWith(labels, capture)).MustRegister(descCollector{d})
+	descs := make(chan *prometheus.Desc, 1)
+	capture.c.Describe(descs)
+	wd := <-descs
+
+	w.mu.Lock()
+	w.descs[d] = &wrappedDesc{desc: wd, used: w.gen}
+	w.mu.Unlock()
+	return wd
+}
+
+// sweep drops the Descs no Collect used since the previous sweep
+func (w *wrappedCollector) sweep() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for d, e := range w.descs {
+		if e.used != w.gen {
+			delete(w.descs, d)
+		}
+	}
+	w.gen++
+}
+
+// wrappedMetric is a metric with a wrapped Desc and the constant labels
+type wrappedMetric struct {
+	prometheus.Metric
+	desc   *prometheus.Desc
+	labels []*dto.LabelPair
+}
+
+func (m *wrappedMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *wrappedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	if len(m.labels) == 0 {
+		return nil
+	}
+	out.Label = append(out.Label, m.labels...)
+	slices.SortFunc(out.Label, compareLabelPairs)
+	return nil
+}
+
+func compareLabelPairs(a, b *dto.LabelPair) int {
+	return strings.Compare(a.GetName(), b.GetName())
+}
+
+// captureRegisterer keeps the collector registered into it
+type captureRegisterer struct {
+	c prometheus.Collector
+}
+
+func (r *captureRegisterer) Register(c prometheus.Collector) error {
+	r.c = c
+	return nil
+}
+
+func (r *captureRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.c = c
+	}
+}
+
+func (r *captureRegisterer) Unregister(prometheus.Collector) bool {
+	return false
+}
+
+// descCollector describes a single Desc
+type descCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c descCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c descCollector) Collect(chan<- prometheus.Metric) {}
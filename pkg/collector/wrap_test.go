@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+)
+
+// Plugins build new Descs on every scrape; the wrapped Descs of earlier
+// scrapes must not pile up
+func TestWrappedCollectorPluginDescs(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "plugin")
+	out := "#!/bin/sh\ncat >/dev/null\necho 'plugin_value{kind=\"a\"} 1'\necho 'plugin_value{kind=\"b\"} 2'\n"
+	if err := os.WriteFile(script, []byte(out), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	proc := t.TempDir()
+	if err := os.WriteFile(filepath.Join(proc, "diskstats"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := discoverytest.New("fake", discoverytest.Volume("data-db-0", "db"))
+	v := NewVolumeCollector(discovery.NewMultiDiscoverer(d), proc, NewExecCollector("test", script, "node", 0))
+	w := newWrappedCollector(v, "volmetd_", prometheus.Labels{"cluster": "test"})
+
+	collect := func() {
+		t.Helper()
+		ch := make(chan prometheus.Metric)
+		go func() {
+			w.Collect(ch)
+			close(ch)
+		}()
+		plugin := 0
+		for m := range ch {
+			if strings.Contains(m.Desc().String(), `"volmetd_plugin_value"`) {
+				plugin++
+			}
+		}
+		if plugin != 2 {
+			t.Fatalf("collected %d plugin metrics, want 2", plugin)
+		}
+	}
+
+	collect()
+	w.mu.RLock()
+	want := len(w.descs)
+	w.mu.RUnlock()
+	for i := 0; i < 10; i++ {
+		collect()
+		w.mu.RLock()
+		got := len(w.descs)
+		w.mu.RUnlock()
+		if got != want {
+			t.Fatalf("scrape %d: %d wrapped Descs, want %d", i+2, got, want)
+		}
+	}
+}
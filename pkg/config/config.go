@@ -1,14 +1,21 @@
 package config
 
 import (
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
 )
 
 // Discovery method names
 const (
-	DiscoveryCSI    = "csi"
-	DiscoveryK8sAPI = "k8sapi"
+	DiscoveryCSI      = "csi"
+	DiscoveryK8sAPI   = "k8sapi"
+	DiscoveryHostPath = "hostpath" // opt-in, not among the defaults
 )
 
 // DefaultDiscoveryMethods is the default order of discovery methods
@@ -18,54 +25,364 @@ var DefaultDiscoveryMethods = []string{DiscoveryK8sAPI, DiscoveryCSI}
 // Config holds the application configuration
 type Config struct {
 	// HTTP server
-	ListenAddr  string
-	MetricsPath string
+	ListenAddr   string
+	MetricsPath  string
+	MetricPrefix string // prepended to all metric names, e.g. "volmetd_"
+
+	// Scrapes served at once; more get 503 with Retry-After. 0 = unlimited.
+	MaxConcurrentScrapes int
+	// Series served per scrape; beyond it the metric families with the most
+	// series, less the scrape and discovery health ones, are dropped and
+	// series_limited is 1. 0 = unlimited.
+	SeriesLimit int
+
+	// Response encodings offered to scrapers in order of preference, "gzip"
+	// and "zstd" (zstd build tag only); "none" disables compression
+	Compression []string
+	// Offer the OpenMetrics format (exemplars) besides text and protobuf.
+	// Off by default, so scrapers keep the format they negotiate today.
+	OpenMetrics bool
 
 	// Paths (for running in containers with host mounts)
 	HostProcPath string // /proc on host
+	HostSysPath  string // /sys on host
 	KubeletPath  string // /var/lib/kubelet on host
+	// Kubelet directory layout, see kubelet.LayoutByName; detected from the
+	// kubelet directories present by default
+	KubeletLayout string
+	HostDevPath   string // /dev on host, empty = resolve against local /dev
+	UdevDataPath  string // udev database for resolving /dev symlinks, empty = disabled
+
+	// Run against a captured node tree instead of the live node, see package
+	// fixture. Host paths are redirected into it and API objects are served
+	// from its api directory.
+	FixtureDir string
 
 	// Filtering
-	Namespaces []string // empty = all namespaces
+	Namespaces        []string // empty = all namespaces
+	ExcludeNamespaces []string // never discovered, even if in Namespaces
+	APIConcurrency    int      // namespaces listed at once by k8sapi discovery
 
 	// Discovery methods in priority order
 	DiscoveryMethods []string
 
+	// Host paths the hostpath discoverer reports hostPath volumes at or
+	// beneath, e.g. /data; empty = any outside the kubelet directory
+	HostPathPrefixes []string
+
+	// Host directory of the kubelet's static pod manifests, e.g.
+	// /etc/kubernetes/manifests, whose pods the hostpath discoverer reports
+	// when the API has no mirror pod for them; empty = disabled
+	StaticPodPath string
+
+	// Don't list PersistentVolumes cluster-wide; take storage class from
+	// PVCs and CSI driver and volume handle from vol_data.json
+	MinimalRBAC bool
+
+	// Per-discoverer retries and circuit breaker
+	DiscoveryAttempts          int           // tries per discovery run
+	DiscoveryBreakerThreshold  int           // consecutive failed runs to open, 0 = disabled
+	DiscoveryBreakerMaxBackoff time.Duration // longest the breaker stays open
+	DiscoveryStaleTTL          time.Duration // serve last volumes this long when discovery fails, 0 = disabled
+
+	// Longest /readyz waits at startup for discovery to first succeed, so a
+	// fresh pod isn't scraped empty; 0 = ready immediately
+	ReadinessMaxWait time.Duration
+
+	// File the discovered volumes are persisted to and loaded from on
+	// startup, served as stale until discovery first succeeds; off if empty
+	DiscoveryStateFile string
+
+	// Parse mounts and resolve device symlinks in the host mount namespace
+	// via <HostProcPath>/1, falling back to the local namespace without access
+	HostMountNamespace bool
+	// Read the host mount namespace through the first process of this name
+	// instead of PID 1, e.g. kubelet where it has a mount namespace of its own
+	MountNamespaceProcess string
+
+	// Run on OpenShift, see ApplyOpenShift
+	OpenShift bool
+
+	// Run without root or capabilities: disables host namespace access, the
+	// dir-walking csi discoverer, device symlinks and per-process I/O, falling
+	// back to the mount table, sysfs and the kubelet stats API. See ApplyRootless.
+	Rootless bool
+	// Kubelet API, e.g. https://10.0.0.5:10250, read for volume capacity
+	// statfs can't get; defaults to the node's kubelet (HOST_IP) when rootless
+	KubeletURL string
+
+	// Run capacity statfs in the host mount namespace via <HostProcPath>/1/root
+	CapacityHostNamespace bool
+	HostKubeletPath       string // kubelet path on the host, for host namespace resolution
+
+	// Also emit diskstats for the parent disk of partition-backed volumes
+	ParentDeviceMetrics bool
+
+	// Also emit diskstats as device_* labelled by device and pv only
+	DeviceMetrics bool
+
+	// Also emit diskstats for node block devices backing no volume,
+	// labeled device_role="node"
+	AllDevices bool
+
+	// Regular expressions selecting the devices diskstats are emitted for by
+	// name; empty = every device, none. DeviceExclude defaults to loop, RAM,
+	// zram, optical and floppy devices.
+	DeviceInclude string
+	DeviceExclude string
+
+	// Hold diskstats counters at their previous value when a device's
+	// counters go backwards, instead of exporting the reset
+	ClampCounterResets bool
+
+	// Sample diskstats this often for device_utilization, avg_queue_depth
+	// and the node's IOPS and throughput distribution, which are rates over
+	// the latest interval rather than since the previous scrape
+	RateInterval time.Duration
+
+	// Report project quota (or du) usage for PVCs carved as directories from
+	// a shared filesystem, e.g. local-path or NFS subdir provisioners
+	SubpathCapacity bool
+
+	// Report usage beneath each subPath containers mount of a PVC
+	// (subpath_*), for PVCs split between tenants by subPath
+	SubPathMountUsage bool
+
+	// Sliding window for capacity fill rate forecasting, 0 = disabled
+	ForecastWindow time.Duration
+
+	// Percent used above which a volume's capacity is also sampled every
+	// HighUsageInterval between scrapes, for capacity_bytes_used_max
+	HighUsageThreshold float64 // 0 = disabled
+	HighUsageInterval  time.Duration
+
+	// Collectors run in the background at most this often, serving cached
+	// metrics in between, by name. Collectors may declare their own default;
+	// 0 runs one on every scrape.
+	CollectorIntervals map[string]time.Duration
+
+	// Sample capacity and I/O in progress this often between scrapes and
+	// export their min/max/avg since the previous scrape, 0 = disabled
+	SampleInterval time.Duration
+
+	// Export VolumeSnapshot state for PVCs on this node via the K8s API
+	SnapshotMetrics bool
+
+	// Export StorageClass parameters of discovered volumes via the K8s API
+	StorageClassInfo bool
+
+	// Call NodeGetVolumeStats on CSI node plugin sockets under <KubeletPath>/plugins
+	CSIVolumeStats bool
+
+	// Report volume_abnormal from CSI health monitor events on PVCs
+	VolumeHealthEvents bool
+
+	// Flag single-writer volumes mounted here that VolumeAttachments show
+	// attached to another node too
+	MultiAttachDetection bool
+
+	// Also run the csi and k8sapi discoverers independently and export how
+	// their volumes disagree
+	DiscoveryCompare bool
+
+	// Export attach state and attach/detach errors of this node's
+	// VolumeAttachments
+	VolumeAttachmentMetrics bool
+
+	// Export used and limit bytes of pods' emptyDir volumes on this node
+	EmptyDirMetrics bool
+
+	// Export capacity of the node's root, kubelet and image filesystems
+	// (node_fs_capacity_*) through <HostProcPath>/1/root
+	NodeFSMetrics bool
+
+	// Container runtime CRI socket, e.g. /run/containerd/containerd.sock,
+	// read for container writable layer and image filesystem usage. Empty =
+	// disabled.
+	CRISocket string
+
+	// Cloud disk enrichers to enable (gce, azure). They need credentials from
+	// the instance metadata service, so none are enabled by default.
+	CloudEnrichers []string
+
+	// Export the top N processes by storage I/O in pods mounting each
+	// volume, read from <HostProcPath>/<pid>/io, 0 = disabled
+	TopProcesses int
+
+	// Compare pod cgroup I/O with device I/O per volume (io_amplification_ratio)
+	IOAmplification bool
+
+	// Directory of external collector plugins (see package plugin), each run
+	// on every scrape for at most PluginTimeout
+	PluginDir     string
+	PluginTimeout time.Duration
+
+	// Active probes, off by default: the fsync probe writes a file into every
+	// volume, and the read probe touches every mount root on each scrape
+	ProbeFsync    bool
+	ProbeInterval time.Duration
+	ProbeRead     bool
+	ProbeTimeout  time.Duration // bound on each read probe
+
+	// Command run for every volume each ProbeInterval, printing metrics in
+	// Prometheus text format (see collector.ExecProbeCollector); off if empty
+	ProbeExec        string
+	ProbeExecTimeout time.Duration
+
+	// Kernel log (usually /dev/kmsg) tailed for filesystem and device errors
+	// (see package kmsg); off if empty
+	KernelLogPath string
+
+	// Target average read/write latency per storage class, e.g.
+	// "gp3=10ms/20ms,*=50ms", exported as latency_slo_violation_seconds_total
+	LatencySLO string
+
+	// ConfigMap ("namespace/name", or a name in volmetd's namespace) holding
+	// per-storage-class policy and collector toggles, watched at runtime
+	PolicyConfigMap string
+
+	// Node topology, looked up from the Node object when empty
+	NodeZone   string
+	NodeRegion string
+	// Add node, zone and region as constant labels on every metric
+	NodeLabels bool
+	// Leave pod and pod_namespace empty on per-volume metrics, keying series
+	// by PVC so they survive pod restarts
+	OmitPodLabels bool
+	// Exclude volumes backing Rook/Ceph OSDs from per-volume metrics,
+	// exporting ceph_osd_volume for them instead
+	CephOSDAwareness bool
+
+	// gRPC volume inventory server (disabled when listen addr is empty)
+	GRPCListenAddr string
+	GRPCInterval   time.Duration
+
+	// Webhook notifications (disabled when URL is empty)
+	WebhookURL           string
+	WebhookInterval      time.Duration
+	WebhookFillThreshold float64 // percent used, 0 = disabled
+
+	// Cluster aggregator (volmetd aggregate)
+	AggregateSelector   string // label selector of node volmetd pods in the aggregator's namespace
+	AggregateInterval   time.Duration
+	AggregateTopN       int    // fullest volumes exported
+	AggregateObjectInfo bool   // info series for each unbound PV and Pending/Lost PVC
+	AggregateLeaseName  string // Lease used for leader election
 }
 
 // DefaultConfig returns the default configuration with auto-detected paths
 func DefaultConfig() *Config {
+	layout, kubeletPath := detectKubeletPath()
 	return &Config{
-		ListenAddr:       ":6060",
-		MetricsPath:      "/metrics",
-		HostProcPath:     detectProcPath(),
-		KubeletPath:      detectKubeletPath(),
-		Namespaces:       nil,
-		DiscoveryMethods: DefaultDiscoveryMethods,
+		ListenAddr:           ":6060",
+		MetricsPath:          "/metrics",
+		MetricPrefix:         "volmetd_",
+		MaxConcurrentScrapes: 4,
+		Compression:          []string{"zstd", "gzip"},
+		HostProcPath:         detectProcPath(),
+		HostSysPath:          detectSysPath(),
+		KubeletPath:          kubeletPath,
+		KubeletLayout:        layout.Name(),
+		Namespaces:           nil,
+		DiscoveryMethods:     DefaultDiscoveryMethods,
+		HostKubeletPath:      layout.Root(),
+		DeviceExclude:        diskstats.DefaultDeviceExclude,
+		APIConcurrency:       8,
+
+		DiscoveryAttempts:          2,
+		DiscoveryBreakerThreshold:  3,
+		DiscoveryBreakerMaxBackoff: 5 * time.Minute,
+		DiscoveryStaleTTL:          5 * time.Minute,
+		ReadinessMaxWait:           30 * time.Second,
+
+		RateInterval: 15 * time.Second,
+
+		ForecastWindow:    6 * time.Hour,
+		HighUsageInterval: time.Second,
+
+		ProbeInterval: time.Minute,
+		ProbeTimeout:  5 * time.Second,
+
+		ProbeExecTimeout: 30 * time.Second,
+
+		PluginTimeout: 10 * time.Second,
+
+		GRPCInterval: 30 * time.Second,
+
+		WebhookInterval:      time.Minute,
+		WebhookFillThreshold: 90,
+
+		AggregateSelector:  "app.kubernetes.io/name=volmetd",
+		AggregateInterval:  time.Minute,
+		AggregateTopN:      10,
+		AggregateLeaseName: "volmetd-aggregator",
 	}
 }
 
-// detectProcPath returns /host/proc if it exists (container), otherwise /proc
+// hostRoots are where the host's root may be seen from, in the order
+// probed: the DaemonSet's /host mounts, the host rootfs Bottlerocket mounts
+// into its admin and control containers, and / when not containerized
+var hostRoots = []string{"/host", "/.bottlerocket/rootfs", ""}
+
+// detectProcPath returns the host's /proc beneath the first of hostRoots it
+// is found in, /host/proc in a container and otherwise /proc
 func detectProcPath() string {
-	if _, err := os.Stat("/host/proc/diskstats"); err == nil {
-		return "/host/proc"
+	for _, root := range hostRoots {
+		if _, err := os.Stat(root + "/proc/diskstats"); err == nil {
+			return root + "/proc"
+		}
 	}
 	return "/proc"
 }
 
-// detectKubeletPath returns the kubelet path, checking common mount points
-func detectKubeletPath() string {
-	candidates := []string{
-		"/host/var/lib/kubelet",
-		"/var/lib/kubelet",
-	}
-	for _, p := range candidates {
-		if _, err := os.Stat(p + "/pods"); err == nil {
-			return p
+// detectSysPath returns the host's /sys beneath the first of hostRoots it is
+// found in, /host/sys in a container and otherwise /sys
+func detectSysPath() string {
+	for _, root := range hostRoots {
+		if _, err := os.Stat(root + "/sys/block"); err == nil {
+			return root + "/sys"
 		}
 	}
+	return "/sys"
+}
+
+// detectKubeletPath returns the kubelet layout and path, checking the roots
+// of known layouts (k3s, microk8s, upstream) beneath hostRoots, see
+// kubelet.DetectLayout. Bottlerocket keeps upstream's root, and is told
+// apart by its /.bottlerocket directory.
+func detectKubeletPath() (kubelet.Layout, string) {
 	// Default to container path since that's the primary use case
-	return "/host/var/lib/kubelet"
+	layout, prefix := kubelet.DetectLayout(hostRoots...)
+	if layout == kubelet.Standard && isBottlerocket() {
+		layout = kubelet.Bottlerocket
+	}
+	return layout, prefix + layout.Root()
+}
+
+// bottlerocketMarkers are paths of the host's /.bottlerocket: through the
+// host's PID 1 with hostPID, or itself in Bottlerocket's own containers and
+// on the host
+var bottlerocketMarkers = []string{"/host/proc/1/root/.bottlerocket", "/proc/1/root/.bottlerocket", "/.bottlerocket"}
+
+// isBottlerocket reports whether volmetd runs on a Bottlerocket host
+func isBottlerocket() bool {
+	for _, p := range bottlerocketMarkers {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutKubeletPath returns the path of layout's kubelet root beneath the
+// first of hostRoots it is found in, by default beneath /host
+func layoutKubeletPath(layout kubelet.Layout) string {
+	for _, root := range hostRoots {
+		if _, err := os.Stat(layout.PodsDir(root + layout.Root())); err == nil {
+			return root + layout.Root()
+		}
+	}
+	return hostRoots[0] + layout.Root()
 }
 
 // FromEnv loads configuration from environment variables
@@ -78,22 +395,403 @@ func FromEnv() *Config {
 	if v := os.Getenv("VOLMETD_METRICS_PATH"); v != "" {
 		c.MetricsPath = v
 	}
+	if v := os.Getenv("VOLMETD_MAX_CONCURRENT_SCRAPES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.MaxConcurrentScrapes = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_SERIES_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.SeriesLimit = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_COMPRESSION"); v != "" {
+		c.Compression = parseList(v)
+	}
+	if v := os.Getenv("VOLMETD_OPENMETRICS"); v != "" {
+		c.OpenMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_METRIC_PREFIX"); v != "" {
+		if !strings.HasSuffix(v, "_") {
+			v += "_"
+		}
+		c.MetricPrefix = v
+	}
 	if v := os.Getenv("VOLMETD_HOST_PROC_PATH"); v != "" {
 		c.HostProcPath = v
 	}
+	if v := os.Getenv("VOLMETD_HOST_SYS_PATH"); v != "" {
+		c.HostSysPath = v
+	}
+	if v := os.Getenv("VOLMETD_HOST_DEV_PATH"); v != "" {
+		c.HostDevPath = v
+	}
+	if v := os.Getenv("VOLMETD_UDEV_DATA_PATH"); v != "" {
+		c.UdevDataPath = v
+	}
+	if v := os.Getenv("VOLMETD_KUBELET_LAYOUT"); v != "" {
+		c.KubeletLayout = v
+		// Its root, unless the paths are set too
+		if l, ok := kubelet.LayoutByName(v); ok {
+			c.KubeletPath = layoutKubeletPath(l)
+			c.HostKubeletPath = l.Root()
+		}
+	}
 	if v := os.Getenv("VOLMETD_KUBELET_PATH"); v != "" {
 		c.KubeletPath = v
 	}
+	if v := os.Getenv("VOLMETD_FIXTURE_DIR"); v != "" {
+		c.FixtureDir = v
+	}
 	if v := os.Getenv("VOLMETD_NAMESPACES"); v != "" {
 		c.Namespaces = parseList(v)
 	}
+	if v := os.Getenv("VOLMETD_EXCLUDE_NAMESPACES"); v != "" {
+		c.ExcludeNamespaces = parseList(v)
+	}
+	if v := os.Getenv("VOLMETD_API_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.APIConcurrency = n
+		}
+	}
 	if v := os.Getenv("VOLMETD_DISCOVERY_METHODS"); v != "" {
 		c.DiscoveryMethods = parseList(v)
 	}
+	if v := os.Getenv("VOLMETD_HOSTPATH_PREFIXES"); v != "" {
+		c.HostPathPrefixes = parseList(v)
+	}
+	if v := os.Getenv("VOLMETD_STATIC_POD_PATH"); v != "" {
+		c.StaticPodPath = v
+	}
+	if v := os.Getenv("VOLMETD_MINIMAL_RBAC"); v != "" {
+		c.MinimalRBAC = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.DiscoveryAttempts = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_BREAKER_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.DiscoveryBreakerThreshold = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_BREAKER_MAX_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.DiscoveryBreakerMaxBackoff = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_STALE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.DiscoveryStaleTTL = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_READINESS_MAX_WAIT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.ReadinessMaxWait = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_STATE_FILE"); v != "" {
+		c.DiscoveryStateFile = v
+	}
+	if v := os.Getenv("VOLMETD_HOST_MOUNT_NAMESPACE"); v != "" {
+		c.HostMountNamespace = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_MOUNT_NAMESPACE_PROCESS"); v != "" {
+		c.MountNamespaceProcess = v
+	}
+	if v := os.Getenv("VOLMETD_OPENSHIFT"); v != "" {
+		c.OpenShift = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_CAPACITY_HOST_NAMESPACE"); v != "" {
+		c.CapacityHostNamespace = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_ROOTLESS"); v != "" {
+		c.Rootless = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_KUBELET_URL"); v != "" {
+		c.KubeletURL = v
+	}
+	if v := os.Getenv("VOLMETD_HOST_KUBELET_PATH"); v != "" {
+		c.HostKubeletPath = v
+	}
+	if v := os.Getenv("VOLMETD_PARENT_DEVICE_METRICS"); v != "" {
+		c.ParentDeviceMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_DEVICE_METRICS"); v != "" {
+		c.DeviceMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_ALL_DEVICES"); v != "" {
+		c.AllDevices = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_DEVICE_INCLUDE"); v != "" {
+		c.DeviceInclude = v
+	}
+	// Set but empty excludes nothing
+	if v, ok := os.LookupEnv("VOLMETD_DEVICE_EXCLUDE"); ok {
+		c.DeviceExclude = v
+	}
+	if v := os.Getenv("VOLMETD_CLAMP_COUNTER_RESETS"); v != "" {
+		c.ClampCounterResets = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_RATE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.RateInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_SUBPATH_CAPACITY"); v != "" {
+		c.SubpathCapacity = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_SUBPATH_MOUNT_USAGE"); v != "" {
+		c.SubPathMountUsage = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_FORECAST_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.ForecastWindow = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_HIGH_USAGE_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.HighUsageThreshold = f
+		}
+	}
+	if v := os.Getenv("VOLMETD_HIGH_USAGE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.HighUsageInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_COLLECTOR_INTERVALS"); v != "" {
+		c.CollectorIntervals = parseDurations(v)
+	}
+	if v := os.Getenv("VOLMETD_SAMPLE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.SampleInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_SNAPSHOT_METRICS"); v != "" {
+		c.SnapshotMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_STORAGECLASS_INFO"); v != "" {
+		c.StorageClassInfo = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_CSI_VOLUME_STATS"); v != "" {
+		c.CSIVolumeStats = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_VOLUME_HEALTH_EVENTS"); v != "" {
+		c.VolumeHealthEvents = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_MULTI_ATTACH_DETECTION"); v != "" {
+		c.MultiAttachDetection = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_DISCOVERY_COMPARE"); v != "" {
+		c.DiscoveryCompare = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_VOLUME_ATTACHMENT_METRICS"); v != "" {
+		c.VolumeAttachmentMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_EMPTYDIR_METRICS"); v != "" {
+		c.EmptyDirMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_NODE_FS_METRICS"); v != "" {
+		c.NodeFSMetrics = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_CRI_SOCKET"); v != "" {
+		c.CRISocket = v
+	}
+	if v := os.Getenv("VOLMETD_CLOUD_ENRICHERS"); v != "" {
+		c.CloudEnrichers = parseList(v)
+	}
+	if v := os.Getenv("VOLMETD_TOP_PROCESSES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.TopProcesses = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_IO_AMPLIFICATION"); v != "" {
+		c.IOAmplification = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_LATENCY_SLO"); v != "" {
+		c.LatencySLO = v
+	}
+	if v := os.Getenv("VOLMETD_POLICY_CONFIGMAP"); v != "" {
+		c.PolicyConfigMap = v
+	}
+	if v := os.Getenv("VOLMETD_PLUGIN_DIR"); v != "" {
+		c.PluginDir = v
+	}
+	if v := os.Getenv("VOLMETD_PLUGIN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.PluginTimeout = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_PROBE_FSYNC"); v != "" {
+		c.ProbeFsync = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_PROBE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.ProbeInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_PROBE_READ"); v != "" {
+		c.ProbeRead = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_PROBE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.ProbeTimeout = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_PROBE_EXEC"); v != "" {
+		c.ProbeExec = v
+	}
+	if v := os.Getenv("VOLMETD_PROBE_EXEC_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.ProbeExecTimeout = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_KERNEL_LOG_PATH"); v != "" {
+		c.KernelLogPath = v
+	}
+	if v := os.Getenv("VOLMETD_NODE_ZONE"); v != "" {
+		c.NodeZone = v
+	}
+	if v := os.Getenv("VOLMETD_NODE_REGION"); v != "" {
+		c.NodeRegion = v
+	}
+	if v := os.Getenv("VOLMETD_NODE_LABELS"); v != "" {
+		c.NodeLabels = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_OMIT_POD_LABELS"); v != "" {
+		c.OmitPodLabels = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_CEPH_OSD_AWARENESS"); v != "" {
+		c.CephOSDAwareness = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_GRPC_LISTEN_ADDR"); v != "" {
+		c.GRPCListenAddr = v
+	}
+	if v := os.Getenv("VOLMETD_GRPC_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.GRPCInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_WEBHOOK_URL"); v != "" {
+		c.WebhookURL = v
+	}
+	if v := os.Getenv("VOLMETD_WEBHOOK_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.WebhookInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_WEBHOOK_FILL_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.WebhookFillThreshold = f
+		}
+	}
+	if v := os.Getenv("VOLMETD_AGGREGATE_SELECTOR"); v != "" {
+		c.AggregateSelector = v
+	}
+	if v := os.Getenv("VOLMETD_AGGREGATE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.AggregateInterval = d
+		}
+	}
+	if v := os.Getenv("VOLMETD_AGGREGATE_TOP_N"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.AggregateTopN = n
+		}
+	}
+	if v := os.Getenv("VOLMETD_AGGREGATE_OBJECT_INFO"); v != "" {
+		c.AggregateObjectInfo = parseBool(v)
+	}
+	if v := os.Getenv("VOLMETD_AGGREGATE_LEASE_NAME"); v != "" {
+		c.AggregateLeaseName = v
+	}
 
+	if c.OpenShift {
+		c.ApplyOpenShift()
+	}
+	if c.Rootless {
+		c.ApplyRootless()
+	}
 	return c
 }
 
+// ApplyOpenShift adapts to OpenShift nodes, where CRI-O and the kubelet run
+// in a mount namespace of their own (kubens). Pod volumes are mounted there
+// and not in the host's, so:
+//   - mounts are read from the host side rather than volmetd's own
+//     namespace, through the kubelet rather than PID 1 unless another
+//     process is configured; this needs hostPID and SYS_PTRACE
+//   - capacity is statfs'd in volmetd's own namespace, which CRI-O created
+//     beneath kubens and sees the volumes through the kubelet directory
+//     mount, rather than beneath PID 1's root, where they aren't mounted
+//
+// The SCC and SELinux type volmetd needs are up to the deployment, see the
+// chart's openshift values.
+func (c *Config) ApplyOpenShift() {
+	if c.MountNamespaceProcess == "" {
+		c.MountNamespaceProcess = "kubelet"
+	}
+	c.HostMountNamespace = true
+	c.CapacityHostNamespace = false
+}
+
+// ApplyRootless turns off what needs privileges, for running as an
+// unprivileged user without hostPID:
+//   - mounts are read from volmetd's own namespace, not the host's
+//   - devices are named by the mount's major:minor in sysfs, not by following
+//     symlinks in the host's /dev
+//   - the csi discoverer, which walks the kubelet's root-only pod
+//     directories, is dropped; k8sapi finds mounts in the mount table
+//   - capacity falls back to the kubelet stats API (KubeletURL)
+//   - per-process I/O, the fsync probe, emptyDir, node filesystem and
+//     container layer metrics, which need ptrace, write access, the pod
+//     directories, the host root and the root-owned CRI socket, are disabled
+func (c *Config) ApplyRootless() {
+	c.HostMountNamespace = false
+	c.CapacityHostNamespace = false
+	c.HostDevPath = ""
+	c.TopProcesses = 0
+	c.ProbeFsync = false
+	c.EmptyDirMetrics = false
+	c.NodeFSMetrics = false
+	c.CRISocket = ""
+
+	var methods []string
+	for _, m := range c.DiscoveryMethods {
+		if m != DiscoveryCSI {
+			methods = append(methods, m)
+		}
+	}
+	c.DiscoveryMethods = methods
+
+	if c.KubeletURL == "" {
+		if ip := os.Getenv("HOST_IP"); ip != "" {
+			c.KubeletURL = "https://" + net.JoinHostPort(ip, "10250")
+		}
+	}
+}
+
+func parseBool(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "1" || s == "true" || s == "yes"
+}
+
+// parseDurations parses name=duration pairs separated by commas, skipping
+// malformed ones
+func parseDurations(s string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	for _, pair := range parseList(s) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(value)); err == nil {
+			result[strings.TrimSpace(name)] = d
+		}
+	}
+	return result
+}
+
 func parseList(s string) []string {
 	parts := strings.Split(s, ",")
 	result := make([]string, 0, len(parts))
@@ -111,6 +809,19 @@ func (c *Config) DiskstatsPath() string {
 	return c.HostProcPath + "/diskstats"
 }
 
+// Layout returns the configured kubelet layout, Standard if unknown
+func (c *Config) Layout() kubelet.Layout {
+	if l, ok := kubelet.LayoutByName(c.KubeletLayout); ok {
+		return l
+	}
+	return kubelet.Standard
+}
+
+// HostRootPath returns the host root filesystem as seen through PID 1
+func (c *Config) HostRootPath() string {
+	return c.HostProcPath + "/1/root"
+}
+
 // MountsPath returns the path to /proc/mounts
 func (c *Config) MountsPath() string {
 	return c.HostProcPath + "/mounts"
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestFromEnvOpenShift(t *testing.T) {
+	t.Setenv("VOLMETD_OPENSHIFT", "true")
+	t.Setenv("VOLMETD_CAPACITY_HOST_NAMESPACE", "true")
+
+	c := FromEnv()
+	if !c.HostMountNamespace {
+		t.Error("HostMountNamespace = false, want mounts read from the host side")
+	}
+	if c.MountNamespaceProcess != "kubelet" {
+		t.Errorf("MountNamespaceProcess = %q, want kubelet", c.MountNamespaceProcess)
+	}
+	if c.CapacityHostNamespace {
+		t.Error("CapacityHostNamespace = true, want capacity statfs'd locally")
+	}
+
+	t.Setenv("VOLMETD_MOUNT_NAMESPACE_PROCESS", "crio")
+	if c := FromEnv(); c.MountNamespaceProcess != "crio" {
+		t.Errorf("MountNamespaceProcess = %q, want the configured crio", c.MountNamespaceProcess)
+	}
+}
@@ -0,0 +1,299 @@
+// Package cri reads container and image filesystem usage from the container
+// runtime (containerd, CRI-O) over its CRI socket. Only the few messages
+// volmetd needs are encoded, by field number, rather than depending on the
+// whole CRI API.
+package cri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	listContainerStatsMethod = "/runtime.v1.RuntimeService/ListContainerStats"
+	imageFsInfoMethod        = "/runtime.v1.ImageService/ImageFsInfo"
+)
+
+// Pod labels the kubelet sets on every container it creates
+const (
+	labelPodName       = "io.kubernetes.pod.name"
+	labelPodNamespace  = "io.kubernetes.pod.namespace"
+	labelPodUID        = "io.kubernetes.pod.uid"
+	labelContainerName = "io.kubernetes.container.name"
+)
+
+// ContainerStats is a container's writable layer usage
+type ContainerStats struct {
+	ID           string
+	Name         string // from the container's metadata
+	Attempt      uint32 // restart count
+	Pod          string
+	PodNamespace string
+	PodUID       string
+
+	// The writable layer, absent if the runtime doesn't report it
+	WritableLayer *FilesystemUsage
+}
+
+// FilesystemUsage is the usage of a filesystem, or of a container's layer
+// on it
+type FilesystemUsage struct {
+	Mountpoint string
+	UsedBytes  uint64
+	UsedInodes uint64
+	HasBytes   bool
+	HasInodes  bool
+}
+
+// Client is a connection to a container runtime's CRI socket
+type Client struct {
+	Socket string
+	conn   *grpc.ClientConn
+}
+
+// NewClient creates a client for the CRI socket at socket, e.g.
+// /run/containerd/containerd.sock. The connection is made on first use.
+func NewClient(socket string) (*Client, error) {
+	conn, err := grpc.NewClient("unix://"+socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Socket: socket, conn: conn}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ListContainerStats returns the stats of every container the runtime has
+func (c *Client) ListContainerStats(ctx context.Context) ([]ContainerStats, error) {
+	var resp []byte
+	if err := c.conn.Invoke(ctx, listContainerStatsMethod, []byte{}, &resp); err != nil {
+		return nil, fmt.Errorf("ListContainerStats: %w", err)
+	}
+
+	var stats []ContainerStats
+	err := fields(resp, func(num protowire.Number, b []byte, _ uint64) error {
+		if num != 1 { // stats
+			return nil
+		}
+		s, err := parseContainerStats(b)
+		if err != nil {
+			return err
+		}
+		stats = append(stats, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListContainerStats: %w", err)
+	}
+	return stats, nil
+}
+
+// ImageFsInfo returns the usage of the filesystems holding images and, if
+// the runtime splits them, container writable layers. Otherwise
+// containerFS is empty and layers are on the image filesystems.
+func (c *Client) ImageFsInfo(ctx context.Context) (imageFS, containerFS []FilesystemUsage, err error) {
+	var resp []byte
+	if err := c.conn.Invoke(ctx, imageFsInfoMethod, []byte{}, &resp); err != nil {
+		return nil, nil, fmt.Errorf("ImageFsInfo: %w", err)
+	}
+
+	err = fields(resp, func(num protowire.Number, b []byte, _ uint64) error {
+		if num != 1 && num != 2 { // image_filesystems, container_filesystems
+			return nil
+		}
+		u, err := parseFilesystemUsage(b)
+		if err != nil {
+			return err
+		}
+		if num == 1 {
+			imageFS = append(imageFS, u)
+		} else {
+			containerFS = append(containerFS, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ImageFsInfo: %w", err)
+	}
+	return imageFS, containerFS, nil
+}
+
+// parseContainerStats parses a runtime.v1.ContainerStats
+func parseContainerStats(b []byte) (ContainerStats, error) {
+	var s ContainerStats
+	err := fields(b, func(num protowire.Number, b []byte, _ uint64) error {
+		switch num {
+		case 1: // attributes
+			return parseContainerAttributes(b, &s)
+		case 4: // writable_layer
+			u, err := parseFilesystemUsage(b)
+			if err != nil {
+				return err
+			}
+			s.WritableLayer = &u
+		}
+		return nil
+	})
+	return s, err
+}
+
+// parseContainerAttributes parses a runtime.v1.ContainerAttributes into s
+func parseContainerAttributes(b []byte, s *ContainerStats) error {
+	return fields(b, func(num protowire.Number, b []byte, _ uint64) error {
+		switch num {
+		case 1: // id
+			s.ID = string(b)
+		case 2: // metadata
+			return fields(b, func(num protowire.Number, b []byte, v uint64) error {
+				switch num {
+				case 1: // name
+					s.Name = string(b)
+				case 2: // attempt
+					s.Attempt = uint32(v)
+				}
+				return nil
+			})
+		case 3: // labels
+			key, value, err := parseMapEntry(b)
+			if err != nil {
+				return err
+			}
+			switch key {
+			case labelPodName:
+				s.Pod = value
+			case labelPodNamespace:
+				s.PodNamespace = value
+			case labelPodUID:
+				s.PodUID = value
+			case labelContainerName:
+				if s.Name == "" {
+					s.Name = value
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// parseFilesystemUsage parses a runtime.v1.FilesystemUsage
+func parseFilesystemUsage(b []byte) (FilesystemUsage, error) {
+	var u FilesystemUsage
+	err := fields(b, func(num protowire.Number, b []byte, _ uint64) error {
+		switch num {
+		case 2: // fs_id
+			return fields(b, func(num protowire.Number, b []byte, _ uint64) error {
+				if num == 1 { // mountpoint
+					u.Mountpoint = string(b)
+				}
+				return nil
+			})
+		case 3: // used_bytes
+			u.HasBytes = true
+			return parseUInt64Value(b, &u.UsedBytes)
+		case 4: // inodes_used
+			u.HasInodes = true
+			return parseUInt64Value(b, &u.UsedInodes)
+		}
+		return nil
+	})
+	return u, err
+}
+
+// parseUInt64Value parses a runtime.v1.UInt64Value into v
+func parseUInt64Value(b []byte, v *uint64) error {
+	return fields(b, func(num protowire.Number, _ []byte, n uint64) error {
+		if num == 1 {
+			*v = n
+		}
+		return nil
+	})
+}
+
+// parseMapEntry parses an entry of a map<string, string>
+func parseMapEntry(b []byte) (key, value string, err error) {
+	err = fields(b, func(num protowire.Number, b []byte, _ uint64) error {
+		switch num {
+		case 1:
+			key = string(b)
+		case 2:
+			value = string(b)
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+var errMalformed = errors.New("malformed message")
+
+// fields calls fn for each field of a message, with the bytes of length
+// delimited fields (strings, messages) or the value of varints. Other wire
+// types are skipped.
+func fields(b []byte, fn func(num protowire.Number, b []byte, v uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		var (
+			bytes []byte
+			v     uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := fn(num, bytes, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rawCodec passes messages through as encoded protobuf bytes. It is named
+// proto so requests have the content type runtimes expect.
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cri: marshal %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("cri: unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
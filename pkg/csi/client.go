@@ -0,0 +1,181 @@
+// Package csi talks to CSI node plugins over their unix sockets beneath the
+// kubelet plugins directory
+package csi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Plugin is a connection to a single CSI node plugin
+type Plugin struct {
+	Driver string
+	Socket string
+
+	conn     *grpc.ClientConn
+	identity csipb.IdentityClient
+	node     csipb.NodeClient
+
+	capsOnce sync.Once
+	caps     map[csipb.NodeServiceCapability_RPC_Type]bool
+	capsErr  error
+}
+
+// Registry finds and caches connections to CSI node plugins by driver name
+type Registry struct {
+	pluginsPath string // e.g. /var/lib/kubelet/plugins
+
+	mu      sync.Mutex
+	plugins map[string]*Plugin // keyed by driver name
+	sockets map[string]bool    // sockets already connected to
+}
+
+// NewRegistry creates a new registry for sockets beneath pluginsPath
+func NewRegistry(pluginsPath string) *Registry {
+	if pluginsPath == "" {
+		pluginsPath = "/var/lib/kubelet/plugins"
+	}
+	return &Registry{
+		pluginsPath: pluginsPath,
+		plugins:     make(map[string]*Plugin),
+		sockets:     make(map[string]bool),
+	}
+}
+
+// Plugin returns the node plugin for driver. Sockets are looked up at
+// <plugins>/<driver>/csi.sock first, then by asking every socket for its name.
+func (r *Registry) Plugin(ctx context.Context, driver string) (*Plugin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.plugins[driver]; ok {
+		return p, nil
+	}
+
+	candidates := []string{filepath.Join(r.pluginsPath, driver, "csi.sock")}
+	if matches, err := filepath.Glob(filepath.Join(r.pluginsPath, "*", "csi.sock")); err == nil {
+		candidates = append(candidates, matches...)
+	}
+
+	for _, socket := range candidates {
+		if r.sockets[socket] {
+			continue
+		}
+		if _, err := os.Stat(socket); err != nil {
+			continue
+		}
+		p, err := dial(ctx, socket)
+		if err != nil {
+			continue
+		}
+		r.sockets[socket] = true
+		if _, known := r.plugins[p.Driver]; known {
+			p.Close()
+			continue
+		}
+		r.plugins[p.Driver] = p
+		if p.Driver == driver {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no CSI node plugin socket for driver %s under %s", driver, r.pluginsPath)
+}
+
+// Close closes all plugin connections
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, p := range r.plugins {
+		p.Close()
+		delete(r.plugins, name)
+	}
+	clear(r.sockets)
+}
+
+func dial(ctx context.Context, socket string) (*Plugin, error) {
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plugin{
+		Socket:   socket,
+		conn:     conn,
+		identity: csipb.NewIdentityClient(conn),
+		node:     csipb.NewNodeClient(conn),
+	}
+
+	info, err := p.identity.GetPluginInfo(ctx, &csipb.GetPluginInfoRequest{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("GetPluginInfo %s: %w", socket, err)
+	}
+	p.Driver = info.GetName()
+	return p, nil
+}
+
+// Close closes the plugin connection
+func (p *Plugin) Close() error {
+	return p.conn.Close()
+}
+
+// HasCapability reports whether the node plugin advertises the given RPC capability
+func (p *Plugin) HasCapability(ctx context.Context, c csipb.NodeServiceCapability_RPC_Type) (bool, error) {
+	p.capsOnce.Do(func() {
+		resp, err := p.node.NodeGetCapabilities(ctx, &csipb.NodeGetCapabilitiesRequest{})
+		if err != nil {
+			p.capsErr = fmt.Errorf("NodeGetCapabilities %s: %w", p.Driver, err)
+			return
+		}
+		p.caps = make(map[csipb.NodeServiceCapability_RPC_Type]bool)
+		for _, cap := range resp.GetCapabilities() {
+			if rpc := cap.GetRpc(); rpc != nil {
+				p.caps[rpc.GetType()] = true
+			}
+		}
+	})
+	return p.caps[c], p.capsErr
+}
+
+// VolumeStats is the usage and condition reported by NodeGetVolumeStats
+type VolumeStats struct {
+	TotalBytes, UsedBytes, AvailableBytes    int64
+	TotalInodes, UsedInodes, AvailableInodes int64
+	HasBytes, HasInodes                      bool
+
+	// Condition is only set if the plugin supports VOLUME_CONDITION
+	Condition *csipb.VolumeCondition
+}
+
+// NodeGetVolumeStats calls NodeGetVolumeStats for a volume. volumePath must be
+// the path as seen by the plugin, i.e. the host path.
+func (p *Plugin) NodeGetVolumeStats(ctx context.Context, volumeID, volumePath string) (*VolumeStats, error) {
+	resp, err := p.node.NodeGetVolumeStats(ctx, &csipb.NodeGetVolumeStatsRequest{
+		VolumeId:   volumeID,
+		VolumePath: volumePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NodeGetVolumeStats %s: %w", volumeID, err)
+	}
+
+	stats := &VolumeStats{Condition: resp.GetVolumeCondition()}
+	for _, u := range resp.GetUsage() {
+		switch u.GetUnit() {
+		case csipb.VolumeUsage_BYTES:
+			stats.TotalBytes, stats.UsedBytes, stats.AvailableBytes = u.GetTotal(), u.GetUsed(), u.GetAvailable()
+			stats.HasBytes = true
+		case csipb.VolumeUsage_INODES:
+			stats.TotalInodes, stats.UsedInodes, stats.AvailableInodes = u.GetTotal(), u.GetUsed(), u.GetAvailable()
+			stats.HasInodes = true
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"sync"
+)
+
+// ErrAccessDenied is wrapped by discovery errors caused by being denied
+// access to the kubelet's directories. File modes aside, this is typically
+// SELinux or AppArmor confining the DaemonSet, which denies reads even to
+// root.
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessReporter is implemented by discoverers reading host paths that
+// count the paths they were denied access to
+type AccessReporter interface {
+	// AccessDenied returns how many distinct paths were denied in the most
+	// recent run
+	AccessDenied() int
+}
+
+// accessLog counts the paths a discoverer is denied access to during a run,
+// and warns when denials start, naming one path and the likely cause
+type accessLog struct {
+	name string // discoverer, for logs
+
+	mu   sync.Mutex
+	run  map[string]error
+	last int
+}
+
+// start begins counting denials for a new run
+func (a *accessLog) start() {
+	a.mu.Lock()
+	a.run = make(map[string]error)
+	a.mu.Unlock()
+}
+
+// denied reports whether err is a permission error, recording path if so
+func (a *accessLog) denied(path string, err error) bool {
+	if !errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	a.mu.Lock()
+	if a.run != nil {
+		a.run[path] = err
+	}
+	a.mu.Unlock()
+	return true
+}
+
+// finish ends the run, returning how many paths were denied
+func (a *accessLog) finish() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := len(a.run)
+	if n > 0 && a.last == 0 {
+		for path, err := range a.run {
+			slog.Warn("discovery denied access to kubelet paths; under SELinux or AppArmor confinement, grant the container access to the kubelet directory",
+				"discoverer", a.name, "denied", n, "path", path, "error", err)
+			break
+		}
+	} else if n == 0 && a.last > 0 {
+		slog.Info("discovery access to kubelet paths restored", "discoverer", a.name)
+	}
+	a.last = n
+	a.run = nil
+	return n
+}
+
+// AccessDenied returns how many paths the most recent run was denied
+func (a *accessLog) AccessDenied() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
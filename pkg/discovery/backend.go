@@ -0,0 +1,42 @@
+package discovery
+
+import "strings"
+
+// Kinds of storage backing a volume, see Backend
+const (
+	BackendBlock   = "block"   // a filesystem on a block device, with diskstats
+	BackendTmpfs   = "tmpfs"   // memory backed, no device
+	BackendOverlay = "overlay" // a union of other filesystems' directories
+	BackendNetwork = "network" // served remotely, e.g. NFS, CephFS or a FUSE client
+)
+
+// tmpfsTypes, overlayTypes and networkTypes classify filesystem types;
+// anything else is taken to be on a block device
+var (
+	tmpfsTypes   = map[string]bool{"tmpfs": true, "ramfs": true}
+	overlayTypes = map[string]bool{"overlay": true, "aufs": true, "fuse.fuse-overlayfs": true}
+	networkTypes = map[string]bool{
+		"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "ceph": true, "glusterfs": true,
+		"lustre": true, "beegfs": true, "gpfs": true, "9p": true, "virtiofs": true, "afs": true,
+	}
+)
+
+// Backend classifies the storage backing the volume by its filesystem type,
+// or by its device when the type isn't known. It is empty when neither is.
+// Only block volumes have diskstats and a device in sysfs.
+func (v *VolumeInfo) Backend() string {
+	switch {
+	case tmpfsTypes[v.FSType]:
+		return BackendTmpfs
+	case overlayTypes[v.FSType]:
+		return BackendOverlay
+	case networkTypes[v.FSType], strings.HasPrefix(v.FSType, "fuse"):
+		return BackendNetwork
+	case v.FSType != "":
+		return BackendBlock
+	case v.DeviceID != "" && !strings.HasPrefix(v.DeviceID, "0:"):
+		// Anonymous devices (major 0) have no block device behind them
+		return BackendBlock
+	}
+	return ""
+}
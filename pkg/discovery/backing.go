@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// readMountTree returns the mountinfo beside resolver's mount table, or nil
+// if it can't be read, in which case volumes resolve by mount table alone
+func readMountTree(ctx context.Context, resolver *mounts.Resolver) *mounts.MountTree {
+	tree, err := resolver.MountTree(ctx)
+	if err != nil {
+		slog.Debug("mountinfo unavailable, resolving volumes by mount table", "path", resolver.MountinfoPath(), "error", err)
+		return nil
+	}
+	return tree
+}
+
+// volumeMount is the filesystem backing a volume's path
+type volumeMount struct {
+	device    string // source, e.g. /dev/sdb
+	deviceID  string // major:minor
+	deviceErr error
+	subPath   string // directory of the filesystem mounted, empty for its root
+	fsType    string
+}
+
+// resolveVolumeMount finds the filesystem backing path. mount is the mount
+// table's longest prefix match for it, which stacked or hidden mounts make
+// the wrong one; when tree is available the mount tree is walked instead,
+// following bind mounts of subdirectories (subPaths, local PVs) to their
+// filesystem's device.
+func resolveVolumeMount(resolver *mounts.Resolver, tree *mounts.MountTree, mount *mounts.Mount, path string) volumeMount {
+	vm := volumeMount{device: mount.Device, fsType: mount.FSType}
+	vm.deviceID, vm.deviceErr = resolver.DeviceID(path)
+	if tree == nil {
+		return vm
+	}
+
+	b, err := resolver.FindBacking(tree, path)
+	if err != nil {
+		slog.Debug("mountinfo: no backing mount", "path", path, "error", err)
+		return vm
+	}
+	vm.device = b.Mount.Source
+	vm.subPath = b.SubPath
+	vm.fsType = b.Mount.FSType
+	if vm.deviceErr != nil && b.Mount.DeviceID != "" {
+		vm.deviceID, vm.deviceErr = b.Mount.DeviceID, nil
+	}
+	return vm
+}
@@ -0,0 +1,73 @@
+//go:build !race
+
+package discovery_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+)
+
+// benchVolumes is the number of volumes merged
+const benchVolumes = 1000
+
+// mergeAllocBudget is the allocations per volume merging two discoverers'
+// copies of a volume may take. About half of it is the fake discoverers
+// copying the volumes they return.
+const mergeAllocBudget float64 = 12
+
+// newBenchDiscoverer returns a discoverer merging an API and a CSI
+// discoverer that both find every volume, with the fields each knows
+func newBenchDiscoverer(tb testing.TB) *discovery.MultiDiscoverer {
+	tb.Helper()
+	// Discovery logs every run
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(out) })
+
+	csi := make([]*discovery.VolumeInfo, benchVolumes)
+	k8s := make([]*discovery.VolumeInfo, benchVolumes)
+	for i := range benchVolumes {
+		pvc := fmt.Sprintf("data-bench-%d", i)
+		pv := fmt.Sprintf("pvc-10000000-0000-4000-8000-%012d", i)
+		device := discoverytest.WithDevice(fmt.Sprintf("nvme%dn1", i+1), fmt.Sprintf("259:%d", i+1))
+		pod := discoverytest.WithPod(fmt.Sprintf("bench-%d", i), fmt.Sprintf("00000000-0000-4000-8000-%012d", i))
+		csi[i] = discoverytest.Volume(pv, fixture.SyntheticNamespace, device, pod, discoverytest.WithCSIDriver(fixture.SyntheticDriver, fmt.Sprintf("vol-%08d", i)))
+		csi[i].PVName = pv
+		k8s[i] = discoverytest.Volume(pvc, fixture.SyntheticNamespace, device, pod, discoverytest.WithStorageClass(fixture.SyntheticStorageClass))
+		k8s[i].PVName = pv
+	}
+	return discovery.NewMultiDiscoverer(discoverytest.New("k8sapi", k8s...), discoverytest.New("csi", csi...))
+}
+
+func BenchmarkMerge(b *testing.B) {
+	multi := newBenchDiscoverer(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := multi.Discover(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMergeAllocBudget(t *testing.T) {
+	multi := newBenchDiscoverer(t)
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := multi.Discover(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if perVolume := allocs / benchVolumes; perVolume > mergeAllocBudget {
+		t.Errorf("merging discovery: %g allocations per volume, budget %g", perVolume, mergeAllocBudget)
+	}
+}
@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // discoverer runs normally
+	BreakerOpen     = "open"      // discoverer is skipped until the backoff elapses
+	BreakerHalfOpen = "half_open" // a single trial run decides whether to close
+)
+
+// ErrBreakerOpen is recorded when a discoverer is skipped by its circuit breaker
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// errUnavailable is counted as a failure when a discoverer reports itself unavailable
+var errUnavailable = errors.New("not available")
+
+// RetryPolicy controls per-discoverer retries within a single discovery run
+// and the circuit breaker across runs
+type RetryPolicy struct {
+	// Attempts is the number of tries per run, including the first
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for each further retry
+	Backoff time.Duration
+
+	// BreakerThreshold is the number of consecutive failed runs that opens
+	// the breaker. 0 disables the breaker.
+	BreakerThreshold int
+	// BreakerBackoff is how long the breaker first stays open, doubled each
+	// time a half-open trial fails, up to BreakerMaxBackoff
+	BreakerBackoff    time.Duration
+	BreakerMaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:          2,
+		Backoff:           200 * time.Millisecond,
+		BreakerThreshold:  3,
+		BreakerBackoff:    10 * time.Second,
+		BreakerMaxBackoff: 5 * time.Minute,
+	}
+}
+
+// breaker is a per-discoverer circuit breaker
+type breaker struct {
+	policy *RetryPolicy
+
+	mu        sync.Mutex
+	state     string
+	failures  int // consecutive failed runs
+	backoff   time.Duration
+	openUntil time.Time
+}
+
+func newBreaker(policy *RetryPolicy) *breaker {
+	return &breaker{policy: policy, state: BreakerClosed}
+}
+
+// allow reports whether the discoverer may run, moving an open breaker whose
+// backoff has elapsed to half-open
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// A trial is already in flight
+		return false
+	}
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.backoff = 0
+}
+
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.policy.BreakerThreshold <= 0 {
+		return
+	}
+	if b.state != BreakerHalfOpen && b.failures < b.policy.BreakerThreshold {
+		return
+	}
+
+	switch {
+	case b.backoff == 0:
+		b.backoff = b.policy.BreakerBackoff
+	case b.state == BreakerHalfOpen:
+		b.backoff *= 2
+	}
+	if b.policy.BreakerMaxBackoff > 0 && b.backoff > b.policy.BreakerMaxBackoff {
+		b.backoff = b.policy.BreakerMaxBackoff
+	}
+	b.state = BreakerOpen
+	b.openUntil = now.Add(b.backoff)
+}
+
+func (b *breaker) snapshot() (state string, failures int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
+
+// retry runs a discoverer up to policy.Attempts times with exponential backoff.
+// available is false if the discoverer reported itself unavailable on the last try.
+func retry(ctx context.Context, policy *RetryPolicy, d Discoverer) (volumes []*VolumeInfo, available bool, err error) {
+	attempts := max(policy.Attempts, 1)
+	delay := policy.Backoff
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, available, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		if available = d.Available(ctx); !available {
+			err = errUnavailable
+			continue
+		}
+		if volumes, err = d.Discover(ctx); err == nil {
+			return volumes, true, nil
+		}
+	}
+	return nil, available, err
+}
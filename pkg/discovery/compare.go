@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// Disagreement is how the volumes found by two discoverers run independently
+// differ, as counted by CompareVolumes
+type Disagreement struct {
+	// Volumes found by both, only the first or only the second discoverer
+	Both, OnlyFirst, OnlySecond int
+	// Volumes both found, on different devices
+	DeviceConflicts int
+}
+
+// CompareVolumes matches the volumes two discoverers found by merge key, as
+// MultiDiscoverer merges them, and counts those only one found and those
+// they map to different devices. Devices are compared by number when both
+// know it, else by name; a volume without a device on either side doesn't
+// conflict. Each disagreement is logged at debug level.
+func CompareVolumes(first, second []*VolumeInfo) Disagreement {
+	byKey := make(map[volumeKey]*VolumeInfo, len(second))
+	for _, v := range second {
+		if key := mergeKey(v); key != (volumeKey{}) {
+			byKey[key] = v
+		}
+	}
+
+	// Discoverers report a volume once per pod mounting it
+	var d Disagreement
+	seen := make(map[volumeKey]bool, len(first))
+	for _, a := range first {
+		key := mergeKey(a)
+		if key == (volumeKey{}) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		b, ok := byKey[key]
+		if !ok {
+			d.OnlyFirst++
+			slog.Debug("discovery compare: volume only found by first discoverer", volumeAttrs(a)...)
+			continue
+		}
+		d.Both++
+		if devicesConflict(a, b) {
+			d.DeviceConflicts++
+			slog.Debug("discovery compare: conflicting devices", append(volumeAttrs(a), "other_device", b.DeviceName, "other_device_id", b.DeviceID)...)
+		}
+	}
+
+	keys := make([]volumeKey, 0, len(byKey))
+	for key := range byKey {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, volumeKey.compare)
+	for _, key := range keys {
+		d.OnlySecond++
+		slog.Debug("discovery compare: volume only found by second discoverer", volumeAttrs(byKey[key])...)
+	}
+	return d
+}
+
+// devicesConflict reports whether a and b are known to be on different devices
+func devicesConflict(a, b *VolumeInfo) bool {
+	if a.DeviceID != "" && b.DeviceID != "" {
+		return a.DeviceID != b.DeviceID
+	}
+	if a.DeviceName != "" && b.DeviceName != "" {
+		return a.DeviceName != b.DeviceName
+	}
+	return false
+}
@@ -3,53 +3,95 @@ package discovery
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
 	"github.com/gfx-labs/volmetd/pkg/mounts"
 )
 
 // CSIDiscoverer discovers PVC volumes by parsing kubelet CSI volume directories
 type CSIDiscoverer struct {
 	kubeletPath string
-	mountsPath  string
+	layout      kubelet.Layout
+	resolver    *mounts.Resolver
+	filter      *NamespaceFilter
+	access      accessLog
 }
 
 // NewCSIDiscoverer creates a new CSI discoverer
-func NewCSIDiscoverer(kubeletPath, mountsPath string) *CSIDiscoverer {
+func NewCSIDiscoverer(kubeletPath string, resolver *mounts.Resolver) *CSIDiscoverer {
 	if kubeletPath == "" {
 		kubeletPath = "/var/lib/kubelet"
 	}
-	if mountsPath == "" {
-		mountsPath = "/proc/mounts"
+	if resolver == nil {
+		resolver = mounts.NewResolver("")
 	}
 	return &CSIDiscoverer{
 		kubeletPath: kubeletPath,
-		mountsPath:  mountsPath,
+		layout:      kubelet.Standard,
+		resolver:    resolver,
+		access:      accessLog{name: "csi"},
 	}
 }
 
+// SetNamespaceFilter limits discovery to the namespaces f allows. It must be
+// called before Discover.
+func (d *CSIDiscoverer) SetNamespaceFilter(f *NamespaceFilter) {
+	d.filter = f
+}
+
+// SetLayout locates the directories beneath the kubelet path with l, by
+// default kubelet.Standard. It must be called before Discover.
+func (d *CSIDiscoverer) SetLayout(l kubelet.Layout) {
+	d.layout = l
+}
+
 func (d *CSIDiscoverer) Name() string {
 	return "csi"
 }
 
 func (d *CSIDiscoverer) Available(ctx context.Context) bool {
-	podsDir := filepath.Join(d.kubeletPath, "pods")
+	podsDir := d.layout.PodsDir(d.kubeletPath)
 	_, err := os.Stat(podsDir)
 	return err == nil
 }
 
+// Discover walks the kubelet's pod directories. Paths it is denied are
+// skipped and counted (see AccessDenied); finding no volumes because of them
+// fails with ErrAccessDenied rather than reporting none.
 func (d *CSIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
-	allMounts, err := mounts.Parse(d.mountsPath)
+	d.access.start()
+	volumes, err := d.discover(ctx)
+	if denied := d.access.finish(); denied > 0 && err == nil && len(volumes) == 0 {
+		err = fmt.Errorf("%w to %d kubelet paths", ErrAccessDenied, denied)
+	}
+	return volumes, err
+}
+
+// AccessDenied returns how many kubelet paths the most recent run was denied
+func (d *CSIDiscoverer) AccessDenied() int {
+	return d.access.AccessDenied()
+}
+
+func (d *CSIDiscoverer) discover(ctx context.Context) ([]*VolumeInfo, error) {
+	allMounts, err := d.resolver.MountsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	tree := readMountTree(ctx, d.resolver)
 
-	podsDir := filepath.Join(d.kubeletPath, "pods")
+	podsDir := d.layout.PodsDir(d.kubeletPath)
 	podDirs, err := os.ReadDir(podsDir)
 	if err != nil {
+		if d.access.denied(podsDir, err) {
+			return nil, fmt.Errorf("%w: %w", ErrAccessDenied, err)
+		}
 		return nil, err
 	}
 
@@ -61,20 +103,20 @@ func (d *CSIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
 		}
 
 		podUID := podDir.Name()
-		volumesDir := filepath.Join(podsDir, podUID, "volumes")
+		csiDir := d.layout.PodVolumesDir(d.kubeletPath, podUID, kubelet.PluginCSI)
+		volumesDir := filepath.Dir(csiDir)
 
-		if _, err := os.Stat(volumesDir); os.IsNotExist(err) {
+		if _, err := os.Stat(volumesDir); os.IsNotExist(err) || d.access.denied(volumesDir, err) {
 			continue
 		}
 
 		// Check kubernetes.io~csi directory for CSI volumes
-		csiDir := filepath.Join(volumesDir, "kubernetes.io~csi")
-		if vols, err := d.discoverCSIVolumes(ctx, podUID, csiDir, allMounts); err == nil {
+		if vols, err := d.discoverCSIVolumes(ctx, podUID, csiDir, allMounts, tree); err == nil {
 			volumes = append(volumes, vols...)
 		}
 
 		// Check for regular PV mounts
-		pvDir := filepath.Join(volumesDir, "kubernetes.io~projected")
+		pvDir := d.layout.PodVolumesDir(d.kubeletPath, podUID, kubelet.PluginProjected)
 		if vols, err := d.discoverProjectedVolumes(ctx, podUID, pvDir, allMounts); err == nil {
 			volumes = append(volumes, vols...)
 		}
@@ -83,9 +125,10 @@ func (d *CSIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
 	return volumes, nil
 }
 
-func (d *CSIDiscoverer) discoverCSIVolumes(ctx context.Context, podUID, csiDir string, allMounts []*mounts.Mount) ([]*VolumeInfo, error) {
+func (d *CSIDiscoverer) discoverCSIVolumes(ctx context.Context, podUID, csiDir string, allMounts []*mounts.Mount, tree *mounts.MountTree) ([]*VolumeInfo, error) {
 	volDirs, err := os.ReadDir(csiDir)
 	if err != nil {
+		d.access.denied(csiDir, err)
 		return nil, err
 	}
 
@@ -102,22 +145,27 @@ func (d *CSIDiscoverer) discoverCSIVolumes(ctx context.Context, podUID, csiDir s
 
 		// Read vol_data.json for volume metadata
 		volDataPath := filepath.Join(volPath, "vol_data.json")
-		volData, err := d.readVolData(volDataPath)
+		volData, err := readVolData(volDataPath)
 		if err != nil {
+			d.access.denied(volDataPath, err)
+			continue
+		}
+		if !d.filter.allowsPod(podUID, volData.PodNamespace) {
 			continue
 		}
 
 		// Find the device backing this mount
-		mount := mounts.FindMountByPath(allMounts, mountPath)
+		mount := d.resolver.FindMount(allMounts, mountPath)
 		if mount == nil {
+			slog.Debug("csi: no mount entry", "path", mountPath)
 			continue
 		}
 
-		// Resolve symlinks to get actual device for diskstats
-		resolvedPath, deviceName := mounts.ResolveDevice(mount.Device)
+		// Device ID for diskstats, and the device through any bind mounts
+		vm := resolveVolumeMount(d.resolver, tree, mount, mountPath)
 
-		// Get device ID from mount point for reliable diskstats lookup
-		deviceID, _ := mounts.GetDeviceID(mountPath)
+		// Resolve symlinks to get actual device for diskstats
+		resolvedPath, deviceName := d.resolver.DeviceName(vm.device, vm.deviceID)
 
 		vol := &VolumeInfo{
 			PVName:        volData.VolumeName,
@@ -128,14 +176,18 @@ func (d *CSIDiscoverer) discoverCSIVolumes(ctx context.Context, podUID, csiDir s
 			PodUID:        podUID,
 			CSIDriver:     volData.DriverName,
 			VolumeHandle:  volData.VolumeHandle,
-			CSIDevicePath: mount.Device,
+			CSIDevicePath: vm.device,
 			DevicePath:    resolvedPath,
 			DeviceName:    deviceName,
-			DeviceID:      deviceID,
+			DeviceID:      vm.deviceID,
+			DeviceErr:     vm.deviceErr,
 			MountPath:     mountPath,
+			SubPath:       vm.subPath,
+			FSType:        vm.fsType,
+			Mounted:       volData.Mounted,
 		}
 
-		slog.Debug("csi: found volume", "pv", volData.VolumeName, "pod", volData.PodName, "deviceID", deviceID)
+		slog.Debug("csi: found volume", "pv", volData.VolumeName, "pod", volData.PodName, "deviceID", vm.deviceID)
 		volumes = append(volumes, vol)
 	}
 
@@ -154,10 +206,23 @@ type volData struct {
 	PodName      string `json:"kubernetes.io/pod.name"`
 	PodNamespace string `json:"kubernetes.io/pod.namespace"`
 	PodUID       string `json:"kubernetes.io/pod.uid"`
+
+	// Mounted is the file's mtime: the kubelet writes it as it sets the
+	// volume up for the pod
+	Mounted time.Time `json:"-"`
 }
 
-func (d *CSIDiscoverer) readVolData(path string) (*volData, error) {
-	data, err := os.ReadFile(path)
+func readVolData(path string) (*volData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
@@ -168,7 +233,7 @@ func (d *CSIDiscoverer) readVolData(path string) (*volData, error) {
 		return nil, err
 	}
 
-	vd := &volData{}
+	vd := &volData{Mounted: fi.ModTime()}
 	if v, ok := raw["specVolID"].(string); ok {
 		vd.VolumeName = v
 	}
@@ -0,0 +1,147 @@
+package discovery
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VolumeMetadata is structured metadata decoded from a CSI volume handle
+type VolumeMetadata struct {
+	CloudVolumeID string // provider volume ID, e.g. vol-0abc..., disk name, RBD image
+	Pool          string // storage pool, e.g. Ceph pool ID
+}
+
+// DriverDecoder parses a driver's volume handle format. ok is false if the
+// handle doesn't match the expected format.
+type DriverDecoder func(handle string) (meta VolumeMetadata, ok bool)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]DriverDecoder{
+		"ebs.csi.aws.com":           decodeEBS,
+		"pd.csi.storage.gke.io":     decodeGCEPD,
+		"disk.csi.azure.com":        decodeAzureDisk,
+		"dobs.csi.digitalocean.com": decodeDigitalOcean,
+	}
+	// Ceph CSI drivers are usually deployed as <namespace>.rbd.csi.ceph.com
+	suffixDecoders = map[string]DriverDecoder{
+		"rbd.csi.ceph.com":    decodeCephCSI,
+		"cephfs.csi.ceph.com": decodeCephCSI,
+	}
+)
+
+// RegisterDriverDecoder registers a volume handle decoder for a CSI driver,
+// replacing any existing decoder for it
+func RegisterDriverDecoder(driver string, decoder DriverDecoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[driver] = decoder
+}
+
+// DecodeVolumeHandle decodes a volume handle using the decoder registered
+// for driver. The zero VolumeMetadata is returned if there is none.
+func DecodeVolumeHandle(driver, handle string) VolumeMetadata {
+	if driver == "" || handle == "" {
+		return VolumeMetadata{}
+	}
+
+	decodersMu.RLock()
+	decoder, ok := decoders[driver]
+	if !ok {
+		for suffix, d := range suffixDecoders {
+			if driver == suffix || strings.HasSuffix(driver, "."+suffix) {
+				decoder, ok = d, true
+				break
+			}
+		}
+	}
+	decodersMu.RUnlock()
+
+	if !ok {
+		return VolumeMetadata{}
+	}
+	meta, _ := decoder(handle)
+	return meta
+}
+
+// decodeVolumeMetadata fills in metadata decoded from each volume's handle
+func decodeVolumeMetadata(volumes []*VolumeInfo) {
+	for _, v := range volumes {
+		meta := DecodeVolumeHandle(v.CSIDriver, v.VolumeHandle)
+		if v.CloudVolumeID == "" {
+			v.CloudVolumeID = meta.CloudVolumeID
+		}
+		if v.Pool == "" {
+			v.Pool = meta.Pool
+		}
+	}
+}
+
+// decodeEBS handles vol-0123456789abcdef0
+func decodeEBS(handle string) (VolumeMetadata, bool) {
+	if !strings.HasPrefix(handle, "vol-") {
+		return VolumeMetadata{}, false
+	}
+	return VolumeMetadata{CloudVolumeID: handle}, true
+}
+
+// decodeGCEPD handles projects/<project>/zones/<zone>/disks/<name> and the
+// regional projects/<project>/regions/<region>/disks/<name>
+func decodeGCEPD(handle string) (VolumeMetadata, bool) {
+	parts := strings.Split(handle, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[4] != "disks" {
+		return VolumeMetadata{}, false
+	}
+	return VolumeMetadata{CloudVolumeID: parts[5]}, true
+}
+
+// decodeAzureDisk handles
+// /subscriptions/<id>/resourceGroups/<rg>/providers/Microsoft.Compute/disks/<name>
+func decodeAzureDisk(handle string) (VolumeMetadata, bool) {
+	i := strings.LastIndex(strings.ToLower(handle), "/providers/microsoft.compute/disks/")
+	if i < 0 {
+		return VolumeMetadata{}, false
+	}
+	name := handle[strings.LastIndex(handle, "/")+1:]
+	if name == "" {
+		return VolumeMetadata{}, false
+	}
+	return VolumeMetadata{CloudVolumeID: name}, true
+}
+
+// decodeDigitalOcean handles bare volume UUIDs
+func decodeDigitalOcean(handle string) (VolumeMetadata, bool) {
+	if len(handle) != 36 || strings.Count(handle, "-") != 4 {
+		return VolumeMetadata{}, false
+	}
+	return VolumeMetadata{CloudVolumeID: handle}, true
+}
+
+// decodeCephCSI handles ceph-csi composite IDs:
+// <version:4>-<cluster ID length:4 hex>-<cluster ID>-<pool ID:16 hex>-<uuid>.
+// The RBD image / CephFS subvolume is csi-vol-<uuid>; the pool is its numeric ID.
+func decodeCephCSI(handle string) (VolumeMetadata, bool) {
+	if len(handle) < 10 || handle[4] != '-' || handle[9] != '-' {
+		return VolumeMetadata{}, false
+	}
+	clusterLen, err := strconv.ParseUint(handle[5:9], 16, 16)
+	if err != nil {
+		return VolumeMetadata{}, false
+	}
+
+	rest := handle[10:]
+	if uint64(len(rest)) < clusterLen+1+16+1 {
+		return VolumeMetadata{}, false
+	}
+	rest = rest[clusterLen+1:]
+
+	poolID, err := strconv.ParseUint(rest[:16], 16, 64)
+	if err != nil || rest[16] != '-' {
+		return VolumeMetadata{}, false
+	}
+	return VolumeMetadata{
+		CloudVolumeID: "csi-vol-" + rest[17:],
+		Pool:          strconv.FormatUint(poolID, 10),
+	}, true
+}
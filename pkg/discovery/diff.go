@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of change between discovery passes
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// volumeFields are the VolumeInfo fields compared between passes, named like
+// the metric labels they feed
+var volumeFields = []struct {
+	name string
+	get  func(*VolumeInfo) string
+}{
+	{"pvc", func(v *VolumeInfo) string { return v.PVCName }},
+	{"namespace", func(v *VolumeInfo) string { return v.PVCNamespace }},
+	{"pv", func(v *VolumeInfo) string { return v.PVName }},
+	{"pods", func(v *VolumeInfo) string { return podNames(v.Pods) }},
+	{"storage_class", func(v *VolumeInfo) string { return v.StorageClass }},
+	{"csi_driver", func(v *VolumeInfo) string { return v.CSIDriver }},
+	{"volume_handle", func(v *VolumeInfo) string { return v.VolumeHandle }},
+	{"cloud_volume_id", func(v *VolumeInfo) string { return v.CloudVolumeID }},
+	{"pool", func(v *VolumeInfo) string { return v.Pool }},
+	{"device", func(v *VolumeInfo) string { return v.DeviceName }},
+	{"device_id", func(v *VolumeInfo) string { return v.DeviceID }},
+	{"device_path", func(v *VolumeInfo) string { return v.DevicePath }},
+	{"mount_path", func(v *VolumeInfo) string { return v.MountPath }},
+	{"container_mount_path", func(v *VolumeInfo) string { return v.ContainerMountPath }},
+	{"subpath", func(v *VolumeInfo) string { return v.SubPath }},
+	{"container_subpaths", func(v *VolumeInfo) string { return strings.Join(v.ContainerSubPaths, ",") }},
+}
+
+// podNames lists pods as sorted namespace/name, ignoring discovery order
+func podNames(pods []PodRef) string {
+	switch len(pods) {
+	case 0:
+		return ""
+	case 1:
+		return pods[0].Namespace + "/" + pods[0].Name
+	}
+	names := make([]string, len(pods))
+	for i, p := range pods {
+		names[i] = p.Namespace + "/" + p.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ChangeCounts returns how many volumes each successful discovery pass
+// added, removed and changed since the previous one, and how often each
+// field changed
+func (m *MultiDiscoverer) ChangeCounts() (changes, fields map[string]uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyCounts(m.changes), copyCounts(m.fieldChanges)
+}
+
+func copyCounts(src map[string]uint64) map[string]uint64 {
+	dst := make(map[string]uint64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// recordChanges logs the volumes that appeared, disappeared or changed since
+// the previous successful pass and counts them. The volumes are copied, since
+// callers go on to fill in fields such as the device name.
+func (m *MultiDiscoverer) recordChanges(volumes []*VolumeInfo) {
+	current := make(map[volumeKey]*VolumeInfo, len(volumes))
+	for _, v := range volumes {
+		c := *v
+		c.Pods = slices.Clone(v.Pods)
+		current[mergeKey(v)] = &c
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]volumeKey, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, volumeKey.compare)
+	for _, key := range keys {
+		cur := current[key]
+		prev, ok := m.volumes[key]
+		if !ok {
+			m.changes[ChangeAdded]++
+			slog.Info("volume added", volumeAttrs(cur)...)
+			continue
+		}
+
+		var changed []string
+		var before, after []any
+		for _, f := range volumeFields {
+			if a, b := f.get(prev), f.get(cur); a != b {
+				changed = append(changed, f.name)
+				before = append(before, f.name, a)
+				after = append(after, f.name, b)
+				m.fieldChanges[f.name]++
+			}
+		}
+		if len(changed) > 0 {
+			m.changes[ChangeChanged]++
+			slog.Info("volume changed", append(volumeAttrs(cur),
+				"fields", changed, slog.Group("old", before...), slog.Group("new", after...))...)
+		}
+	}
+
+	keys = keys[:0]
+	for key := range m.volumes {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, volumeKey.compare)
+	for _, key := range keys {
+		m.changes[ChangeRemoved]++
+		slog.Info("volume removed", volumeAttrs(m.volumes[key])...)
+	}
+
+	m.volumes = current
+}
+
+// volumeAttrs identifies a volume in change logs
+func volumeAttrs(v *VolumeInfo) []any {
+	return []any{
+		"pvc", v.PVCNamespace + "/" + v.PVCName,
+		"pv", v.PVName,
+		"pods", podNames(v.Pods),
+		"device", v.DeviceName,
+		"mount_path", v.MountPath,
+	}
+}
@@ -0,0 +1,180 @@
+// Package discoverytest provides a fake Discoverer and canned VolumeInfo
+// builders, for testing collectors and embedders of volmetd without a node.
+//
+//	d := discoverytest.New("fake",
+//		discoverytest.Volume("data-db-0", "db"),
+//		discoverytest.Volume("logs", "web", discoverytest.WithDevice("sdc", "8:32")),
+//	)
+//	exporter, err := volmetd.New(volmetd.WithDiscoverers(d), ...)
+package discoverytest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Discoverer is a discovery.Discoverer returning canned volumes. It is safe
+// for concurrent use, so volumes and errors can be changed between scrapes.
+type Discoverer struct {
+	name string
+
+	mu          sync.Mutex
+	volumes     []*discovery.VolumeInfo
+	err         error
+	unavailable bool
+	calls       int
+}
+
+// New creates a fake discoverer returning volumes
+func New(name string, volumes ...*discovery.VolumeInfo) *Discoverer {
+	return &Discoverer{name: name, volumes: volumes}
+}
+
+func (d *Discoverer) Name() string {
+	return d.name
+}
+
+// Discover returns copies of the volumes, since callers fill in fields such
+// as the device name, or the error set with SetError
+func (d *Discoverer) Discover(ctx context.Context) ([]*discovery.VolumeInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+	if d.err != nil {
+		return nil, d.err
+	}
+	result := make([]*discovery.VolumeInfo, len(d.volumes))
+	for i, v := range d.volumes {
+		c := *v
+		c.Pods = append([]discovery.PodRef(nil), v.Pods...)
+		result[i] = &c
+	}
+	return result, nil
+}
+
+func (d *Discoverer) Available(ctx context.Context) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.unavailable
+}
+
+// SetVolumes replaces the volumes returned by later calls
+func (d *Discoverer) SetVolumes(volumes ...*discovery.VolumeInfo) {
+	d.mu.Lock()
+	d.volumes = volumes
+	d.mu.Unlock()
+}
+
+// SetError makes later calls fail with err, or succeed again if it's nil
+func (d *Discoverer) SetError(err error) {
+	d.mu.Lock()
+	d.err = err
+	d.mu.Unlock()
+}
+
+// SetAvailable sets what Available reports
+func (d *Discoverer) SetAvailable(available bool) {
+	d.mu.Lock()
+	d.unavailable = !available
+	d.mu.Unlock()
+}
+
+// Calls returns how many times Discover was called
+func (d *Discoverer) Calls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+// Option customizes a volume built by Volume
+type Option func(*discovery.VolumeInfo)
+
+// Volume builds a fully populated CSI volume for the PVC, mounted by pod
+// <pvc>-pod on device sdb (8:16), with storage class "standard". Fields
+// derived from the PVC, such as the PV name and pod UID, are stable, so
+// volumes built twice compare equal.
+func Volume(pvc, namespace string, opts ...Option) *discovery.VolumeInfo {
+	h := fnv.New32a()
+	h.Write([]byte(namespace + "/" + pvc))
+	id := h.Sum32()
+
+	pv := fmt.Sprintf("pvc-%08x", id)
+	v := &discovery.VolumeInfo{
+		PVCName:            pvc,
+		PVCNamespace:       namespace,
+		PVName:             pv,
+		StorageClass:       "standard",
+		CSIDriver:          "csi.example.com",
+		VolumeHandle:       fmt.Sprintf("vol-%08x", id),
+		DevicePath:         "/dev/sdb",
+		DeviceName:         "sdb",
+		DeviceID:           "8:16",
+		ContainerMountPath: "/data",
+	}
+	WithPod(pvc+"-pod", fmt.Sprintf("%08x-0000-4000-8000-000000000000", id))(v)
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// WithPod sets the pod mounting the volume, replacing any others, and the
+// kubelet mount path beneath it
+func WithPod(name, uid string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.PodName, v.PodNamespace, v.PodUID = name, v.PVCNamespace, uid
+		v.Pods = []discovery.PodRef{{Name: name, Namespace: v.PVCNamespace, UID: uid}}
+		v.MountPath = "/var/lib/kubelet/pods/" + uid + "/volumes/kubernetes.io~csi/" + v.PVName + "/mount"
+	}
+}
+
+// WithSharedPod adds another pod mounting the volume, as for RWX PVCs
+func WithSharedPod(name, uid string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.Pods = append(v.Pods, discovery.PodRef{Name: name, Namespace: v.PVCNamespace, UID: uid})
+	}
+}
+
+// WithWorkload sets the owning workload of the volume's pods
+func WithWorkload(kind, name string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.Workload, v.WorkloadKind = name, kind
+		for i := range v.Pods {
+			v.Pods[i].Workload, v.Pods[i].WorkloadKind = name, kind
+		}
+	}
+}
+
+// WithDevice sets the device name and major:minor ID
+func WithDevice(name, id string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.DevicePath, v.DeviceName, v.DeviceID = "/dev/"+name, name, id
+	}
+}
+
+// WithStorageClass sets the storage class
+func WithStorageClass(class string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.StorageClass = class
+	}
+}
+
+// WithCSIDriver sets the CSI driver and volume handle
+func WithCSIDriver(driver, handle string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.CSIDriver, v.VolumeHandle = driver, handle
+	}
+}
+
+// WithMountPath sets the host mount path, e.g. to a directory a test
+// created so statfs-based collectors have something to read
+func WithMountPath(path string) Option {
+	return func(v *discovery.VolumeInfo) {
+		v.MountPath = path
+	}
+}
@@ -0,0 +1,195 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// HostPathDriver is the csi_driver of hostPath volumes. They have no PVC,
+// PV or CSI driver, so this tells their series apart from PVCs'.
+const HostPathDriver = "kubernetes.io/host-path"
+
+// pseudoFilesystems hold no data worth reporting as a volume, e.g. a
+// monitoring agent's hostPath of /proc or /sys
+var pseudoFilesystems = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "fusectl": true,
+	"hugetlbfs": true, "mqueue": true, "nsfs": true, "proc": true, "pstore": true,
+	"rpc_pipefs": true, "securityfs": true, "sysfs": true, "tracefs": true,
+}
+
+// HostPathDiscoverer discovers the hostPath volumes of pods on this node,
+// mapping each host path to the filesystem and device it lives on. It is
+// opt-in: hostPath is mostly used by node agents, but some legacy workloads
+// keep their data there with no other monitoring.
+//
+// The volumes have no PVC or PV. CSIDriver is HostPathDriver, VolumeHandle
+// and MountPath are the host path, and pods sharing a path are merged into
+// one volume. The paths are host paths, so resolving devices and capacity
+// needs the host mount namespace.
+type HostPathDiscoverer struct {
+	api      *K8sAPIDiscoverer // lists the node's pods
+	prefixes []string
+
+	// Host directory of the kubelet's static pod manifests, empty = none
+	staticPodPath string
+}
+
+// NewHostPathDiscoverer creates a hostPath discoverer listing pods through
+// api, with its node, namespaces, namespace filter and resolver
+func NewHostPathDiscoverer(api *K8sAPIDiscoverer) *HostPathDiscoverer {
+	return &HostPathDiscoverer{api: api}
+}
+
+// SetPrefixes only discovers host paths at or beneath prefixes, e.g. /data.
+// By default any path not under the kubelet directory is, if it is on a
+// real filesystem. It must be called before Discover.
+func (d *HostPathDiscoverer) SetPrefixes(prefixes []string) {
+	d.prefixes = nil
+	for _, p := range prefixes {
+		d.prefixes = append(d.prefixes, filepath.Clean(p))
+	}
+}
+
+// SetStaticPodPath also reads the static pod manifests in the host directory
+// path, e.g. /etc/kubernetes/manifests, so the hostPath volumes of static
+// pods without a mirror pod in the API, such as etcd's data directory, are
+// discovered too. It must be called before Discover.
+func (d *HostPathDiscoverer) SetStaticPodPath(path string) {
+	d.staticPodPath = path
+}
+
+func (d *HostPathDiscoverer) Name() string {
+	return "hostpath"
+}
+
+func (d *HostPathDiscoverer) Available(ctx context.Context) bool {
+	return d.api.Available(ctx)
+}
+
+func (d *HostPathDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
+	resolver := d.api.resolver
+	allMounts, err := resolver.MountsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree := readMountTree(ctx, resolver)
+
+	pods, err := d.api.getPodsOnNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if d.staticPodPath != "" {
+		pods = d.addStaticPods(pods)
+	}
+
+	var volumes []*VolumeInfo
+	for _, pod := range pods {
+		for _, vol := range pod.Spec.Volumes {
+			if vol.HostPath == nil || !hostPathTypeSupported(vol.HostPath.Type) {
+				continue
+			}
+			path := filepath.Clean(vol.HostPath.Path)
+			if !d.wanted(path) {
+				continue
+			}
+
+			mount := mounts.FindMountByPath(allMounts, path)
+			if mount == nil || pseudoFilesystems[mount.FSType] {
+				continue
+			}
+
+			// The path itself first, as it may be on a bind mount or
+			// subvolume with its own device; then the mount containing it,
+			// which mountinfo knows about
+			vm := resolveVolumeMount(resolver, tree, mount, path)
+			if vm.deviceErr != nil {
+				if id, err := resolver.DeviceID(mount.MountPoint); err == nil {
+					vm.deviceID, vm.deviceErr = id, nil
+				}
+			}
+			resolvedPath, deviceName := resolver.DeviceName(vm.device, vm.deviceID)
+			workload, workloadKind := podWorkload(&pod)
+
+			slog.Debug("hostpath: found volume", "pod", pod.Namespace+"/"+pod.Name, "path", path, "device", deviceName)
+			volumes = append(volumes, &VolumeInfo{
+				PVCNamespace:       pod.Namespace,
+				PodName:            pod.Name,
+				PodNamespace:       pod.Namespace,
+				PodUID:             KubeletPodUID(&pod),
+				PodTerminating:     pod.DeletionTimestamp != nil,
+				Workload:           workload,
+				WorkloadKind:       workloadKind,
+				CSIDriver:          HostPathDriver,
+				VolumeHandle:       path,
+				CSIDevicePath:      vm.device,
+				DevicePath:         resolvedPath,
+				DeviceName:         deviceName,
+				DeviceID:           vm.deviceID,
+				DeviceErr:          vm.deviceErr,
+				MountPath:          path,
+				ContainerMountPath: findContainerMountPath(&pod, vol.Name),
+				SubPath:            vm.subPath,
+				FSType:             vm.fsType,
+				ContainerSubPaths:  findContainerSubPaths(&pod, vol.Name),
+				PodStarted:         podStarted(&pod),
+			})
+		}
+	}
+	return volumes, nil
+}
+
+// addStaticPods adds the static pods of allowed namespaces the API has no
+// mirror pod for
+func (d *HostPathDiscoverer) addStaticPods(pods []corev1.Pod) []corev1.Pod {
+	static, err := readStaticPods(d.api.resolver.LocalPath(d.staticPodPath), d.api.nodeName)
+	if err != nil {
+		slog.Warn("hostpath: failed to read static pod manifests", "path", d.staticPodPath, "error", err)
+		return pods
+	}
+	allowed := static[:0]
+	for _, pod := range static {
+		if d.api.filter.Allows(pod.Namespace) {
+			allowed = append(allowed, pod)
+		}
+	}
+	return addStaticPods(pods, allowed)
+}
+
+// wanted reports whether path is under the configured prefixes, or without
+// any, outside the kubelet directory, whose volumes the other discoverers find
+func (d *HostPathDiscoverer) wanted(path string) bool {
+	if len(d.prefixes) == 0 {
+		return !underPath(path, d.api.resolver.HostPath(d.api.kubeletPath))
+	}
+	for _, p := range d.prefixes {
+		if underPath(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPath reports whether path is dir or beneath it
+func underPath(path, dir string) bool {
+	return path == dir || dir == "/" || strings.HasPrefix(path, dir+"/")
+}
+
+// hostPathTypeSupported reports whether a hostPath of type t can hold data:
+// directories and files, not sockets or device nodes
+func hostPathTypeSupported(t *corev1.HostPathType) bool {
+	if t == nil {
+		return true
+	}
+	switch *t {
+	case corev1.HostPathSocket, corev1.HostPathCharDev, corev1.HostPathBlockDev:
+		return false
+	}
+	return true
+}
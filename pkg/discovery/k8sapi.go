@@ -2,17 +2,21 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
 	"github.com/gfx-labs/volmetd/pkg/mounts"
 )
 
@@ -21,15 +25,37 @@ type K8sAPIDiscoverer struct {
 	client      kubernetes.Interface
 	nodeName    string
 	kubeletPath string
-	mountsPath  string
+	layout      kubelet.Layout
+	resolver    *mounts.Resolver
 	namespaces  []string // empty = all namespaces
+	concurrency int      // namespaces listed at once
+	minimalRBAC bool     // never list PersistentVolumes
+	filter      *NamespaceFilter
+	access      accessLog
+
+	mu        sync.Mutex
+	nsStatus  map[string]*NamespaceStatus
+	pvListed  bool  // PVs were listed in the most recent run
+	pvListErr error // why the most recent PV list failed
+}
+
+// DefaultAPIConcurrency is how many namespaces are listed at once by default
+const DefaultAPIConcurrency = 8
+
+// NamespaceStatus records the outcome of a namespace's most recent pod listing
+type NamespaceStatus struct {
+	Namespace string
+	Pods      int
+	Duration  time.Duration
+	Error     string
+	Time      time.Time
 }
 
 // ErrNotInCluster is returned when not running inside a Kubernetes cluster
 var ErrNotInCluster = fmt.Errorf("not running in a kubernetes cluster")
 
 // NewK8sAPIDiscoverer creates a new Kubernetes API discoverer
-func NewK8sAPIDiscoverer(kubeletPath, mountsPath string, namespaces []string) (*K8sAPIDiscoverer, error) {
+func NewK8sAPIDiscoverer(kubeletPath string, resolver *mounts.Resolver, namespaces []string) (*K8sAPIDiscoverer, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		if rest.ErrNotInCluster == err {
@@ -43,27 +69,104 @@ func NewK8sAPIDiscoverer(kubeletPath, mountsPath string, namespaces []string) (*
 		return nil, err
 	}
 
-	nodeName := detectNodeName()
+	nodeName := DetectNodeName()
 	slog.Info("k8sapi: detected node name", "node", nodeName)
 
+	return NewK8sAPIDiscovererForClient(client, nodeName, kubeletPath, resolver, namespaces), nil
+}
+
+// NewK8sAPIDiscovererForClient creates a Kubernetes API discoverer for the
+// given node using an existing client, e.g. a fake clientset serving fixtures
+func NewK8sAPIDiscovererForClient(client kubernetes.Interface, nodeName, kubeletPath string, resolver *mounts.Resolver, namespaces []string) *K8sAPIDiscoverer {
 	if kubeletPath == "" {
 		kubeletPath = "/var/lib/kubelet"
 	}
-	if mountsPath == "" {
-		mountsPath = "/proc/mounts"
+	if resolver == nil {
+		resolver = mounts.NewResolver("")
 	}
 
 	return &K8sAPIDiscoverer{
 		client:      client,
 		nodeName:    nodeName,
 		kubeletPath: kubeletPath,
-		mountsPath:  mountsPath,
+		layout:      kubelet.Standard,
+		resolver:    resolver,
 		namespaces:  namespaces,
-	}, nil
+		concurrency: DefaultAPIConcurrency,
+		nsStatus:    make(map[string]*NamespaceStatus),
+		access:      accessLog{name: "k8sapi"},
+	}
+}
+
+// SetConcurrency bounds how many namespaces are listed at once when
+// namespaces are configured. It must be called before Discover.
+func (d *K8sAPIDiscoverer) SetConcurrency(n int) {
+	if n <= 0 {
+		n = DefaultAPIConcurrency
+	}
+	d.concurrency = n
+}
+
+// SetNamespaceFilter skips pods in namespaces f doesn't allow, e.g. a
+// deny-list on top of listing all namespaces. It must be called before Discover.
+func (d *K8sAPIDiscoverer) SetNamespaceFilter(f *NamespaceFilter) {
+	d.filter = f
+}
+
+// SetLayout locates the directories beneath the kubelet path with l, by
+// default kubelet.Standard. It must be called before Discover.
+func (d *K8sAPIDiscoverer) SetLayout(l kubelet.Layout) {
+	d.layout = l
+}
+
+// SetMinimalRBAC stops listing PersistentVolumes, which needs cluster-wide
+// list permission. Storage class then comes from the PVC spec, and CSI driver
+// and volume handle from the volume's vol_data.json.
+func (d *K8sAPIDiscoverer) SetMinimalRBAC(enabled bool) {
+	d.minimalRBAC = enabled
+}
+
+// PVListStatus reports whether PVs were listed in the most recent run, and
+// why listing failed
+func (d *K8sAPIDiscoverer) PVListStatus() (attempted bool, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pvListed, d.pvListErr
+}
+
+// AccessDenied returns how many kubelet paths the most recent run was
+// denied, and looked up in the mount table instead
+func (d *K8sAPIDiscoverer) AccessDenied() int {
+	return d.access.AccessDenied()
+}
+
+func (d *K8sAPIDiscoverer) setPVListStatus(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil && (!d.pvListed || d.pvListErr == nil) {
+		slog.Warn("k8sapi: cannot list persistentvolumes, taking storage class from PVCs and CSI driver from vol_data.json",
+			"error", err, "hint", "grant list on persistentvolumes, or set VOLMETD_MINIMAL_RBAC to stop listing them")
+	}
+	d.pvListed, d.pvListErr = true, err
 }
 
-// detectNodeName tries multiple methods to determine the node name
-func detectNodeName() string {
+// NamespaceStatus returns the most recent pod listing status of each
+// configured namespace, in configured order
+func (d *K8sAPIDiscoverer) NamespaceStatus() []NamespaceStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]NamespaceStatus, 0, len(d.nsStatus))
+	for _, ns := range d.namespaces {
+		if s, ok := d.nsStatus[ns]; ok {
+			result = append(result, *s)
+		}
+	}
+	return result
+}
+
+// DetectNodeName tries multiple methods to determine the node name
+func DetectNodeName() string {
 	// 1. Explicit env var (standard k8s pattern)
 	if v := os.Getenv("NODE_NAME"); v != "" {
 		return v
@@ -90,6 +193,21 @@ func detectNodeName() string {
 	return ""
 }
 
+// serviceAccountNamespace holds the namespace of the pod's service account
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// DetectNamespace returns the namespace volmetd runs in, from POD_NAMESPACE
+// or the service account, or "" outside a cluster
+func DetectNamespace() string {
+	if v := os.Getenv("POD_NAMESPACE"); v != "" {
+		return v
+	}
+	if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
 func (d *K8sAPIDiscoverer) Name() string {
 	return "k8sapi"
 }
@@ -113,10 +231,14 @@ func (d *K8sAPIDiscoverer) Available(ctx context.Context) bool {
 }
 
 func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
-	allMounts, err := mounts.Parse(d.mountsPath)
+	d.access.start()
+	defer d.access.finish()
+
+	allMounts, err := d.resolver.MountsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	tree := readMountTree(ctx, d.resolver)
 
 	// Get all pods on this node
 	pods, err := d.getPodsOnNode(ctx)
@@ -125,10 +247,15 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 	}
 	slog.Debug("k8sapi: found pods", "count", len(pods), "node", d.nodeName)
 
-	// Build PV -> PVC mapping
+	// Build PV -> PVC mapping. Without it (minimal RBAC mode, or PV list
+	// forbidden) metadata comes from PVCs and vol_data.json instead.
 	pvToPVC := make(map[string]*pvcInfo)
-	pvs, err := d.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
-	if err == nil {
+	var pvs *corev1.PersistentVolumeList
+	if !d.minimalRBAC {
+		pvs, err = d.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		d.setPVListStatus(err)
+	}
+	if pvs != nil && err == nil {
 		for _, pv := range pvs.Items {
 			if pv.Spec.ClaimRef != nil {
 				pvToPVC[pv.Name] = &pvcInfo{
@@ -156,6 +283,7 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 			// Get the PVC
 			pvc, err := d.client.CoreV1().PersistentVolumeClaims(pvcNamespace).Get(ctx, pvcName, metav1.GetOptions{})
 			if err != nil {
+				slog.Debug("k8sapi: get pvc", "pod", pod.Name, "pvc", pvcNamespace+"/"+pvcName, "error", err)
 				continue
 			}
 
@@ -165,29 +293,30 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 			}
 
 			// Find mount path for this volume
-			mountPath := d.findMountPath(string(pod.UID), vol.Name, pvName)
+			mountPath := d.findMountPath(allMounts, KubeletPodUID(&pod), vol.Name, pvName)
 			if mountPath == "" {
 				slog.Debug("k8sapi: no mount path", "pod", pod.Name, "vol", vol.Name, "pvc", pvcName, "pv", pvName)
 				continue
 			}
 
 			// Find device from mount
-			mount := mounts.FindMountByPath(allMounts, mountPath)
+			mount := d.resolver.FindMount(allMounts, mountPath)
 			if mount == nil {
 				slog.Debug("k8sapi: no mount entry", "path", mountPath)
 				continue
 			}
 
-			// Resolve symlinks to get actual device for diskstats
-			resolvedPath, deviceName := mounts.ResolveDevice(mount.Device)
+			// Device ID for diskstats, and the device through any bind mounts
+			vm := resolveVolumeMount(d.resolver, tree, mount, mountPath)
 
-			// Get device ID from mount point for reliable diskstats lookup
-			deviceID, _ := mounts.GetDeviceID(mountPath)
+			// Resolve symlinks to get actual device for diskstats
+			resolvedPath, deviceName := d.resolver.DeviceName(vm.device, vm.deviceID)
 
 			// Find container mount path
 			containerMountPath := findContainerMountPath(&pod, vol.Name)
 
 			pvcMeta := pvToPVC[pvName]
+			workload, workloadKind := podWorkload(&pod)
 
 			volInfo := &VolumeInfo{
 				PVCName:            pvcName,
@@ -195,13 +324,22 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 				PVName:             pvName,
 				PodName:            pod.Name,
 				PodNamespace:       pod.Namespace,
-				PodUID:             string(pod.UID),
-				CSIDevicePath:      mount.Device,
+				PodUID:             KubeletPodUID(&pod),
+				PodTerminating:     pod.DeletionTimestamp != nil,
+				Workload:           workload,
+				WorkloadKind:       workloadKind,
+				CSIDevicePath:      vm.device,
 				DevicePath:         resolvedPath,
 				DeviceName:         deviceName,
-				DeviceID:           deviceID,
+				DeviceID:           vm.deviceID,
+				DeviceErr:          vm.deviceErr,
 				MountPath:          mountPath,
 				ContainerMountPath: containerMountPath,
+				SubPath:            vm.subPath,
+				FSType:             vm.fsType,
+				ContainerSubPaths:  findContainerSubPaths(&pod, vol.Name),
+				PVCCreated:         pvc.CreationTimestamp.Time,
+				PodStarted:         podStarted(&pod),
 			}
 
 			if pvcMeta != nil {
@@ -209,8 +347,9 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 				volInfo.CSIDriver = pvcMeta.csiDriver
 				volInfo.VolumeHandle = pvcMeta.volumeHandle
 			}
+			fillFromPVC(volInfo, pvc, mountPath)
 
-			slog.Debug("k8sapi: found volume", "pvc", pvcNamespace+"/"+pvcName, "pv", pvName, "deviceID", deviceID)
+			slog.Debug("k8sapi: found volume", "pvc", pvcNamespace+"/"+pvcName, "pv", pvName, "deviceID", vm.deviceID)
 			volumes = append(volumes, volInfo)
 		}
 	}
@@ -218,6 +357,39 @@ func (d *K8sAPIDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error)
 	return volumes, nil
 }
 
+// fillFromPVC fills in metadata the PV didn't provide from the PVC spec and,
+// for CSI volumes, the vol_data.json kubelet writes beside the mount
+func fillFromPVC(vol *VolumeInfo, pvc *corev1.PersistentVolumeClaim, mountPath string) {
+	if vol.StorageClass == "" && pvc.Spec.StorageClassName != nil {
+		vol.StorageClass = *pvc.Spec.StorageClassName
+	}
+	// The bound PV's modes, or those requested until bound
+	modes := pvc.Status.AccessModes
+	if len(modes) == 0 {
+		modes = pvc.Spec.AccessModes
+	}
+	for _, mode := range modes {
+		vol.AccessModes = append(vol.AccessModes, string(mode))
+	}
+	volDataPath := filepath.Join(filepath.Dir(mountPath), "vol_data.json")
+	if fi, err := os.Stat(volDataPath); err == nil {
+		vol.Mounted = fi.ModTime()
+	}
+	if vol.CSIDriver != "" && vol.VolumeHandle != "" {
+		return
+	}
+	vd, err := readVolData(volDataPath)
+	if err != nil {
+		return
+	}
+	if vol.CSIDriver == "" {
+		vol.CSIDriver = vd.DriverName
+	}
+	if vol.VolumeHandle == "" {
+		vol.VolumeHandle = vd.VolumeHandle
+	}
+}
+
 type pvcInfo struct {
 	name         string
 	namespace    string
@@ -227,58 +399,162 @@ type pvcInfo struct {
 }
 
 func (d *K8sAPIDiscoverer) getPodsOnNode(ctx context.Context) ([]corev1.Pod, error) {
-	var allPods []corev1.Pod
-
 	if len(d.namespaces) == 0 {
-		// All namespaces
-		pods, err := d.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-			FieldSelector: "spec.nodeName=" + d.nodeName,
-		})
+		// All namespaces, less any excluded
+		pods, err := d.listPods(ctx, "")
 		if err != nil {
 			return nil, err
 		}
-		allPods = pods.Items
-	} else {
-		for _, ns := range d.namespaces {
-			pods, err := d.client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
-				FieldSelector: "spec.nodeName=" + d.nodeName,
-			})
+		d.filter.learnPods(pods)
+		allowed := pods[:0]
+		for _, pod := range pods {
+			if d.filter.Allows(pod.Namespace) {
+				allowed = append(allowed, pod)
+			}
+		}
+		return allowed, nil
+	}
+
+	var namespaces []string
+	for _, ns := range d.namespaces {
+		if d.filter.Allows(ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) == 0 {
+		return nil, nil
+	}
+
+	// List namespaces in parallel, at most concurrency at once
+	type result struct {
+		pods []corev1.Pod
+		err  error
+	}
+	results := make([]result, len(namespaces))
+	sem := make(chan struct{}, d.concurrency)
+	wg := sync.WaitGroup{}
+	for i, ns := range namespaces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ns string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			pods, err := d.listPods(ctx, ns)
+			s := &NamespaceStatus{Namespace: ns, Pods: len(pods), Duration: time.Since(start), Time: time.Now()}
 			if err != nil {
-				continue
+				err = fmt.Errorf("namespace %s: %w", ns, err)
+				s.Error = err.Error()
 			}
-			allPods = append(allPods, pods.Items...)
+			d.mu.Lock()
+			d.nsStatus[ns] = s
+			d.mu.Unlock()
+			results[i] = result{pods, err}
+		}(i, ns)
+	}
+	wg.Wait()
+
+	var allPods []corev1.Pod
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
 		}
+		allPods = append(allPods, r.pods...)
 	}
+	d.filter.learnPods(allPods)
 
+	// Volumes in the namespaces that could be listed are still worth
+	// reporting; only fail discovery when none could
+	if len(errs) == len(namespaces) {
+		return nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		slog.Warn("k8sapi: failed to list pods in some namespaces", "failed", len(errs), "namespaces", len(namespaces), "error", errors.Join(errs...))
+	}
 	return allPods, nil
 }
 
-func (d *K8sAPIDiscoverer) findMountPath(podUID, volName, pvName string) string {
-	csiDir := filepath.Join(d.kubeletPath, "pods", podUID, "volumes", "kubernetes.io~csi")
+// listPods lists the pods on this node in namespace, or all namespaces if empty
+func (d *K8sAPIDiscoverer) listPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	pods, err := d.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + d.nodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// findMountPath returns the kubelet directory a pod's volume is mounted at
+func (d *K8sAPIDiscoverer) findMountPath(allMounts []*mounts.Mount, podUID, volName, pvName string) string {
+	csiDir := d.layout.PodVolumesDir(d.kubeletPath, podUID, kubelet.PluginCSI)
 
 	// Try volume name first (standard behavior)
 	csiPath := filepath.Join(csiDir, volName, "mount")
-	if _, err := os.Stat(csiPath); err == nil {
+	if d.exists(allMounts, csiPath) {
 		return csiPath
 	}
 
 	// Try PV name (some CSI drivers use PV name as directory)
 	if pvName != "" {
 		csiPathByPV := filepath.Join(csiDir, pvName, "mount")
-		if _, err := os.Stat(csiPathByPV); err == nil {
+		if d.exists(allMounts, csiPathByPV) {
 			return csiPathByPV
 		}
 	}
 
 	// Regular PV volumes (non-CSI)
-	pvPath := filepath.Join(d.kubeletPath, "pods", podUID, "volumes", "kubernetes.io~projected", volName)
-	if _, err := os.Stat(pvPath); err == nil {
+	pvPath := filepath.Join(d.layout.PodVolumesDir(d.kubeletPath, podUID, kubelet.PluginProjected), volName)
+	if d.exists(allMounts, pvPath) {
 		return pvPath
 	}
 
 	return ""
 }
 
+// exists reports whether path exists. The kubelet's pod directories are only
+// searchable by root, so when stat is denied a path that is itself a mount
+// point in the mount table counts as existing.
+func (d *K8sAPIDiscoverer) exists(allMounts []*mounts.Mount, path string) bool {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true
+	}
+	if !d.access.denied(path, err) {
+		return false
+	}
+	m := d.resolver.FindMount(allMounts, path)
+	return m != nil && m.MountPoint == d.resolver.HostPath(path)
+}
+
+// podStarted returns when the kubelet started the pod, zero if it hasn't
+func podStarted(pod *corev1.Pod) time.Time {
+	if pod.Status.StartTime == nil {
+		return time.Time{}
+	}
+	return pod.Status.StartTime.Time
+}
+
+// podWorkload returns the name and kind of the workload owning a pod. Pods
+// owned by a Deployment's ReplicaSet are attributed to the Deployment using
+// the pod-template-hash suffix, which avoids needing access to ReplicaSets.
+func podWorkload(pod *corev1.Pod) (name, kind string) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return "", ""
+	}
+
+	if ref.Kind == "ReplicaSet" {
+		if hash := pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+			return strings.TrimSuffix(ref.Name, "-"+hash), "Deployment"
+		}
+	}
+	return ref.Name, ref.Kind
+}
+
 func getCSIDriver(pv *corev1.PersistentVolume) string {
 	if pv.Spec.CSI != nil {
 		return pv.Spec.CSI.Driver
@@ -313,3 +589,20 @@ func findContainerMountPath(pod *corev1.Pod, volName string) string {
 	}
 	return ""
 }
+
+// findContainerSubPaths finds the distinct subPaths containers mount of a
+// volume, sorted. subPathExpr is expanded from each container's environment
+// at runtime, so those can't be known here and are left out.
+func findContainerSubPaths(pod *corev1.Pod, volName string) []string {
+	var subPaths []string
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for _, c := range containers {
+			for _, vm := range c.VolumeMounts {
+				if vm.Name == volName && vm.SubPath != "" {
+					subPaths = addSubPath(subPaths, filepath.Clean(vm.SubPath))
+				}
+			}
+		}
+	}
+	return subPaths
+}
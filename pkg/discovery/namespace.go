@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NamespaceFilter decides which namespaces' volumes are discovered, from an
+// allow-list and a deny-list. A nil *NamespaceFilter allows every namespace.
+//
+// Sharing one filter between discoverers lets those that can't see a
+// volume's namespace (vol_data.json rarely records it) look it up by pod UID
+// from the pods the K8s API discoverer listed.
+type NamespaceFilter struct {
+	include map[string]bool // empty = all namespaces
+	exclude map[string]bool
+
+	mu   sync.Mutex
+	pods map[string]string // pod UID -> namespace, from the latest pod listing
+}
+
+// NewNamespaceFilter creates a filter allowing the include namespaces, or all
+// namespaces if include is empty, except the exclude namespaces
+func NewNamespaceFilter(include, exclude []string) *NamespaceFilter {
+	f := &NamespaceFilter{
+		include: make(map[string]bool, len(include)),
+		exclude: make(map[string]bool, len(exclude)),
+	}
+	for _, ns := range include {
+		f.include[ns] = true
+	}
+	for _, ns := range exclude {
+		f.exclude[ns] = true
+	}
+	return f
+}
+
+// Allows reports whether volumes in namespace are discovered. A volume whose
+// namespace isn't known is only allowed when there is no allow-list.
+func (f *NamespaceFilter) Allows(namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !f.include[namespace] {
+		return false
+	}
+	return !f.exclude[namespace]
+}
+
+// allowsPod is Allows for a volume of the pod with UID uid, looking up the
+// pod's namespace when the volume doesn't record one
+func (f *NamespaceFilter) allowsPod(uid, namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if namespace == "" {
+		f.mu.Lock()
+		namespace = f.pods[uid]
+		f.mu.Unlock()
+	}
+	return f.Allows(namespace)
+}
+
+// learnPods replaces the pod namespaces used by allowsPod
+func (f *NamespaceFilter) learnPods(pods []corev1.Pod) {
+	if f == nil {
+		return
+	}
+	m := make(map[string]string, len(pods))
+	for i := range pods {
+		m[KubeletPodUID(&pods[i])] = pods[i].Namespace
+	}
+	f.mu.Lock()
+	f.pods = m
+	f.mu.Unlock()
+}
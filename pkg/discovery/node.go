@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Node topology labels, with the deprecated beta labels as fallbacks
+var (
+	zoneLabels   = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+	regionLabels = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+)
+
+// NodeInfo identifies the node volmetd runs on
+type NodeInfo struct {
+	Name   string
+	Zone   string
+	Region string
+}
+
+// LookupNode returns the node name from DetectNodeName and its zone and
+// region from the Node object's topology labels. zone and region override
+// the looked up values when set, e.g. from env vars outside a cluster.
+func LookupNode(ctx context.Context, zone, region string) NodeInfo {
+	info := NodeInfo{Name: DetectNodeName(), Zone: zone, Region: region}
+	if info.Name == "" || (info.Zone != "" && info.Region != "") {
+		return info
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return info
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return info
+	}
+
+	node, err := client.CoreV1().Nodes().Get(ctx, info.Name, metav1.GetOptions{})
+	if err != nil {
+		slog.Warn("failed to get node topology", "node", info.Name, "error", err)
+		return info
+	}
+
+	if info.Zone == "" {
+		info.Zone = firstLabel(node.Labels, zoneLabels)
+	}
+	if info.Region == "" {
+		info.Region = firstLabel(node.Labels, regionLabels)
+	}
+	return info
+}
+
+func firstLabel(labels map[string]string, keys []string) string {
+	for _, k := range keys {
+		if v := labels[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
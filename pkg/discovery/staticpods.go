@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// KubeletPodUID returns the UID the kubelet knows a pod by, naming its
+// directory under <kubelet>/pods. A static pod's API object is a mirror pod
+// with a UID of its own; the kubelet's is in the config.mirror annotation.
+func KubeletPodUID(pod *corev1.Pod) string {
+	if uid := pod.Annotations[corev1.MirrorPodAnnotationKey]; uid != "" {
+		return uid
+	}
+	return string(pod.UID)
+}
+
+// readStaticPods reads the static pod manifests the kubelet runs from dir,
+// naming each pod as its mirror pod is, <name>-<node>. The kubelet only
+// creates mirror pods when the API server lets it, e.g. not before a static
+// API server is up or when NodeRestriction rejects the pod, so the manifests
+// are the only record of those pods. A missing dir has no pods; manifests
+// that don't parse are skipped.
+func readStaticPods(dir, nodeName string) ([]corev1.Pod, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []corev1.Pod
+	for _, e := range entries {
+		// The kubelet ignores hidden files, e.g. editors' swap files
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Debug("static pod: read manifest", "path", path, "error", err)
+			continue
+		}
+		var pod corev1.Pod
+		if err := yaml.Unmarshal(data, &pod); err != nil || pod.Name == "" {
+			slog.Debug("static pod: parse manifest", "path", path, "error", err)
+			continue
+		}
+		if nodeName != "" {
+			pod.Name += "-" + nodeName
+		}
+		if pod.Namespace == "" {
+			pod.Namespace = "default"
+		}
+		pod.Spec.NodeName = nodeName
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// addStaticPods appends the static pods missing a mirror pod in pods
+func addStaticPods(pods, static []corev1.Pod) []corev1.Pod {
+	listed := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		listed[pod.Namespace+"/"+pod.Name] = true
+	}
+	for _, pod := range static {
+		if !listed[pod.Namespace+"/"+pod.Name] {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
@@ -2,7 +2,12 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 // VolumeInfo represents a discovered PVC volume
@@ -16,12 +21,22 @@ type VolumeInfo struct {
 	PodName      string
 	PodNamespace string
 	PodUID       string
+	// PodTerminating is set when the pod is being deleted, from the API
+	PodTerminating bool
+
+	// Owning workload of the pod, e.g. StatefulSet "postgres"
+	Workload     string
+	WorkloadKind string
 
 	// Storage info
 	StorageClass string
 	CSIDriver    string
 	VolumeHandle string // CSI volume handle / cloud provider volume ID
 
+	// Decoded from VolumeHandle by the driver's DriverDecoder
+	CloudVolumeID string
+	Pool          string
+
 	// Node-local info
 	DevicePath         string // resolved device path, e.g., /dev/sda
 	DeviceName         string // device name for diskstats, e.g., sda
@@ -29,6 +44,125 @@ type VolumeInfo struct {
 	CSIDevicePath      string // original CSI device path, e.g., /dev/disk/by-id/scsi-0DO_Volume_...
 	MountPath          string // host path, e.g., /var/lib/kubelet/pods/.../volumes/...
 	ContainerMountPath string // path inside container, e.g., /data
+	// SubPath is the directory of the backing filesystem the volume is, e.g.
+	// /pvc-123 for a PV carved from a shared filesystem; empty for the
+	// filesystem's root
+	SubPath string
+	// FSType is the type of the filesystem backing the volume, e.g. ext4,
+	// tmpfs or nfs4; see Backend
+	FSType string `json:",omitempty"`
+
+	// ContainerSubPaths are the subPaths of the volume containers mount,
+	// relative to its root and sorted, e.g. one per tenant of a shared PVC
+	ContainerSubPaths []string `json:",omitempty"`
+
+	// AccessModes of the PVC, e.g. ReadWriteOnce, when known from the API
+	AccessModes []string `json:",omitempty"`
+
+	// Lifecycle timestamps, zero when unknown: when the PVC was created and
+	// the pod started, from the API, and when the kubelet set the volume up
+	// for the pod, from the mtime of a CSI volume's vol_data.json
+	PVCCreated time.Time `json:",omitzero"`
+	PodStarted time.Time `json:",omitzero"`
+	Mounted    time.Time `json:",omitzero"`
+
+	// DeviceErr is why DeviceID is empty, matching mounts.ErrDeviceUnresolvable
+	// or mounts.ErrMountNotFound. Collectors needing the device report it.
+	DeviceErr error `json:"-"`
+
+	// Pods lists every pod on this node mounting the volume, including the one
+	// in PodName. Shared (RWX) PVCs are merged into one volume with several pods.
+	Pods []PodRef
+}
+
+// Terminating reports whether every pod on this node mounting the volume is
+// being deleted, when its mount may already be half torn down
+func (v *VolumeInfo) Terminating() bool {
+	if len(v.Pods) == 0 {
+		return v.PodTerminating
+	}
+	for _, p := range v.Pods {
+		if !p.Terminating {
+			return false
+		}
+	}
+	return true
+}
+
+// addSubPath adds sub to the sorted subPaths if missing
+func addSubPath(subPaths []string, sub string) []string {
+	i, found := slices.BinarySearch(subPaths, sub)
+	if found {
+		return subPaths
+	}
+	return slices.Insert(subPaths, i, sub)
+}
+
+// PodRef identifies a pod consuming a volume
+type PodRef struct {
+	Name         string
+	Namespace    string
+	UID          string
+	Workload     string
+	WorkloadKind string
+	Terminating  bool
+}
+
+// addPod records src's pod (and any pods it already lists) as consumers of dst
+func addPod(dst, src *VolumeInfo) {
+	if src.PodName != "" || src.PodUID != "" {
+		addPodRef(dst, PodRef{
+			Name:         src.PodName,
+			Namespace:    src.PodNamespace,
+			UID:          src.PodUID,
+			Workload:     src.Workload,
+			WorkloadKind: src.WorkloadKind,
+			Terminating:  src.PodTerminating,
+		})
+	}
+	for _, ref := range src.Pods {
+		addPodRef(dst, ref)
+	}
+}
+
+// addPodRef adds ref to dst's pods, or fills in the matching pod
+func addPodRef(dst *VolumeInfo, ref PodRef) {
+	for i, p := range dst.Pods {
+		if samePod(p, ref) {
+			// Fill in whatever the other discoverer didn't know
+			if p.Name == "" {
+				dst.Pods[i].Name = ref.Name
+			}
+			if p.Namespace == "" {
+				dst.Pods[i].Namespace = ref.Namespace
+			}
+			if p.UID == "" {
+				dst.Pods[i].UID = ref.UID
+			}
+			if p.Workload == "" {
+				dst.Pods[i].Workload = ref.Workload
+				dst.Pods[i].WorkloadKind = ref.WorkloadKind
+			}
+			if ref.Terminating {
+				dst.Pods[i].Terminating = true
+			}
+			return
+		}
+	}
+	dst.Pods = append(dst.Pods, ref)
+}
+
+// volumePod returns the pod in v's PodName
+func volumePod(v *VolumeInfo) PodRef {
+	return PodRef{Name: v.PodName, Namespace: v.PodNamespace, UID: v.PodUID}
+}
+
+// samePod compares pods by UID when both are known, otherwise by name
+func samePod(a, b PodRef) bool {
+	if a.UID != "" && b.UID != "" {
+		return a.UID == b.UID
+	}
+	return a.Name == b.Name && a.Namespace == b.Namespace
 }
 
 // Discoverer discovers PVC to device mappings
@@ -43,61 +177,289 @@ type Discoverer interface {
 	Available(ctx context.Context) bool
 }
 
+// NamespaceReporter is implemented by discoverers that query namespaces
+// separately and record each namespace's outcome
+type NamespaceReporter interface {
+	NamespaceStatus() []NamespaceStatus
+}
+
+// PVListReporter is implemented by discoverers that list PersistentVolumes
+// for volume metadata
+type PVListReporter interface {
+	PVListStatus() (attempted bool, err error)
+}
+
+// DiscovererStatus records the outcome of a discoverer's most recent run
+type DiscovererStatus struct {
+	Name      string
+	Available bool
+	Volumes   int
+	Error     string
+	Time      time.Time
+
+	// Circuit breaker state and consecutive failed runs
+	Breaker  string
+	Failures int
+}
+
 // MultiDiscoverer tries multiple discoverers and merges results
 type MultiDiscoverer struct {
 	discoverers []Discoverer
+	policy      RetryPolicy
+	breakers    map[string]*breaker
+
+	// Run only when another discoverer was denied access to host paths
+	fallback Discoverer
+	denied   map[string]bool // by discoverer, in its most recent run
+
+	mu     sync.Mutex
+	status map[string]*DiscovererStatus
+
+	// Result of the previous successful pass by mergeKey, diffed against
+	// the next, and the change counts
+	volumes      map[volumeKey]*VolumeInfo
+	changes      map[string]uint64
+	fieldChanges map[string]uint64
 }
 
-// NewMultiDiscoverer creates a new multi-discoverer
+// NewMultiDiscoverer creates a new multi-discoverer using DefaultRetryPolicy
 func NewMultiDiscoverer(discoverers ...Discoverer) *MultiDiscoverer {
-	return &MultiDiscoverer{discoverers: discoverers}
+	m := &MultiDiscoverer{
+		discoverers:  discoverers,
+		policy:       DefaultRetryPolicy(),
+		breakers:     make(map[string]*breaker),
+		denied:       make(map[string]bool),
+		status:       make(map[string]*DiscovererStatus),
+		changes:      make(map[string]uint64),
+		fieldChanges: make(map[string]uint64),
+	}
+	for _, d := range discoverers {
+		m.breakers[d.Name()] = newBreaker(&m.policy)
+	}
+	return m
 }
 
-// Discover tries all discoverers and returns merged results
-func (m *MultiDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
-	seen := make(map[string]*VolumeInfo) // key by device ID (preferred) or device name
+// SetRetryPolicy replaces the retry policy. It must be called before Discover.
+func (m *MultiDiscoverer) SetRetryPolicy(policy RetryPolicy) {
+	m.policy = policy
+}
+
+// SetAccessFallback sets a discoverer run only in passes where another was
+// denied access to host paths (see ErrAccessDenied), typically k8sapi, which
+// finds mounts in the mount table without reading the kubelet's directories.
+// It must be called before Discover.
+func (m *MultiDiscoverer) SetAccessFallback(d Discoverer) {
+	m.fallback = d
+	m.breakers[d.Name()] = newBreaker(&m.policy)
+}
 
+// Status returns the most recent run status of each discoverer in priority
+// order, followed by the access fallback once it has run
+func (m *MultiDiscoverer) Status() []DiscovererStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]DiscovererStatus, 0, len(m.discoverers)+1)
 	for _, d := range m.discoverers {
-		if !d.Available(ctx) {
-			log.Printf("discoverer %s not available", d.Name())
-			continue
+		if s, ok := m.status[d.Name()]; ok {
+			result = append(result, *s)
+		} else {
+			result = append(result, DiscovererStatus{Name: d.Name(), Breaker: BreakerClosed})
 		}
+	}
+	if m.fallback != nil {
+		if s, ok := m.status[m.fallback.Name()]; ok {
+			result = append(result, *s)
+		}
+	}
+	return result
+}
 
-		volumes, err := d.Discover(ctx)
-		if err != nil {
-			log.Printf("discoverer %s error: %v", d.Name(), err)
-			continue
+// AccessDenied returns how many host paths each discoverer reporting it was
+// denied in its most recent run, keyed by discoverer name
+func (m *MultiDiscoverer) AccessDenied() map[string]int {
+	result := make(map[string]int)
+	for _, d := range m.all() {
+		if r, ok := d.(AccessReporter); ok {
+			result[d.Name()] = r.AccessDenied()
 		}
+	}
+	return result
+}
 
-		log.Printf("discoverer %s found %d volumes", d.Name(), len(volumes))
+// all returns the discoverers including the access fallback
+func (m *MultiDiscoverer) all() []Discoverer {
+	if m.fallback == nil {
+		return m.discoverers
+	}
+	return append(slices.Clip(m.discoverers), m.fallback)
+}
 
-		for _, v := range volumes {
-			// Use device ID as key if available, otherwise device name
-			key := v.DeviceID
-			if key == "" {
-				key = v.DeviceName
-			}
-			if key == "" {
-				continue
-			}
+// NamespaceStatus returns the most recent per-namespace status of every
+// discoverer that reports one
+func (m *MultiDiscoverer) NamespaceStatus() []NamespaceStatus {
+	var result []NamespaceStatus
+	for _, d := range m.all() {
+		if r, ok := d.(NamespaceReporter); ok {
+			result = append(result, r.NamespaceStatus()...)
+		}
+	}
+	return result
+}
 
-			if existing, exists := seen[key]; exists {
-				// Merge: fill in empty fields from new discoverer
-				mergeVolumeInfo(existing, v)
-			} else {
-				seen[key] = v
+// PVListStatus returns the most recent PV list outcome of each discoverer
+// that listed PVs, keyed by discoverer name
+func (m *MultiDiscoverer) PVListStatus() map[string]error {
+	result := make(map[string]error)
+	for _, d := range m.all() {
+		if r, ok := d.(PVListReporter); ok {
+			if attempted, err := r.PVListStatus(); attempted {
+				result[d.Name()] = err
 			}
 		}
 	}
+	return result
+}
+
+func (m *MultiDiscoverer) setStatus(s *DiscovererStatus) {
+	s.Time = time.Now()
+	m.mu.Lock()
+	m.status[s.Name] = s
+	m.mu.Unlock()
+}
+
+// ErrDiscoveryFailed is returned when no discoverer ran successfully, so an
+// empty result can't be trusted to mean there are no volumes
+var ErrDiscoveryFailed = errors.New("all discoverers failed")
+
+// Discover tries all discoverers and returns merged results. ErrDiscoveryFailed
+// is returned if every discoverer failed, was unavailable or was skipped.
+func (m *MultiDiscoverer) Discover(ctx context.Context) ([]*VolumeInfo, error) {
+	seen := make(map[volumeKey]*VolumeInfo) // keyed by mergeKey
+	succeeded := 0
+	denied := false
+
+	for _, d := range m.discoverers {
+		ok, wasDenied := m.run(ctx, d, seen)
+		if ok {
+			succeeded++
+		}
+		denied = denied || wasDenied
+	}
+	if denied && m.fallback != nil {
+		log.Printf("discovery denied access to kubelet paths, also running %s", m.fallback.Name())
+		if ok, _ := m.run(ctx, m.fallback, seen); ok {
+			succeeded++
+		}
+	}
+
+	if succeeded == 0 && len(m.discoverers) > 0 {
+		return nil, ErrDiscoveryFailed
+	}
 
 	result := make([]*VolumeInfo, 0, len(seen))
 	for _, v := range seen {
 		result = append(result, v)
 	}
+	decodeVolumeMetadata(result)
+	m.recordChanges(result)
 
 	return result, nil
 }
 
+// run runs one discoverer through its circuit breaker and merges its volumes
+// into seen. denied reports whether it was denied access to host paths, in
+// its most recent run when the breaker skips it.
+func (m *MultiDiscoverer) run(ctx context.Context, d Discoverer, seen map[volumeKey]*VolumeInfo) (ok, denied bool) {
+	b := m.breakers[d.Name()]
+	if !b.allow(time.Now()) {
+		state, failures := b.snapshot()
+		log.Printf("discoverer %s skipped: circuit breaker %s", d.Name(), state)
+		m.setStatus(&DiscovererStatus{Name: d.Name(), Error: ErrBreakerOpen.Error(), Breaker: state, Failures: failures})
+		// Keep falling back while a discoverer denied access backs off
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return false, m.denied[d.Name()]
+	}
+	defer func() {
+		m.mu.Lock()
+		m.denied[d.Name()] = denied
+		m.mu.Unlock()
+	}()
+
+	volumes, available, err := retry(ctx, &m.policy, d)
+	if r, isReporter := d.(AccessReporter); isReporter && r.AccessDenied() > 0 {
+		denied = true
+	}
+	if err != nil {
+		b.failure(time.Now())
+		state, failures := b.snapshot()
+		if !available {
+			log.Printf("discoverer %s not available", d.Name())
+			m.setStatus(&DiscovererStatus{Name: d.Name(), Breaker: state, Failures: failures})
+		} else {
+			log.Printf("discoverer %s error: %v", d.Name(), err)
+			m.setStatus(&DiscovererStatus{Name: d.Name(), Available: true, Error: err.Error(), Breaker: state, Failures: failures})
+		}
+		return false, denied || errors.Is(err, ErrAccessDenied)
+	}
+	b.success()
+
+	log.Printf("discoverer %s found %d volumes", d.Name(), len(volumes))
+	m.setStatus(&DiscovererStatus{Name: d.Name(), Available: true, Volumes: len(volumes), Breaker: BreakerClosed})
+
+	for _, v := range volumes {
+		key := mergeKey(v)
+		if key == (volumeKey{}) {
+			continue
+		}
+
+		if existing, exists := seen[key]; exists {
+			// Merge: fill in empty fields from new discoverer
+			mergeVolumeInfo(existing, v)
+			addPod(existing, v)
+		} else {
+			own := *v
+			own.Pods = nil
+			addPod(&own, v)
+			seen[key] = &own
+		}
+	}
+	return true, denied
+}
+
+// mergeKey identifies a volume across discoverers. Volumes are keyed by PV
+// name, then volume handle, so distinct PVCs sharing a device (e.g. subpath
+// provisioners carving directories from one disk) stay separate. The device
+// is only used when neither is known.
+func mergeKey(v *VolumeInfo) volumeKey {
+	switch {
+	case v.PVName != "":
+		return volumeKey{"pv", v.PVName}
+	case v.VolumeHandle != "":
+		return volumeKey{"handle", v.VolumeHandle}
+	case v.DeviceID != "":
+		return volumeKey{"dev", v.DeviceID}
+	case v.DeviceName != "":
+		return volumeKey{"dev", v.DeviceName}
+	}
+	return volumeKey{}
+}
+
+// volumeKey is a merge key: what a volume is identified by, and its value.
+// A struct rather than a joined string, so keying every volume of every
+// pass doesn't allocate.
+type volumeKey struct {
+	kind, id string
+}
+
+func (k volumeKey) compare(o volumeKey) int {
+	if c := strings.Compare(k.kind, o.kind); c != 0 {
+		return c
+	}
+	return strings.Compare(k.id, o.id)
+}
+
 // mergeVolumeInfo fills empty fields in dst from src
 func mergeVolumeInfo(dst, src *VolumeInfo) {
 	if dst.PVCName == "" || dst.PVCName == dst.PVName {
@@ -120,6 +482,13 @@ func mergeVolumeInfo(dst, src *VolumeInfo) {
 	if dst.PodUID == "" {
 		dst.PodUID = src.PodUID
 	}
+	if src.PodTerminating && samePod(volumePod(dst), volumePod(src)) {
+		dst.PodTerminating = true
+	}
+	if dst.Workload == "" {
+		dst.Workload = src.Workload
+		dst.WorkloadKind = src.WorkloadKind
+	}
 	if dst.StorageClass == "" {
 		dst.StorageClass = src.StorageClass
 	}
@@ -134,6 +503,7 @@ func mergeVolumeInfo(dst, src *VolumeInfo) {
 	}
 	if dst.DeviceID == "" {
 		dst.DeviceID = src.DeviceID
+		dst.DeviceErr = src.DeviceErr
 	}
 	if dst.CSIDevicePath == "" {
 		dst.CSIDevicePath = src.CSIDevicePath
@@ -144,4 +514,25 @@ func mergeVolumeInfo(dst, src *VolumeInfo) {
 	if dst.ContainerMountPath == "" {
 		dst.ContainerMountPath = src.ContainerMountPath
 	}
+	if dst.SubPath == "" {
+		dst.SubPath = src.SubPath
+	}
+	if dst.FSType == "" {
+		dst.FSType = src.FSType
+	}
+	for _, sub := range src.ContainerSubPaths {
+		dst.ContainerSubPaths = addSubPath(dst.ContainerSubPaths, sub)
+	}
+	if len(dst.AccessModes) == 0 {
+		dst.AccessModes = src.AccessModes
+	}
+	if dst.PVCCreated.IsZero() {
+		dst.PVCCreated = src.PVCCreated
+	}
+	if dst.PodStarted.IsZero() {
+		dst.PodStarted = src.PodStarted
+	}
+	if dst.Mounted.IsZero() {
+		dst.Mounted = src.Mounted
+	}
 }
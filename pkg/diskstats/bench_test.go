@@ -0,0 +1,61 @@
+//go:build !race
+
+package diskstats_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+)
+
+// benchVolumes is the number of volumes of the synthetic node benchmarked
+const benchVolumes = 1000
+
+// parseAllocBudget is the allocations per volume parsing diskstats may take.
+// Parsing into a warm StatsMap allocates nothing per device, only the two
+// allocations of a read.
+const parseAllocBudget = 0.01
+
+// synthesize returns the diskstats of a synthetic node with benchVolumes
+// volumes
+func synthesize(tb testing.TB) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := fixture.Synthesize(dir, benchVolumes); err != nil {
+		tb.Fatal(err)
+	}
+	return filepath.Join(dir, "proc", "diskstats")
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	path := synthesize(b)
+	stats := diskstats.NewStatsMap()
+	defer stats.Close()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := diskstats.ParseIntoContext(ctx, path, stats, diskstats.DefaultLimits); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseIntoAllocBudget(t *testing.T) {
+	path := synthesize(t)
+	stats := diskstats.NewStatsMap()
+	defer stats.Close()
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := diskstats.ParseIntoContext(ctx, path, stats, diskstats.DefaultLimits); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if perVolume := allocs / benchVolumes; perVolume > parseAllocBudget {
+		t.Errorf("parsing diskstats: %g allocations per volume, budget %g", perVolume, parseAllocBudget)
+	}
+}
@@ -1,13 +1,21 @@
 package diskstats
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"math"
 	"strconv"
-	"strings"
+
+	"github.com/gfx-labs/volmetd/pkg/procfile"
 )
 
+// ErrNoDiskstats is wrapped by errors for devices without a diskstats row,
+// e.g. a device detached since discovery
+var ErrNoDiskstats = errors.New("no diskstats row")
+
 // Stats represents disk I/O statistics from /proc/diskstats
 // See https://www.kernel.org/doc/Documentation/iostats.txt
 type Stats struct {
@@ -41,6 +49,8 @@ type Stats struct {
 	// Flush (kernel 5.5+)
 	FlushCompleted uint64
 	FlushTimeMs    uint64
+
+	gen uint64 // StatsMap parse that last saw the device
 }
 
 // ReadBytesTotal returns total bytes read (sectors * 512)
@@ -57,71 +67,214 @@ func (s *Stats) WriteBytesTotal() uint64 {
 type StatsMap struct {
 	ByName     map[string]*Stats // keyed by device name (e.g., "sda")
 	ByDeviceID map[string]*Stats // keyed by "major:minor" (e.g., "8:0")
+
+	buf  []byte         // file contents, reused by ParseInto
+	gen  uint64         // incremented by each ParseInto
+	file *procfile.File // held open across ParseInto calls
+}
+
+// Limits bounds what a parse accepts, so a misconfigured proc path (a huge
+// regular file or a stalled FUSE mount) can't exhaust memory. Zero fields
+// are unlimited.
+type Limits struct {
+	MaxBytes   int // of the whole file
+	MaxEntries int // device lines
+}
+
+// DefaultLimits are far above any real node's diskstats
+var DefaultLimits = Limits{MaxBytes: 16 << 20, MaxEntries: 65536}
+
+// ErrLimitExceeded is wrapped by parse errors for input beyond the Limits
+var ErrLimitExceeded = errors.New("diskstats limit exceeded")
+
+// NewStatsMap returns an empty StatsMap for ParseInto
+func NewStatsMap() *StatsMap {
+	return &StatsMap{
+		ByName:     make(map[string]*Stats),
+		ByDeviceID: make(map[string]*Stats),
+	}
 }
 
 // Parse reads /proc/diskstats and returns stats for all devices
 func Parse(path string) (*StatsMap, error) {
+	m := NewStatsMap()
+	defer m.Close()
+	if err := ParseInto(path, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseInto reads /proc/diskstats into m with the DefaultLimits
+func ParseInto(path string, m *StatsMap) error {
+	return ParseIntoContext(context.Background(), path, m, DefaultLimits)
+}
+
+// ParseIntoContext reads /proc/diskstats into m, reusing the read buffer,
+// maps and Stats of m's previous parse. Once a node's devices have been seen,
+// a parse only allocates for devices that appeared since. Stats from the
+// previous parse are overwritten, so they must no longer be in use. The file
+// is held open in m and rewound by the next parse of the same path until
+// Close.
+func ParseIntoContext(ctx context.Context, path string, m *StatsMap, limits Limits) error {
 	if path == "" {
 		path = "/proc/diskstats"
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	f, err := os.Open(path)
+	if m.file == nil || m.file.Path() != path {
+		m.file.Close()
+		m.file = procfile.New(path)
+	}
+	f, err := m.file.Rewind()
 	if err != nil {
-		return nil, fmt.Errorf("open diskstats: %w", err)
+		return fmt.Errorf("open diskstats: %w", err)
 	}
-	defer f.Close()
 
-	result := &StatsMap{
-		ByName:     make(map[string]*Stats),
-		ByDeviceID: make(map[string]*Stats),
+	// procfs files report no size, so read until EOF into the reused buffer
+	m.buf = m.buf[:0]
+	for {
+		if len(m.buf) == cap(m.buf) {
+			m.buf = append(m.buf, 0)[:len(m.buf)]
+		}
+		n, err := f.Read(m.buf[len(m.buf):cap(m.buf)])
+		m.buf = m.buf[:len(m.buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			m.file.Close()
+			return fmt.Errorf("read diskstats: %w", err)
+		}
+		if limits.MaxBytes > 0 && len(m.buf) > limits.MaxBytes {
+			return fmt.Errorf("%s: over %d bytes: %w", path, limits.MaxBytes, ErrLimitExceeded)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 	}
-	scanner := bufio.NewScanner(f)
 
-	for scanner.Scan() {
-		stats, err := parseLine(scanner.Text())
-		if err != nil {
-			continue // skip malformed lines
+	m.gen++
+	data, entries := m.buf, 0
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if !m.parseLine(line) { // malformed lines are skipped
+			continue
+		}
+		if entries++; limits.MaxEntries > 0 && entries > limits.MaxEntries {
+			return fmt.Errorf("%s: over %d devices: %w", path, limits.MaxEntries, ErrLimitExceeded)
 		}
-		result.ByName[stats.DeviceName] = stats
-		deviceID := fmt.Sprintf("%d:%d", stats.Major, stats.Minor)
-		result.ByDeviceID[deviceID] = stats
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan diskstats: %w", err)
+	// Drop devices that are gone
+	for name, s := range m.ByName {
+		if s.gen != m.gen {
+			delete(m.ByName, name)
+		}
+	}
+	for id, s := range m.ByDeviceID {
+		if s.gen != m.gen {
+			delete(m.ByDeviceID, id)
+		}
 	}
+	return nil
+}
 
-	return result, nil
+// Close closes the diskstats file m holds open between parses
+func (m *StatsMap) Close() error {
+	return m.file.Close()
 }
 
-func parseLine(line string) (*Stats, error) {
-	fields := strings.Fields(line)
-	if len(fields) < 14 {
-		return nil, fmt.Errorf("not enough fields: %d", len(fields))
+// maxFields bounds the fields of a diskstats line: major, minor, name and
+// 17 counters as of kernel 5.5
+const maxFields = 20
+
+// parseLine parses one diskstats line into the device's Stats in m
+func (m *StatsMap) parseLine(line []byte) bool {
+	var fields [maxFields][]byte
+	n := 0
+	for len(line) > 0 && n < maxFields {
+		line = bytes.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			break
+		}
+		end := bytes.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields[n] = line[:end]
+		line = line[end:]
+		n++
+	}
+	if n < 14 {
+		return false
 	}
 
-	s := &Stats{}
-	var err error
+	var nums [maxFields - 3]uint64
+	for i := 3; i < n; i++ {
+		v, ok := parseUint(fields[i])
+		if !ok {
+			return false
+		}
+		nums[i-3] = v
+	}
+	major, ok1 := parseUint(fields[0])
+	minor, ok2 := parseUint(fields[1])
+	if !ok1 || !ok2 {
+		return false
+	}
 
-	s.Major, err = strconv.Atoi(fields[0])
-	if err != nil {
-		return nil, err
+	// Indexing with string(b) doesn't allocate; only new devices do
+	s, ok := m.ByName[string(fields[2])]
+	if !ok {
+		s = &Stats{DeviceName: string(fields[2])}
+		m.ByName[s.DeviceName] = s
 	}
-	s.Minor, err = strconv.Atoi(fields[1])
-	if err != nil {
-		return nil, err
+	if !ok || s.Major != int(major) || s.Minor != int(minor) {
+		if ok && m.ByDeviceID[deviceID(s)] == s {
+			delete(m.ByDeviceID, deviceID(s))
+		}
+		s.Major, s.Minor = int(major), int(minor)
+		m.ByDeviceID[deviceID(s)] = s
 	}
-	s.DeviceName = fields[2]
+	s.gen = m.gen
+	s.setCounters(nums[:n-3])
+	return true
+}
 
-	// Parse numeric fields
-	nums := make([]uint64, len(fields)-3)
-	for i := 3; i < len(fields); i++ {
-		nums[i-3], err = strconv.ParseUint(fields[i], 10, 64)
-		if err != nil {
-			return nil, err
+func deviceID(s *Stats) string {
+	return strconv.Itoa(s.Major) + ":" + strconv.Itoa(s.Minor)
+}
+
+// parseUint parses a decimal uint64 without converting to a string
+func parseUint(b []byte) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	var v uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
 		}
+		d := uint64(c - '0')
+		if v > (math.MaxUint64-d)/10 {
+			return 0, false
+		}
+		v = v*10 + d
 	}
+	return v, true
+}
 
+// setCounters sets the counters following the device name, of which older
+// kernels have fewer
+func (s *Stats) setCounters(nums []uint64) {
 	s.ReadsCompleted = nums[0]
 	s.ReadsMerged = nums[1]
 	s.SectorsRead = nums[2]
@@ -135,6 +288,7 @@ func parseLine(line string) (*Stats, error) {
 	s.WeightedIOTimeMs = nums[10]
 
 	// Discard stats (kernel 4.18+)
+	s.DiscardsCompleted, s.DiscardsMerged, s.SectorsDiscarded, s.DiscardTimeMs = 0, 0, 0, 0
 	if len(nums) >= 15 {
 		s.DiscardsCompleted = nums[11]
 		s.DiscardsMerged = nums[12]
@@ -143,10 +297,9 @@ func parseLine(line string) (*Stats, error) {
 	}
 
 	// Flush stats (kernel 5.5+)
+	s.FlushCompleted, s.FlushTimeMs = 0, 0
 	if len(nums) >= 17 {
 		s.FlushCompleted = nums[15]
 		s.FlushTimeMs = nums[16]
 	}
-
-	return s, nil
 }
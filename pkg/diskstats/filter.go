@@ -0,0 +1,46 @@
+package diskstats
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultDeviceExclude matches pseudo and removable devices that carry no
+// volume data: loop and RAM disks, zram swap, optical and floppy drives
+const DefaultDeviceExclude = `^(loop|ram|zram|sr|fd)\d+$`
+
+// DeviceFilter selects devices by name. A nil *DeviceFilter allows every
+// device.
+type DeviceFilter struct {
+	include *regexp.Regexp // nil = every device
+	exclude *regexp.Regexp // nil = none
+}
+
+// NewDeviceFilter creates a filter allowing devices whose name matches
+// include, unless it also matches exclude. An empty pattern is ignored.
+func NewDeviceFilter(include, exclude string) (*DeviceFilter, error) {
+	f := &DeviceFilter{}
+	var err error
+	if include != "" {
+		if f.include, err = regexp.Compile(include); err != nil {
+			return nil, fmt.Errorf("device include: %w", err)
+		}
+	}
+	if exclude != "" {
+		if f.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("device exclude: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// Allows reports whether the device named name passes the filter
+func (f *DeviceFilter) Allows(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(name)
+}
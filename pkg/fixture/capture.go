@@ -0,0 +1,296 @@
+package fixture
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Source is where Capture reads a node's state from
+type Source struct {
+	Node            string
+	MountsPath      string // mount table, e.g. /host/proc/mounts or /host/proc/1/mounts
+	MountinfoPath   string // mountinfo alongside the mount table
+	DiskstatsPath   string
+	KubeletPath     string // where volmetd can read the kubelet directory
+	HostKubeletPath string // the kubelet directory's path in the mount table
+	DevPath         string // where volmetd can read the host's /dev
+}
+
+// sensitiveWords appear in mount option and vol_data.json keys whose values
+// may hold credentials, e.g. CIFS password= or Ceph secret=
+const sensitiveWords = `pass|secret|token|cred|key|user|domain|auth`
+
+var (
+	sensitiveKey    = regexp.MustCompile(`(?i)(` + sensitiveWords + `)`)
+	sensitiveOption = regexp.MustCompile(`(?i)([a-z0-9_.-]*(?:` + sensitiveWords + `)[a-z0-9_.-]*)=[^,\s]*`)
+)
+
+// redacted replaces sensitive values in captures
+const redacted = "REDACTED"
+
+// Capture writes a gzipped tar of the node's mount table, diskstats, kubelet
+// volume directory structure and vol_data.json files, /dev symlinks and a
+// manifest, in the fixture layout beneath a single top-level directory.
+// Mount options and vol_data.json values that may hold credentials are
+// redacted. No volume data, and no paths inside volumes, are included.
+func Capture(w io.Writer, src Source) error {
+	gz := gzip.NewWriter(w)
+	c := &capture{
+		tw:   tar.NewWriter(gz),
+		root: "volmetd-capture",
+		time: time.Now().Truncate(time.Second),
+		dirs: make(map[string]bool),
+	}
+	if src.Node != "" {
+		c.root += "-" + src.Node
+	}
+
+	manifest, err := json.MarshalIndent(Manifest{Node: src.Node, KubeletPath: src.HostKubeletPath}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := c.file(ManifestFile, append(manifest, '\n')); err != nil {
+		return err
+	}
+
+	for _, f := range []struct{ src, dst string }{
+		{src.MountsPath, "proc/mounts"},
+		{src.MountinfoPath, "proc/self/mountinfo"},
+		{src.DiskstatsPath, "proc/diskstats"},
+	} {
+		data, err := os.ReadFile(f.src)
+		if err != nil && f.dst == "proc/self/mountinfo" {
+			// Only needed where device IDs can't be stat'ed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if f.dst != "proc/diskstats" {
+			data = sensitiveOption.ReplaceAll(data, []byte("${1}="+redacted))
+		}
+		if err := c.file(f.dst, data); err != nil {
+			return err
+		}
+	}
+
+	kubelet := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(src.HostKubeletPath)), "/")
+	if err := c.kubeletPods(filepath.Join(src.KubeletPath, "pods"), path.Join(kubelet, "pods")); err != nil {
+		return err
+	}
+	if src.DevPath != "" {
+		if err := c.dev(src.DevPath); err != nil {
+			return err
+		}
+	}
+
+	if err := c.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type capture struct {
+	tw   *tar.Writer
+	root string
+	time time.Time
+	dirs map[string]bool // directories already written
+}
+
+// dir writes a directory entry and any missing parents
+func (c *capture) dir(name string) error {
+	if name == "." || name == "" || c.dirs[name] {
+		return nil
+	}
+	if err := c.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	c.dirs[name] = true
+	return c.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     path.Join(c.root, name) + "/",
+		Mode:     0o755,
+		ModTime:  c.time,
+	})
+}
+
+func (c *capture) file(name string, data []byte) error {
+	if err := c.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := c.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(c.root, name),
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  c.time,
+	}); err != nil {
+		return err
+	}
+	_, err := c.tw.Write(data)
+	return err
+}
+
+func (c *capture) symlink(name, target string) error {
+	if err := c.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	return c.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     path.Join(c.root, name),
+		Linkname: target,
+		Mode:     0o777,
+		ModTime:  c.time,
+	})
+}
+
+// kubeletPods captures <kubelet>/pods/<uid>/{volumes,volumeDevices}/<plugin>/<name>
+// and, inside each volume directory, only vol_data.json and the names of
+// its entries. Volume mounts are never descended into.
+func (c *capture) kubeletPods(dir, dst string) error {
+	if err := c.dir(dst); err != nil {
+		return err
+	}
+	pods, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if !pod.IsDir() {
+			continue
+		}
+		for _, kind := range []string{"volumes", "volumeDevices"} {
+			plugins, err := os.ReadDir(filepath.Join(dir, pod.Name(), kind))
+			if err != nil {
+				continue
+			}
+			for _, plugin := range plugins {
+				vols, err := os.ReadDir(filepath.Join(dir, pod.Name(), kind, plugin.Name()))
+				if err != nil {
+					continue
+				}
+				for _, vol := range vols {
+					rel := path.Join(pod.Name(), kind, plugin.Name(), vol.Name())
+					if err := c.volume(filepath.Join(dir, filepath.FromSlash(rel)), path.Join(dst, rel), vol); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// volume captures one volume directory, or a block volume's device entry
+func (c *capture) volume(src, dst string, entry fs.DirEntry) error {
+	if !entry.IsDir() {
+		return c.file(dst, nil)
+	}
+	if err := c.dir(dst); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		name := path.Join(dst, e.Name())
+		switch {
+		case e.Name() == "vol_data.json":
+			data, err := os.ReadFile(filepath.Join(src, e.Name()))
+			if err != nil {
+				return err
+			}
+			if err := c.file(name, redactVolData(data)); err != nil {
+				return err
+			}
+		case e.IsDir():
+			if err := c.dir(name); err != nil {
+				return err
+			}
+		default:
+			if err := c.file(name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dev captures the /dev symlink trees device paths in the mount table go
+// through, and empty placeholders for block device nodes they point at
+func (c *capture) dev(devPath string) error {
+	entries, err := os.ReadDir(devPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Type()&fs.ModeDevice != 0 && e.Type()&fs.ModeCharDevice == 0 {
+			if err := c.file(path.Join("dev", e.Name()), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range []string{"disk", "mapper"} {
+		root := filepath.Join(devPath, sub)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == root && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(devPath, p)
+			if err != nil {
+				return err
+			}
+			name := path.Join("dev", filepath.ToSlash(rel))
+			switch {
+			case d.IsDir():
+				return c.dir(name)
+			case d.Type()&fs.ModeSymlink != 0:
+				target, err := os.Readlink(p)
+				if err != nil {
+					return err
+				}
+				return c.symlink(name, target)
+			default:
+				return c.file(name, nil)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactVolData redacts sensitive top-level values in a vol_data.json file;
+// files that don't parse are dropped rather than copied unredacted
+func redactVolData(data []byte) []byte {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	for k := range fields {
+		if sensitiveKey.MatchString(k) {
+			fields[k] = redacted
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return append(out, '\n')
+}
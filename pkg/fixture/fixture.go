@@ -0,0 +1,244 @@
+// Package fixture loads a captured node tree so the full discovery and
+// collection pipeline can run against it instead of a live node, to
+// reproduce user reports locally.
+//
+// A fixture directory mirrors the host filesystem:
+//
+//	fixture.json            node name and the host kubelet path
+//	proc/mounts             mount table, with proc/self/mountinfo for device IDs
+//	proc/<pid>/             optional comm, mounts and mountinfo of a process
+//	                        whose mount namespace holds the volumes, e.g. the
+//	                        kubelet's on OpenShift (see Fixture.Resolver)
+//	proc/diskstats
+//	sys/                    optional sysfs subset (block, class, ...)
+//	dev/                    optional device symlinks (disk/by-id, mapper, ...)
+//	var/lib/kubelet/pods/   kubelet volume directories and vol_data.json files
+//	api/                    Kubernetes objects (Pods, PVs, PVCs, Nodes) as
+//	                        YAML or JSON, single objects or Lists
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// ManifestFile describes the fixture in the root of a fixture directory
+const ManifestFile = "fixture.json"
+
+// Manifest is the content of ManifestFile
+type Manifest struct {
+	Node        string `json:"node"`
+	KubeletPath string `json:"kubeletPath"` // kubelet path on the captured host, default /var/lib/kubelet
+}
+
+// Fixture is a loaded fixture directory
+type Fixture struct {
+	Dir string
+	Manifest
+
+	objects []runtime.Object
+}
+
+// Load reads the manifest and API objects of the fixture in dir
+func Load(dir string) (*Fixture, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "proc", "mounts")); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", dir, err)
+	}
+
+	f := &Fixture{Dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &f.Manifest); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", ManifestFile, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	if f.KubeletPath == "" {
+		f.KubeletPath = "/var/lib/kubelet"
+	}
+
+	f.objects, err = loadObjects(filepath.Join(dir, "api"))
+	if err != nil {
+		return nil, err
+	}
+	if f.Node == "" {
+		// Default to the only Node object, if there is one
+		for _, obj := range f.objects {
+			if n, ok := obj.(*corev1.Node); ok {
+				f.Node = n.Name
+				break
+			}
+		}
+	}
+	return f, nil
+}
+
+// Config returns a copy of cfg with host paths pointing into the fixture
+// and host namespace resolution turned off. A configured
+// MountNamespaceProcess, as on OpenShift, is kept for Resolver to read that
+// process's namespace from the fixture.
+func (f *Fixture) Config(cfg *config.Config) *config.Config {
+	c := *cfg
+	c.HostProcPath = filepath.Join(f.Dir, "proc")
+	c.HostSysPath = filepath.Join(f.Dir, "sys")
+	c.HostDevPath = filepath.Join(f.Dir, "dev")
+	c.KubeletPath = filepath.Join(f.Dir, f.KubeletPath)
+	c.HostKubeletPath = f.KubeletPath
+	if !c.HostMountNamespace {
+		c.MountNamespaceProcess = ""
+	}
+	c.HostMountNamespace = false
+	c.CapacityHostNamespace = false
+	c.UdevDataPath = ""
+	if udev := filepath.Join(f.Dir, "run", "udev", "data"); isDir(udev) {
+		c.UdevDataPath = udev
+	}
+	return &c
+}
+
+// Resolver returns a mount resolver reading the fixture's mount table and
+// devices. With process set, e.g. kubelet for an OpenShift node, the mount
+// table of the fixture's process of that name, proc/<pid>/mounts, is read
+// rather than proc/mounts.
+func (f *Fixture) Resolver(process string) (*mounts.Resolver, error) {
+	r := mounts.NewFixtureResolver(f.Dir, f.KubeletPath)
+	if process != "" {
+		var err error
+		if r, err = mounts.NewFixtureProcessResolver(f.Dir, process, f.KubeletPath); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", f.Dir, err)
+		}
+	}
+	if udev := filepath.Join(f.Dir, "run", "udev", "data"); isDir(udev) {
+		r.SetUdevDataPath(udev, filepath.Join(f.Dir, "sys"))
+	}
+	return r, nil
+}
+
+// Client returns a fake clientset serving the fixture's API objects.
+// Field selectors aren't applied, so every Pod is taken to be on the node.
+func (f *Fixture) Client() kubernetes.Interface {
+	return fake.NewSimpleClientset(f.objects...)
+}
+
+// loadObjects decodes every YAML or JSON file in dir, which may be missing
+func loadObjects(dir string) ([]runtime.Object, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var objects []runtime.Object
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range splitYAML(data) {
+			obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("fixture api/%s document %d: %w", name, i, err)
+			}
+			items, err := flatten(obj)
+			if err != nil {
+				return nil, fmt.Errorf("fixture api/%s document %d: %w", name, i, err)
+			}
+			objects = append(objects, items...)
+		}
+	}
+	return objects, nil
+}
+
+// splitYAML splits a multi-document YAML file; JSON passes through whole
+func splitYAML(data []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range bytes.Split(data, []byte("\n---")) {
+		// Drop the rest of the separator line, e.g. "--- # pods"
+		if i := bytes.IndexByte(doc, '\n'); i >= 0 && bytes.HasPrefix(doc, []byte("---")) {
+			doc = doc[i+1:]
+		}
+		if len(bytes.TrimSpace(doc)) > 0 && !isComment(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// isComment reports whether a YAML document holds only comments
+func isComment(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// flatten expands Lists, e.g. from kubectl get -o yaml, into their items
+func flatten(obj runtime.Object) ([]runtime.Object, error) {
+	if !meta.IsListType(obj) {
+		return []runtime.Object{obj}, nil
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, item := range items {
+		// Items of a v1 List are left undecoded
+		if u, ok := item.(*runtime.Unknown); ok {
+			item, _, err = scheme.Codecs.UniversalDeserializer().Decode(u.Raw, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		o, err := flatten(item)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o...)
+	}
+	return objects, nil
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,170 @@
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Synthetic fixture naming, shared with benchmarks that build volumes
+// matching the synthetic tree
+const (
+	SyntheticNode         = "synthetic-node"
+	SyntheticNamespace    = "bench"
+	SyntheticDriver       = "bench.csi.example.com"
+	SyntheticStorageClass = "bench-ssd"
+)
+
+// Synthesize writes a fixture of a node with n CSI volumes, each mounted by
+// its own pod on its own NVMe namespace, to dir. Alongside the volume mounts
+// every pod gets the service account and container rootfs mounts a real node
+// has, so mount tables are about three times the volume count.
+func Synthesize(dir string, n int) error {
+	const kubelet = "/var/lib/kubelet"
+
+	var mountsB, mountinfoB, diskstatsB strings.Builder
+	mountsB.WriteString("/dev/sda1 / ext4 rw,relatime 0 0\n" +
+		"proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0\n" +
+		"sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0\n" +
+		"/dev/sda1 /var/lib/kubelet ext4 rw,relatime 0 0\n")
+	mountinfoB.WriteString("21 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n" +
+		"22 21 0:5 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw\n" +
+		"23 21 0:6 / /sys rw,nosuid,nodev,noexec,relatime - sysfs sysfs rw\n" +
+		"24 21 8:1 /var/lib/kubelet /var/lib/kubelet rw,relatime - ext4 /dev/sda1 rw\n")
+	diskstatsB.WriteString(diskstatsLine(8, 0, "sda", 0) + diskstatsLine(8, 1, "sda1", 0))
+
+	items := make([]any, 0, 3*n)
+	for i := range n {
+		uid := fmt.Sprintf("00000000-0000-4000-8000-%012d", i)
+		pv := fmt.Sprintf("pvc-10000000-0000-4000-8000-%012d", i)
+		pvc := fmt.Sprintf("data-bench-%d", i)
+		pod := fmt.Sprintf("bench-%d", i)
+		dev := fmt.Sprintf("nvme%dn1", i+1)
+		podDir := filepath.Join(kubelet, "pods", uid)
+		volDir := filepath.Join(podDir, "volumes", "kubernetes.io~csi", pv)
+		mountPath := filepath.Join(volDir, "mount")
+		token := filepath.Join(podDir, "volumes", "kubernetes.io~projected", "kube-api-access")
+		rootfs := fmt.Sprintf("/run/containerd/io.containerd.runtime.v2.task/k8s.io/%064x/rootfs", i)
+		overlay := fmt.Sprintf("rw,relatime,lowerdir=/var/lib/containerd/snapshots/%d/fs:/var/lib/containerd/snapshots/%d/fs,upperdir=/var/lib/containerd/snapshots/%d/fs,workdir=/var/lib/containerd/snapshots/%d/work", 3*i+1, 3*i+2, 3*i+3, 3*i+3)
+
+		fmt.Fprintf(&mountsB, "/dev/%s %s ext4 rw,relatime 0 0\n", dev, mountPath)
+		fmt.Fprintf(&mountsB, "tmpfs %s tmpfs rw,relatime,size=65536k 0 0\n", token)
+		fmt.Fprintf(&mountsB, "overlay %s overlay %s 0 0\n", rootfs, overlay)
+		fmt.Fprintf(&mountinfoB, "%d 24 259:%d / %s rw,relatime - ext4 /dev/%s rw\n", 100+3*i, i+1, mountPath, dev)
+		fmt.Fprintf(&mountinfoB, "%d 24 0:%d / %s rw,relatime - tmpfs tmpfs rw,size=65536k\n", 101+3*i, 100+i, token)
+		fmt.Fprintf(&mountinfoB, "%d 21 0:%d / %s rw,relatime - overlay overlay %s\n", 102+3*i, 5000+i, rootfs, overlay)
+		diskstatsB.WriteString(diskstatsLine(259, i+1, dev, uint64(i)))
+
+		volData, err := json.Marshal(map[string]string{
+			"specVolID":                   pv,
+			"driverName":                  SyntheticDriver,
+			"volumeHandle":                fmt.Sprintf("vol-%08d", i),
+			"kubernetes.io/pod.name":      pod,
+			"kubernetes.io/pod.namespace": SyntheticNamespace,
+			"kubernetes.io/pod.uid":       uid,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, volDir, "vol_data.json"), volData); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(dir, mountPath), 0o755); err != nil {
+			return err
+		}
+
+		items = append(items, syntheticObjects(i, uid, pod, pvc, pv)...)
+	}
+
+	manifest, err := json.Marshal(Manifest{Node: SyntheticNode, KubeletPath: kubelet})
+	if err != nil {
+		return err
+	}
+	api, err := json.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": items})
+	if err != nil {
+		return err
+	}
+	for name, data := range map[string][]byte{
+		ManifestFile:          manifest,
+		"proc/mounts":         []byte(mountsB.String()),
+		"proc/self/mountinfo": []byte(mountinfoB.String()),
+		"proc/diskstats":      []byte(diskstatsB.String()),
+		"api/synthetic.json":  api,
+	} {
+		if err := writeFile(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syntheticObjects returns the Pod, PVC and PV of synthetic volume i
+func syntheticObjects(i int, uid, pod, pvc, pv string) []any {
+	class := SyntheticStorageClass
+	size := resource.MustParse("10Gi")
+	return []any{
+		&corev1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Name: pod, Namespace: SyntheticNamespace, UID: types.UID(uid)},
+			Spec: corev1.PodSpec{
+				NodeName: SyntheticNode,
+				Volumes: []corev1.Volume{{
+					Name:         "data",
+					VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc}},
+				}},
+				Containers: []corev1.Container{{
+					Name:         "app",
+					VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.PersistentVolumeClaim{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+			ObjectMeta: metav1.ObjectMeta{Name: pvc, Namespace: SyntheticNamespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				VolumeName:       pv,
+				StorageClassName: &class,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase:    corev1.ClaimBound,
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+		&corev1.PersistentVolume{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
+			ObjectMeta: metav1.ObjectMeta{Name: pv},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName: class,
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: size},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: SyntheticDriver, VolumeHandle: fmt.Sprintf("vol-%08d", i)},
+				},
+				ClaimRef: &corev1.ObjectReference{Namespace: SyntheticNamespace, Name: pvc},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		},
+	}
+}
+
+// diskstatsLine returns a diskstats line with all 17 counters, scaled by seed
+func diskstatsLine(major, minor int, name string, seed uint64) string {
+	c := seed*1000 + 1
+	return fmt.Sprintf("%4d %7d %s %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d\n",
+		major, minor, name, c, c/2, c*8, c*3, c*2, c, c*16, c*5, 0, c*4, c*9, 0, 0, 0, 0, c/10, c/5)
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
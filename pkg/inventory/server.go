@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	pb "github.com/gfx-labs/volmetd/pkg/inventory/inventorypb"
+)
+
+// Server implements the VolumeInventory gRPC service. It runs discovery on an
+// interval and fans out changes to all active watchers, so the number of
+// watchers doesn't multiply discovery work.
+type Server struct {
+	pb.UnimplementedVolumeInventoryServer
+
+	discoverer *discovery.MultiDiscoverer
+	nodeName   string
+	interval   time.Duration
+
+	mu       sync.RWMutex
+	volumes  map[string]*pb.VolumeInfo // keyed by pod UID + PV name
+	synced   bool
+	watchers map[chan *pb.WatchVolumesResponse]struct{}
+}
+
+// NewServer creates a new inventory server
+func NewServer(discoverer *discovery.MultiDiscoverer, interval time.Duration) *Server {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Server{
+		discoverer: discoverer,
+		nodeName:   discovery.DetectNodeName(),
+		interval:   interval,
+		volumes:    make(map[string]*pb.VolumeInfo),
+		watchers:   make(map[chan *pb.WatchVolumesResponse]struct{}),
+	}
+}
+
+// Run refreshes the inventory every interval until ctx is cancelled
+func (s *Server) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) refresh(ctx context.Context) {
+	volumes, err := s.discoverer.Discover(ctx)
+	if err != nil {
+		slog.Warn("inventory: discovery error", "error", err)
+		return
+	}
+
+	current := make(map[string]*pb.VolumeInfo, len(volumes))
+	for _, v := range volumes {
+		current[volumeKey(v)] = toProto(v)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []*pb.WatchVolumesResponse
+	for k, v := range current {
+		prev, ok := s.volumes[k]
+		switch {
+		case !ok:
+			events = append(events, &pb.WatchVolumesResponse{Type: pb.WatchVolumesResponse_EVENT_TYPE_ADDED, Volume: v})
+		case !proto.Equal(prev, v):
+			events = append(events, &pb.WatchVolumesResponse{Type: pb.WatchVolumesResponse_EVENT_TYPE_MODIFIED, Volume: v})
+		}
+	}
+	for k, v := range s.volumes {
+		if _, ok := current[k]; !ok {
+			events = append(events, &pb.WatchVolumesResponse{Type: pb.WatchVolumesResponse_EVENT_TYPE_REMOVED, Volume: v})
+		}
+	}
+
+	s.volumes = current
+	s.synced = true
+
+	for ch := range s.watchers {
+	send:
+		for _, e := range events {
+			select {
+			case ch <- e:
+			default:
+				// Slow watcher: drop it rather than block discovery
+				slog.Warn("inventory: dropping slow watcher")
+				delete(s.watchers, ch)
+				close(ch)
+				break send
+			}
+		}
+	}
+}
+
+// ListVolumes implements VolumeInventoryServer
+func (s *Server) ListVolumes(ctx context.Context, req *pb.ListVolumesRequest) (*pb.ListVolumesResponse, error) {
+	s.mu.RLock()
+	synced := s.synced
+	s.mu.RUnlock()
+	if !synced {
+		s.refresh(ctx)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := &pb.ListVolumesResponse{
+		NodeName: s.nodeName,
+		Volumes:  make([]*pb.VolumeInfo, 0, len(s.volumes)),
+	}
+	for _, v := range s.volumes {
+		resp.Volumes = append(resp.Volumes, v)
+	}
+	return resp, nil
+}
+
+// WatchVolumes implements VolumeInventoryServer
+func (s *Server) WatchVolumes(req *pb.WatchVolumesRequest, stream pb.VolumeInventory_WatchVolumesServer) error {
+	ch := make(chan *pb.WatchVolumesResponse, 256)
+
+	// Register and snapshot under the same lock so no change is missed
+	s.mu.Lock()
+	initial := make([]*pb.WatchVolumesResponse, 0, len(s.volumes))
+	for _, v := range s.volumes {
+		initial = append(initial, &pb.WatchVolumesResponse{Type: pb.WatchVolumesResponse_EVENT_TYPE_ADDED, Volume: v})
+	}
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if _, ok := s.watchers[ch]; ok {
+			delete(s.watchers, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}()
+
+	for _, e := range initial {
+		if err := stream.Send(e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell too far behind")
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func volumeKey(v *discovery.VolumeInfo) string {
+	return v.PodUID + "/" + v.PVName
+}
+
+func toProto(v *discovery.VolumeInfo) *pb.VolumeInfo {
+	return &pb.VolumeInfo{
+		PvcName:            v.PVCName,
+		PvcNamespace:       v.PVCNamespace,
+		PvName:             v.PVName,
+		PodName:            v.PodName,
+		PodNamespace:       v.PodNamespace,
+		PodUid:             v.PodUID,
+		StorageClass:       v.StorageClass,
+		CsiDriver:          v.CSIDriver,
+		VolumeHandle:       v.VolumeHandle,
+		DevicePath:         v.DevicePath,
+		DeviceName:         v.DeviceName,
+		DeviceId:           v.DeviceID,
+		CsiDevicePath:      v.CSIDevicePath,
+		MountPath:          v.MountPath,
+		ContainerMountPath: v.ContainerMountPath,
+	}
+}
@@ -0,0 +1,236 @@
+// Package kmsg tails the kernel log from /dev/kmsg and counts filesystem and
+// block device errors by device: ext4 errors, XFS corruption, I/O errors and
+// NVMe controller resets, which otherwise only show up in dmesg.
+package kmsg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Kinds of kernel log error
+const (
+	KindExt4Error     = "ext4_error"
+	KindXFSCorruption = "xfs_corruption"
+	KindIOError       = "io_error"
+	KindNVMeReset     = "nvme_reset"
+)
+
+// patterns match kernel log messages to a kind of error. The first submatch
+// is the device: a block device, partition or, for NVMe resets, controller.
+var patterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{KindExt4Error, regexp.MustCompile(`^EXT4-fs error \(device ([^)]+)\)`)},
+	{KindXFSCorruption, regexp.MustCompile(`^XFS \(([^)]+)\): .*(?i:corrupt|metadata I/O error|log I/O error)`)},
+	{KindIOError, regexp.MustCompile(`I/O error,? (?:on )?dev ([\w-]+)`)},
+	{KindNVMeReset, regexp.MustCompile(`^nvme (nvme\d+): .*\breset`)},
+}
+
+// Match returns the device and kind of error a kernel log message reports
+func Match(message string) (device, kind string, ok bool) {
+	for _, p := range patterns {
+		if m := p.re.FindStringSubmatch(message); m != nil {
+			return m[1], p.kind, true
+		}
+	}
+	return "", "", false
+}
+
+// Key identifies a device's errors of one kind
+type Key struct {
+	Device string
+	Kind   string
+}
+
+// Event is the most recent error of a device and kind
+type Event struct {
+	Time    time.Time
+	Message string
+}
+
+// Watcher counts the errors in the kernel log
+type Watcher struct {
+	path     string
+	procPath string // for the boot time that record timestamps count from
+
+	mu      sync.Mutex
+	counts  map[Key]uint64
+	last    map[Key]Event
+	seq     uint64 // of the last record read, to skip it when reopening
+	started bool   // whether any record was read
+}
+
+// NewWatcher creates a watcher of the kernel log at path, usually /dev/kmsg.
+// Reading it needs CAP_SYSLOG, or kernel.dmesg_restrict=0.
+func NewWatcher(path, procPath string) *Watcher {
+	if path == "" {
+		path = "/dev/kmsg"
+	}
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	return &Watcher{
+		path:     path,
+		procPath: procPath,
+		counts:   make(map[Key]uint64),
+		last:     make(map[Key]Event),
+	}
+}
+
+// retryInterval is how long Run waits to reopen the log after an error
+const retryInterval = 30 * time.Second
+
+// Run reads the kernel log, starting with the records still in the ring
+// buffer, until ctx is done. Counts therefore cover roughly since boot.
+func (w *Watcher) Run(ctx context.Context) {
+	for {
+		err := w.read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("kernel log watcher failed, retrying", "path", w.path, "error", err, "retry", retryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (w *Watcher) read(ctx context.Context) error {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return err
+	}
+	// Close the file to unblock the pending read when ctx is done
+	stop := context.AfterFunc(ctx, func() { f.Close() })
+	defer func() {
+		if stop() {
+			f.Close()
+		}
+	}()
+
+	boot := bootTime(w.procPath)
+	// Each read returns exactly one record
+	buf := make([]byte, 8192)
+	for {
+		n, err := f.Read(buf)
+		if errors.Is(err, syscall.EPIPE) {
+			// Records were overwritten before we read them
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if r, ok := parseRecord(string(buf[:n])); ok {
+			w.record(r, boot)
+		}
+	}
+}
+
+// record is one /dev/kmsg record: "<prefix>,<seq>,<usec>,<flags>;<message>",
+// followed by continuation lines of device properties
+type record struct {
+	seq     uint64
+	usec    uint64 // since boot
+	message string
+}
+
+func parseRecord(s string) (record, bool) {
+	header, rest, ok := strings.Cut(s, ";")
+	if !ok {
+		return record{}, false
+	}
+	fields := strings.Split(header, ",")
+	if len(fields) < 3 {
+		return record{}, false
+	}
+	seq, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return record{}, false
+	}
+	usec, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return record{}, false
+	}
+	message, _, _ := strings.Cut(rest, "\n")
+	return record{seq: seq, usec: usec, message: message}, true
+}
+
+func (w *Watcher) record(r record, boot time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// A reopened log starts over at the oldest record in the ring buffer
+	if w.started && r.seq <= w.seq {
+		return
+	}
+	w.seq, w.started = r.seq, true
+
+	device, kind, ok := Match(r.message)
+	if !ok {
+		return
+	}
+	key := Key{Device: device, Kind: kind}
+	t := time.Now()
+	if !boot.IsZero() {
+		t = boot.Add(time.Duration(r.usec) * time.Microsecond)
+	}
+	w.counts[key]++
+	w.last[key] = Event{Time: t, Message: r.message}
+	slog.Debug("kernel log error", "device", device, "kind", kind, "message", r.message)
+}
+
+// Counts returns the number of errors seen by device and kind
+func (w *Watcher) Counts() map[Key]uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	counts := make(map[Key]uint64, len(w.counts))
+	for k, v := range w.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// Last returns the most recent error of each device and kind
+func (w *Watcher) Last() map[Key]Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := make(map[Key]Event, len(w.last))
+	for k, v := range w.last {
+		last[k] = v
+	}
+	return last
+}
+
+// bootTime derives the boot time from <procPath>/uptime, zero if unreadable
+func bootTime(procPath string) time.Time {
+	f, err := os.Open(procPath + "/uptime")
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString(' ')
+	if err != nil {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(secs * float64(time.Second)))
+}
@@ -0,0 +1,148 @@
+// Package kubelet reads volume stats from the kubelet's /stats/summary API.
+// The kubelet statfs's every mounted volume itself, so this stands in for
+// volmetd's own statfs when it runs without access to the kubelet's pod
+// directories.
+package kubelet
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// serviceAccountToken authenticates to the kubelet, which needs get on the
+// nodes/stats subresource
+const serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// DefaultCacheTTL is how long a fetched summary is reused, so one scrape
+// fetches it once rather than once per volume
+const DefaultCacheTTL = 10 * time.Second
+
+// summary is the part of the kubelet's stats summary volmetd reads
+type summary struct {
+	Pods []struct {
+		Volume []volumeStats `json:"volume"`
+	} `json:"pods"`
+}
+
+type volumeStats struct {
+	CapacityBytes  *uint64 `json:"capacityBytes"`
+	UsedBytes      *uint64 `json:"usedBytes"`
+	AvailableBytes *uint64 `json:"availableBytes"`
+	Inodes         *uint64 `json:"inodes"`
+	InodesUsed     *uint64 `json:"inodesUsed"`
+	InodesFree     *uint64 `json:"inodesFree"`
+	PVCRef         *struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"pvcRef"`
+}
+
+// Client fetches volume capacity from a kubelet
+type Client struct {
+	url       string
+	tokenPath string
+	ttl       time.Duration
+	http      *http.Client
+
+	mu      sync.Mutex
+	fetched time.Time
+	pvcs    map[string]*mounts.Capacity // keyed by namespace/name
+	err     error
+}
+
+// NewClient creates a client for the kubelet at url, e.g.
+// https://10.0.0.5:10250, authenticating with the pod's service account
+// token. Kubelet serving certificates are usually self-signed, so they
+// aren't verified.
+func NewClient(url string) *Client {
+	return &Client{
+		url:       strings.TrimSuffix(url, "/"),
+		tokenPath: serviceAccountToken,
+		ttl:       DefaultCacheTTL,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	}
+}
+
+// Capacity returns the capacity the kubelet last measured for a PVC
+func (c *Client) Capacity(ctx context.Context, namespace, pvc string) (*mounts.Capacity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.fetched) > c.ttl {
+		c.pvcs, c.err = c.fetch(ctx)
+		c.fetched = time.Now()
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	cap, ok := c.pvcs[namespace+"/"+pvc]
+	if !ok {
+		return nil, fmt.Errorf("kubelet has no stats for pvc %s/%s", namespace, pvc)
+	}
+	copied := *cap
+	return &copied, nil
+}
+
+func (c *Client) fetch(ctx context.Context) (map[string]*mounts.Capacity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/stats/summary", nil)
+	if err != nil {
+		return nil, err
+	}
+	// Projected tokens rotate, so read it on every request
+	if token, err := os.ReadFile(c.tokenPath); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubelet stats: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet stats: %s", resp.Status)
+	}
+
+	var s summary
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("kubelet stats: %w", err)
+	}
+
+	pvcs := make(map[string]*mounts.Capacity)
+	for _, pod := range s.Pods {
+		for _, v := range pod.Volume {
+			if v.PVCRef == nil || v.CapacityBytes == nil {
+				continue
+			}
+			pvcs[v.PVCRef.Namespace+"/"+v.PVCRef.Name] = &mounts.Capacity{
+				TotalBytes:  value(v.CapacityBytes),
+				UsedBytes:   value(v.UsedBytes),
+				FreeBytes:   value(v.AvailableBytes),
+				TotalInodes: value(v.Inodes),
+				UsedInodes:  value(v.InodesUsed),
+				FreeInodes:  value(v.InodesFree),
+			}
+		}
+	}
+	return pvcs, nil
+}
+
+func value(p *uint64) uint64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
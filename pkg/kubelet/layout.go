@@ -0,0 +1,108 @@
+package kubelet
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Volume plugin directories beneath a pod's volumes directory
+const (
+	PluginCSI       = "kubernetes.io~csi"
+	PluginProjected = "kubernetes.io~projected"
+	PluginEmptyDir  = "kubernetes.io~empty-dir"
+)
+
+// Layout locates the kubelet's directories: its root directory on the host,
+// which distros move, and the pod, volume and plugin directories beneath it.
+// Paths are built on the root passed in, so one layout serves both the root
+// as volmetd sees it through a host mount and as the host sees it.
+type Layout interface {
+	// Name identifies the layout, e.g. in logs and VOLMETD_KUBELET_LAYOUT
+	Name() string
+	// Root is the kubelet root directory on the host
+	Root() string
+	// PodsDir holds a directory per pod, named by the pod's UID
+	PodsDir(root string) string
+	// PodVolumesDir holds a pod's volumes of a volume plugin, e.g.
+	// PluginCSI, a directory per volume
+	PodVolumesDir(root, podUID, plugin string) string
+	// PluginsDir holds the node plugins' directories, with CSI drivers'
+	// sockets
+	PluginsDir(root string) string
+}
+
+// standardLayout is the upstream kubelet's layout beneath root: pods/<uid>/
+// volumes/<plugin>/<volume> and plugins/<driver>. Every kubelet since CSI
+// went GA (1.13) uses it; distros only move the root.
+type standardLayout struct {
+	name, root string
+}
+
+func (l standardLayout) Name() string { return l.name }
+func (l standardLayout) Root() string { return l.root }
+
+func (l standardLayout) PodsDir(root string) string {
+	return filepath.Join(root, "pods")
+}
+
+func (l standardLayout) PodVolumesDir(root, podUID, plugin string) string {
+	return filepath.Join(root, "pods", podUID, "volumes", plugin)
+}
+
+func (l standardLayout) PluginsDir(root string) string {
+	return filepath.Join(root, "plugins")
+}
+
+// Known layouts
+var (
+	// Standard is the upstream kubelet's, rooted at /var/lib/kubelet
+	Standard Layout = standardLayout{"standard", "/var/lib/kubelet"}
+	// K3s is k3s's kubelet, rooted in its agent directory
+	K3s Layout = standardLayout{"k3s", "/var/lib/rancher/k3s/agent/kubelet"}
+	// MicroK8s is microk8s's kubelet, rooted in its snap's writable directory
+	MicroK8s Layout = standardLayout{"microk8s", "/var/snap/microk8s/common/var/lib/kubelet"}
+	// Bottlerocket is Bottlerocket's kubelet, rooted as upstream's
+	Bottlerocket Layout = standardLayout{"bottlerocket", "/var/lib/kubelet"}
+)
+
+// layouts are the known layouts, in the order DetectLayout tries them:
+// moved roots first, as a node may keep an unused /var/lib/kubelet
+var layouts = []Layout{K3s, MicroK8s, Standard, Bottlerocket}
+
+// LayoutByName returns the known layout called name
+func LayoutByName(name string) (Layout, bool) {
+	for _, l := range layouts {
+		if l.Name() == name {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// LayoutNames lists the known layouts' names
+func LayoutNames() []string {
+	names := make([]string, len(layouts))
+	for i, l := range layouts {
+		names[i] = l.Name()
+	}
+	return names
+}
+
+// DetectLayout returns the first known layout whose pods directory exists
+// beneath one of prefixes, e.g. "/host" for a host root mounted there and ""
+// for the local root, along with that prefix. Without one, it returns
+// Standard and the first prefix.
+func DetectLayout(prefixes ...string) (Layout, string) {
+	for _, l := range layouts {
+		for _, p := range prefixes {
+			if _, err := os.Stat(l.PodsDir(p + l.Root())); err == nil {
+				return l, p
+			}
+		}
+	}
+	var prefix string
+	if len(prefixes) > 0 {
+		prefix = prefixes[0]
+	}
+	return Standard, prefix
+}
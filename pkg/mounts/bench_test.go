@@ -0,0 +1,63 @@
+//go:build !race
+
+package mounts_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// benchVolumes is the number of volumes of the synthetic node benchmarked,
+// which has three mounts per volume
+const benchVolumes = 1000
+
+// readAllocBudget is the allocations per volume re-reading an unchanged mount
+// table may take. Mount strings are interned and Mounts allocated in blocks,
+// so it costs a few allocations in all.
+const readAllocBudget = 0.1
+
+// synthesize returns a table of a synthetic node's mounts with benchVolumes
+// volumes, read once
+func synthesize(tb testing.TB) *mounts.Table {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := fixture.Synthesize(dir, benchVolumes); err != nil {
+		tb.Fatal(err)
+	}
+	table := mounts.NewTable(filepath.Join(dir, "proc", "mounts"), mounts.DefaultLimits)
+	tb.Cleanup(func() { table.Close() })
+	if _, err := table.Read(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return table
+}
+
+func BenchmarkTableRead(b *testing.B) {
+	table := synthesize(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := table.Read(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTableReadAllocBudget(t *testing.T) {
+	table := synthesize(t)
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := table.Read(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if perVolume := allocs / benchVolumes; perVolume > readAllocBudget {
+		t.Errorf("reading mounts: %g allocations per volume, budget %g", perVolume, readAllocBudget)
+	}
+}
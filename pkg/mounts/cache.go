@@ -0,0 +1,101 @@
+package mounts
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// resolveCacheTTL bounds how long a resolution is reused even when the mount
+// table hasn't changed, in case a symlink is replaced under a live mount
+const resolveCacheTTL = 10 * time.Minute
+
+// resolveCache holds device resolutions and device IDs until the mount table
+// changes, so every scrape doesn't re-walk symlinks and re-stat mount points
+// for every volume. The zero value is empty and ready to use.
+type resolveCache struct {
+	mu      sync.Mutex
+	sum     uint64 // hash of the mount table the entries were resolved against
+	time    time.Time
+	devices map[string]resolvedDevice // by device path
+	ids     map[string]string         // by mount point
+
+	hits, misses uint64
+}
+
+type resolvedDevice struct {
+	path, name string
+}
+
+// update empties the cache when mounts differ from the table its entries
+// were resolved against, or when resolveCacheTTL has passed
+func (c *resolveCache) update(mounts []*Mount) {
+	h := fnv.New64a()
+	for _, m := range mounts {
+		h.Write([]byte(m.Device))
+		h.Write([]byte{0})
+		h.Write([]byte(m.MountPoint))
+		h.Write([]byte{0})
+		h.Write([]byte(m.FSType))
+		h.Write([]byte{0})
+	}
+	sum := h.Sum64()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sum == c.sum && time.Since(c.time) < resolveCacheTTL {
+		return
+	}
+	c.sum, c.time = sum, time.Now()
+	c.devices = make(map[string]resolvedDevice)
+	c.ids = make(map[string]string)
+}
+
+func (c *resolveCache) device(devicePath string) (resolvedDevice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.devices[devicePath]
+	c.count(ok)
+	return d, ok
+}
+
+func (c *resolveCache) setDevice(devicePath string, d resolvedDevice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.devices != nil {
+		c.devices[devicePath] = d
+	}
+}
+
+func (c *resolveCache) id(mountPoint string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.ids[mountPoint]
+	c.count(ok)
+	return id, ok
+}
+
+func (c *resolveCache) setID(mountPoint, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids != nil {
+		c.ids[mountPoint] = id
+	}
+}
+
+// count records a lookup. Must be called with c.mu held.
+func (c *resolveCache) count(hit bool) {
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// CacheStats returns how many device and device ID lookups were served from
+// the resolver's cache and how many were resolved
+func (r *Resolver) CacheStats() (hits, misses uint64) {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	return r.cache.hits, r.cache.misses
+}
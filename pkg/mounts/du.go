@@ -0,0 +1,58 @@
+package mounts
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DiskUsage walks path like du -sx and returns the bytes allocated and the
+// number of inodes beneath it, without crossing into other filesystems.
+// Hard-linked files are counted once.
+func DiskUsage(ctx context.Context, path string) (bytes, inodes uint64, err error) {
+	var root syscall.Stat_t
+	if err := syscall.Lstat(path, &root); err != nil {
+		return 0, 0, err
+	}
+
+	type inode struct{ dev, ino uint64 }
+	seen := make(map[inode]struct{})
+
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Entries can vanish mid-walk; skip what we can't read
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var st syscall.Stat_t
+		if err := syscall.Lstat(p, &st); err != nil {
+			return nil
+		}
+		if uint64(st.Dev) != uint64(root.Dev) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if st.Nlink > 1 {
+			key := inode{uint64(st.Dev), uint64(st.Ino)}
+			if _, ok := seen[key]; ok {
+				return nil
+			}
+			seen[key] = struct{}{}
+		}
+
+		bytes += uint64(st.Blocks) * 512
+		inodes++
+		return nil
+	})
+	return bytes, inodes, err
+}
@@ -0,0 +1,33 @@
+package mounts
+
+import "errors"
+
+var (
+	// ErrMountNotFound is wrapped by errors for paths with no entry in the
+	// mount table or mountinfo
+	ErrMountNotFound = errors.New("mount not found")
+
+	// ErrDeviceUnresolvable is matched by errors for mount points and device
+	// paths whose block device couldn't be determined
+	ErrDeviceUnresolvable = errors.New("device unresolvable")
+)
+
+// DeviceError records why the device of a mount point or device path
+// couldn't be determined. It matches ErrDeviceUnresolvable, and Err is
+// available to errors.Is and errors.As, e.g. fs.ErrPermission.
+type DeviceError struct {
+	Path string
+	Err  error
+}
+
+func (e *DeviceError) Error() string {
+	return "device of " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DeviceError) Is(target error) bool {
+	return target == ErrDeviceUnresolvable
+}
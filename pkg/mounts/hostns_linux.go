@@ -0,0 +1,103 @@
+package mounts
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNotMountPoint is returned when a path expected to be a mount point lives
+// on the same filesystem as its parent, which usually means the mount did not
+// propagate and statfs would report the parent filesystem instead
+var ErrNotMountPoint = errors.New("not a mount point")
+
+// GetCapacityInRoot returns capacity information for a mount point resolved
+// inside root (e.g. /host/proc/1/root for the host mount namespace).
+// Resolution uses openat2 with RESOLVE_IN_ROOT so symlinks cannot escape root,
+// and RESOLVE_NO_XDEV on the last component to verify it is really a mount.
+func GetCapacityInRoot(root, mountPoint string) (*Capacity, error) {
+	rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open root %s: %w", root, err)
+	}
+	defer unix.Close(rootFd)
+
+	parentFd, err := unix.Openat2(rootFd, filepath.Dir(mountPoint), &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_DIRECTORY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openat2 %s in %s: %w", filepath.Dir(mountPoint), root, err)
+	}
+	defer unix.Close(parentFd)
+
+	base := filepath.Base(mountPoint)
+
+	// Opening the last component without crossing devices only succeeds if
+	// it's on the parent's filesystem, i.e. not a mount point
+	if fd, err := unix.Openat2(parentFd, base, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_XDEV,
+	}); err == nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("statfs %s: %w", mountPoint, ErrNotMountPoint)
+	} else if !errors.Is(err, unix.EXDEV) {
+		return nil, fmt.Errorf("openat2 %s: %w", mountPoint, err)
+	}
+
+	fd, err := unix.Openat2(parentFd, base, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openat2 %s: %w", mountPoint, err)
+	}
+	defer unix.Close(fd)
+
+	return fstatfs(fd, mountPoint)
+}
+
+// CheckDirBeneath verifies that sub, relative to root, is a directory
+// reached without following symlinks or leaving root. Paths under a volume
+// are tenant controlled, so any component may have been swapped for a link.
+func CheckDirBeneath(root, sub string) error {
+	rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open root %s: %w", root, err)
+	}
+	defer unix.Close(rootFd)
+
+	fd, err := unix.Openat2(rootFd, sub, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_DIRECTORY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if err != nil {
+		return fmt.Errorf("openat2 %s beneath %s: %w", sub, root, err)
+	}
+	unix.Close(fd)
+	return nil
+}
+
+// GetPathCapacityInRoot returns capacity information for the filesystem
+// holding path, resolved inside root like GetCapacityInRoot. Unlike it, path
+// needn't be a mount point, e.g. / or /var/lib/kubelet.
+func GetPathCapacityInRoot(root, path string) (*Capacity, error) {
+	rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open root %s: %w", root, err)
+	}
+	defer unix.Close(rootFd)
+
+	fd, err := unix.Openat2(rootFd, path, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openat2 %s in %s: %w", path, root, err)
+	}
+	defer unix.Close(fd)
+
+	return fstatfs(fd, path)
+}
@@ -0,0 +1,50 @@
+package mounts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A subPath whose parent a tenant replaced with a symlink must not resolve
+// outside the volume, even though its last component is a real directory
+func TestCheckDirBeneath(t *testing.T) {
+	vol := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{
+		filepath.Join(vol, "tenant-a", "data"),
+		filepath.Join(outside, "data"),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(vol, "tenant-b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "data"), filepath.Join(vol, "tenant-a", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vol, "tenant-a", "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sub string
+		ok  bool
+	}{
+		{sub: "tenant-a", ok: true},
+		{sub: "tenant-a/data", ok: true},
+		{sub: "tenant-b/data"},
+		{sub: "tenant-a/link"},
+		{sub: "tenant-a/file"},
+		{sub: "tenant-a/missing"},
+		{sub: "../" + filepath.Base(outside)},
+	}
+	for _, tt := range tests {
+		err := CheckDirBeneath(vol, tt.sub)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckDirBeneath(%q) = %v, want ok %v", tt.sub, err, tt.ok)
+		}
+	}
+}
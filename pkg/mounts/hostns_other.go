@@ -0,0 +1,27 @@
+//go:build !linux
+
+package mounts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotMountPoint is returned when a path expected to be a mount point lives
+// on the same filesystem as its parent
+var ErrNotMountPoint = errors.New("not a mount point")
+
+// GetCapacityInRoot is only supported on Linux
+func GetCapacityInRoot(root, mountPoint string) (*Capacity, error) {
+	return nil, fmt.Errorf("statfs %s in %s: openat2 not supported on this platform", mountPoint, root)
+}
+
+// GetPathCapacityInRoot is only supported on Linux
+func GetPathCapacityInRoot(root, path string) (*Capacity, error) {
+	return nil, fmt.Errorf("statfs %s in %s: openat2 not supported on this platform", path, root)
+}
+
+// CheckDirBeneath is only supported on Linux
+func CheckDirBeneath(root, sub string) error {
+	return fmt.Errorf("open %s beneath %s: openat2 not supported on this platform", sub, root)
+}
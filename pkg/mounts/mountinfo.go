@@ -0,0 +1,251 @@
+package mounts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is an entry of a mountinfo file, which unlike the mount table
+// records how mounts nest and which directory of its filesystem each mount
+// shows
+type MountInfo struct {
+	ID       int
+	ParentID int
+	DeviceID string // major:minor of the filesystem
+	// Root is the directory of the filesystem mounted, / unless it is a bind
+	// mount of a subdirectory (e.g. a subPath or a local PV's directory)
+	Root       string
+	MountPoint string
+	FSType     string
+	Source     string // e.g. /dev/sdb or server:/export
+}
+
+// ParseMountinfo reads a mountinfo file (default /proc/self/mountinfo)
+// within limits, stopping early when ctx is done
+func ParseMountinfo(ctx context.Context, path string, limits Limits) ([]*MountInfo, error) {
+	if path == "" {
+		path = "/proc/self/mountinfo"
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open mountinfo: %w", err)
+	}
+	defer f.Close()
+
+	maxLine := limits.MaxLineBytes
+	if maxLine <= 0 {
+		maxLine = math.MaxInt
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, min(64<<10, maxLine)), maxLine)
+
+	// Allocated in blocks, like the mount table
+	var infos []*MountInfo
+	var block []MountInfo
+	for n := 0; scanner.Scan(); n++ {
+		if n%1024 == 1023 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		info, ok := parseMountinfoLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if limits.MaxEntries > 0 && len(infos) == limits.MaxEntries {
+			return nil, fmt.Errorf("%s: over %d mounts: %w", path, limits.MaxEntries, ErrLimitExceeded)
+		}
+		if len(block) == cap(block) {
+			block = make([]MountInfo, 0, max(64, len(infos)))
+		}
+		block = append(block, info)
+		infos = append(infos, &block[len(block)-1])
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%s: line over %d bytes: %w", path, limits.MaxLineBytes, ErrLimitExceeded)
+		}
+		return nil, fmt.Errorf("scan mountinfo: %w", err)
+	}
+	return infos, nil
+}
+
+// parseMountinfoLine parses a line such as
+//
+//	36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+//
+// where optional fields run up to the - separator
+func parseMountinfoLine(line string) (MountInfo, bool) {
+	// ID, parent, maj:min, root, mount point, then after the separator the
+	// type and source
+	var fields [5]string
+	for i := range fields {
+		fields[i], line = nextField(line)
+	}
+	var fsType, source string
+	for {
+		var f string
+		f, line = nextField(line)
+		if f == "" {
+			return MountInfo{}, false
+		}
+		if f == "-" {
+			fsType, line = nextField(line)
+			source, _ = nextField(line)
+			break
+		}
+	}
+	if source == "" {
+		return MountInfo{}, false
+	}
+	id, err1 := strconv.Atoi(fields[0])
+	parent, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return MountInfo{}, false
+	}
+	return MountInfo{
+		ID:         id,
+		ParentID:   parent,
+		DeviceID:   fields[2],
+		Root:       filepath.Clean(unescapeOctal(fields[3])),
+		MountPoint: filepath.Clean(unescapeOctal(fields[4])),
+		FSType:     fsType,
+		Source:     unescapeOctal(source),
+	}, true
+}
+
+// nextField returns the first space separated field of s and the rest
+func nextField(s string) (field, rest string) {
+	s = strings.TrimLeft(s, " \t")
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// Backing is the filesystem a path is on, found through the bind mounts
+// leading to it
+type Backing struct {
+	// Mount is the mount the path is on
+	Mount *MountInfo
+	// SubPath is the directory of the filesystem at the path, empty for its
+	// root
+	SubPath string
+}
+
+// MountTree indexes mountinfo entries by where they are mounted, to find the
+// filesystem of many paths
+type MountTree struct {
+	Mounts []*MountInfo
+
+	roots    map[string][]*MountInfo   // by mount point, of mounts with no parent in the table
+	children map[mountKey][]*MountInfo // in mount order
+}
+
+type mountKey struct {
+	parent     int
+	mountPoint string
+}
+
+// NewMountTree indexes infos, in the order they were mounted
+func NewMountTree(infos []*MountInfo) *MountTree {
+	t := &MountTree{
+		Mounts:   infos,
+		roots:    make(map[string][]*MountInfo),
+		children: make(map[mountKey][]*MountInfo, len(infos)),
+	}
+	ids := make(map[int]bool, len(infos))
+	for _, info := range infos {
+		ids[info.ID] = true
+	}
+	for _, info := range infos {
+		if !ids[info.ParentID] || info.ParentID == info.ID {
+			// Normally the namespace's root mount
+			t.roots[info.MountPoint] = append(t.roots[info.MountPoint], info)
+			continue
+		}
+		key := mountKey{info.ParentID, info.MountPoint}
+		t.children[key] = append(t.children[key], info)
+	}
+	return t
+}
+
+// FindBacking finds the filesystem path is on by walking the mount tree from
+// the root mount down, rather than matching mount points as prefixes: of
+// mounts stacked on one mount point only the top one is visible, and a mount
+// beneath a mount point that was later mounted over is hidden. Bind mounts
+// of subdirectories keep their filesystem's device, with the directory as
+// SubPath.
+func (t *MountTree) FindBacking(path string) (*Backing, error) {
+	path = filepath.Clean(path)
+
+	// The deepest root containing path, then repeatedly the deepest child of
+	// the current mount containing it. A child at its parent's own mount
+	// point is stacked on it, and later mounts at a point shadow earlier ones.
+	var cur *MountInfo
+	forEachPrefix(path, "/", func(prefix string) bool {
+		if roots := t.roots[prefix]; len(roots) > 0 {
+			cur = roots[len(roots)-1]
+			return false
+		}
+		return true
+	})
+	if cur == nil {
+		return nil, fmt.Errorf("mountinfo: no mount containing %s: %w", path, ErrMountNotFound)
+	}
+	for {
+		var next *MountInfo
+		forEachPrefix(path, cur.MountPoint, func(prefix string) bool {
+			if children := t.children[mountKey{cur.ID, prefix}]; len(children) > 0 {
+				next = children[len(children)-1]
+				return false
+			}
+			return true
+		})
+		if next == nil {
+			break
+		}
+		cur = next
+	}
+
+	b := &Backing{Mount: cur}
+	rel := path
+	if cur.MountPoint != "/" {
+		rel = strings.TrimPrefix(path, cur.MountPoint)
+	}
+	if subPath := filepath.Join(cur.Root, rel); subPath != "/" {
+		b.SubPath = subPath
+	}
+	return b, nil
+}
+
+// forEachPrefix calls fn with path and each parent directory of it down to
+// stop, longest first, until fn returns false. path must be clean and at or
+// beneath stop.
+func forEachPrefix(path, stop string, fn func(prefix string) bool) {
+	for {
+		if !fn(path) || path == stop || path == "/" {
+			return
+		}
+		i := strings.LastIndexByte(path, '/')
+		if i <= 0 {
+			path = "/"
+		} else {
+			path = path[:i]
+		}
+		if len(path) < len(stop) {
+			return
+		}
+	}
+}
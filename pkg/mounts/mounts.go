@@ -2,10 +2,22 @@ package mounts
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/gfx-labs/volmetd/pkg/procfile"
 )
 
 // Mount represents a mounted filesystem
@@ -32,11 +44,34 @@ type MountWithCapacity struct {
 	Capacity
 }
 
+// Limits bounds what a mount table parse accepts, so a misconfigured proc
+// path can't exhaust memory. Zero fields are unlimited.
+type Limits struct {
+	MaxLineBytes int
+	MaxEntries   int
+}
+
+// DefaultLimits allow long overlay lines and far more mounts than any real
+// node has
+var DefaultLimits = Limits{MaxLineBytes: 256 << 10, MaxEntries: 200000}
+
+// ErrLimitExceeded is wrapped by parse errors for input beyond the Limits
+var ErrLimitExceeded = errors.New("mount table limit exceeded")
+
 // Parse reads /proc/mounts and returns all mounts
 func Parse(path string) ([]*Mount, error) {
+	return ParseContext(context.Background(), path, DefaultLimits)
+}
+
+// ParseContext reads a mount table (default /proc/mounts) within limits,
+// stopping early when ctx is done
+func ParseContext(ctx context.Context, path string, limits Limits) ([]*Mount, error) {
 	if path == "" {
 		path = "/proc/mounts"
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	f, err := os.Open(path)
 	if err != nil {
@@ -44,87 +79,283 @@ func Parse(path string) ([]*Mount, error) {
 	}
 	defer f.Close()
 
-	var mounts []*Mount
-	scanner := bufio.NewScanner(f)
+	return parseMounts(ctx, f, path, limits, nil)
+}
 
-	for scanner.Scan() {
-		m, err := parseLine(scanner.Text())
-		if err != nil {
+// Table reads a mount table repeatedly through a file held open between
+// reads, which on nodes with thousands of mounts saves an open and close per
+// scrape. It is safe for concurrent use.
+type Table struct {
+	limits Limits
+
+	mu     sync.Mutex
+	file   *procfile.File
+	parser parser
+}
+
+// NewTable creates a reader of the mount table at path (default
+// /proc/mounts), opened on the first Read
+func NewTable(path string, limits Limits) *Table {
+	if path == "" {
+		path = "/proc/mounts"
+	}
+	return &Table{limits: limits, file: procfile.New(path)}
+}
+
+// Read parses the current mount table, stopping early when ctx is done
+func (t *Table) Read(ctx context.Context) ([]*Mount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, err := t.file.Rewind()
+	if err != nil {
+		return nil, fmt.Errorf("open mounts: %w", err)
+	}
+	mounts, err := parseMounts(ctx, f, t.file.Path(), t.limits, &t.parser)
+	if err != nil {
+		// Reopen on the next read rather than trust the file's state
+		t.file.Close()
+	}
+	return mounts, err
+}
+
+// Close closes the held file
+func (t *Table) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.file.Close()
+}
+
+// parser holds what parseMounts reuses across reads of a Table: the line
+// buffer and the strings seen, since a node's mount table barely changes
+// between scrapes
+type parser struct {
+	buf     []byte
+	strings map[string]string
+}
+
+// intern returns b as a string, reusing the string of an earlier equal b
+func (p *parser) intern(b []byte) string {
+	if s, ok := p.strings[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	p.strings[s] = s
+	return s
+}
+
+// parseMounts parses the mount table read from r
+func parseMounts(ctx context.Context, r io.Reader, path string, limits Limits, p *parser) ([]*Mount, error) {
+	if p == nil {
+		p = &parser{}
+	}
+	if p.buf == nil {
+		p.buf = make([]byte, 0, 64<<10)
+	}
+	if p.strings == nil {
+		p.strings = make(map[string]string)
+	}
+
+	// The scanner allows tokens up to the larger of its buffer and max
+	scanner := bufio.NewScanner(r)
+	buf, maxLine := p.buf, limits.MaxLineBytes
+	if maxLine <= 0 {
+		maxLine = math.MaxInt
+	}
+	if cap(buf) > maxLine {
+		buf = buf[:0:maxLine]
+	}
+	scanner.Buffer(buf, maxLine)
+
+	// Mounts are allocated in blocks rather than one by one
+	var mounts []*Mount
+	var block []Mount
+	for n := 0; scanner.Scan(); n++ {
+		// Checked every 1024 lines; a scan of a few lines is never slow
+		if n%1024 == 1023 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		m, ok := p.parseLine(scanner.Bytes())
+		if !ok {
 			continue
 		}
-		mounts = append(mounts, m)
+		if limits.MaxEntries > 0 && len(mounts) == limits.MaxEntries {
+			return nil, fmt.Errorf("%s: over %d mounts: %w", path, limits.MaxEntries, ErrLimitExceeded)
+		}
+		if len(block) == cap(block) {
+			block = make([]Mount, 0, max(64, len(mounts)))
+		}
+		block = append(block, m)
+		mounts = append(mounts, &block[len(block)-1])
 	}
 
 	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%s: line over %d bytes: %w", path, limits.MaxLineBytes, ErrLimitExceeded)
+		}
 		return nil, fmt.Errorf("scan mounts: %w", err)
 	}
 
+	// Forget strings of mounts long gone once they outnumber current ones
+	if len(p.strings) > 8*len(mounts)+1024 {
+		p.strings = nil
+	}
 	return mounts, nil
 }
 
-func parseLine(line string) (*Mount, error) {
-	fields := strings.Fields(line)
-	if len(fields) < 4 {
-		return nil, fmt.Errorf("not enough fields")
+// parseLine parses the device, mount point, type and options of a mount
+// table line
+func (p *parser) parseLine(line []byte) (Mount, bool) {
+	var fields [4][]byte
+	n := 0
+	for n < len(fields) {
+		line = bytes.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			break
+		}
+		end := bytes.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields[n], line = line[:end], line[end:]
+		n++
+	}
+	if n < len(fields) {
+		return Mount{}, false
 	}
 
-	return &Mount{
-		Device:     fields[0],
-		MountPoint: fields[1],
-		FSType:     fields[2],
-		Options:    fields[3],
-	}, nil
+	return Mount{
+		Device:     unescapeOctal(p.intern(fields[0])),
+		MountPoint: filepath.Clean(unescapeOctal(p.intern(fields[1]))),
+		FSType:     p.intern(fields[2]),
+		Options:    p.intern(fields[3]),
+	}, true
 }
 
-// GetCapacity returns capacity information for a mount point
-func GetCapacity(mountPoint string) (*Capacity, error) {
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs(mountPoint, &stat); err != nil {
-		return nil, fmt.Errorf("statfs %s: %w", mountPoint, err)
+// unescapeOctal decodes the octal escapes the kernel uses for whitespace and
+// backslashes in mount table fields, e.g. \040 for a space
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1:i+4]) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '7' {
+			return false
+		}
 	}
+	return true
+}
 
-	blockSize := uint64(stat.Bsize)
+// ReadOnly returns true if the mount has the ro option set
+func (m *Mount) ReadOnly() bool {
+	return m.HasOption("ro")
+}
+
+// HasOption returns true if the mount has the given option set
+func (m *Mount) HasOption(option string) bool {
+	for _, opt := range strings.Split(m.Options, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
 
-	return &Capacity{
-		TotalBytes:  stat.Blocks * blockSize,
-		FreeBytes:   stat.Bfree * blockSize,
-		UsedBytes:   (stat.Blocks - stat.Bfree) * blockSize,
-		TotalInodes: stat.Files,
-		FreeInodes:  stat.Ffree,
-		UsedInodes:  stat.Files - stat.Ffree,
-	}, nil
+// GetCapacity returns capacity information for a mount point
+func GetCapacity(mountPoint string) (*Capacity, error) {
+	return statfs(mountPoint)
 }
 
 // ResolveDevice resolves a device path (following symlinks) and returns both
 // the resolved path and the device name for diskstats
 func ResolveDevice(devicePath string) (resolvedPath, deviceName string) {
 	// Try to fully resolve symlinks
-	resolved, err := evalSymlinks(devicePath)
+	resolved, err := filepath.EvalSymlinks(devicePath)
 	if err != nil {
-		resolved = devicePath
+		resolved = filepath.Clean(devicePath)
 	}
 
-	// Extract device name (basename)
-	parts := strings.Split(resolved, "/")
-	name := parts[len(parts)-1]
-
-	return resolved, name
+	return resolved, filepath.Base(resolved)
 }
 
 // GetDeviceID returns the major:minor device ID for a mount point
-// This works by stat'ing the mount point and extracting the device ID
+// This works by stat'ing the mount point and extracting the device ID,
+// falling back to the maj:min column of /proc/self/mountinfo
 func GetDeviceID(mountPoint string) (string, error) {
 	var stat syscall.Stat_t
 	if err := syscall.Stat(mountPoint, &stat); err != nil {
-		return "", fmt.Errorf("stat %s: %w", mountPoint, err)
+		if id, mErr := DeviceIDFromMountinfo("", mountPoint); mErr == nil {
+			return id, nil
+		}
+		return "", &DeviceError{Path: mountPoint, Err: err}
+	}
+
+	return formatDeviceID(uint64(stat.Dev)), nil
+}
+
+// formatDeviceID formats an encoded dev_t as major:minor
+func formatDeviceID(dev uint64) string {
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev))
+}
+
+// DeviceIDFromMountinfo returns the maj:min of the mount at mountPoint from a
+// mountinfo file (default /proc/self/mountinfo). The mount point must match
+// exactly; this is used when the mount point itself can't be stat'ed.
+func DeviceIDFromMountinfo(mountinfoPath, mountPoint string) (string, error) {
+	if mountinfoPath == "" {
+		mountinfoPath = "/proc/self/mountinfo"
+	}
+
+	f, err := os.Open(mountinfoPath)
+	if err != nil {
+		return "", fmt.Errorf("open mountinfo: %w", err)
 	}
+	defer f.Close()
 
-	// Dev contains major:minor encoded
-	// On Linux: major = (dev >> 8) & 0xfff, minor = (dev & 0xff) | ((dev >> 12) & 0xfff00)
-	major := (stat.Dev >> 8) & 0xfff
-	minor := (stat.Dev & 0xff) | ((stat.Dev >> 12) & 0xfff00)
+	mountPoint = filepath.Clean(mountPoint)
+
+	// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+	var deviceID string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		// Later entries shadow earlier ones mounted at the same point
+		if filepath.Clean(unescapeOctal(fields[4])) == mountPoint {
+			deviceID = fields[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("scan mountinfo: %w", err)
+	}
 
-	deviceID := fmt.Sprintf("%d:%d", major, minor)
+	if deviceID == "" {
+		return "", fmt.Errorf("no mountinfo entry for %s: %w", mountPoint, ErrMountNotFound)
+	}
 	return deviceID, nil
 }
 
@@ -139,7 +370,7 @@ func GetDeviceIDFromPath(devicePath, hostSysPath string) (string, error) {
 	// The last component often contains the device identifier
 	parts := strings.Split(devicePath, "/")
 	if len(parts) == 0 {
-		return "", fmt.Errorf("invalid device path: %s", devicePath)
+		return "", &DeviceError{Path: devicePath, Err: errors.New("invalid device path")}
 	}
 
 	// Try to find matching device in /sys/block by reading symlinks
@@ -190,37 +421,7 @@ func GetDeviceIDFromPath(devicePath, hostSysPath string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("no matching device found for %s", devicePath)
-}
-
-// evalSymlinks resolves all symlinks in a path
-func evalSymlinks(path string) (string, error) {
-	// Use filepath.EvalSymlinks equivalent
-	for i := 0; i < 255; i++ { // limit iterations to prevent infinite loops
-		fi, err := os.Lstat(path)
-		if err != nil {
-			return path, err
-		}
-
-		if fi.Mode()&os.ModeSymlink == 0 {
-			return path, nil
-		}
-
-		target, err := os.Readlink(path)
-		if err != nil {
-			return path, err
-		}
-
-		if !strings.HasPrefix(target, "/") {
-			// Relative symlink - resolve relative to parent dir
-			dir := path[:strings.LastIndex(path, "/")+1]
-			path = dir + target
-		} else {
-			path = target
-		}
-	}
-
-	return path, fmt.Errorf("too many symlinks")
+	return "", &DeviceError{Path: devicePath, Err: errors.New("no block device with a matching serial or wwid")}
 }
 
 // GetDeviceName extracts the base device name from a device path
@@ -230,13 +431,15 @@ func GetDeviceName(devicePath string) (string, error) {
 	return name, nil
 }
 
-// FindMountByPath finds a mount that contains the given path
+// FindMountByPath finds a mount that contains the given path. Paths are
+// normalized and matched on whole components, so /a/bc is not under /a/b.
 func FindMountByPath(mounts []*Mount, path string) *Mount {
 	var best *Mount
-	bestLen := 0
+	bestLen := -1
+	path = filepath.Clean(path)
 
 	for _, m := range mounts {
-		if strings.HasPrefix(path, m.MountPoint) {
+		if pathHasPrefix(path, m.MountPoint) {
 			if len(m.MountPoint) > bestLen {
 				best = m
 				bestLen = len(m.MountPoint)
@@ -246,3 +449,11 @@ func FindMountByPath(mounts []*Mount, path string) *Mount {
 
 	return best
 }
+
+// pathHasPrefix reports whether path is prefix or beneath it
+func pathHasPrefix(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix) && path[len(prefix)] == '/'
+}
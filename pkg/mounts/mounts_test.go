@@ -0,0 +1,159 @@
+package mounts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnescapeOctal(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`/plain`, `/plain`},
+		{`/with\040space`, `/with space`},
+		{`/with\011tab`, "/with\ttab"},
+		{`/back\134slash`, `/back\slash`},
+		{`/new\012line`, "/new\nline"},
+		{`/two\040\040spaces`, `/two  spaces`},
+		{`/end\040`, `/end `},
+		{`/short\04`, `/short\04`},   // too few digits
+		{`/digit\089`, `/digit\089`}, // not octal
+		{`/big\777`, `/big\777`},     // doesn't fit a byte
+		{`/trailing\`, `/trailing\`},
+		{`\\040`, "\\ "}, // a backslash, then an escaped space
+	}
+	for _, tt := range tests {
+		if got := unescapeOctal(tt.in); got != tt.want {
+			t.Errorf("unescapeOctal(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseEscapedMountPoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mounts")
+	table := `/dev/sdb /var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/my\040vol/mount ext4 rw,relatime 0 0` + "\n"
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].MountPoint != "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/my vol/mount" {
+		t.Fatalf("Parse = %+v", ms)
+	}
+}
+
+func TestPathHasPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		want         bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/b/c", "/a/b", true},
+		{"/a/bc", "/a/b", false},
+		{"/a/b", "/a/bc", false},
+		{"/a", "/a/b", false},
+		{"/anything", "/", true},
+		{"/", "/", true},
+	}
+	for _, tt := range tests {
+		if got := pathHasPrefix(tt.path, tt.prefix); got != tt.want {
+			t.Errorf("pathHasPrefix(%q, %q) = %v, want %v", tt.path, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestFindMountByPath(t *testing.T) {
+	table := []*Mount{
+		{Device: "overlay", MountPoint: "/"},
+		{Device: "/dev/sda1", MountPoint: "/var/lib/kubelet"},
+		{Device: "/dev/sdb", MountPoint: "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/mount"},
+		{Device: "/dev/sdc", MountPoint: "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv2/mount"},
+		{Device: "/dev/sdd", MountPoint: "/data/a/b"},
+	}
+	tests := []struct {
+		path, want string
+	}{
+		{"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/mount", "/dev/sdb"},
+		{"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/mount/dir/file", "/dev/sdb"},
+		{"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv2/mount", "/dev/sdc"},
+		{"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/../pv2/mount", "/dev/sdc"},
+		{"/var/lib/kubelet//pods/uid/volumes/kubernetes.io~csi/pv/mount/", "/dev/sdb"},
+		{"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/mount/..", "/dev/sda1"},
+		{"/data/a/bc", "overlay"},
+		{"/data/a/b/c", "/dev/sdd"},
+		{"/data/a/./b", "/dev/sdd"},
+		{"/etc", "overlay"},
+	}
+	for _, tt := range tests {
+		m := FindMountByPath(table, tt.path)
+		if m == nil || m.Device != tt.want {
+			t.Errorf("FindMountByPath(%q) = %+v, want %s", tt.path, m, tt.want)
+		}
+	}
+	if m := FindMountByPath(table[1:], "/etc"); m != nil {
+		t.Errorf("FindMountByPath outside every mount = %+v, want nil", m)
+	}
+}
+
+// evalSymlinksMapped reads links beneath a root standing in for the host's
+// /, as through /proc/1/root
+func TestEvalSymlinksMapped(t *testing.T) {
+	root := t.TempDir()
+	local := func(path string) string { return filepath.Join(root, path) }
+	for _, dir := range []string{"/dev/disk/by-id", "/dev/mapper", "/real/dir"} {
+		if err := os.MkdirAll(local(dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"/dev/sdb", "/dev/dm-0", "/real/sdc"} {
+		if err := os.WriteFile(local(f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"/dev/disk/by-id/scsi-0": "../../sdb",
+		"/dev/mapper/crypt":      "/dev/dm-0", // absolute, in the host's namespace
+		"/dev/mapper/chain":      "crypt",
+		"/dev/disk/by-dir":       "/real/dir", // a directory link ".." must leave through its target
+		"/dev/loop-a":            "loop-b",
+		"/dev/loop-b":            "loop-a",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, local(link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path, want string
+		err        string
+	}{
+		{path: "/dev/sdb", want: "/dev/sdb"},
+		{path: "/dev/disk/by-id/scsi-0", want: "/dev/sdb"},
+		{path: "/dev/mapper/crypt", want: "/dev/dm-0"},
+		{path: "/dev/mapper/chain", want: "/dev/dm-0"},
+		{path: "/dev//disk/./by-id/scsi-0", want: "/dev/sdb"},
+		{path: "/dev/disk/by-dir/../sdc", want: "/real/sdc"},
+		{path: "/../../dev/sdb", want: "/dev/sdb"},
+		{path: "/dev/missing", err: "no such file"},
+		{path: "/dev/loop-a", err: "too many symlinks"},
+		{path: "dev/sdb", err: "not an absolute path"},
+	}
+	for _, tt := range tests {
+		got, err := evalSymlinksMapped(tt.path, local)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("evalSymlinksMapped(%q) error %v, want %q", tt.path, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("evalSymlinksMapped(%q): %v", tt.path, err)
+		case got != tt.want:
+			t.Errorf("evalSymlinksMapped(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package mounts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ProbeFile prefixes the files written by ProbeFsync in the root of a volume
+const ProbeFile = ".volmetd-probe"
+
+// ProbeRead checks that a mount still answers read-only requests: statfs,
+// then open and read the root directory. A wedged mount (stale NFS handle,
+// dead iSCSI session) typically blocks here, so callers should bound it with
+// a timeout.
+func ProbeRead(path string) error {
+	if _, err := statfs(path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		// Raw block volumes: opening the device node is as far as we go
+		return nil
+	}
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	return nil
+}
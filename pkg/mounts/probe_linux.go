@@ -0,0 +1,45 @@
+package mounts
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProbeFsync writes a small file in dir, fsyncs it and removes it, returning
+// how long the write and fsync took. Volumes belong to tenants, so neither dir
+// nor the probe file is followed if it is a symlink, and the probe file gets a
+// name of its own, created exclusively: an existing file is never truncated or
+// removed.
+func ProbeFsync(dir string) (time.Duration, error) {
+	dirFd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	defer unix.Close(dirFd)
+
+	now := time.Now().UnixNano()
+	name := ProbeFile + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(now, 36)
+	path := dir + "/" + name
+	payload := []byte(strconv.FormatInt(now, 10) + "\n")
+
+	start := time.Now()
+	fd, err := unix.Openat(dirFd, name, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return 0, &os.PathError{Op: "create", Path: path, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), path)
+	defer unix.Unlinkat(dirFd, name, 0)
+	defer f.Close()
+
+	if _, err := f.Write(payload); err != nil {
+		return 0, fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("fsync %s: %w", path, err)
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,68 @@
+package mounts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// A tenant owns the volume: ProbeFsync must not write through a planted
+// symlink, nor truncate or remove a file of theirs
+func TestProbeFsyncTenantFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	target := filepath.Join(other, "data")
+	if err := os.WriteFile(target, []byte("other pod's data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vol := filepath.Join(dir, "vol")
+	if err := os.Mkdir(vol, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(vol, ProbeFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProbeFsync(vol); err != nil {
+		t.Fatalf("ProbeFsync with a symlink planted: %v", err)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "other pod's data" {
+		t.Errorf("symlink target = %q, %v, want it untouched", data, err)
+	}
+
+	if err := os.Remove(filepath.Join(vol, ProbeFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vol, ProbeFile), []byte("user data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProbeFsync(vol); err != nil {
+		t.Fatalf("ProbeFsync with an existing file: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(vol, ProbeFile)); err != nil || string(data) != "user data" {
+		t.Errorf("existing %s = %q, %v, want it untouched", ProbeFile, data, err)
+	}
+
+	entries, err := os.ReadDir(vol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("volume holds %d entries after probing, want only the user's file", len(entries))
+	}
+
+	// The volume root itself replaced by a symlink: O_DIRECTORY|O_NOFOLLOW
+	// fails with ENOTDIR
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(other, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProbeFsync(link); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("ProbeFsync(symlink) error %v, want ENOTDIR", err)
+	}
+	if entries, _ := os.ReadDir(other); len(entries) != 1 {
+		t.Errorf("symlinked directory holds %d entries, want 1", len(entries))
+	}
+}
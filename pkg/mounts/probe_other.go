@@ -0,0 +1,13 @@
+//go:build !linux
+
+package mounts
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProbeFsync is only supported on Linux
+func ProbeFsync(dir string) (time.Duration, error) {
+	return 0, fmt.Errorf("fsync probe %s: openat not supported on this platform", dir)
+}
@@ -0,0 +1,6 @@
+//go:build linux && !ppc64 && !ppc64le && !mips && !mipsle && !mips64 && !mips64le
+
+package mounts
+
+// _IOR('X', 31, struct fsxattr) with the asm-generic ioctl encoding
+const fsIocFsgetxattr = 0x801c581f
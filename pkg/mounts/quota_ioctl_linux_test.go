@@ -0,0 +1,32 @@
+package mounts
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+// ioctlRead encodes _IOR(typ, nr, size) as this GOARCH's kernel does
+func ioctlRead(typ, nr, size uintptr) uintptr {
+	// powerpc and mips have a 13-bit size field and the read bit one below
+	// asm-generic's
+	readShift := 30
+	switch runtime.GOARCH {
+	case "ppc64", "ppc64le", "mips", "mipsle", "mips64", "mips64le":
+		readShift = 29
+	}
+	return 2<<readShift | size<<16 | typ<<8 | nr
+}
+
+func TestFsIocFsgetxattr(t *testing.T) {
+	if size := unsafe.Sizeof(fsxattr{}); size != 28 {
+		t.Fatalf("fsxattr is %d bytes, want 28 as struct fsxattr", size)
+	}
+	if size := unsafe.Sizeof(ifDqblk{}); size != 72 {
+		t.Fatalf("ifDqblk is %d bytes, want 72 as struct if_dqblk", size)
+	}
+	want := ioctlRead('X', 31, unsafe.Sizeof(fsxattr{}))
+	if got := uintptr(fsIocFsgetxattr); got != want {
+		t.Errorf("FS_IOC_FSGETXATTR on %s = %#x, want %#x", runtime.GOARCH, got, want)
+	}
+}
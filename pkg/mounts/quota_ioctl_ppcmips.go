@@ -0,0 +1,7 @@
+//go:build linux && (ppc64 || ppc64le || mips || mipsle || mips64 || mips64le)
+
+package mounts
+
+// _IOR('X', 31, struct fsxattr) with the powerpc/mips ioctl encoding, where
+// the read direction bit sits one position lower than asm-generic
+const fsIocFsgetxattr = 0x401c581f
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package mounts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoProjectQuota is returned when a directory has no project ID or its
+// filesystem has no project quota enforcement
+var ErrNoProjectQuota = errors.New("no project quota")
+
+// GetProjectQuota is only supported on Linux
+func GetProjectQuota(path string) (*Capacity, error) {
+	return nil, fmt.Errorf("%s: %w", path, ErrNoProjectQuota)
+}
@@ -0,0 +1,487 @@
+package mounts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// capSysPtrace is required to read another process's root and mount table
+const capSysPtrace = 19
+
+// hasCapability is HasCapability, replaced in tests to run without privileges
+var hasCapability = HasCapability
+
+// Resolver parses a mount table and resolves device paths, either from
+// volmetd's own mount namespace or from the host's.
+type Resolver struct {
+	// ns is the mount namespace read, replaced when the process it is read
+	// through exits (see NewProcessResolver)
+	ns atomic.Pointer[mountNS]
+
+	// procPath and comm find that process again, empty unless it is
+	// looked up by name
+	procPath, comm string
+
+	// localPrefix is rewritten to hostPrefix before looking up a path in a
+	// host mount table, e.g. /host/var/lib/kubelet -> /var/lib/kubelet
+	localPrefix string
+	hostPrefix  string
+
+	// devPath is where the host's /dev is mounted, e.g. /host/dev
+	devPath string
+
+	// udev maps /dev symlinks to kernel names when symlinks can't be resolved
+	udev *udevIndex
+
+	// sysPath, when set, names devices by their major:minor in
+	// <sysPath>/dev/block rather than by following /dev symlinks, which
+	// needs the host's /dev
+	sysPath string
+
+	// fixture resolves device IDs from mountinfo only, since the directories
+	// of a captured tree aren't the real mounts
+	fixture bool
+
+	// cache holds resolutions until Mounts sees the mount table change
+	cache resolveCache
+}
+
+// mountNS is a mount namespace as a resolver reads it
+type mountNS struct {
+	mountsPath string
+	table      *Table
+
+	// root is prepended when resolving device symlinks, e.g. /host/proc/1/root.
+	// Empty means the local root.
+	root string
+}
+
+func newMountNS(mountsPath, root string) *mountNS {
+	return &mountNS{mountsPath: mountsPath, table: NewTable(mountsPath, DefaultLimits), root: root}
+}
+
+// NewResolver creates a resolver for the local mount namespace
+func NewResolver(mountsPath string) *Resolver {
+	if mountsPath == "" {
+		mountsPath = "/proc/mounts"
+	}
+	r := &Resolver{}
+	r.ns.Store(newMountNS(mountsPath, ""))
+	return r
+}
+
+// NewHostResolver creates a resolver that reads the host mount namespace
+// through PID 1 in hostProcPath: mounts from <proc>/1/mounts and device
+// symlinks beneath <proc>/1/root. This is the equivalent of entering
+// /proc/1/ns/mnt, which a multithreaded Go process cannot do with setns.
+// Paths under localKubelet are rewritten to hostKubelet for mount lookups.
+// An error is returned if the process lacks access, so callers can fall back.
+func NewHostResolver(hostProcPath, localKubelet, hostKubelet string) (*Resolver, error) {
+	if hostProcPath == "" {
+		hostProcPath = "/proc"
+	}
+	ns, err := processMountNS(hostProcPath, "1")
+	if err != nil {
+		return nil, fmt.Errorf("host mount namespace: %w", err)
+	}
+	r := &Resolver{localPrefix: localKubelet, hostPrefix: hostKubelet}
+	r.ns.Store(ns)
+	return r, nil
+}
+
+// NewProcessResolver creates a resolver like NewHostResolver reading the
+// mount namespace of the first process in hostProcPath named comm rather
+// than PID 1's, e.g. the kubelet where it runs in a mount namespace of its
+// own and pod volumes aren't mounted in the host's (OpenShift's kubens).
+// When the process exits, e.g. the kubelet restarts, its successor is found
+// on the next read.
+func NewProcessResolver(hostProcPath, comm, localKubelet, hostKubelet string) (*Resolver, error) {
+	if hostProcPath == "" {
+		hostProcPath = "/proc"
+	}
+	r := &Resolver{procPath: hostProcPath, comm: comm, localPrefix: localKubelet, hostPrefix: hostKubelet}
+	ns, err := r.findProcess()
+	if err != nil {
+		return nil, fmt.Errorf("%s mount namespace: %w", comm, err)
+	}
+	r.ns.Store(ns)
+	return r, nil
+}
+
+// findProcess reads the mount namespace of the first process named r.comm
+func (r *Resolver) findProcess() (*mountNS, error) {
+	pid, err := FindProcess(r.procPath, r.comm)
+	if err != nil {
+		return nil, err
+	}
+	return processMountNS(r.procPath, pid)
+}
+
+// processMountNS reads the mount namespace of process pid, checking it has
+// access to its root and mount table
+func processMountNS(procPath, pid string) (*mountNS, error) {
+	if ok, err := hasCapability(capSysPtrace); err == nil && !ok {
+		return nil, fmt.Errorf("missing CAP_SYS_PTRACE")
+	}
+
+	root := filepath.Join(procPath, pid, "root")
+	if _, err := os.ReadDir(filepath.Join(root, "dev")); err != nil {
+		return nil, err
+	}
+	mountsPath := filepath.Join(procPath, pid, "mounts")
+	if _, err := Parse(mountsPath); err != nil {
+		return nil, err
+	}
+	return newMountNS(mountsPath, root), nil
+}
+
+// FindProcess returns the PID of the first process in procPath whose command
+// name (comm) is comm
+func FindProcess(procPath, comm string) (string, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procPath, e.Name(), "comm"))
+		if err == nil && strings.TrimSpace(string(data)) == comm {
+			return e.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no %s process in %s", comm, procPath)
+}
+
+// NewFixtureResolver creates a resolver for a host tree captured beneath
+// root: the mount table at <root>/proc/mounts, device symlinks beneath
+// <root>/dev and device IDs from <root>/proc/self/mountinfo. Paths under
+// <root><hostKubelet> are rewritten to hostKubelet for mount lookups.
+func NewFixtureResolver(root, hostKubelet string) *Resolver {
+	r := &Resolver{
+		localPrefix: filepath.Join(root, hostKubelet),
+		hostPrefix:  hostKubelet,
+		devPath:     filepath.Join(root, "dev"),
+		fixture:     true,
+	}
+	r.ns.Store(newMountNS(filepath.Join(root, "proc", "mounts"), root))
+	return r
+}
+
+// NewFixtureProcessResolver creates a resolver like NewFixtureResolver
+// reading the mount namespace of the first process in <root>/proc named comm,
+// as NewProcessResolver does on a live node: the mount table at
+// <root>/proc/<pid>/mounts with its mountinfo alongside.
+func NewFixtureProcessResolver(root, comm, hostKubelet string) (*Resolver, error) {
+	procPath := filepath.Join(root, "proc")
+	pid, err := FindProcess(procPath, comm)
+	if err != nil {
+		return nil, fmt.Errorf("%s mount namespace: %w", comm, err)
+	}
+	r := NewFixtureResolver(root, hostKubelet)
+	r.ns.Store(newMountNS(filepath.Join(procPath, pid, "mounts"), root))
+	return r, nil
+}
+
+// SetDevPath resolves /dev paths beneath devPath (e.g. /host/dev) instead of /dev
+func (r *Resolver) SetDevPath(devPath string) {
+	r.devPath = strings.TrimSuffix(devPath, "/")
+}
+
+// SetUdevDataPath enables falling back to the udev database (e.g.
+// /run/udev/data) for device paths whose symlinks can't be resolved.
+// sysPath is used to map major:minor to kernel device names.
+func (r *Resolver) SetUdevDataPath(udevDataPath, sysPath string) {
+	if udevDataPath == "" {
+		r.udev = nil
+		return
+	}
+	r.udev = newUdevIndex(udevDataPath, sysPath)
+}
+
+// SetSysDeviceNames names devices by major:minor in <sysPath>/dev/block, see
+// DeviceName. Unlike /dev, sysfs is readable without privileges.
+func (r *Resolver) SetSysDeviceNames(sysPath string) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	r.sysPath = sysPath
+}
+
+// MountsPath returns the mount table this resolver parses
+func (r *Resolver) MountsPath() string {
+	return r.ns.Load().mountsPath
+}
+
+// Mounts parses the resolver's mount table
+func (r *Resolver) Mounts() ([]*Mount, error) {
+	return r.MountsContext(context.Background())
+}
+
+// MountsContext parses the resolver's mount table, stopping early when ctx is
+// done. Cached device resolutions and device IDs are dropped when it changed
+// since the previous call.
+func (r *Resolver) MountsContext(ctx context.Context) ([]*Mount, error) {
+	ns := r.ns.Load()
+	mounts, err := ns.table.Read(ctx)
+	if err != nil && r.comm != "" && ctx.Err() == nil {
+		// The process exited: read through its successor
+		if next, ferr := r.findProcess(); ferr == nil && next.mountsPath != ns.mountsPath {
+			if r.ns.CompareAndSwap(ns, next) {
+				ns.table.Close()
+			} else {
+				next.table.Close()
+			}
+			mounts, err = r.ns.Load().table.Read(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.cache.update(mounts)
+	return mounts, nil
+}
+
+// FindMount finds the mount containing a local path
+func (r *Resolver) FindMount(mounts []*Mount, path string) *Mount {
+	return FindMountByPath(mounts, r.HostPath(path))
+}
+
+// MountTree parses the mountinfo alongside the resolver's mount table (see
+// MountinfoPath), stopping early when ctx is done
+func (r *Resolver) MountTree(ctx context.Context) (*MountTree, error) {
+	infos, err := ParseMountinfo(ctx, r.MountinfoPath(), DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	return NewMountTree(infos), nil
+}
+
+// FindBacking finds the filesystem a local path is on in tree, following
+// bind mounts (see MountTree.FindBacking)
+func (r *Resolver) FindBacking(tree *MountTree, path string) (*Backing, error) {
+	return tree.FindBacking(r.HostPath(path))
+}
+
+// HostPath rewrites a local path to the path seen in the resolver's mount namespace
+func (r *Resolver) HostPath(path string) string {
+	return RebasePath(path, r.localPrefix, r.hostPrefix)
+}
+
+// RebasePath rewrites path from beneath the directory from to beneath to,
+// e.g. the kubelet directory as volmetd sees it to the host's. Paths not at
+// or beneath from, including siblings sharing its prefix such as
+// /var/lib/kubelet-x, are returned unchanged, as are all paths when either
+// directory is unset.
+func RebasePath(path, from, to string) string {
+	if from == "" || to == "" || from == to {
+		return path
+	}
+	from = strings.TrimSuffix(from, "/")
+	if path == from {
+		return to
+	}
+	if rest, ok := strings.CutPrefix(path, from+"/"); ok {
+		return strings.TrimSuffix(to, "/") + "/" + rest
+	}
+	return path
+}
+
+// ResolveDevice resolves a device path (following symlinks beneath the
+// resolver's root or dev path) and returns the resolved path and the device
+// name. The udev database is consulted if symlinks can't be followed.
+func (r *Resolver) ResolveDevice(devicePath string) (resolvedPath, deviceName string) {
+	if d, ok := r.cache.device(devicePath); ok {
+		return d.path, d.name
+	}
+	resolvedPath, deviceName = r.resolveDevice(devicePath)
+	r.cache.setDevice(devicePath, resolvedDevice{path: resolvedPath, name: deviceName})
+	return resolvedPath, deviceName
+}
+
+func (r *Resolver) resolveDevice(devicePath string) (resolvedPath, deviceName string) {
+	if r.ns.Load().root == "" && r.devPath == "" && r.udev == nil {
+		return ResolveDevice(devicePath)
+	}
+
+	resolved, err := evalSymlinksMapped(devicePath, r.LocalPath)
+	if err != nil && r.udev != nil {
+		if name, _, ok := r.udev.lookup(devicePath); ok {
+			return "/dev/" + name, name
+		}
+	}
+	if err != nil {
+		resolved = devicePath
+	}
+	return resolved, filepath.Base(resolved)
+}
+
+// DeviceName returns the device backing a mount like ResolveDevice, or with
+// SetSysDeviceNames, the kernel name of its device ID (see DeviceID). Devices
+// missing from sysfs, e.g. of network filesystems, keep their mount table name.
+func (r *Resolver) DeviceName(devicePath, deviceID string) (resolvedPath, deviceName string) {
+	if r.sysPath == "" || deviceID == "" {
+		return r.ResolveDevice(devicePath)
+	}
+	target, err := os.Readlink(filepath.Join(r.sysPath, "dev", "block", deviceID))
+	if err != nil {
+		return devicePath, filepath.Base(devicePath)
+	}
+	name := filepath.Base(target)
+	return "/dev/" + name, name
+}
+
+// LocalPath maps a path in the resolver's namespace to where volmetd can read it
+func (r *Resolver) LocalPath(path string) string {
+	if r.devPath != "" && (path == "/dev" || strings.HasPrefix(path, "/dev/")) {
+		return r.devPath + strings.TrimPrefix(path, "/dev")
+	}
+	if root := r.ns.Load().root; root != "" {
+		return filepath.Join(root, path)
+	}
+	return path
+}
+
+// DeviceID returns the major:minor device ID for a local mount point, stat'ing
+// it through the resolver's root when set. If stat fails, the maj:min column
+// of the mountinfo alongside the resolver's mount table is used. Errors match
+// ErrDeviceUnresolvable.
+func (r *Resolver) DeviceID(mountPoint string) (string, error) {
+	if id, ok := r.cache.id(mountPoint); ok {
+		return id, nil
+	}
+	id, err := r.deviceID(mountPoint)
+	if err == nil {
+		r.cache.setID(mountPoint, id)
+	}
+	return id, err
+}
+
+func (r *Resolver) deviceID(mountPoint string) (string, error) {
+	if r.fixture {
+		id, err := DeviceIDFromMountinfo(r.MountinfoPath(), r.HostPath(mountPoint))
+		if err != nil {
+			return "", &DeviceError{Path: mountPoint, Err: err}
+		}
+		return id, nil
+	}
+	root := r.ns.Load().root
+	if root == "" {
+		id, err := GetDeviceID(mountPoint)
+		if err != nil {
+			if mid, mErr := DeviceIDFromMountinfo(r.MountinfoPath(), mountPoint); mErr == nil {
+				return mid, nil
+			}
+		}
+		return id, err
+	}
+
+	hostPath := r.HostPath(mountPoint)
+	id, err := GetDeviceID(filepath.Join(root, hostPath))
+	if err != nil {
+		if mid, mErr := DeviceIDFromMountinfo(r.MountinfoPath(), hostPath); mErr == nil {
+			return mid, nil
+		}
+	}
+	return id, err
+}
+
+// MountinfoPath returns the mountinfo file next to the resolver's mount table,
+// e.g. /host/proc/1/mountinfo for /host/proc/1/mounts. /proc/mounts has no
+// sibling mountinfo, so <proc>/self/mountinfo is used for it.
+func (r *Resolver) MountinfoPath() string {
+	if base, ok := strings.CutSuffix(r.MountsPath(), "mounts"); ok {
+		for _, p := range []string{base + "mountinfo", base + "self/mountinfo"} {
+			if _, err := os.Stat(p); err == nil {
+				return p
+			}
+		}
+	}
+	return "/proc/self/mountinfo"
+}
+
+// maxSymlinks bounds the links evalSymlinksMapped follows, as the kernel's
+// ELOOP limit does
+const maxSymlinks = 255
+
+// evalSymlinksMapped resolves symlinks in every component of path like
+// filepath.EvalSymlinks, reading each link at local(path). The returned path
+// is in the unmapped namespace. filepath.EvalSymlinks can't be used, as
+// links must be read through local and absolute targets are in the
+// unmapped namespace. ".." is applied to the resolved path, so it leaves
+// the directory a link points to rather than the link's.
+func evalSymlinksMapped(path string, local func(string) string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return path, fmt.Errorf("%s: not an absolute path", path)
+	}
+	resolved, rest := "/", path
+	links := 0
+	for rest != "" {
+		var name string
+		name, rest, _ = strings.Cut(rest, "/")
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		fi, err := os.Lstat(local(next))
+		if err != nil {
+			return filepath.Join(next, rest), err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return path, fmt.Errorf("%s: too many symlinks", path)
+		}
+		target, err := os.Readlink(local(next))
+		if err != nil {
+			return filepath.Join(next, rest), err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = target + "/" + rest
+	}
+	return resolved, nil
+}
+
+// HasCapability reports whether the current process has the given capability
+// in its effective set, read from /proc/self/status
+func HasCapability(capability uint) (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return false, err
+		}
+		return caps&(1<<capability) != 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("CapEff not found")
+}
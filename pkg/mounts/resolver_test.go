@@ -0,0 +1,120 @@
+package mounts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProcess adds process pid named comm to the proc tree at procPath,
+// with the mount table mounts and a root holding the host's /dev
+func writeProcess(t *testing.T, procPath, pid, comm, mounts string) {
+	t.Helper()
+	dir := filepath.Join(procPath, pid)
+	if err := os.MkdirAll(filepath.Join(dir, "root", "dev", "disk", "by-id"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"comm":         comm + "\n",
+		"mounts":       mounts,
+		"root/dev/sdb": "",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../../sdb", filepath.Join(dir, "root", "dev", "disk", "by-id", "scsi-0")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// On OpenShift, CRI-O and the kubelet run in a mount namespace of their own
+// (kubens): pod volumes are mounted there, not in PID 1's
+func TestProcessResolverOpenShift(t *testing.T) {
+	saved := hasCapability
+	hasCapability = func(uint) (bool, error) { return true, nil }
+	t.Cleanup(func() { hasCapability = saved })
+
+	const volume = "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv/mount"
+	host := "/dev/sda4 / xfs rw,relatime 0 0\n" +
+		"/dev/sda4 /var/lib/kubelet xfs rw,relatime 0 0\n"
+	kubens := host +
+		"tmpfs /var/lib/kubelet/pods/uid/volumes/kubernetes.io~projected/token tmpfs rw 0 0\n" +
+		"/dev/disk/by-id/scsi-0 " + volume + " ext4 rw,relatime 0 0\n"
+
+	procPath := filepath.Join(t.TempDir(), "proc")
+	writeProcess(t, procPath, "1", "systemd", host)
+	writeProcess(t, procPath, "2310", "crio", host)
+	writeProcess(t, procPath, "2417", "kubelet", kubens)
+	if err := os.MkdirAll(filepath.Join(procPath, "sys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	const localKubelet = "/host/var/lib/kubelet"
+	local := localKubelet + strings.TrimPrefix(volume, "/var/lib/kubelet")
+
+	// PID 1 sees only the kubelet directory's filesystem
+	hr, err := NewHostResolver(procPath, localKubelet, "/var/lib/kubelet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts, err := hr.Mounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := hr.FindMount(mounts, local); m == nil || m.MountPoint != "/var/lib/kubelet" {
+		t.Errorf("host FindMount(%q) = %+v, want the kubelet directory", local, m)
+	}
+
+	r, err := NewProcessResolver(procPath, "kubelet", localKubelet, "/var/lib/kubelet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(procPath, "2417", "mounts"); r.MountsPath() != want {
+		t.Errorf("MountsPath() = %q, want %q", r.MountsPath(), want)
+	}
+	mounts, err = r.Mounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := r.FindMount(mounts, local+"/data")
+	if m == nil || m.MountPoint != volume {
+		t.Fatalf("FindMount(%q) = %+v, want %s", local, m, volume)
+	}
+	// Device symlinks are followed beneath the kubelet's root
+	if path, name := r.ResolveDevice(m.Device); path != "/dev/sdb" || name != "sdb" {
+		t.Errorf("ResolveDevice(%q) = %q, %q, want /dev/sdb, sdb", m.Device, path, name)
+	}
+
+	if _, err := NewProcessResolver(procPath, "hyperkube", localKubelet, "/var/lib/kubelet"); err == nil {
+		t.Error("NewProcessResolver without a matching process succeeded")
+	}
+
+	hasCapability = func(uint) (bool, error) { return false, nil }
+	if _, err := NewProcessResolver(procPath, "kubelet", localKubelet, "/var/lib/kubelet"); err == nil || !strings.Contains(err.Error(), "CAP_SYS_PTRACE") {
+		t.Errorf("NewProcessResolver without CAP_SYS_PTRACE error %v", err)
+	}
+}
+
+func TestRebasePath(t *testing.T) {
+	tests := []struct {
+		path, from, to string
+		want           string
+	}{
+		{"/host/var/lib/kubelet/pods/u/volumes/x", "/host/var/lib/kubelet", "/var/lib/kubelet", "/var/lib/kubelet/pods/u/volumes/x"},
+		{"/var/lib/kubelet/pods/u", "/var/lib/kubelet/", "/var/data/kubelet", "/var/data/kubelet/pods/u"},
+		{"/var/lib/kubelet", "/var/lib/kubelet", "/var/data/kubelet", "/var/data/kubelet"},
+		{"/var/lib/kubelet-x/pods/u", "/var/lib/kubelet", "/var/data/kubelet", "/var/lib/kubelet-x/pods/u"},
+		{"/var/lib/kubeletpods", "/var/lib/kubelet", "/var/data/kubelet", "/var/lib/kubeletpods"},
+		{"/mnt/data", "/var/lib/kubelet", "/var/data/kubelet", "/mnt/data"},
+		{"/var/lib/kubelet/pods/u", "/var/lib/kubelet", "", "/var/lib/kubelet/pods/u"},
+		{"/var/lib/kubelet/pods/u", "", "/var/data/kubelet", "/var/lib/kubelet/pods/u"},
+	}
+	for _, tt := range tests {
+		if got := RebasePath(tt.path, tt.from, tt.to); got != tt.want {
+			t.Errorf("RebasePath(%q, %q, %q) = %q, want %q", tt.path, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package mounts
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	"golang.org/x/sys/unix"
+)
+
+// Statfs_t field types differ by GOOS and GOARCH (e.g. Bsize is int64 on
+// amd64, int32 on 386/arm/mips and uint32 on s390x, and some counts are
+// signed on BSDs), so every field is converted explicitly here and nowhere
+// else.
+
+// statfs returns the capacity of the filesystem containing path
+func statfs(path string) (*Capacity, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, fmt.Errorf("statfs %s: %w", path, err)
+	}
+	return capacityFromStatfs(&st), nil
+}
+
+// fstatfs returns the capacity of the filesystem containing fd
+func fstatfs(fd int, path string) (*Capacity, error) {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(fd, &st); err != nil {
+		return nil, fmt.Errorf("fstatfs %s: %w", path, err)
+	}
+	return capacityFromStatfs(&st), nil
+}
+
+func capacityFromStatfs(st *unix.Statfs_t) *Capacity {
+	blockSize := fragmentSize(st)
+	blocks := statfsCount(st.Blocks)
+	bfree := statfsCount(st.Bfree)
+	files := statfsCount(st.Files)
+	ffree := statfsCount(st.Ffree)
+
+	return &Capacity{
+		TotalBytes:  saturatingMul(blocks, blockSize),
+		FreeBytes:   saturatingMul(bfree, blockSize),
+		UsedBytes:   saturatingMul(saturatingSub(blocks, bfree), blockSize),
+		TotalInodes: files,
+		FreeInodes:  ffree,
+		UsedInodes:  saturatingSub(files, ffree),
+	}
+}
+
+// statfsCount converts a Statfs_t field of whatever integer type it has on
+// this platform. Signed counts, such as f_bavail and f_ffree on FreeBSD, go
+// negative when root's reserve is overdrawn; they count as 0.
+func statfsCount[T int32 | int64 | uint32 | uint64](v T) uint64 {
+	if v < 0 {
+		return 0
+	}
+	return uint64(v)
+}
+
+// saturatingSub returns a-b, or 0 if b > a. Some filesystems (FUSE, btrfs)
+// report free counts that don't fit within the totals.
+func saturatingSub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// saturatingMul returns a*b, or the largest uint64 if it overflows, as for
+// bogus block counts from FUSE filesystems
+func saturatingMul(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
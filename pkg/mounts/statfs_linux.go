@@ -0,0 +1,13 @@
+package mounts
+
+import "golang.org/x/sys/unix"
+
+// fragmentSize returns the unit f_blocks and f_bfree are counted in. That's
+// f_frsize on Linux; f_bsize is only the preferred I/O size, and differs
+// from it on some filesystems.
+func fragmentSize(st *unix.Statfs_t) uint64 {
+	if st.Frsize > 0 {
+		return statfsCount(st.Frsize)
+	}
+	return statfsCount(st.Bsize)
+}
@@ -0,0 +1,49 @@
+package mounts
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCapacityFromStatfs(t *testing.T) {
+	tests := []struct {
+		name string
+		st   unix.Statfs_t
+		want Capacity
+	}{{
+		name: "ext4",
+		st:   unix.Statfs_t{Bsize: 4096, Frsize: 4096, Blocks: 1000, Bfree: 400, Bavail: 350, Files: 100, Ffree: 60},
+		want: Capacity{TotalBytes: 4096000, FreeBytes: 1638400, UsedBytes: 2457600, TotalInodes: 100, FreeInodes: 60, UsedInodes: 40},
+	}, {
+		// f_blocks counts f_frsize units; f_bsize is only the I/O size
+		name: "frsize differs from bsize",
+		st:   unix.Statfs_t{Bsize: 131072, Frsize: 4096, Blocks: 1000, Bfree: 1000},
+		want: Capacity{TotalBytes: 4096000, FreeBytes: 4096000},
+	}, {
+		name: "no frsize",
+		st:   unix.Statfs_t{Bsize: 512, Blocks: 8, Bfree: 2},
+		want: Capacity{TotalBytes: 4096, FreeBytes: 1024, UsedBytes: 3072},
+	}, {
+		// FUSE and btrfs may report more free than total
+		name: "free exceeds total",
+		st:   unix.Statfs_t{Frsize: 4096, Blocks: 10, Bfree: 20, Files: 5, Ffree: 9},
+		want: Capacity{TotalBytes: 40960, FreeBytes: 81920, TotalInodes: 5, FreeInodes: 9},
+	}, {
+		name: "bytes overflow",
+		st:   unix.Statfs_t{Frsize: 4096, Blocks: math.MaxUint64, Bfree: 1},
+		want: Capacity{TotalBytes: math.MaxUint64, FreeBytes: 4096, UsedBytes: math.MaxUint64},
+	}, {
+		// Bavail beyond Bfree, as a negative count reads unsigned, doesn't
+		// leak into the capacity
+		name: "bavail wrapped",
+		st:   unix.Statfs_t{Frsize: 4096, Blocks: 10, Bfree: 4, Bavail: math.MaxUint64},
+		want: Capacity{TotalBytes: 40960, FreeBytes: 16384, UsedBytes: 24576},
+	}}
+	for _, tt := range tests {
+		if got := capacityFromStatfs(&tt.st); *got != tt.want {
+			t.Errorf("%s: capacityFromStatfs = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package mounts
+
+import "golang.org/x/sys/unix"
+
+// fragmentSize returns the unit block counts are in, f_bsize outside Linux
+func fragmentSize(st *unix.Statfs_t) uint64 {
+	return statfsCount(st.Bsize)
+}
@@ -0,0 +1,61 @@
+package mounts
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStatfsCount(t *testing.T) {
+	// f_bavail and f_ffree are int64 on FreeBSD, and negative when root's
+	// reserve is overdrawn
+	if got := statfsCount(int64(-42)); got != 0 {
+		t.Errorf("statfsCount(int64(-42)) = %d, want 0", got)
+	}
+	if got := statfsCount(int32(-1)); got != 0 {
+		t.Errorf("statfsCount(int32(-1)) = %d, want 0", got)
+	}
+	if got := statfsCount(int32(4096)); got != 4096 {
+		t.Errorf("statfsCount(int32(4096)) = %d, want 4096", got)
+	}
+	// Bsize is uint32 on s390x; its top bit must not read as a sign
+	if got := statfsCount(uint32(math.MaxUint32)); got != math.MaxUint32 {
+		t.Errorf("statfsCount(uint32 max) = %d, want %d", got, uint64(math.MaxUint32))
+	}
+	if got := statfsCount(uint64(math.MaxUint64)); got != math.MaxUint64 {
+		t.Errorf("statfsCount(uint64 max) = %d, want %d", got, uint64(math.MaxUint64))
+	}
+}
+
+func TestSaturatingSub(t *testing.T) {
+	tests := []struct {
+		a, b, want uint64
+	}{
+		{10, 4, 6},
+		{4, 4, 0},
+		{4, 10, 0},
+		{0, math.MaxUint64, 0},
+		{math.MaxUint64, 0, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		if got := saturatingSub(tt.a, tt.b); got != tt.want {
+			t.Errorf("saturatingSub(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSaturatingMul(t *testing.T) {
+	tests := []struct {
+		a, b, want uint64
+	}{
+		{1000, 4096, 4096000},
+		{0, math.MaxUint64, 0},
+		{1 << 52, 4096, math.MaxUint64},
+		{math.MaxUint64, 2, math.MaxUint64},
+		{1 << 51, 4096, 1 << 63},
+	}
+	for _, tt := range tests {
+		if got := saturatingMul(tt.a, tt.b); got != tt.want {
+			t.Errorf("saturatingMul(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
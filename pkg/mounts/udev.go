@@ -0,0 +1,93 @@
+package mounts
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// udevIndex maps /dev symlinks to kernel device names using the udev
+// database, so by-id/by-uuid paths resolve even when the symlinks themselves
+// aren't visible to volmetd
+type udevIndex struct {
+	dataPath string // e.g. /run/udev/data
+	sysPath  string // e.g. /sys, for major:minor -> kernel name
+
+	mu      sync.Mutex
+	modTime time.Time
+	links   map[string]string // /dev/disk/by-id/... -> major:minor
+}
+
+func newUdevIndex(dataPath, sysPath string) *udevIndex {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	return &udevIndex{dataPath: dataPath, sysPath: sysPath}
+}
+
+// lookup returns the kernel device name and major:minor for a /dev symlink
+func (u *udevIndex) lookup(devicePath string) (name, deviceID string, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err := u.refresh(); err != nil {
+		return "", "", false
+	}
+
+	deviceID, ok = u.links[filepath.Clean(devicePath)]
+	if !ok {
+		return "", "", false
+	}
+
+	target, err := os.Readlink(filepath.Join(u.sysPath, "dev", "block", deviceID))
+	if err != nil {
+		return "", deviceID, false
+	}
+	return filepath.Base(target), deviceID, true
+}
+
+// refresh rebuilds the index when the udev data directory changes
+func (u *udevIndex) refresh() error {
+	fi, err := os.Stat(u.dataPath)
+	if err != nil {
+		return err
+	}
+	if u.links != nil && fi.ModTime().Equal(u.modTime) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(u.dataPath)
+	if err != nil {
+		return err
+	}
+
+	links := make(map[string]string)
+	for _, e := range entries {
+		// Block devices are named b<major>:<minor>
+		name := e.Name()
+		if !strings.HasPrefix(name, "b") || !strings.Contains(name, ":") {
+			continue
+		}
+		deviceID := name[1:]
+
+		f, err := os.Open(filepath.Join(u.dataPath, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// S: lines are symlinks relative to /dev
+			if link, ok := strings.CutPrefix(scanner.Text(), "S:"); ok {
+				links["/dev/"+link] = deviceID
+			}
+		}
+		f.Close()
+	}
+
+	u.links = links
+	u.modTime = fi.ModTime()
+	return nil
+}
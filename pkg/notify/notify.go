@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Condition names
+const (
+	ConditionVolumeFull        = "volume_full"
+	ConditionReadOnly          = "read_only"
+	ConditionDeviceDisappeared = "device_disappeared"
+	ConditionNoVolumes         = "no_volumes"
+)
+
+// Event describes a volume condition that started or cleared
+type Event struct {
+	Condition string    `json:"condition"`
+	Resolved  bool      `json:"resolved"`
+	Node      string    `json:"node,omitempty"`
+	PVC       string    `json:"pvc,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	PV        string    `json:"pv,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	Value     float64   `json:"value,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers events to an external system
+type Notifier interface {
+	// Name returns the notifier name for logging
+	Name() string
+	// Notify sends a single event
+	Notify(ctx context.Context, e *Event) error
+}
+
+// WebhookNotifier POSTs events as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a new webhook notifier
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook post: unexpected status %s", resp.Status)
+	}
+	return nil
+}
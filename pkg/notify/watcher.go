@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+	"github.com/gfx-labs/volmetd/pkg/policy"
+)
+
+// Source is what conditions are evaluated from: the exporter's scrapes, so
+// notifications neither discover volumes nor statfs them a second time
+type Source interface {
+	// LastScrape returns the most recent scrape, nil before the first
+	LastScrape() *collector.LastScrape
+	// LastCapacity returns the capacity the last scrape found for the volume
+	// mounted at mountPath, or nil if it had none
+	LastCapacity(mountPath string) *mounts.Capacity
+	// Resolver returns the resolver volumes' mounts are looked up with
+	Resolver() *mounts.Resolver
+}
+
+// Watcher periodically evaluates volume conditions and notifies on changes.
+// Events are edge triggered: one when a condition starts and one when it clears.
+type Watcher struct {
+	source        Source
+	notifier      Notifier
+	procPath      string
+	nodeName      string
+	interval      time.Duration
+	fillThreshold float64 // percent used
+	policy        policy.Source
+
+	active    map[string]*Event // conditions notified of, keyed by condition + volume
+	evaluated map[string]*Event // conditions the last evaluated scrape found
+	lastCount int
+	scraped   time.Time // of the scrape last evaluated
+}
+
+// NewWatcher creates a new condition watcher evaluating source's scrapes
+func NewWatcher(source Source, notifier Notifier, procPath string, interval time.Duration, fillThreshold float64) *Watcher {
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Watcher{
+		source:        source,
+		notifier:      notifier,
+		procPath:      procPath,
+		nodeName:      discovery.DetectNodeName(),
+		interval:      interval,
+		fillThreshold: fillThreshold,
+		active:        make(map[string]*Event),
+	}
+}
+
+// SetPolicy uses the fill threshold of each volume's storage class policy
+// from src where it sets one, ahead of the watcher's own
+func (w *Watcher) SetPolicy(src policy.Source) {
+	w.policy = src
+}
+
+// Run evaluates conditions every interval until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates the latest scrape, unless it was already evaluated or
+// served stale volumes because discovery failed. Unsent events are retried
+// on the next check regardless.
+func (w *Watcher) check(ctx context.Context) {
+	if last := w.source.LastScrape(); last != nil && last.Time.After(w.scraped) && last.Stale == 0 {
+		w.scraped = last.Time
+		w.evaluated = w.evaluate(last.Volumes)
+	}
+	if w.evaluated == nil {
+		return
+	}
+	current := maps.Clone(w.evaluated)
+
+	// Fire newly started conditions, retrying next pass on failure
+	for k, e := range current {
+		if prev, ok := w.active[k]; ok {
+			current[k] = prev
+			continue
+		}
+		if !w.send(ctx, e) {
+			delete(current, k)
+		}
+	}
+
+	// Fire resolved conditions, keeping them active on failure
+	for k, e := range w.active {
+		if _, ok := current[k]; ok {
+			continue
+		}
+		resolved := *e
+		resolved.Resolved = true
+		resolved.Timestamp = time.Now()
+		if !w.send(ctx, &resolved) {
+			current[k] = e
+		}
+	}
+
+	w.active = current
+}
+
+// evaluate returns the conditions active on volumes
+func (w *Watcher) evaluate(volumes []*discovery.VolumeInfo) map[string]*Event {
+	resolver := w.source.Resolver()
+	allMounts, err := resolver.Mounts()
+	if err != nil {
+		slog.Warn("notify: failed to parse mounts", "error", err)
+	}
+	stats, err := diskstats.Parse(w.procPath + "/diskstats")
+	if err != nil {
+		slog.Warn("notify: failed to parse diskstats", "error", err)
+	}
+
+	current := make(map[string]*Event)
+	p := policy.Get(w.policy)
+
+	if len(volumes) == 0 && w.lastCount > 0 {
+		current[ConditionNoVolumes] = w.event(ConditionNoVolumes, nil, 0,
+			fmt.Sprintf("discovery dropped from %d volumes to zero", w.lastCount))
+	} else if prev, ok := w.active[ConditionNoVolumes]; ok && len(volumes) == 0 {
+		current[ConditionNoVolumes] = prev
+	}
+	w.lastCount = len(volumes)
+
+	for _, vol := range volumes {
+		if vol.MountPath == "" {
+			continue
+		}
+		key := volumeKey(vol)
+
+		threshold := w.fillThreshold
+		if class, ok := p.Class(vol.StorageClass); ok && class.FillThreshold > 0 {
+			threshold = class.FillThreshold
+		}
+		if threshold > 0 {
+			full := ConditionVolumeFull + "/" + key
+			if c := w.source.LastCapacity(vol.MountPath); c != nil && c.TotalBytes > 0 {
+				used := float64(c.UsedBytes) / float64(c.TotalBytes) * 100
+				if used >= threshold {
+					current[full] = w.event(ConditionVolumeFull, vol, used,
+						fmt.Sprintf("volume is %.1f%% full (threshold %.1f%%)", used, threshold))
+				}
+			} else if prev, ok := w.active[full]; ok {
+				// Capacity unknown this scrape: don't take it as resolved
+				current[full] = prev
+			}
+		}
+
+		if allMounts != nil {
+			if m := resolver.FindMount(allMounts, vol.MountPath); m != nil && m.ReadOnly() {
+				current[ConditionReadOnly+"/"+key] = w.event(ConditionReadOnly, vol, 0, "volume is mounted read-only")
+			}
+		}
+
+		if stats != nil && (vol.DeviceID != "" || vol.DeviceName != "") {
+			_, byID := stats.ByDeviceID[vol.DeviceID]
+			_, byName := stats.ByName[vol.DeviceName]
+			if !byID && !byName {
+				current[ConditionDeviceDisappeared+"/"+key] = w.event(ConditionDeviceDisappeared, vol, 0,
+					"backing device is missing from diskstats")
+			}
+		}
+	}
+	return current
+}
+
+func (w *Watcher) event(condition string, vol *discovery.VolumeInfo, value float64, message string) *Event {
+	e := &Event{
+		Condition: condition,
+		Node:      w.nodeName,
+		Value:     value,
+		Message:   message,
+		Timestamp: time.Now(),
+	}
+	if vol != nil {
+		e.PVC = vol.PVCName
+		e.Namespace = vol.PVCNamespace
+		e.PV = vol.PVName
+		e.Pod = vol.PodName
+		e.Device = vol.DeviceName
+	}
+	return e
+}
+
+func (w *Watcher) send(ctx context.Context, e *Event) bool {
+	if err := w.notifier.Notify(ctx, e); err != nil {
+		slog.Error("notify: failed to send event", "notifier", w.notifier.Name(), "condition", e.Condition, "pvc", e.PVC, "error", err)
+		return false
+	}
+	slog.Info("notify: sent event", "notifier", w.notifier.Name(), "condition", e.Condition, "pvc", e.PVC, "resolved", e.Resolved)
+	return true
+}
+
+func volumeKey(vol *discovery.VolumeInfo) string {
+	if vol.PVCName != "" {
+		return vol.PVCNamespace + "/" + vol.PVCName
+	}
+	return vol.PVName
+}
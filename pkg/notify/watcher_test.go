@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/discovery/discoverytest"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// fakeSource serves a scrape of volumes with the given used percentages
+type fakeSource struct {
+	last     *collector.LastScrape
+	capacity map[string]*mounts.Capacity
+	resolver *mounts.Resolver
+}
+
+func (s *fakeSource) LastScrape() *collector.LastScrape         { return s.last }
+func (s *fakeSource) LastCapacity(path string) *mounts.Capacity { return s.capacity[path] }
+func (s *fakeSource) Resolver() *mounts.Resolver                { return s.resolver }
+
+// fakeNotifier records events as "condition pvc" or "condition pvc
+// resolved", failing while fail is set
+type fakeNotifier struct {
+	events []string
+	fail   bool
+}
+
+func (n *fakeNotifier) Name() string { return "fake" }
+
+func (n *fakeNotifier) Notify(ctx context.Context, e *Event) error {
+	if n.fail {
+		return errors.New("webhook down")
+	}
+	s := e.Condition + " " + e.PVC
+	if e.Resolved {
+		s += " resolved"
+	}
+	n.events = append(n.events, s)
+	return nil
+}
+
+// scrape is one check of the watcher: a new scrape of volumes, unless
+// volumes is nil, and the events it sends
+type scrape struct {
+	volumes map[string]int // used percent by PVC, -1 = capacity unknown
+	stale   bool           // discovery failed, the volumes are served stale
+	fail    bool           // the notifier fails
+	want    []string
+}
+
+func TestWatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		scrapes []scrape
+	}{
+		{
+			name: "full then resolved",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 50}},
+				{volumes: map[string]int{"data": 95}, want: []string{"volume_full data"}},
+				{volumes: map[string]int{"data": 96}},
+				{volumes: map[string]int{"data": 40}, want: []string{"volume_full data resolved"}},
+			},
+		},
+		{
+			name: "no new scrape",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 95}, want: []string{"volume_full data"}},
+				{},
+				{},
+			},
+		},
+		{
+			name: "start retried",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 95}, fail: true},
+				{want: []string{"volume_full data"}},
+				{volumes: map[string]int{"data": 95}},
+			},
+		},
+		{
+			name: "resolve retried",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 95}, want: []string{"volume_full data"}},
+				{volumes: map[string]int{"data": 10}, fail: true},
+				{want: []string{"volume_full data resolved"}},
+				{volumes: map[string]int{"data": 10}},
+			},
+		},
+		{
+			name: "capacity unknown keeps the condition",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 95}, want: []string{"volume_full data"}},
+				{volumes: map[string]int{"data": -1}},
+				{volumes: map[string]int{"data": 10}, want: []string{"volume_full data resolved"}},
+			},
+		},
+		{
+			name: "stale volumes are not evaluated",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 95}, want: []string{"volume_full data"}},
+				{volumes: map[string]int{"data": 10}, stale: true},
+			},
+		},
+		{
+			name: "no volumes",
+			scrapes: []scrape{
+				{volumes: map[string]int{"data": 10}},
+				{volumes: map[string]int{}, want: []string{"no_volumes "}},
+				{volumes: map[string]int{}},
+				{volumes: map[string]int{"data": 10}, want: []string{"no_volumes  resolved"}},
+			},
+		},
+		{
+			name: "read-only mount",
+			scrapes: []scrape{
+				{volumes: map[string]int{"archive": 10}, want: []string{"read_only archive"}},
+				{volumes: map[string]int{"archive": 10}},
+			},
+		},
+		{
+			name: "device disappeared",
+			scrapes: []scrape{
+				{volumes: map[string]int{"gone": 10}, want: []string{"device_disappeared gone"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			for name, data := range map[string]string{
+				"mounts": "/dev/sdb /var/lib/kubelet/pods/data ext4 rw 0 0\n" +
+					"/dev/sdb /var/lib/kubelet/pods/archive ext4 ro 0 0\n",
+				"diskstats": "   8      16 sdb 1 0 8 1 2 0 16 2 0 3 3 0 0 0 0 0 0\n",
+			} {
+				if err := os.WriteFile(filepath.Join(proc, name), []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			source := &fakeSource{resolver: mounts.NewResolver(filepath.Join(proc, "mounts"))}
+			notifier := &fakeNotifier{}
+			w := NewWatcher(source, notifier, proc, time.Minute, 90)
+
+			scraped := time.Now()
+			for i, s := range tt.scrapes {
+				if s.volumes != nil {
+					scraped = scraped.Add(time.Minute)
+					source.last, source.capacity = newScrape(scraped, s.volumes)
+					if s.stale {
+						source.last.Stale = time.Minute
+					}
+				}
+				notifier.fail, notifier.events = s.fail, nil
+				w.check(context.Background())
+				if !slices.Equal(notifier.events, s.want) {
+					t.Errorf("check %d sent %q, want %q", i, notifier.events, s.want)
+				}
+			}
+		})
+	}
+}
+
+// newScrape returns a scrape of a volume for each PVC in used, on device
+// sdb but for "gone", and their capacity
+func newScrape(t time.Time, used map[string]int) (*collector.LastScrape, map[string]*mounts.Capacity) {
+	last := &collector.LastScrape{Time: t}
+	capacity := make(map[string]*mounts.Capacity)
+	for pvc, pct := range used {
+		vol := discoverytest.Volume(pvc, "db")
+		if pvc == "gone" {
+			vol.DeviceName, vol.DeviceID = "sdz", "65:144"
+		}
+		vol.MountPath = "/var/lib/kubelet/pods/" + pvc
+		last.Volumes = append(last.Volumes, vol)
+		if pct >= 0 {
+			capacity[vol.MountPath] = &mounts.Capacity{TotalBytes: 100, UsedBytes: uint64(pct)}
+		}
+	}
+	return last, capacity
+}
@@ -0,0 +1,11 @@
+// Package nvme reads the SMART / health information log of NVMe devices via
+// the admin command passthrough ioctl, for the error counters sysfs lacks
+package nvme
+
+// SMARTLog is the part of the SMART / health information log page volmetd
+// exports
+type SMARTLog struct {
+	CriticalWarning uint8
+	MediaErrors     uint64 // unrecovered data integrity errors
+	ErrorLogEntries uint64 // error information log entries over the controller's life
+}
@@ -0,0 +1,75 @@
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeIoctlAdminCmd = 0xc0484e41
+
+	opGetLogPage   = 0x02
+	logSMART       = 0x02
+	smartLogSize   = 512
+	nsidController = 0xffffffff
+)
+
+// passthruCmd mirrors struct nvme_passthru_cmd from linux/nvme_ioctl.h
+type passthruCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// ReadSMARTLog reads the controller-wide SMART / health log of the NVMe
+// device at devPath, e.g. /dev/nvme0n1. It needs CAP_SYS_ADMIN.
+func ReadSMARTLog(devPath string) (*SMARTLog, error) {
+	f, err := os.Open(devPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, smartLogSize)
+	cmd := passthruCmd{
+		opcode:  opGetLogPage,
+		nsid:    nsidController,
+		addr:    uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		dataLen: smartLogSize,
+		// Log page ID, and the number of dwords to read minus one
+		cdw10: logSMART | (smartLogSize/4-1)<<16,
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd))); errno != 0 {
+		return nil, fmt.Errorf("nvme smart log %s: %w", devPath, errno)
+	}
+	// buf is only referenced through cmd.addr during the ioctl
+	runtime.KeepAlive(buf)
+
+	// The counters are 128-bit little endian; the high halves never matter
+	return &SMARTLog{
+		CriticalWarning: buf[0],
+		MediaErrors:     binary.LittleEndian.Uint64(buf[160:]),
+		ErrorLogEntries: binary.LittleEndian.Uint64(buf[176:]),
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nvme
+
+import "errors"
+
+// ReadSMARTLog is only supported on Linux
+func ReadSMARTLog(devPath string) (*SMARTLog, error) {
+	return nil, errors.New("nvme smart log is only supported on linux")
+}
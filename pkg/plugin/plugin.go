@@ -0,0 +1,179 @@
+// Package plugin runs external collectors, so storage vendors can ship
+// driver-specific metrics without forking volmetd.
+//
+// A plugin is any executable. On every scrape volmetd runs it with a JSON
+// Request on stdin describing the node's volumes, and reads metrics in the
+// Prometheus text exposition format from stdout:
+//
+//	$ echo '{"node":"node-1","volumes":[{"pvc":"data","namespace":"db",...}]}' | ./plugin
+//	# TYPE netapp_volume_dedupe_ratio gauge
+//	netapp_volume_dedupe_ratio{pvc="data",namespace="db"} 1.7
+//
+// Metric names get volmetd's metric prefix, so plugins print them without
+// it. A plugin that exits non-zero, prints malformed output or outlives its
+// timeout fails that scrape (scrape_success{collector="plugin:<name>"} 0)
+// and its stderr is logged.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// MaxOutput bounds how much of a plugin's stdout is read
+const MaxOutput = 4 << 20
+
+// maxStderr bounds how much of a plugin's stderr is kept for logging
+const maxStderr = 4 << 10
+
+// waitDelay bounds the wait for a plugin's output after it is killed
+const waitDelay = time.Second
+
+// Request is written to a plugin's stdin
+type Request struct {
+	Node    string   `json:"node"`
+	Volumes []Volume `json:"volumes"`
+}
+
+// Volume describes one volume to plugins. Field names are part of the
+// plugin protocol and don't change with VolumeInfo.
+type Volume struct {
+	PVC                string `json:"pvc,omitempty"`
+	Namespace          string `json:"namespace,omitempty"`
+	PV                 string `json:"pv,omitempty"`
+	Pod                string `json:"pod,omitempty"`
+	PodNamespace       string `json:"pod_namespace,omitempty"`
+	PodUID             string `json:"pod_uid,omitempty"`
+	StorageClass       string `json:"storage_class,omitempty"`
+	CSIDriver          string `json:"csi_driver,omitempty"`
+	VolumeHandle       string `json:"volume_handle,omitempty"`
+	Device             string `json:"device,omitempty"`
+	DeviceID           string `json:"device_id,omitempty"`
+	DevicePath         string `json:"device_path,omitempty"`
+	MountPath          string `json:"mount_path,omitempty"`
+	ContainerMountPath string `json:"container_mount_path,omitempty"`
+	BackendType        string `json:"backend_type,omitempty"`
+}
+
+// NewVolume converts a discovered volume to its plugin form
+func NewVolume(v *discovery.VolumeInfo) Volume {
+	return Volume{
+		PVC:                v.PVCName,
+		Namespace:          v.PVCNamespace,
+		PV:                 v.PVName,
+		Pod:                v.PodName,
+		PodNamespace:       v.PodNamespace,
+		PodUID:             v.PodUID,
+		StorageClass:       v.StorageClass,
+		CSIDriver:          v.CSIDriver,
+		VolumeHandle:       v.VolumeHandle,
+		Device:             v.DeviceName,
+		DeviceID:           v.DeviceID,
+		DevicePath:         v.DevicePath,
+		MountPath:          v.MountPath,
+		ContainerMountPath: v.ContainerMountPath,
+		BackendType:        v.Backend(),
+	}
+}
+
+// Find returns the executables in dir by name, which is the file name
+func Find(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	plugins := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		// Follow symlinks, e.g. ConfigMap volume entries
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() || fi.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		plugins[e.Name()] = path
+	}
+	return plugins, nil
+}
+
+// Run executes the command with stdin, extra environment variables and the
+// context's deadline, and parses its output as Prometheus text format.
+// Families come back sorted by name.
+func Run(ctx context.Context, path string, args []string, env []string, stdin []byte) ([]*dto.MetricFamily, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{max: MaxOutput}
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Don't wait on children that outlive a killed plugin holding its pipes
+	cmd.WaitDelay = waitDelay
+
+	err := cmd.Run()
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("%s: %w", path, ctx.Err())
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	case stdout.truncated:
+		return nil, fmt.Errorf("%s: output exceeds %d bytes", path, MaxOutput)
+	}
+	return Parse(stdout.Bytes())
+}
+
+// Parse parses Prometheus text format, returning the families sorted by name
+func Parse(data []byte) ([]*dto.MetricFamily, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	byName, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		families = append(families, mf)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
+
+// MarshalRequest encodes the request for a plugin's stdin
+func MarshalRequest(node string, volumes []*discovery.VolumeInfo) ([]byte, error) {
+	req := Request{Node: node, Volumes: make([]Volume, 0, len(volumes))}
+	for _, v := range volumes {
+		req.Volumes = append(req.Volumes, NewVolume(v))
+	}
+	return json.Marshal(req)
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.Len(); n > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.Buffer.Write(p)
+	return n, nil
+}
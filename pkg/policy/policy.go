@@ -0,0 +1,124 @@
+// Package policy loads exporter policy (per-storage-class thresholds and
+// labels, and collector toggles) from a ConfigMap watched at runtime, so
+// platform teams can manage it declaratively rather than through env vars on
+// every DaemonSet.
+//
+// The ConfigMap holds the policy as YAML or JSON under DataKey:
+//
+//	storageClasses:
+//	  gp3:
+//	    readLatency: 10ms      # latency SLO targets
+//	    writeLatency: 20ms
+//	    fillThreshold: 85      # percent used
+//	    labels:                # exported as storage_class_label
+//	      tier: standard
+//	  "*":                     # every other storage class
+//	    fillThreshold: 90
+//	disabledCollectors: [topproc, hba]
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DataKey is the ConfigMap data key holding the policy
+const DataKey = "policy.yaml"
+
+// DefaultClass names the policy for storage classes without their own
+const DefaultClass = "*"
+
+// Policy is the exporter policy
+type Policy struct {
+	StorageClasses map[string]StorageClass `json:"storageClasses"`
+
+	// Collectors, by name, that are skipped. Collectors not enabled by the
+	// exporter's configuration can't be turned on here.
+	DisabledCollectors []string `json:"disabledCollectors"`
+
+	disabled map[string]bool
+}
+
+// StorageClass is the policy for volumes of one storage class
+type StorageClass struct {
+	ReadLatency   Duration          `json:"readLatency"`
+	WriteLatency  Duration          `json:"writeLatency"`
+	FillThreshold float64           `json:"fillThreshold"` // percent used, 0 = none
+	Labels        map[string]string `json:"labels"`
+}
+
+// Duration is a time.Duration written as a string such as "10ms"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Parse parses a YAML or JSON policy. Unknown fields are rejected, so typos
+// don't silently leave a setting at its default.
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	for name, c := range p.StorageClasses {
+		if c.FillThreshold < 0 || c.FillThreshold > 100 {
+			return nil, fmt.Errorf("storage class %s: fillThreshold %v is not a percentage", name, c.FillThreshold)
+		}
+		if c.ReadLatency < 0 || c.WriteLatency < 0 {
+			return nil, fmt.Errorf("storage class %s: negative latency target", name)
+		}
+	}
+	p.disabled = make(map[string]bool, len(p.DisabledCollectors))
+	for _, name := range p.DisabledCollectors {
+		p.disabled[name] = true
+	}
+	return p, nil
+}
+
+// Class returns the policy for a storage class, falling back to DefaultClass.
+// A nil *Policy has none.
+func (p *Policy) Class(storageClass string) (StorageClass, bool) {
+	if p == nil {
+		return StorageClass{}, false
+	}
+	if c, ok := p.StorageClasses[storageClass]; ok {
+		return c, true
+	}
+	c, ok := p.StorageClasses[DefaultClass]
+	return c, ok
+}
+
+// CollectorEnabled reports whether the policy leaves a collector running
+func (p *Policy) CollectorEnabled(name string) bool {
+	return p == nil || !p.disabled[name]
+}
+
+// Source provides the current policy, nil when there is none
+type Source interface {
+	Policy() *Policy
+}
+
+// Get returns src's current policy, or nil for a nil src
+func Get(src Source) *Policy {
+	if src == nil {
+		return nil
+	}
+	return src.Policy()
+}
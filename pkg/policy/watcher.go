@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+)
+
+// Watcher keeps the policy from a ConfigMap up to date. A policy that fails
+// to parse is logged and the previous one kept; deleting the ConfigMap
+// removes the policy.
+type Watcher struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+
+	mu     sync.Mutex
+	policy *Policy
+	status Status
+}
+
+// Status is the outcome of the most recent policy load
+type Status struct {
+	Loaded bool // a ConfigMap has been seen
+	Error  string
+	Time   time.Time
+}
+
+// NewWatcher creates a watcher for the ConfigMap ref, "namespace/name" or a
+// name in volmetd's own namespace, using in-cluster credentials
+func NewWatcher(ref string) (*Watcher, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("k8s config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewWatcherForClient(client, ref)
+}
+
+// NewWatcherForClient creates a watcher for the ConfigMap ref using client
+func NewWatcherForClient(client kubernetes.Interface, ref string) (*Watcher, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = discovery.DetectNamespace(), ref
+	}
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("policy configmap %q: want namespace/name", ref)
+	}
+	return &Watcher{client: client, namespace: namespace, name: name}, nil
+}
+
+// Policy returns the current policy, nil if there is none. Methods on a nil
+// *Watcher return nil.
+func (w *Watcher) Policy() *Policy {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.policy
+}
+
+// Status returns the outcome of the most recent policy load
+func (w *Watcher) Status() Status {
+	if w == nil {
+		return Status{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run watches the ConfigMap until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(w.client, 0,
+		informers.WithNamespace(w.namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = "metadata.name=" + w.name
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { w.load(obj) },
+		UpdateFunc: func(_, obj any) { w.load(obj) },
+		DeleteFunc: func(any) {
+			slog.Info("policy: configmap deleted, policy removed", "configmap", w.namespace+"/"+w.name)
+			w.set(nil, Status{Time: time.Now()})
+		},
+	})
+
+	slog.Info("policy: watching configmap", "configmap", w.namespace+"/"+w.name)
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+func (w *Watcher) load(obj any) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	status := Status{Loaded: true, Time: time.Now()}
+
+	data, ok := cm.Data[DataKey]
+	if !ok {
+		status.Error = fmt.Sprintf("configmap has no %s key", DataKey)
+		slog.Error("policy: not loaded", "configmap", w.namespace+"/"+w.name, "error", status.Error)
+		w.setStatus(status)
+		return
+	}
+	p, err := Parse([]byte(data))
+	if err != nil {
+		status.Error = err.Error()
+		slog.Error("policy: not loaded, keeping previous policy", "configmap", w.namespace+"/"+w.name, "error", err)
+		w.setStatus(status)
+		return
+	}
+
+	slog.Info("policy: loaded", "configmap", w.namespace+"/"+w.name, "storageClasses", len(p.StorageClasses), "disabledCollectors", p.DisabledCollectors)
+	w.set(p, status)
+}
+
+func (w *Watcher) set(p *Policy, status Status) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.policy = p
+	w.status = status
+}
+
+func (w *Watcher) setStatus(status Status) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+}
@@ -0,0 +1,66 @@
+// Package procfile keeps a procfs table such as /proc/diskstats or
+// /proc/1/mounts open across reads, seeking back to the start for each one
+// instead of reopening it every scrape. procfs regenerates the contents on a
+// read from offset 0, so every read sees the current table.
+package procfile
+
+import (
+	"io"
+	"os"
+)
+
+// File is a file held open for repeated reads. It is not safe for
+// concurrent use.
+type File struct {
+	path string
+	f    *os.File
+	fi   os.FileInfo
+}
+
+// New returns a File for path, opened on first use
+func New(path string) *File {
+	return &File{path: path}
+}
+
+// Path returns the path the file is opened from
+func (p *File) Path() string {
+	return p.path
+}
+
+// Rewind returns the file positioned at its start, opening it on first use.
+// It is reopened if path now names a different file, e.g. a fixture file
+// replaced by a rename, or if seeking fails.
+func (p *File) Rewind() (*os.File, error) {
+	if p.f != nil {
+		fi, err := os.Stat(p.path)
+		if err == nil && os.SameFile(fi, p.fi) {
+			if _, err := p.f.Seek(0, io.SeekStart); err == nil {
+				return p.f, nil
+			}
+		}
+		p.Close()
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.f, p.fi = f, fi
+	return f, nil
+}
+
+// Close closes the file if open. A later Rewind reopens it, so Close also
+// serves to drop the file after a read error.
+func (p *File) Close() error {
+	if p == nil || p.f == nil {
+		return nil
+	}
+	err := p.f.Close()
+	p.f, p.fi = nil, nil
+	return err
+}
@@ -0,0 +1,83 @@
+// Package procio reads per-process storage I/O counters from /proc/<pid>/io
+package procio
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gfx-labs/volmetd/pkg/cgroup"
+)
+
+// Process is a pod process and its cumulative storage I/O
+type Process struct {
+	PID        int
+	Comm       string
+	PodUID     string
+	ReadBytes  uint64 // bytes fetched from storage (read_bytes)
+	WriteBytes uint64 // bytes sent to storage, counted when pages are dirtied (write_bytes)
+}
+
+// ScanPods returns the processes in <procPath> running in the given pods.
+// Reading another process's io file needs CAP_SYS_PTRACE; processes that
+// can't be read, or exit mid-scan, are skipped.
+func ScanPods(procPath string, podUIDs map[string]bool) ([]*Process, error) {
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []*Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		uid, ok, err := cgroup.ProcessPodUID(procPath, pid)
+		if err != nil || !ok || !podUIDs[uid] {
+			continue
+		}
+		p := &Process{PID: pid, PodUID: uid}
+		if err := readIO(filepath.Join(procPath, e.Name(), "io"), p); err != nil {
+			continue
+		}
+		if comm, err := os.ReadFile(filepath.Join(procPath, e.Name(), "comm")); err == nil {
+			p.Comm = strings.TrimSpace(string(comm))
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// readIO parses read_bytes and write_bytes from a /proc/<pid>/io file
+func readIO(path string, p *Process) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "read_bytes":
+			p.ReadBytes = n
+		case "write_bytes":
+			p.WriteBytes = n
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,88 @@
+package selfcheck
+
+import (
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// capSysPtrace is required to read other processes' I/O and mount tables
+const capSysPtrace = 19
+
+// FallbackNone is the fallback of a capability whose data is simply missing
+const FallbackNone = "none"
+
+// Capability is a privileged way volmetd gathers data, and what it falls
+// back to when the access is missing, e.g. when running rootless
+type Capability struct {
+	Name      string // e.g. "device_symlinks"
+	Available bool
+	Fallback  string // used when unavailable, FallbackNone if nothing is
+}
+
+// Capabilities derives from cfg and the results of Run which privileged
+// access volmetd has and which fallbacks are in use
+func Capabilities(cfg *config.Config, results []Result) []Capability {
+	ptrace, _ := mounts.HasCapability(capSysPtrace)
+	kubeletDir := passed(results, "kubelet_pods") && !cfg.Rootless
+
+	devFallback := "mount_device"
+	switch {
+	case cfg.Rootless:
+		devFallback = "sysfs_device_id"
+	case cfg.UdevDataPath != "":
+		devFallback = "udev"
+	}
+	statfsFallback := FallbackNone
+	if cfg.KubeletURL != "" {
+		statfsFallback = "kubelet_stats_api"
+	}
+
+	return []Capability{
+		{
+			Name:      "host_mount_namespace",
+			Available: cfg.HostMountNamespace && ptrace && passed(results, "proc_host_mountinfo"),
+			Fallback:  "local_mount_namespace",
+		},
+		{
+			Name:      "device_symlinks",
+			Available: !cfg.Rootless && passed(results, "dev"),
+			Fallback:  devFallback,
+		},
+		{
+			Name:      "kubelet_directory",
+			Available: kubeletDir,
+			Fallback:  "mount_table",
+		},
+		{
+			Name:      "statfs",
+			Available: kubeletDir,
+			Fallback:  statfsFallback,
+		},
+		{
+			Name:      "process_io",
+			Available: !cfg.Rootless && ptrace,
+			Fallback:  FallbackNone,
+		},
+	}
+}
+
+// Degraded returns the capabilities that aren't available
+func Degraded(capabilities []Capability) []Capability {
+	var degraded []Capability
+	for _, c := range capabilities {
+		if !c.Available {
+			degraded = append(degraded, c)
+		}
+	}
+	return degraded
+}
+
+// passed reports whether the check ran and passed
+func passed(results []Result, check string) bool {
+	for _, r := range results {
+		if r.Check == check {
+			return r.OK
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+// Package selfcheck verifies on startup that volmetd can actually read the
+// host paths and Kubernetes resources its configuration needs. A DaemonSet
+// missing a host mount or an RBAC rule otherwise only shows up as silently
+// empty metrics.
+package selfcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	authv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	Check  string // e.g. "proc_diskstats" or "rbac_list_pods"
+	OK     bool
+	Target string // path or resource checked
+	Error  string // why the check failed
+	Hint   string // likely fix when the check failed
+}
+
+// Run performs every check relevant to cfg. RBAC checks are skipped outside
+// a cluster.
+func Run(ctx context.Context, cfg *config.Config) []Result {
+	results := []Result{
+		checkRead("proc_diskstats", cfg.DiskstatsPath(),
+			"mount the host /proc and set VOLMETD_HOST_PROC_PATH"),
+		checkRead("proc_mounts", cfg.MountsPath(),
+			"mount the host /proc and set VOLMETD_HOST_PROC_PATH"),
+		checkDir("sys_block", filepath.Join(cfg.HostSysPath, "block"),
+			"mount the host /sys and set VOLMETD_HOST_SYS_PATH"),
+		checkDir("kubelet_pods", cfg.Layout().PodsDir(cfg.KubeletPath),
+			"mount the host kubelet directory (usually /var/lib/kubelet) and set VOLMETD_KUBELET_PATH or VOLMETD_KUBELET_LAYOUT"),
+		checkDev(cfg),
+	}
+	if cfg.HostMountNamespace {
+		results = append(results, checkHostMountinfo(cfg))
+	}
+	if cfg.OpenShift {
+		results = append(results, checkSELinux(cfg))
+	}
+	if cfg.CapacityHostNamespace {
+		results = append(results, checkDir("proc_host_root", cfg.HostRootPath(),
+			"run with hostPID: true and CAP_SYS_PTRACE, or disable VOLMETD_CAPACITY_HOST_NAMESPACE"))
+	}
+	return append(results, checkRBAC(ctx, cfg)...)
+}
+
+// Failed returns the results that didn't pass
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if !r.OK {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+func result(check, target string, err error, hint string) Result {
+	r := Result{Check: check, OK: err == nil, Target: target}
+	if err != nil {
+		r.Error = err.Error()
+		r.Hint = hint
+		if errors.Is(err, fs.ErrPermission) {
+			// Running as root, this is usually mandatory access control rather than file modes
+			r.Hint += "; if it is mounted, SELinux or AppArmor may be denying access (e.g. set seLinuxOptions type spc_t)"
+		}
+	}
+	return r
+}
+
+// checkRead reads a file, which for procfs also catches files that exist but
+// come back empty or EPERM
+func checkRead(check, path, hint string) Result {
+	data, err := os.ReadFile(path)
+	if err == nil && len(data) == 0 {
+		err = errors.New("file is empty")
+	}
+	return result(check, path, err, hint)
+}
+
+// checkDir opens a directory and lists it
+func checkDir(check, path, hint string) Result {
+	f, err := os.Open(path)
+	if err == nil {
+		_, err = f.Readdirnames(1)
+		f.Close()
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	return result(check, path, err, hint)
+}
+
+// checkHostMountinfo reads the mountinfo of the process the host mount
+// namespace is read through, PID 1 or the configured one
+func checkHostMountinfo(cfg *config.Config) Result {
+	const check = "proc_host_mountinfo"
+	hint := "run with hostPID: true, or disable VOLMETD_HOST_MOUNT_NAMESPACE"
+	pid := "1"
+	if cfg.MountNamespaceProcess != "" {
+		p, err := mounts.FindProcess(cfg.HostProcPath, cfg.MountNamespaceProcess)
+		if err != nil {
+			return result(check, cfg.HostProcPath, err, "set VOLMETD_MOUNT_NAMESPACE_PROCESS to a process in the mount namespace pod volumes are mounted in, or unset it to use PID 1")
+		}
+		pid = p
+	}
+	return checkRead(check, filepath.Join(cfg.HostProcPath, pid, "mountinfo"), hint)
+}
+
+// confinedSELinuxTypes are SELinux types that may not read the kubelet's
+// directories: container_t is what container runtimes run containers as
+var confinedSELinuxTypes = map[string]bool{"container_t": true}
+
+// checkSELinux verifies volmetd isn't confined by SELinux as an ordinary
+// container when the host enforces it, which denies it the kubelet's pod
+// directories though they're mounted
+func checkSELinux(cfg *config.Config) Result {
+	const check = "selinux"
+	enforce, err := os.ReadFile(filepath.Join(cfg.HostSysPath, "fs", "selinux", "enforce"))
+	if err != nil || strings.TrimSpace(string(enforce)) != "1" {
+		// Disabled or permissive
+		return result(check, "", nil, "")
+	}
+	data, err := os.ReadFile("/proc/self/attr/current")
+	if err != nil {
+		return result(check, "/proc/self/attr/current", err, "")
+	}
+	// user:role:type:level
+	label := strings.TrimRight(string(data), "\x00\n")
+	if parts := strings.Split(label, ":"); len(parts) >= 3 && confinedSELinuxTypes[parts[2]] {
+		return result(check, label, fmt.Errorf("running as SELinux type %s", parts[2]),
+			"set seLinuxOptions type spc_t, e.g. with the chart's openshift.scc")
+	}
+	return result(check, label, nil, "")
+}
+
+// checkDev verifies the /dev used for device resolution has nodes for the
+// host's block devices. A container's own /dev has none of them, so
+// symlinked device paths from the mount table don't resolve.
+func checkDev(cfg *config.Config) Result {
+	const check = "dev"
+	devPath := cfg.HostDevPath
+	if devPath == "" {
+		devPath = "/dev"
+	}
+	hint := "mount the host /dev and set VOLMETD_HOST_DEV_PATH"
+
+	entries, err := os.ReadDir(filepath.Join(cfg.HostSysPath, "block"))
+	if err != nil {
+		// Reported by sys_block; only check /dev is readable
+		return checkDir(check, devPath, hint)
+	}
+
+	var missing []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(devPath, name)); err == nil {
+			return result(check, devPath, nil, "")
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) == 0 {
+		// No real block devices on this node, nothing to resolve
+		return checkDir(check, devPath, hint)
+	}
+	return result(check, devPath, fmt.Errorf("no device nodes for host block devices (%s)", strings.Join(missing, ", ")), hint)
+}
+
+// permission is an API verb on a resource volmetd needs
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	reason      string // config that needs it, for the hint
+}
+
+// permissions returns the API access cfg needs
+func permissions(cfg *config.Config) []permission {
+	perms := []permission{
+		{verb: "get", resource: "nodes", reason: "node topology labels"},
+	}
+	for _, m := range cfg.DiscoveryMethods {
+		if m == config.DiscoveryK8sAPI {
+			perms = append(perms,
+				permission{verb: "list", resource: "pods", reason: "k8sapi discovery"},
+				permission{verb: "get", resource: "persistentvolumeclaims", reason: "k8sapi discovery"},
+			)
+			if !cfg.MinimalRBAC {
+				perms = append(perms, permission{verb: "list", resource: "persistentvolumes", reason: "k8sapi discovery, unless VOLMETD_MINIMAL_RBAC"})
+			}
+		}
+	}
+	if cfg.KubeletURL != "" {
+		perms = append(perms, permission{verb: "get", resource: "nodes", subresource: "stats", reason: "capacity from the kubelet stats API"})
+	}
+	if cfg.VolumeHealthEvents {
+		perms = append(perms, permission{verb: "list", resource: "events", reason: "VOLMETD_VOLUME_HEALTH_EVENTS"})
+	}
+	if cfg.StorageClassInfo {
+		perms = append(perms, permission{verb: "get", group: "storage.k8s.io", resource: "storageclasses", reason: "VOLMETD_STORAGECLASS_INFO"})
+	}
+	if cfg.SnapshotMetrics {
+		perms = append(perms,
+			permission{verb: "list", group: "snapshot.storage.k8s.io", resource: "volumesnapshots", reason: "VOLMETD_SNAPSHOT_METRICS"},
+			permission{verb: "list", group: "snapshot.storage.k8s.io", resource: "volumesnapshotcontents", reason: "VOLMETD_SNAPSHOT_METRICS"},
+		)
+	}
+	return perms
+}
+
+// namespaced resources are checked in each configured namespace
+var namespaced = map[string]bool{
+	"pods":                   true,
+	"persistentvolumeclaims": true,
+	"events":                 true,
+	"volumesnapshots":        true,
+}
+
+// checkRBAC asks the API server whether the service account holds each
+// needed permission. SelfSubjectAccessReviews need no extra RBAC rules.
+func checkRBAC(ctx context.Context, cfg *config.Config) []Result {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil
+	}
+
+	var results []Result
+	for _, p := range permissions(cfg) {
+		namespaces := []string{""}
+		if namespaced[p.resource] && len(cfg.Namespaces) > 0 {
+			namespaces = cfg.Namespaces
+		}
+
+		check := "rbac_" + p.verb + "_" + p.resource
+		target := p.resource
+		if p.subresource != "" {
+			check += "_" + p.subresource
+			target += "/" + p.subresource
+		}
+		if p.group != "" {
+			target += "." + p.group
+		}
+		hint := fmt.Sprintf("grant %s on %s to the service account (needed for %s)", p.verb, target, p.reason)
+
+		var denied []string
+		var reviewErr error
+		for _, ns := range namespaces {
+			ok, err := allowed(ctx, client, p, ns)
+			if err != nil {
+				reviewErr = err
+				break
+			}
+			if !ok {
+				if ns == "" {
+					ns = "all namespaces"
+				}
+				denied = append(denied, ns)
+			}
+		}
+		switch {
+		case reviewErr != nil:
+			results = append(results, result(check, target, reviewErr, hint))
+		case len(denied) > 0:
+			results = append(results, result(check, target, fmt.Errorf("forbidden in %s", strings.Join(denied, ", ")), hint))
+		default:
+			results = append(results, result(check, target, nil, ""))
+		}
+	}
+	return results
+}
+
+func allowed(ctx context.Context, client kubernetes.Interface, p permission, namespace string) (bool, error) {
+	review := &authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        p.verb,
+				Group:       p.group,
+				Resource:    p.resource,
+				Subresource: p.subresource,
+			},
+		},
+	}
+	resp, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("access review: %w", err)
+	}
+	return resp.Status.Allowed, nil
+}
@@ -0,0 +1,44 @@
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// cephOSDMapperRe matches the device-mapper names of the logical volumes
+// ceph-volume creates for an OSD's data, DB and WAL, e.g.
+// ceph--0b1c...--osd--block--5d2e...
+var cephOSDMapperRe = regexp.MustCompile(`^ceph--[0-9a-f-]+-osd--(block|db|wal)--`)
+
+// maxHolderDepth bounds the walk up the device stack, e.g. a disk held by a
+// dm-crypt device held by an OSD logical volume
+const maxHolderDepth = 4
+
+// CephOSDDevice reports whether a block device is, or is beneath, a Ceph
+// OSD's logical volume, as created by ceph-volume (and so Rook): its I/O is
+// OSD traffic, already counted on the RBD or CephFS volumes of the clients.
+func CephOSDDevice(sysPath, dev string) bool {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	devs := []string{dev}
+	for depth := 0; depth <= maxHolderDepth && len(devs) > 0; depth++ {
+		var next []string
+		for _, d := range devs {
+			dir := filepath.Join(sysPath, "class", "block", d)
+			if cephOSDMapperRe.MatchString(readString(filepath.Join(dir, "dm", "name"))) {
+				return true
+			}
+			entries, err := os.ReadDir(filepath.Join(dir, "holders"))
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				next = append(next, e.Name())
+			}
+		}
+		devs = next
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package sysfs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HBA transport types
+const (
+	HBATypeFC  = "fc"
+	HBATypeSAS = "sas"
+)
+
+var (
+	hostRe    = regexp.MustCompile(`^host\d+$`)
+	sasPortRe = regexp.MustCompile(`^port-\d+:\d+$`)
+)
+
+// FC host statistics exported as error counters, keyed by counter name
+var fcErrorCounters = map[string]string{
+	"loss_of_signal":    "loss_of_signal_count",
+	"loss_of_sync":      "loss_of_sync_count",
+	"link_failure":      "link_failure_count",
+	"invalid_crc":       "invalid_crc_count",
+	"invalid_tx_word":   "invalid_tx_word_count",
+	"prim_seq_protocol": "prim_seq_protocol_err_count",
+	"error_frames":      "error_frames",
+	"dumped_frames":     "dumped_frames",
+	"fcp_packet_aborts": "fcp_packet_aborts",
+}
+
+// SAS phy error counters, keyed by counter name
+var sasErrorCounters = map[string]string{
+	"invalid_dword":           "invalid_dword_count",
+	"loss_of_dword_sync":      "loss_of_dword_sync_count",
+	"phy_reset_problem":       "phy_reset_problem_count",
+	"running_disparity_error": "running_disparity_error_count",
+}
+
+// HBAPort is a Fibre Channel host port or SAS phy a block device is reached through
+type HBAPort struct {
+	Type   string            // fc or sas
+	Host   string            // SCSI host, e.g. host2
+	Port   string            // FC WWPN or SAS phy name
+	State  string            // FC port state, e.g. Online; SAS phys have none
+	Errors map[string]uint64 // error counters by name
+}
+
+// HBAPortsForDevice returns the FC host port or SAS phys a block device hangs
+// off, resolved from its /sys/devices path. An empty result means the device
+// isn't behind an FC or SAS HBA.
+func HBAPortsForDevice(sysPath, dev string) ([]*HBAPort, error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+
+	// FC:  /sys/devices/pci.../host2/rport-2:0-3/target2:0:1/2:0:1:4/block/sdc
+	// SAS: /sys/devices/pci.../host0/port-0:0/end_device-0:0/target0:0:0/0:0:0:0/block/sda
+	devPath, err := DevicePath(sysPath, dev)
+	if err != nil {
+		return nil, err
+	}
+
+	var host, hostDir, sasPort string
+	parts := strings.Split(devPath, "/")
+	for i, part := range parts {
+		switch {
+		case hostRe.MatchString(part) && host == "":
+			host = part
+			hostDir = strings.Join(parts[:i+1], "/")
+		case sasPortRe.MatchString(part) && host != "" && sasPort == "":
+			sasPort = strings.Join(parts[:i+1], "/")
+		}
+	}
+	if host == "" {
+		return nil, nil
+	}
+
+	fcDir := filepath.Join(sysPath, "class", "fc_host", host)
+	if _, err := readUint(filepath.Join(fcDir, "statistics", "link_failure_count")); err == nil {
+		p := &HBAPort{
+			Type:   HBATypeFC,
+			Host:   host,
+			Port:   readString(filepath.Join(fcDir, "port_name")),
+			State:  readString(filepath.Join(fcDir, "port_state")),
+			Errors: readCounters(filepath.Join(fcDir, "statistics"), fcErrorCounters),
+		}
+		return []*HBAPort{p}, nil
+	}
+
+	if sasPort == "" {
+		return nil, nil
+	}
+	// The port directory holds the phys that make up the (wide) port
+	phys, _ := filepath.Glob(filepath.Join(sasPort, "phy-*"))
+	if len(phys) == 0 {
+		phys, _ = filepath.Glob(filepath.Join(hostDir, "phy-*"))
+	}
+
+	var ports []*HBAPort
+	for _, phy := range phys {
+		name := filepath.Base(phy)
+		ports = append(ports, &HBAPort{
+			Type:   HBATypeSAS,
+			Host:   host,
+			Port:   name,
+			Errors: readCounters(filepath.Join(sysPath, "class", "sas_phy", name), sasErrorCounters),
+		})
+	}
+	return ports, nil
+}
+
+// readCounters reads the counter files in dir, skipping any that are missing
+// or unsupported by the driver (reported as 0xffffffffffffffff)
+func readCounters(dir string, files map[string]string) map[string]uint64 {
+	counters := make(map[string]uint64, len(files))
+	for name, file := range files {
+		n, err := readUint(filepath.Join(dir, file))
+		if err != nil || n == ^uint64(0) {
+			continue
+		}
+		counters[name] = n
+	}
+	return counters
+}
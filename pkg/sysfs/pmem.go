@@ -0,0 +1,149 @@
+package sysfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// pmem0, pmem0.1 for further namespaces of a region, pmem0s in sector
+	// mode, and their partitions, e.g. pmem0p1
+	pmemRe      = regexp.MustCompile(`^pmem\d+(\.\d+)?s?(p\d+)?$`)
+	regionRe    = regexp.MustCompile(`^region\d+$`)
+	namespaceRe = regexp.MustCompile(`^namespace\d+\.\d+$`)
+)
+
+// PMEMRegion is a persistent memory region from /sys/bus/nd, the
+// interleaved DIMMs namespaces are carved from
+type PMEMRegion struct {
+	Region            string // e.g. region0
+	Size              uint64 // bytes
+	Available         uint64 // bytes not allocated to a namespace
+	BadSectors        uint64 // 512-byte sectors with known media errors
+	PersistenceDomain string // e.g. cpu_cache, memory_controller
+	NUMANode          string
+}
+
+// PMEMNamespace is the namespace of a region a pmem block device exposes
+type PMEMNamespace struct {
+	Namespace  string // e.g. namespace0.0
+	Region     string
+	Mode       string // fsdax, devdax, sector or raw
+	Size       uint64 // bytes
+	BadSectors uint64 // 512-byte sectors of the whole device with known media errors
+}
+
+// IsPMEM reports whether dev is named like a persistent memory block device
+func IsPMEM(dev string) bool {
+	return pmemRe.MatchString(dev)
+}
+
+// PMEMRegions reads the persistent memory regions of the node, sorted by
+// name; none without an nd bus
+func PMEMRegions(sysPath string) ([]*PMEMRegion, error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	dirs, err := filepath.Glob(filepath.Join(sysPath, "bus", "nd", "devices", "region*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(dirs)
+
+	var regions []*PMEMRegion
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if !regionRe.MatchString(name) {
+			continue
+		}
+		r := &PMEMRegion{
+			Region:            name,
+			BadSectors:        badSectors(filepath.Join(dir, "badblocks")),
+			PersistenceDomain: readString(filepath.Join(dir, "persistence_domain")),
+			NUMANode:          readString(filepath.Join(dir, "numa_node")),
+		}
+		if r.Size, err = readUint(filepath.Join(dir, "size")); err != nil {
+			return nil, err
+		}
+		r.Available, _ = readUint(filepath.Join(dir, "available_size"))
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+// PMEMNamespaceForDevice returns the namespace a pmem block device exposes.
+// ok is false if the device isn't on an nd bus.
+func PMEMNamespaceForDevice(sysPath, dev string) (ns *PMEMNamespace, ok bool, err error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if !IsPMEM(dev) {
+		return nil, false, nil
+	}
+	disk := dev
+	if parent, ok := ParentDevice(sysPath, dev); ok {
+		disk = parent
+	}
+
+	// /sys/devices/LNXSYSTM:00/.../ndbus0/region0/namespace0.0/block/pmem0,
+	// or .../region0/btt0.1/block/pmem0s in sector mode
+	devPath, err := DevicePath(sysPath, dev)
+	if err != nil {
+		return nil, false, err
+	}
+	parts := strings.Split(devPath, "/")
+	var regionDir string
+	for i, part := range parts {
+		if regionRe.MatchString(part) {
+			regionDir = strings.Join(parts[:i+1], "/")
+			break
+		}
+	}
+	if regionDir == "" {
+		return nil, false, nil
+	}
+
+	ns = &PMEMNamespace{
+		Region:     filepath.Base(regionDir),
+		BadSectors: badSectors(filepath.Join(sysPath, "block", disk, "badblocks")),
+	}
+	nsDir := filepath.Dir(filepath.Dir(devPath)) // the block device's parent
+	if !namespaceRe.MatchString(filepath.Base(nsDir)) {
+		// A btt or pfn device links to the namespace it is on
+		if target, err := filepath.EvalSymlinks(filepath.Join(nsDir, "namespace")); err == nil {
+			nsDir = target
+		}
+	}
+	ns.Namespace = filepath.Base(nsDir)
+	ns.Mode = readString(filepath.Join(nsDir, "mode"))
+	ns.Size, _ = readUint(filepath.Join(nsDir, "size"))
+	return ns, true, nil
+}
+
+// badSectors sums the lengths of the "<sector> <count>" ranges of a
+// badblocks file, 0 when it can't be read
+func badSectors(path string) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var total uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			total += n
+		}
+	}
+	return total
+}
@@ -0,0 +1,105 @@
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// QueueAttr reads a request queue attribute of a block device from
+// /sys/block/<dev>/queue/<attr>. Partitions share their disk's queue.
+func QueueAttr(sysPath, dev, attr string) (string, error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if parent, ok := ParentDevice(sysPath, dev); ok {
+		dev = parent
+	}
+	data, err := os.ReadFile(filepath.Join(sysPath, "block", dev, "queue", attr))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// DiscardSupported reports whether a block device accepts discard (TRIM/UNMAP)
+// requests, i.e. its discard_granularity is non-zero
+func DiscardSupported(sysPath, dev string) (bool, error) {
+	v, err := QueueAttr(sysPath, dev, "discard_granularity")
+	if err != nil {
+		return false, err
+	}
+	return v != "" && v != "0", nil
+}
+
+// Zoned describes a zoned block device's zone model and limits
+type Zoned struct {
+	Model          string // host-managed or host-aware
+	Zones          uint64
+	ZoneSizeBytes  uint64
+	MaxOpenZones   uint64 // 0 = no limit
+	MaxActiveZones uint64 // 0 = no limit
+}
+
+// ZonedInfo returns the zone model and limits of a block device from its
+// queue attributes. ok is false for conventional (non-zoned) devices.
+func ZonedInfo(sysPath, dev string) (z *Zoned, ok bool, err error) {
+	model, err := QueueAttr(sysPath, dev, "zoned")
+	if err != nil {
+		return nil, false, err
+	}
+	if model == "" || model == "none" {
+		return nil, false, nil
+	}
+
+	z = &Zoned{Model: model}
+	readQueueUint := func(attr string) uint64 {
+		v, err := QueueAttr(sysPath, dev, attr)
+		if err != nil {
+			return 0
+		}
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	}
+	z.Zones = readQueueUint("nr_zones")
+	z.ZoneSizeBytes = readQueueUint("chunk_sectors") * 512
+	z.MaxOpenZones = readQueueUint("max_open_zones")
+	z.MaxActiveZones = readQueueUint("max_active_zones")
+	return z, true, nil
+}
+
+// QueueTuning is the tunable I/O settings of a block device's request queue
+type QueueTuning struct {
+	Scheduler   string // active I/O scheduler, e.g. mq-deadline, bfq or none
+	ReadAheadKB uint64
+	NrRequests  uint64
+}
+
+// ReadQueueTuning returns the active I/O scheduler, read_ahead_kb and
+// nr_requests of a block device's request queue
+func ReadQueueTuning(sysPath, dev string) (*QueueTuning, error) {
+	schedulers, err := QueueAttr(sysPath, dev, "scheduler")
+	if err != nil {
+		return nil, err
+	}
+	t := &QueueTuning{Scheduler: activeScheduler(schedulers)}
+	if v, err := QueueAttr(sysPath, dev, "read_ahead_kb"); err == nil {
+		t.ReadAheadKB, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v, err := QueueAttr(sysPath, dev, "nr_requests"); err == nil {
+		t.NrRequests, _ = strconv.ParseUint(v, 10, 64)
+	}
+	return t, nil
+}
+
+// activeScheduler picks the bracketed entry of a scheduler attribute such as
+// "mq-deadline kyber [bfq] none". Devices without a choice list just one.
+func activeScheduler(s string) string {
+	for _, f := range strings.Fields(s) {
+		if name, ok := strings.CutPrefix(f, "["); ok {
+			return strings.TrimSuffix(name, "]")
+		}
+	}
+	return s
+}
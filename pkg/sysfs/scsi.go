@@ -0,0 +1,136 @@
+package sysfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sessionRe = regexp.MustCompile(`^session\d+$`)
+
+// ISCSISession describes the iSCSI session a block device is attached through
+type ISCSISession struct {
+	Session string // e.g. session1
+	Target  string // target IQN
+	State   string // e.g. LOGGED_IN, FAILED, FREE
+	Portal  string // address:port of the session's first connection
+}
+
+// LoggedIn reports whether the session is logged in
+func (s *ISCSISession) LoggedIn() bool {
+	return s.State == "LOGGED_IN"
+}
+
+// SCSICounters are the SCSI mid-layer I/O counters of a SCSI disk
+type SCSICounters struct {
+	IORequests uint64
+	IODone     uint64
+	IOErrors   uint64
+	IOTimeouts uint64
+}
+
+// DevicePath returns the resolved /sys/devices path of a block device, with
+// partitions resolved to their parent disk
+func DevicePath(sysPath, dev string) (string, error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if parent, ok := ParentDevice(sysPath, dev); ok {
+		dev = parent
+	}
+	return filepath.EvalSymlinks(filepath.Join(sysPath, "block", dev))
+}
+
+// ISCSISessionForDevice returns the iSCSI session backing a block device.
+// ok is false if the device isn't attached over iSCSI.
+func ISCSISessionForDevice(sysPath, dev string) (session *ISCSISession, ok bool, err error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+
+	// /sys/devices/platform/host3/session1/target3:0:0/3:0:0:1/block/sdb
+	devPath, err := DevicePath(sysPath, dev)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var name string
+	for _, part := range strings.Split(devPath, "/") {
+		if sessionRe.MatchString(part) {
+			name = part
+			break
+		}
+	}
+	if name == "" {
+		return nil, false, nil
+	}
+
+	dir := filepath.Join(sysPath, "class", "iscsi_session", name)
+	s := &ISCSISession{
+		Session: name,
+		Target:  readString(filepath.Join(dir, "targetname")),
+		State:   readString(filepath.Join(dir, "state")),
+	}
+
+	// Connections are named connection<session id>:<cid>
+	id := strings.TrimPrefix(name, "session")
+	if conns, err := filepath.Glob(filepath.Join(sysPath, "class", "iscsi_connection", "connection"+id+":*")); err == nil && len(conns) > 0 {
+		addr := readString(filepath.Join(conns[0], "persistent_address"))
+		port := readString(filepath.Join(conns[0], "persistent_port"))
+		if addr != "" {
+			s.Portal = addr + ":" + port
+		}
+	}
+	return s, true, nil
+}
+
+// ReadSCSICounters reads the SCSI I/O counters of a block device's SCSI device
+func ReadSCSICounters(sysPath, dev string) (*SCSICounters, error) {
+	devPath, err := DevicePath(sysPath, dev)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(devPath, "device")
+
+	c := &SCSICounters{}
+	for _, f := range []struct {
+		name string
+		v    *uint64
+	}{
+		{"iorequest_cnt", &c.IORequests},
+		{"iodone_cnt", &c.IODone},
+		{"ioerr_cnt", &c.IOErrors},
+		{"iotmo_cnt", &c.IOTimeouts},
+	} {
+		n, err := readUint(filepath.Join(dir, f.name))
+		if err != nil {
+			return nil, err
+		}
+		*f.v = n
+	}
+	return c, nil
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readUint reads a decimal or 0x-prefixed hex counter
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,118 @@
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParentDevice returns the whole-disk device for a partition, e.g. sda3 -> sda,
+// nvme0n1p2 -> nvme0n1. It uses /sys/class/block when available and falls back
+// to kernel naming conventions. ok is false if dev is not a partition.
+func ParentDevice(sysPath, dev string) (parent string, ok bool) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	if dev == "" {
+		return "", false
+	}
+
+	classDir := filepath.Join(sysPath, "class", "block", dev)
+	if _, err := os.Stat(classDir); err == nil {
+		if _, err := os.Stat(filepath.Join(classDir, "partition")); err != nil {
+			return "", false
+		}
+		// /sys/class/block/sda3 -> ../../devices/.../block/sda/sda3
+		if target, err := filepath.EvalSymlinks(classDir); err == nil {
+			return filepath.Base(filepath.Dir(target)), true
+		}
+	}
+
+	return parentFromName(dev)
+}
+
+// parentFromName derives the parent device from kernel partition naming
+func parentFromName(dev string) (string, bool) {
+	trimmed := strings.TrimRight(dev, "0123456789")
+	if trimmed == dev || trimmed == "" {
+		return "", false
+	}
+
+	// nvme0n1p2, mmcblk0p1, loop0p1: partition suffix is "p<N>" after a digit
+	if strings.HasSuffix(trimmed, "p") && len(trimmed) > 1 {
+		base := trimmed[:len(trimmed)-1]
+		if last := base[len(base)-1]; last >= '0' && last <= '9' {
+			return base, true
+		}
+	}
+
+	// Whole disks that end in digits (nvme0n1, mmcblk0, dm-0, loop0, pmem0) have no
+	// letter-only prefix we can strip safely
+	for _, p := range []string{"nvme", "mmcblk", "dm-", "loop", "md", "nbd", "zram", "rbd", "pmem"} {
+		if strings.HasPrefix(dev, p) {
+			return "", false
+		}
+	}
+
+	// sda3, vdb1, xvda2
+	return trimmed, true
+}
+
+// DeviceNumber returns the major:minor number of a block device, read from
+// /sys/class/block/<dev>/dev
+func DeviceNumber(sysPath, dev string) (string, error) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+	data, err := os.ReadFile(filepath.Join(sysPath, "class", "block", dev, "dev"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// maxDMDepth bounds the walk down stacked device-mapper devices
+const maxDMDepth = 8
+
+// DMCrypt reports whether a block device is, or is stacked on (e.g. LVM on
+// LUKS), a dm-crypt mapping, identified by the CRYPT- prefix of its
+// /sys/block/<dev>/dm/uuid. backing lists the devices beneath the crypt
+// mapping, which hold the ciphertext.
+func DMCrypt(sysPath, dev string) (encrypted bool, backing []string) {
+	if sysPath == "" {
+		sysPath = "/sys"
+	}
+
+	devs := []string{dev}
+	for depth := 0; depth < maxDMDepth && len(devs) > 0; depth++ {
+		var next []string
+		for _, d := range devs {
+			uuid, err := os.ReadFile(filepath.Join(sysPath, "block", d, "dm", "uuid"))
+			if err != nil {
+				// Not a device-mapper device, nothing further down
+				continue
+			}
+			slaves := Slaves(sysPath, d)
+			if strings.HasPrefix(strings.TrimSpace(string(uuid)), "CRYPT-") {
+				return true, slaves
+			}
+			next = append(next, slaves...)
+		}
+		devs = next
+	}
+	return false, nil
+}
+
+// Slaves returns the devices a stacked block device (device-mapper, md) is
+// built on, from /sys/block/<dev>/slaves
+func Slaves(sysPath, dev string) []string {
+	entries, err := os.ReadDir(filepath.Join(sysPath, "block", dev, "slaves"))
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
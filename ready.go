@@ -0,0 +1,62 @@
+package volmetd
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// warmUpBackoff is the delay before retrying a failed warm-up discovery,
+// doubled up to warmUpMaxBackoff
+const (
+	warmUpBackoff    = time.Second
+	warmUpMaxBackoff = 10 * time.Second
+)
+
+// warmUp runs discovery until it first succeeds, or cfg.ReadinessMaxWait
+// passes, then marks the exporter ready. Serving an empty target right after
+// a rollout would look like every volume disappeared.
+func (e *Exporter) warmUp(ctx context.Context) {
+	defer e.ready.Store(true)
+	if e.cfg.ReadinessMaxWait <= 0 {
+		return
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.ReadinessMaxWait)
+	defer cancel()
+
+	backoff := warmUpBackoff
+	for {
+		volumes, err := e.discoverer.Discover(ctx)
+		if err == nil {
+			slog.Info("warm-up discovery done", "volumes", len(volumes), "duration", time.Since(start).Round(time.Millisecond))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			slog.Warn("warm-up discovery did not succeed, marking ready anyway", "wait", e.cfg.ReadinessMaxWait, "error", err)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, warmUpMaxBackoff)
+	}
+}
+
+// Ready reports whether the warm-up discovery started by Run has finished
+func (e *Exporter) Ready() bool {
+	return e.ready.Load()
+}
+
+// ReadyHandler serves 200 once the exporter is ready and 503 before
+func (e *Exporter) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !e.Ready() {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
@@ -0,0 +1,142 @@
+package volmetd
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// seriesLimiter caps the series a scrape serves. A node that suddenly
+// discovers thousands of ephemeral PVCs, as CI clusters do, would otherwise
+// push all of their series into Prometheus at once. Beyond the limit the
+// families with the most series are dropped whole, which are the per-volume
+// ones. The exporter's own health families (see healthFamilies) are never
+// dropped, however large, and series_limited reports the scrape was cut
+// short.
+type seriesLimiter struct {
+	g       prometheus.Gatherer
+	limit   int
+	prefix  string // metric prefix of the exporter's families
+	name    string // of the series_limited family
+	limited atomic.Bool
+}
+
+// healthFamilies are the families, less the metric prefix, the series
+// limiter keeps: whether scrapes, discovery and its discoverers succeed.
+// Names ending in _ match every family starting with them.
+var healthFamilies = []string{
+	"scrape_success",
+	"scrape_duration_seconds",
+	"scrape_response_size_bytes",
+	"scrapes_rejected_total",
+	"volumes_discovered",
+	"capability_degraded",
+	"node_info",
+	"kubelet_layout_info",
+	"discovery_",
+	"discoverer_",
+}
+
+func newSeriesLimiter(g prometheus.Gatherer, limit int, prefix string) *seriesLimiter {
+	return &seriesLimiter{g: g, limit: limit, prefix: prefix, name: prefix + "series_limited"}
+}
+
+// health reports whether the family named name is one of healthFamilies
+func (l *seriesLimiter) health(name string) bool {
+	name, ok := strings.CutPrefix(name, l.prefix)
+	if !ok {
+		return false
+	}
+	for _, h := range healthFamilies {
+		if name == h || strings.HasSuffix(h, "_") && strings.HasPrefix(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *seriesLimiter) Gather() ([]*dto.MetricFamily, error) {
+	families, err := l.g.Gather()
+
+	total := 0
+	for _, mf := range families {
+		total += seriesCount(mf)
+	}
+	var dropped []string
+	if total > l.limit {
+		// Largest first, by name among equals so the same families go
+		// from one scrape to the next
+		bySize := slices.Clone(families)
+		slices.SortFunc(bySize, func(a, b *dto.MetricFamily) int {
+			if c := cmp.Compare(seriesCount(b), seriesCount(a)); c != 0 {
+				return c
+			}
+			return strings.Compare(a.GetName(), b.GetName())
+		})
+		drop := make(map[*dto.MetricFamily]bool)
+		for _, mf := range bySize {
+			if total <= l.limit {
+				break
+			}
+			if l.health(mf.GetName()) {
+				continue
+			}
+			drop[mf] = true
+			dropped = append(dropped, mf.GetName())
+			total -= seriesCount(mf)
+		}
+		families = slices.DeleteFunc(families, func(mf *dto.MetricFamily) bool { return drop[mf] })
+	}
+
+	limited := len(dropped) > 0
+	if limited && !l.limited.Swap(true) {
+		slog.Warn("series limit exceeded, dropping metric families", "limit", l.limit, "dropped", dropped)
+	} else if !limited && l.limited.Swap(false) {
+		slog.Info("series back under limit", "limit", l.limit, "series", total)
+	}
+
+	// Registries gather families sorted by name; keep it so
+	i, _ := slices.BinarySearchFunc(families, l.name, func(mf *dto.MetricFamily, name string) int {
+		return strings.Compare(mf.GetName(), name)
+	})
+	return slices.Insert(families, i, l.family(limited)), err
+}
+
+// seriesCount is the number of series mf is stored as in Prometheus, where
+// histograms and summaries take one per bucket or quantile besides _sum and
+// _count
+func seriesCount(mf *dto.MetricFamily) int {
+	n := 0
+	for _, m := range mf.Metric {
+		switch {
+		case m.Histogram != nil:
+			// The +Inf bucket is implicit
+			n += len(m.Histogram.Bucket) + 3
+		case m.Summary != nil:
+			n += len(m.Summary.Quantile) + 2
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// family is the series_limited gauge
+func (l *seriesLimiter) family(limited bool) *dto.MetricFamily {
+	var v float64
+	if limited {
+		v = 1
+	}
+	return &dto.MetricFamily{
+		Name:   proto.String(l.name),
+		Help:   proto.String("Whether the last scrape exceeded the series limit and had its largest metric families dropped"),
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(v)}}},
+	}
+}
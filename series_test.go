@@ -0,0 +1,52 @@
+package volmetd
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The largest families are dropped beyond the limit, but never the
+// exporter's health families
+func TestSeriesLimiterKeepsHealth(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	families := map[string]int{
+		"volmetd_scrape_success":              40,
+		"volmetd_discovery_namespace_success": 30,
+		"volmetd_discoverer_breaker_state":    20,
+		"volmetd_read_bytes_total":            25,
+		"volmetd_volume_info":                 15,
+		"volmetd_capacity_bytes":              5,
+	}
+	for name, n := range families {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: name}, []string{"i"})
+		for i := range n {
+			g.WithLabelValues(fmt.Sprint(i)).Set(1)
+		}
+		reg.MustRegister(g)
+	}
+
+	gathered, err := newSeriesLimiter(reg, 100, "volmetd_").Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, mf := range gathered {
+		names = append(names, mf.GetName())
+	}
+	want := []string{
+		"volmetd_capacity_bytes",
+		"volmetd_discoverer_breaker_state",
+		"volmetd_discovery_namespace_success",
+		"volmetd_scrape_success",
+		"volmetd_series_limited",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("families served %q, want %q", names, want)
+	}
+	if v := gathered[4].Metric[0].GetGauge().GetValue(); v != 1 {
+		t.Errorf("series_limited = %g, want 1", v)
+	}
+}
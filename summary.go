@@ -0,0 +1,67 @@
+package volmetd
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// volumeIdentityLabels mark series about one volume, pod or container rather
+// than the node as a whole, when set
+var volumeIdentityLabels = map[string]bool{
+	"pvc":           true,
+	"pv":            true,
+	namespaceLabel:  true,
+	"pod":           true,
+	"pod_namespace": true,
+	"mount_path":    true,
+	"csi_device":    true,
+	"volume_handle": true,
+	"container":     true,
+}
+
+// SummaryHandler returns an http.Handler serving only node-level series:
+// node aggregates such as node_volume_iops and node_fs_capacity_*, the
+// diskstats of node devices backing no volume (VOLMETD_ALL_DEVICES), and the
+// exporter's own health (scrape_success, discovery and breaker state). It
+// lets a meta-monitoring Prometheus check volmetd on every node without
+// ingesting per-volume cardinality. Series are gathered as for ServeHTTP,
+// so a scrape costs a full collection, and count towards the same
+// concurrency limit.
+func (e *Exporter) SummaryHandler() http.Handler {
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := e.gatherer.Gather()
+		return filterNodeLevel(families), err
+	})
+	return e.limiter.wrap(instrumentSize(e.sizes, promhttp.HandlerFor(g, e.opts)))
+}
+
+// filterNodeLevel drops the series labelled with a volume's identity,
+// dropping families left empty
+func filterNodeLevel(families []*dto.MetricFamily) []*dto.MetricFamily {
+	result := families[:0]
+	for _, mf := range families {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			if !hasVolumeIdentity(m) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			result = append(result, mf)
+		}
+	}
+	return result
+}
+
+func hasVolumeIdentity(m *dto.Metric) bool {
+	for _, l := range m.Label {
+		if volumeIdentityLabels[l.GetName()] && l.GetValue() != "" {
+			return true
+		}
+	}
+	return false
+}
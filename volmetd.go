@@ -0,0 +1,861 @@
+// Package volmetd exposes PVC volume discovery and metrics collection as an
+// embeddable library. The volmetd binary in cmd/volmetd is a thin wrapper
+// around it.
+package volmetd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/gfx-labs/volmetd/pkg/cloud"
+	"github.com/gfx-labs/volmetd/pkg/collector"
+	"github.com/gfx-labs/volmetd/pkg/config"
+	"github.com/gfx-labs/volmetd/pkg/cri"
+	"github.com/gfx-labs/volmetd/pkg/discovery"
+	"github.com/gfx-labs/volmetd/pkg/diskstats"
+	"github.com/gfx-labs/volmetd/pkg/fixture"
+	"github.com/gfx-labs/volmetd/pkg/kmsg"
+	"github.com/gfx-labs/volmetd/pkg/kubelet"
+	"github.com/gfx-labs/volmetd/pkg/mounts"
+	"github.com/gfx-labs/volmetd/pkg/plugin"
+	"github.com/gfx-labs/volmetd/pkg/policy"
+	"github.com/gfx-labs/volmetd/pkg/selfcheck"
+)
+
+// nodeLookupTimeout bounds the Node object lookup for topology labels
+const nodeLookupTimeout = 5 * time.Second
+
+// selfCheckTimeout bounds the startup permission self-check
+const selfCheckTimeout = 10 * time.Second
+
+// ErrNoDiscoverers is returned when none of the configured discoverers could be created
+var ErrNoDiscoverers = errors.New("no discoverers available")
+
+// Exporter discovers PVC volumes and serves their metrics
+type Exporter struct {
+	cfg        *config.Config
+	discoverer *discovery.MultiDiscoverer
+	collector  *collector.VolumeCollector
+	resolver   *mounts.Resolver
+	gatherer   prometheus.Gatherer
+	handler    http.Handler
+	policy     *policy.Watcher               // nil without a policy ConfigMap
+	kmsg       *kmsg.Watcher                 // nil without a kernel log path
+	sampler    *collector.SamplingCollector  // nil without a sample interval
+	diskstats  *collector.DiskstatsCollector // nil with collectors given by WithCollectors
+	capacity   *collector.CapacityCollector  // nil with collectors given by WithCollectors and against fixtures
+	limiter    *scrapeLimiter
+	sizes      *prometheus.HistogramVec // response sizes by format and encoding
+	opts       promhttp.HandlerOpts
+	ready      atomic.Bool
+}
+
+type options struct {
+	cfg         *config.Config
+	discoverers []discovery.Discoverer
+	collectors  []collector.Collector
+	registerer  prometheus.Registerer
+	gatherer    prometheus.Gatherer
+}
+
+// Option configures an Exporter
+type Option func(*options)
+
+// WithConfig replaces the base configuration. Defaults come from config.DefaultConfig.
+// Options applied after WithConfig override its fields.
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		c := *cfg
+		o.cfg = &c
+	}
+}
+
+// WithProcPath sets the path to the host's /proc
+func WithProcPath(path string) Option {
+	return func(o *options) {
+		o.cfg.HostProcPath = path
+	}
+}
+
+// WithSysPath sets the path to the host's /sys
+func WithSysPath(path string) Option {
+	return func(o *options) {
+		o.cfg.HostSysPath = path
+	}
+}
+
+// WithKubeletPath sets the path to the host's kubelet directory
+func WithKubeletPath(path string) Option {
+	return func(o *options) {
+		o.cfg.KubeletPath = path
+	}
+}
+
+// WithNamespaces limits discovery to the given namespaces
+func WithNamespaces(namespaces ...string) Option {
+	return func(o *options) {
+		o.cfg.Namespaces = namespaces
+	}
+}
+
+// WithExcludeNamespaces skips discovery in the given namespaces
+func WithExcludeNamespaces(namespaces ...string) Option {
+	return func(o *options) {
+		o.cfg.ExcludeNamespaces = namespaces
+	}
+}
+
+// WithDiscoveryMethods sets the built-in discovery methods in priority order
+func WithDiscoveryMethods(methods ...string) Option {
+	return func(o *options) {
+		o.cfg.DiscoveryMethods = methods
+	}
+}
+
+// WithMetricPrefix sets the prefix prepended to all metric names
+func WithMetricPrefix(prefix string) Option {
+	return func(o *options) {
+		o.cfg.MetricPrefix = prefix
+	}
+}
+
+// WithSeriesLimit caps the series served per scrape, dropping the largest
+// metric families beyond it; 0 disables the limit
+func WithSeriesLimit(limit int) Option {
+	return func(o *options) {
+		o.cfg.SeriesLimit = limit
+	}
+}
+
+// WithDiscoverers uses the given discoverers instead of the configured discovery methods
+func WithDiscoverers(discoverers ...discovery.Discoverer) Option {
+	return func(o *options) {
+		o.discoverers = discoverers
+	}
+}
+
+// WithCollectors uses the given collectors instead of the default collectors
+func WithCollectors(collectors ...collector.Collector) Option {
+	return func(o *options) {
+		o.collectors = collectors
+	}
+}
+
+// WithRegisterer registers metrics into reg instead of a private registry.
+// If reg is also a prometheus.Gatherer it is used to serve metrics.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = reg
+	}
+}
+
+// WithGatherer sets the gatherer used to serve metrics
+func WithGatherer(g prometheus.Gatherer) Option {
+	return func(o *options) {
+		o.gatherer = g
+	}
+}
+
+// New creates a new Exporter
+func New(opts ...Option) (*Exporter, error) {
+	o := &options{cfg: config.DefaultConfig()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := o.cfg
+	if _, ok := kubelet.LayoutByName(cfg.KubeletLayout); !ok && cfg.KubeletLayout != "" {
+		return nil, fmt.Errorf("unknown kubelet layout %q, want one of %s", cfg.KubeletLayout, strings.Join(kubelet.LayoutNames(), ", "))
+	}
+
+	var fx *fixture.Fixture
+	if cfg.FixtureDir != "" {
+		var err error
+		if fx, err = fixture.Load(cfg.FixtureDir); err != nil {
+			return nil, err
+		}
+		cfg = fx.Config(cfg)
+		slog.Info("running against fixture", "dir", fx.Dir, "node", fx.Node)
+	}
+
+	var resolver *mounts.Resolver
+	if fx != nil {
+		var err error
+		if resolver, err = fx.Resolver(cfg.MountNamespaceProcess); err != nil {
+			return nil, err
+		}
+	} else {
+		resolver = newResolver(cfg)
+	}
+	discoverers := o.discoverers
+	var fallback discovery.Discoverer
+	if len(discoverers) == 0 {
+		if fx != nil {
+			discoverers = buildFixtureDiscoverers(cfg, fx, resolver)
+		} else {
+			discoverers, fallback = buildDiscoverers(cfg, resolver)
+		}
+	}
+	if len(discoverers) == 0 {
+		return nil, ErrNoDiscoverers
+	}
+	multi := discovery.NewMultiDiscoverer(discoverers...)
+	multi.SetRetryPolicy(retryPolicy(cfg))
+	if fallback != nil {
+		multi.SetAccessFallback(fallback)
+	}
+
+	var node discovery.NodeInfo
+	var checks []selfcheck.Result
+	var capabilities []selfcheck.Capability
+	if fx != nil {
+		node = discovery.NodeInfo{Name: fx.Node, Zone: cfg.NodeZone, Region: cfg.NodeRegion}
+	} else {
+		lookupCtx, cancel := context.WithTimeout(context.Background(), nodeLookupTimeout)
+		node = discovery.LookupNode(lookupCtx, cfg.NodeZone, cfg.NodeRegion)
+		cancel()
+
+		checks = runSelfCheck(cfg)
+		capabilities = selfcheck.Capabilities(cfg, checks)
+		for _, c := range selfcheck.Degraded(capabilities) {
+			slog.Info("capability degraded", "capability", c.Name, "fallback", c.Fallback)
+		}
+	}
+
+	// Policy is watched by Run; until then, and without a ConfigMap, there
+	// is none and configured defaults apply
+	var pw *policy.Watcher
+	if cfg.PolicyConfigMap != "" && fx == nil {
+		w, err := policy.NewWatcher(cfg.PolicyConfigMap)
+		if err != nil {
+			slog.Warn("policy disabled", "error", err)
+		} else {
+			pw = w
+		}
+	}
+
+	// The kernel log is tailed by Run
+	var kw *kmsg.Watcher
+	if cfg.KernelLogPath != "" && len(o.collectors) == 0 && fx == nil {
+		kw = kmsg.NewWatcher(cfg.KernelLogPath, cfg.HostProcPath)
+	}
+
+	devices, err := diskstats.NewDeviceFilter(cfg.DeviceInclude, cfg.DeviceExclude)
+	if err != nil {
+		return nil, err
+	}
+
+	collectors := o.collectors
+	var ds *collector.DiskstatsCollector
+	if len(collectors) == 0 {
+		permissions := collector.NewPermissionCollector(checks)
+		permissions.SetCapabilities(capabilities)
+		// Sampled for its rates by Run
+		ds = newDiskstatsCollector(cfg, devices)
+		collectors = []collector.Collector{
+			permissions,
+			ds,
+			collector.NewPodsCollector(),
+			collector.NewInfoCollector(cfg.HostSysPath),
+			collector.NewNodeCollector(node),
+			collector.NewLayoutCollector(cfg.Layout().Name(), cfg.HostKubeletPath, cfg.KubeletPath, cfg.HostProcPath),
+			collector.NewISCSICollector(cfg.HostSysPath),
+			collector.NewPMEMCollector(cfg.HostSysPath),
+			collector.NewHBACollector(cfg.HostSysPath),
+			collector.NewThrottleCollector(filepath.Join(cfg.HostSysPath, "fs", "cgroup"), cfg.HostSysPath),
+			collector.NewMountOptionsCollector(resolver),
+			collector.NewAttachCollector(),
+			collector.NewQueueCollector(cfg.HostSysPath, cfg.HostDevPath),
+			collector.NewDeviceErrorsCollector(cfg.HostSysPath, cfg.HostDevPath),
+		}
+		if cfg.IOAmplification {
+			collectors = append(collectors, collector.NewAmplificationCollector(cfg.HostProcPath, filepath.Join(cfg.HostSysPath, "fs", "cgroup"), cfg.HostSysPath))
+		}
+		if cfg.LatencySLO != "" || pw != nil {
+			slos, err := collector.ParseLatencySLOs(cfg.LatencySLO)
+			if err != nil {
+				return nil, err
+			}
+			slo := collector.NewLatencySLOCollector(cfg.HostProcPath, slos)
+			if pw != nil {
+				slo.SetPolicy(pw)
+			}
+			collectors = append(collectors, slo)
+		}
+		if pw != nil {
+			collectors = append(collectors, collector.NewPolicyCollector(pw))
+		}
+	}
+	var sampler *collector.SamplingCollector
+	var capacity *collector.CapacityCollector
+	if len(o.collectors) == 0 && fx == nil {
+		// Collectors that only make sense against a live node: statfs and
+		// probes of the mounts, process and CSI sockets, and remote APIs
+		capacity = newCapacityCollector(cfg)
+		collectors = append(collectors, capacity)
+		if cfg.SampleInterval > 0 {
+			// Sampled between scrapes by Run
+			sampler = collector.NewSamplingCollector(capacity, cfg.HostProcPath, cfg.SampleInterval)
+			collectors = append(collectors, sampler)
+		}
+		if cfg.TopProcesses > 0 {
+			collectors = append(collectors, collector.NewTopProcessesCollector(cfg.HostProcPath, cfg.TopProcesses))
+		}
+		if cfg.ProbeRead {
+			collectors = append(collectors, collector.NewReadProbeCollector(cfg.ProbeTimeout))
+		}
+		if kw != nil {
+			collectors = append(collectors, collector.NewKernelLogCollector(kw, cfg.HostSysPath))
+		}
+		if cfg.ProbeFsync {
+			collectors = append(collectors, collector.NewFsyncProbeCollector(cfg.ProbeInterval))
+		}
+		if cfg.ProbeExec != "" {
+			collectors = append(collectors, collector.NewExecProbeCollector(cfg.ProbeExec, cfg.ProbeInterval, cfg.ProbeExecTimeout))
+		}
+		if cfg.CSIVolumeStats {
+			cs := collector.NewCSIStatsCollector(cfg.KubeletPath, cfg.HostKubeletPath)
+			cs.SetLayout(cfg.Layout())
+			collectors = append(collectors, cs)
+		}
+		if cfg.VolumeHealthEvents {
+			if hc, err := collector.NewVolumeHealthCollector(cfg.Namespaces); err != nil {
+				slog.Warn("collector disabled", "collector", "volumehealth", "error", err)
+			} else {
+				collectors = append(collectors, hc)
+			}
+		}
+		if cfg.MultiAttachDetection {
+			if mc, err := collector.NewMultiAttachCollector(); err != nil {
+				slog.Warn("collector disabled", "collector", "multiattach", "error", err)
+			} else {
+				collectors = append(collectors, mc)
+			}
+		}
+		if cfg.VolumeAttachmentMetrics {
+			if vc, err := collector.NewVolumeAttachmentCollector(); err != nil {
+				slog.Warn("collector disabled", "collector", "volumeattachment", "error", err)
+			} else {
+				collectors = append(collectors, vc)
+			}
+		}
+		if cfg.DiscoveryCompare {
+			if dc, err := newDiscoveryCompareCollector(cfg, resolver); err != nil {
+				slog.Warn("collector disabled", "collector", "discoverycompare", "error", err)
+			} else {
+				collectors = append(collectors, dc)
+			}
+		}
+		if cfg.EmptyDirMetrics {
+			if ec, err := newEmptyDirCollector(cfg); err != nil {
+				slog.Warn("collector disabled", "collector", "emptydir", "error", err)
+			} else {
+				collectors = append(collectors, ec)
+			}
+		}
+		var criClient *cri.Client
+		if cfg.CRISocket != "" {
+			if c, err := cri.NewClient(cfg.CRISocket); err != nil {
+				slog.Warn("collector disabled", "collector", "containerlayer", "error", err)
+			} else {
+				criClient = c
+				collectors = append(collectors, collector.NewContainerLayerCollector(c, cfg.Namespaces))
+			}
+		}
+		if cfg.NodeFSMetrics {
+			nc := collector.NewNodeFSCollector(cfg.HostProcPath, cfg.HostKubeletPath, resolver)
+			if criClient != nil {
+				nc.SetCRI(criClient)
+			}
+			collectors = append(collectors, nc)
+		}
+		if cfg.StorageClassInfo {
+			if sc, err := collector.NewStorageClassCollector(); err != nil {
+				slog.Warn("collector disabled", "collector", "storageclass", "error", err)
+			} else {
+				collectors = append(collectors, sc)
+			}
+		}
+		if len(cfg.CloudEnrichers) > 0 {
+			var providers []cloud.DiskProvider
+			for _, name := range cfg.CloudEnrichers {
+				p, err := cloud.NewProvider(name)
+				if err != nil {
+					slog.Warn("cloud enricher disabled", "error", err)
+					continue
+				}
+				providers = append(providers, p)
+			}
+			if len(providers) > 0 {
+				collectors = append(collectors, collector.NewCloudDiskCollector(providers...))
+			}
+		}
+		if cfg.PluginDir != "" {
+			collectors = append(collectors, pluginCollectors(cfg, node.Name)...)
+		}
+		if cfg.SnapshotMetrics {
+			if sc, err := collector.NewSnapshotCollector(cfg.Namespaces); err != nil {
+				slog.Warn("collector disabled", "collector", "snapshot", "error", err)
+			} else {
+				collectors = append(collectors, sc)
+			}
+		}
+	}
+	if len(o.collectors) == 0 {
+		// Expensive collectors refresh in the background, the rest per scrape
+		collectors = collector.WithIntervals(collectors, cfg.CollectorIntervals)
+	}
+	vc := collector.NewVolumeCollector(multi, cfg.HostProcPath, collectors...)
+	vc.SetStaleTTL(cfg.DiscoveryStaleTTL)
+	if cfg.DiscoveryStateFile != "" && fx == nil {
+		vc.SetStateFile(cfg.DiscoveryStateFile)
+	}
+	vc.SetOmitPodLabels(cfg.OmitPodLabels)
+	vc.SetExcludeCephOSDs(cfg.CephOSDAwareness, cfg.HostSysPath)
+	if pw != nil {
+		vc.SetPolicy(pw)
+	}
+
+	reg, gatherer := o.registerer, o.gatherer
+	if reg == nil {
+		r := prometheus.NewRegistry()
+		reg = r
+		if gatherer == nil {
+			gatherer = r
+		}
+	}
+	if gatherer == nil {
+		if g, ok := reg.(prometheus.Gatherer); ok {
+			gatherer = g
+		} else {
+			gatherer = prometheus.DefaultGatherer
+		}
+	}
+
+	var nodeLabels prometheus.Labels
+	if cfg.NodeLabels {
+		nodeLabels = prometheus.Labels{
+			"node":   node.Name,
+			"zone":   node.Zone,
+			"region": node.Region,
+		}
+	}
+	if err := vc.Register(reg, cfg.MetricPrefix, nodeLabels); err != nil {
+		return nil, err
+	}
+	limiter := newScrapeLimiter(cfg.MaxConcurrentScrapes)
+	sizes := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scrape_response_size_bytes",
+		Help:    "Size of metrics responses as sent, after compression, by exposition format and content encoding",
+		Buckets: scrapeSizeBuckets,
+	}, []string{"format", "encoding"})
+	for _, c := range []prometheus.Collector{limiter.rejected, sizes} {
+		if err := prometheus.WrapRegistererWithPrefix(cfg.MetricPrefix, reg).Register(c); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SeriesLimit > 0 {
+		gatherer = newSeriesLimiter(gatherer, cfg.SeriesLimit, cfg.MetricPrefix)
+	}
+	hopts := handlerOpts(cfg.OpenMetrics, cfg.Compression)
+
+	return &Exporter{
+		cfg:        cfg,
+		discoverer: multi,
+		collector:  vc,
+		resolver:   resolver,
+		policy:     pw,
+		kmsg:       kw,
+		sampler:    sampler,
+		diskstats:  ds,
+		capacity:   capacity,
+		gatherer:   gatherer,
+		limiter:    limiter,
+		sizes:      sizes,
+		opts:       hopts,
+		handler:    promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(gatherer, hopts)),
+	}, nil
+}
+
+// pluginCollectors returns a collector for each plugin in cfg.PluginDir
+func pluginCollectors(cfg *config.Config, node string) []collector.Collector {
+	plugins, err := plugin.Find(cfg.PluginDir)
+	if err != nil {
+		slog.Warn("plugins disabled", "error", err)
+		return nil
+	}
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	collectors := make([]collector.Collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, collector.NewExecCollector(name, plugins[name], node, cfg.PluginTimeout))
+	}
+	slog.Info("enabled plugins", "dir", cfg.PluginDir, "plugins", names)
+	return collectors
+}
+
+// retryPolicy returns the default discovery retry policy with configured overrides
+func retryPolicy(cfg *config.Config) discovery.RetryPolicy {
+	p := discovery.DefaultRetryPolicy()
+	p.Attempts = cfg.DiscoveryAttempts
+	p.BreakerThreshold = cfg.DiscoveryBreakerThreshold
+	if cfg.DiscoveryBreakerMaxBackoff > 0 {
+		p.BreakerMaxBackoff = cfg.DiscoveryBreakerMaxBackoff
+	}
+	return p
+}
+
+func newDiskstatsCollector(cfg *config.Config, devices *diskstats.DeviceFilter) *collector.DiskstatsCollector {
+	c := collector.NewDiskstatsCollector(cfg.HostProcPath, cfg.HostSysPath, cfg.ParentDeviceMetrics)
+	c.SetDeviceFilter(devices)
+	c.SetDeviceMetrics(cfg.DeviceMetrics)
+	c.SetAllDevices(cfg.AllDevices)
+	c.SetClampCounterResets(cfg.ClampCounterResets)
+	c.SetRateInterval(cfg.RateInterval)
+	return c
+}
+
+func newCapacityCollector(cfg *config.Config) *collector.CapacityCollector {
+	var c *collector.CapacityCollector
+	if cfg.CapacityHostNamespace {
+		c = collector.NewCapacityCollector(cfg.HostRootPath(), cfg.KubeletPath, cfg.HostKubeletPath, cfg.SubpathCapacity)
+	} else {
+		c = collector.NewCapacityCollector("", "", "", cfg.SubpathCapacity)
+	}
+	c.SetForecastWindow(cfg.ForecastWindow)
+	c.SetHighUsageSampling(cfg.HighUsageThreshold, cfg.HighUsageInterval)
+	c.SetSubPathMounts(cfg.SubPathMountUsage)
+	if cfg.KubeletURL != "" {
+		c.SetKubeletStats(kubelet.NewClient(cfg.KubeletURL))
+	}
+	return c
+}
+
+func newEmptyDirCollector(cfg *config.Config) (*collector.EmptyDirCollector, error) {
+	var c *collector.EmptyDirCollector
+	var err error
+	if cfg.CapacityHostNamespace {
+		c, err = collector.NewEmptyDirCollector(cfg.KubeletPath, cfg.HostRootPath(), cfg.HostKubeletPath, cfg.Namespaces)
+	} else {
+		c, err = collector.NewEmptyDirCollector(cfg.KubeletPath, "", "", cfg.Namespaces)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.SetLayout(cfg.Layout())
+	return c, nil
+}
+
+// runSelfCheck checks access to the host paths and API resources cfg needs
+// and logs a report, so misconfigured mounts and RBAC are obvious in the logs
+func runSelfCheck(cfg *config.Config) []selfcheck.Result {
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+
+	results := selfcheck.Run(ctx, cfg)
+	for _, r := range results {
+		if r.OK {
+			slog.Debug("self-check passed", "check", r.Check, "target", r.Target)
+		} else {
+			slog.Warn("self-check failed", "check", r.Check, "target", r.Target, "error", r.Error, "hint", r.Hint)
+		}
+	}
+	if failed := selfcheck.Failed(results); len(failed) > 0 {
+		slog.Warn("self-check found missing permissions, affected metrics will be empty", "failed", len(failed), "checks", len(results))
+	} else {
+		slog.Info("self-check passed", "checks", len(results))
+	}
+	return results
+}
+
+// newResolver returns a host mount namespace resolver when configured and
+// permitted, otherwise a resolver for the local mount table
+func newResolver(cfg *config.Config) *mounts.Resolver {
+	var r *mounts.Resolver
+	if cfg.HostMountNamespace {
+		var hr *mounts.Resolver
+		var err error
+		if cfg.MountNamespaceProcess != "" {
+			hr, err = mounts.NewProcessResolver(cfg.HostProcPath, cfg.MountNamespaceProcess, cfg.KubeletPath, cfg.HostKubeletPath)
+		} else {
+			hr, err = mounts.NewHostResolver(cfg.HostProcPath, cfg.KubeletPath, cfg.HostKubeletPath)
+		}
+		if err == nil {
+			slog.Info("resolving mounts in host mount namespace", "mounts", hr.MountsPath())
+			r = hr
+		} else {
+			slog.Warn("host mount namespace unavailable, falling back to local", "error", err)
+		}
+	}
+	if r == nil {
+		r = mounts.NewResolver(cfg.MountsPath())
+	}
+
+	if cfg.HostDevPath != "" {
+		r.SetDevPath(cfg.HostDevPath)
+	}
+	if cfg.UdevDataPath != "" {
+		r.SetUdevDataPath(cfg.UdevDataPath, cfg.HostSysPath)
+	}
+	if cfg.Rootless {
+		r.SetSysDeviceNames(cfg.HostSysPath)
+	}
+	return r
+}
+
+// buildDiscoverers creates the configured discoverers in priority order, and
+// without k8sapi among them, a k8sapi discoverer to fall back to when they're
+// denied access to the kubelet's directories, e.g. by SELinux
+func buildDiscoverers(cfg *config.Config, resolver *mounts.Resolver) (discoverers []discovery.Discoverer, fallback discovery.Discoverer) {
+	filter := namespaceFilter(cfg)
+
+	for _, method := range cfg.DiscoveryMethods {
+		switch method {
+		case config.DiscoveryCSI:
+			csi := discovery.NewCSIDiscoverer(cfg.KubeletPath, resolver)
+			csi.SetLayout(cfg.Layout())
+			csi.SetNamespaceFilter(filter)
+			discoverers = append(discoverers, csi)
+			slog.Info("enabled discoverer", "method", method)
+
+		case config.DiscoveryK8sAPI:
+			k8s, err := newK8sAPIDiscoverer(cfg, resolver, filter)
+			if err != nil {
+				slog.Warn("discoverer disabled", "method", method, "error", err)
+			} else {
+				discoverers = append(discoverers, k8s)
+				slog.Info("enabled discoverer", "method", method)
+			}
+
+		case config.DiscoveryHostPath:
+			k8s, err := newK8sAPIDiscoverer(cfg, resolver, filter)
+			if err != nil {
+				slog.Warn("discoverer disabled", "method", method, "error", err)
+			} else {
+				discoverers = append(discoverers, newHostPathDiscoverer(cfg, k8s))
+				slog.Info("enabled discoverer", "method", method)
+			}
+
+		default:
+			slog.Warn("unknown discovery method", "method", method)
+		}
+	}
+
+	if !slices.Contains(cfg.DiscoveryMethods, config.DiscoveryK8sAPI) {
+		if k8s, err := newK8sAPIDiscoverer(cfg, resolver, filter); err == nil {
+			fallback = k8s
+		} else {
+			slog.Debug("no k8sapi fallback for denied kubelet paths", "error", err)
+		}
+	}
+	return discoverers, fallback
+}
+
+// errDiscoveryCompareMethods is returned when discovery comparison is enabled
+// without both of the discoverers it compares
+var errDiscoveryCompareMethods = errors.New("needs both csi and k8sapi discovery methods")
+
+// newDiscoveryCompareCollector creates a collector comparing csi and k8sapi
+// discoverers of its own, configured like those of the merged discovery
+func newDiscoveryCompareCollector(cfg *config.Config, resolver *mounts.Resolver) (*collector.DiscoveryCompareCollector, error) {
+	if !slices.Contains(cfg.DiscoveryMethods, config.DiscoveryCSI) || !slices.Contains(cfg.DiscoveryMethods, config.DiscoveryK8sAPI) {
+		return nil, errDiscoveryCompareMethods
+	}
+	filter := namespaceFilter(cfg)
+	k8s, err := newK8sAPIDiscoverer(cfg, resolver, filter)
+	if err != nil {
+		return nil, err
+	}
+	csi := discovery.NewCSIDiscoverer(cfg.KubeletPath, resolver)
+	csi.SetLayout(cfg.Layout())
+	csi.SetNamespaceFilter(filter)
+	return collector.NewDiscoveryCompareCollector(csi, k8s), nil
+}
+
+// newK8sAPIDiscoverer creates a k8sapi discoverer configured from cfg
+func newK8sAPIDiscoverer(cfg *config.Config, resolver *mounts.Resolver, filter *discovery.NamespaceFilter) (*discovery.K8sAPIDiscoverer, error) {
+	k8s, err := discovery.NewK8sAPIDiscoverer(cfg.KubeletPath, resolver, cfg.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+	k8s.SetConcurrency(cfg.APIConcurrency)
+	k8s.SetMinimalRBAC(cfg.MinimalRBAC)
+	k8s.SetNamespaceFilter(filter)
+	k8s.SetLayout(cfg.Layout())
+	return k8s, nil
+}
+
+// newHostPathDiscoverer creates a hostpath discoverer listing pods through api
+func newHostPathDiscoverer(cfg *config.Config, api *discovery.K8sAPIDiscoverer) *discovery.HostPathDiscoverer {
+	d := discovery.NewHostPathDiscoverer(api)
+	d.SetPrefixes(cfg.HostPathPrefixes)
+	d.SetStaticPodPath(cfg.StaticPodPath)
+	return d
+}
+
+// namespaceFilter applies the configured namespace allow- and deny-lists. One
+// filter is shared by all discoverers, see discovery.NamespaceFilter.
+func namespaceFilter(cfg *config.Config) *discovery.NamespaceFilter {
+	return discovery.NewNamespaceFilter(cfg.Namespaces, cfg.ExcludeNamespaces)
+}
+
+// Capture writes a support bundle of the node as volmetd sees it with cfg,
+// for attaching to bug reports and replaying with cfg.FixtureDir. See
+// fixture.Capture for its content.
+func Capture(w io.Writer, cfg *config.Config) error {
+	resolver := newResolver(cfg)
+	devPath := cfg.HostDevPath
+	if devPath == "" {
+		devPath = "/dev"
+	}
+	return fixture.Capture(w, fixture.Source{
+		Node:            discovery.DetectNodeName(),
+		MountsPath:      resolver.MountsPath(),
+		MountinfoPath:   resolver.MountinfoPath(),
+		DiskstatsPath:   cfg.DiskstatsPath(),
+		KubeletPath:     cfg.KubeletPath,
+		HostKubeletPath: resolver.HostPath(cfg.KubeletPath),
+		DevPath:         devPath,
+	})
+}
+
+// buildFixtureDiscoverers creates the configured discoverers against a
+// fixture, with the K8s API served from its captured objects
+func buildFixtureDiscoverers(cfg *config.Config, fx *fixture.Fixture, resolver *mounts.Resolver) []discovery.Discoverer {
+	var discoverers []discovery.Discoverer
+	filter := namespaceFilter(cfg)
+	for _, method := range cfg.DiscoveryMethods {
+		switch method {
+		case config.DiscoveryCSI:
+			csi := discovery.NewCSIDiscoverer(cfg.KubeletPath, resolver)
+			csi.SetLayout(cfg.Layout())
+			csi.SetNamespaceFilter(filter)
+			discoverers = append(discoverers, csi)
+		case config.DiscoveryK8sAPI:
+			k8s := discovery.NewK8sAPIDiscovererForClient(fx.Client(), fx.Node, cfg.KubeletPath, resolver, cfg.Namespaces)
+			k8s.SetConcurrency(cfg.APIConcurrency)
+			k8s.SetMinimalRBAC(cfg.MinimalRBAC)
+			k8s.SetNamespaceFilter(filter)
+			k8s.SetLayout(cfg.Layout())
+			discoverers = append(discoverers, k8s)
+		case config.DiscoveryHostPath:
+			k8s := discovery.NewK8sAPIDiscovererForClient(fx.Client(), fx.Node, cfg.KubeletPath, resolver, cfg.Namespaces)
+			k8s.SetConcurrency(cfg.APIConcurrency)
+			k8s.SetNamespaceFilter(filter)
+			k8s.SetLayout(cfg.Layout())
+			discoverers = append(discoverers, newHostPathDiscoverer(cfg, k8s))
+		default:
+			slog.Warn("unknown discovery method", "method", method)
+		}
+	}
+	return discoverers
+}
+
+// ServeHTTP serves the exporter's metrics in the Prometheus exposition
+// format. With namespace query parameters, e.g. ?namespace=a&namespace=b,
+// only the series of PVCs in those namespaces are served. Beyond the
+// configured concurrent scrapes it serves 503 with Retry-After.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.limiter.wrap(instrumentSize(e.sizes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if namespaces := r.URL.Query()[namespaceLabel]; len(namespaces) > 0 {
+			e.serveNamespaces(w, r, namespaces)
+			return
+		}
+		e.handler.ServeHTTP(w, r)
+	}))).ServeHTTP(w, r)
+}
+
+// WriteMetrics gathers all metrics once and writes them to w in the text
+// exposition format. Metrics gathered before an error are still written.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	families, gatherErr := e.gatherer.Gather()
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	if gatherErr != nil {
+		return fmt.Errorf("gather: %w", gatherErr)
+	}
+	return nil
+}
+
+// Volumes runs discovery and returns the volumes currently on this node
+func (e *Exporter) Volumes(ctx context.Context) ([]*discovery.VolumeInfo, error) {
+	return e.discoverer.Discover(ctx)
+}
+
+// Discoverer returns the exporter's merged discoverer
+func (e *Exporter) Discoverer() *discovery.MultiDiscoverer {
+	return e.discoverer
+}
+
+// Resolver returns the exporter's mount resolver
+func (e *Exporter) Resolver() *mounts.Resolver {
+	return e.resolver
+}
+
+// LastScrape returns the volume collector's most recent scrape, nil before
+// the first
+func (e *Exporter) LastScrape() *collector.LastScrape {
+	return e.collector.LastScrape()
+}
+
+// LastCapacity returns the capacity the last scrape found for the volume
+// mounted at mountPath, or nil if it had none or capacity isn't collected
+func (e *Exporter) LastCapacity(mountPath string) *mounts.Capacity {
+	if e.capacity == nil {
+		return nil
+	}
+	return e.capacity.LastCapacity(mountPath)
+}
+
+// Run runs the exporter's background work, the warm-up discovery gating
+// Ready, tailing the kernel log, sampling volumes between scrapes and
+// diskstats for their rates, and watching the policy ConfigMap, until ctx is
+// cancelled
+func (e *Exporter) Run(ctx context.Context) {
+	go e.warmUp(ctx)
+	if e.kmsg != nil {
+		go e.kmsg.Run(ctx)
+	}
+	if e.sampler != nil {
+		go e.sampler.Run(ctx)
+	}
+	if e.diskstats != nil {
+		go e.diskstats.Run(ctx)
+	}
+	if e.policy == nil {
+		<-ctx.Done()
+		return
+	}
+	e.policy.Run(ctx)
+}
+
+// Policy returns the exporter's policy source, nil without a policy ConfigMap
+func (e *Exporter) Policy() *policy.Watcher {
+	return e.policy
+}
+
+// Collector returns the exporter's volume collector
+func (e *Exporter) Collector() *collector.VolumeCollector {
+	return e.collector
+}
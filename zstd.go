@@ -0,0 +1,12 @@
+//go:build zstd
+
+package volmetd
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	_ "github.com/prometheus/client_golang/prometheus/promhttp/zstd"
+)
+
+func init() {
+	compressions["zstd"] = promhttp.Zstd
+}